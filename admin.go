@@ -0,0 +1,40 @@
+package main
+
+import (
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+const roleAdmin = "admin"
+
+// authenticateAdmin validates the caller's JWT and checks that they hold the
+// admin role. It writes the error response itself and reports whether the
+// caller may proceed.
+func (cfg *apiConfig) authenticateAdmin(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, unauthorized("Unauthorized", err))
+		return uuid.Nil, false
+	}
+	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
+		return uuid.Nil, false
+	}
+
+	isAdmin, err := cfg.store.Roles().UserHasRole(r.Context(), database.UserHasRoleParams{
+		UserID: userID,
+		Role:   roleAdmin,
+	})
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't check roles", err))
+		return uuid.Nil, false
+	}
+	if !isAdmin {
+		respondWithError(w, r, forbidden("Admin role required", nil))
+		return uuid.Nil, false
+	}
+	return userID, true
+}
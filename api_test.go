@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"example.com/chirpy/internal/apitest"
+	"example.com/chirpy/internal/config"
+	"example.com/chirpy/internal/store/storetest"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAPISQLite(t *testing.T) {
+	apitest.Run(t, func(t *testing.T) http.Handler {
+		return newTestHandler(t, "sqlite:"+filepath.Join(t.TempDir(), "chirpy.db"))
+	})
+}
+
+func TestAPIPostgres(t *testing.T) {
+	url := os.Getenv(storetest.PostgresURLEnv)
+	if url == "" {
+		t.Skip(storetest.PostgresURLEnv + " is not set")
+	}
+	apitest.Run(t, func(t *testing.T) http.Handler {
+		return newTestHandler(t, url)
+	})
+}
+
+// newTestHandler returns the API handler on a migrated, empty database at
+// dbURL, with responses checked against the OpenAPI document.
+func newTestHandler(t *testing.T, dbURL string) http.Handler {
+	t.Helper()
+	_, handler := newTestAPI(t, dbURL)
+	return handler
+}
+
+// newTestAPI is newTestHandler that also returns the API's state.
+func newTestAPI(t *testing.T, dbURL string) (*apiConfig, http.Handler) {
+	t.Helper()
+	ctx := context.Background()
+
+	cfg := config.Default()
+	cfg.DBURL = dbURL
+	cfg.JWTSecret = "test-secret"
+	cfg.Platform = "dev"
+	cfg.ValidateResponses = true
+
+	b, err := openDB(cfg.DBURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.db.Close() })
+	migrator, err := migrateOnStart(ctx, b, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.store.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	apiCfg, handler, err := newAPIConfig(cfg, b, migrator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { apiCfg.workers.Stop(context.Background()) })
+	return apiCfg, handler
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"database/sql"
+	"example.com/chirpy/internal/config"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/migrate"
+	"example.com/chirpy/internal/store"
+
+	sqlfs "example.com/chirpy/sql"
+)
+
+// backend is the storage chosen from the DB URL at startup.
+type backend struct {
+	driver string
+	db     *sql.DB
+	store  store.Store
+	// queries is only set for Postgres, whose LISTEN/NOTIFY event stream
+	// and rate limit store have no SQLite equivalent.
+	queries *database.Queries
+}
+
+// openBackend opens the storage backend named by the DB URL, as described
+// at store.Open, and sizes its connection pool.
+func openBackend(cfg config.Config) (*backend, error) {
+	b, err := openDB(cfg.DBURL)
+	if err != nil {
+		return nil, err
+	}
+	b.db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	b.db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	b.db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	b.db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+	return b, nil
+}
+
+// openDB opens the store named by dbURL without configuring the pool.
+func openDB(dbURL string) (*backend, error) {
+	s, db, err := store.Open(dbURL)
+	if err != nil {
+		return nil, err
+	}
+	b := &backend{driver: "sqlite", db: db, store: s}
+	if _, ok := s.(*store.Postgres); ok {
+		b.driver = "postgres"
+		b.queries = database.New(db)
+	}
+	return b, nil
+}
+
+// migrator returns a Migrator for the backend's embedded schema.
+func (b *backend) migrator() (*migrate.Migrator, error) {
+	if b.driver == "sqlite" {
+		return migrate.New(b.db, migrate.SQLite{}, sqlfs.SQLite, "sqlite/schema")
+	}
+	return migrate.New(b.db, migrate.Postgres{}, sqlfs.Postgres, "schema")
+}
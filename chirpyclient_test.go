@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/pkg/chirpyclient"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestServer starts a real server on an empty database and returns its
+// URL.
+func newTestServer(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(newTestHandler(t, "sqlite:"+filepath.Join(t.TempDir(), "chirpy.db")))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func newTestClient(t *testing.T) *chirpyclient.Client {
+	t.Helper()
+	return chirpyclient.New(newTestServer(t))
+}
+
+func loggedIn(t *testing.T, c *chirpyclient.Client, email string) chirpyclient.User {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := c.CreateUser(ctx, email, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := c.Login(ctx, email, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestClientChirps(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	walt := loggedIn(t, c, "walt@example.com")
+
+	var created []chirpyclient.Chirp
+	for i := range 5 {
+		chirp, err := c.CreateChirp(ctx, fmt.Sprintf("chirp %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, chirp)
+	}
+	if _, err := c.CreateChirp(ctx, "chirp 0"); !errors.Is(err, chirpyclient.ErrDuplicateChirp) {
+		t.Fatalf("duplicate chirp: got %v, want ErrDuplicateChirp", err)
+	}
+
+	collect := func(opts chirpyclient.ListChirpsOptions) []chirpyclient.Chirp {
+		t.Helper()
+		var got []chirpyclient.Chirp
+		for chirp, err := range c.Chirps(ctx, opts) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, chirp)
+		}
+		return got
+	}
+	wantOrder := func(got []chirpyclient.Chirp, order ...int) {
+		t.Helper()
+		if len(got) != len(order) {
+			t.Fatalf("got %d chirps, want %d", len(got), len(order))
+		}
+		for i, j := range order {
+			if got[i].ID != created[j].ID {
+				t.Fatalf("chirp %d is %q, want %q", i, got[i].Body, created[j].Body)
+			}
+		}
+	}
+	wantOrder(collect(chirpyclient.ListChirpsOptions{PageSize: 2}), 0, 1, 2, 3, 4)
+	wantOrder(collect(chirpyclient.ListChirpsOptions{PageSize: 2, Newest: true}), 4, 3, 2, 1, 0)
+	wantOrder(collect(chirpyclient.ListChirpsOptions{AuthorID: walt.ID}), 0, 1, 2, 3, 4)
+
+	// The caller may stop partway through a page
+	for chirp, err := range c.Chirps(ctx, chirpyclient.ListChirpsOptions{PageSize: 3}) {
+		if err != nil || chirp.ID != created[0].ID {
+			t.Fatalf("first chirp = %+v, %v", chirp, err)
+		}
+		break
+	}
+
+	all, err := c.ListChirps(ctx, chirpyclient.ListChirpsOptions{Newest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOrder(all, 4, 3, 2, 1, 0)
+
+	if err := c.DeleteChirp(ctx, created[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetChirp(ctx, created[0].ID); !errors.Is(err, chirpyclient.ErrNotFound) {
+		t.Fatalf("deleted chirp: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestClientRefresh(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	walt := loggedIn(t, c, "walt@example.com")
+
+	var saved []chirpyclient.Tokens
+	c.OnTokens = func(t chirpyclient.Tokens) { saved = append(saved, t) }
+
+	// An expired access token is refreshed and the call repeated
+	expired, err := auth.MakeJWT(walt.ID, "test-secret", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetTokens(chirpyclient.Tokens{AccessToken: expired, RefreshToken: walt.RefreshToken})
+	if _, err := c.MyEntitlements(ctx); err != nil {
+		t.Fatalf("with an expired access token: %v", err)
+	}
+	if len(saved) != 1 || saved[0].AccessToken == expired || saved[0].RefreshToken != walt.RefreshToken {
+		t.Fatalf("tokens after refresh: %+v", saved)
+	}
+
+	// A missing access token isn't something refreshing fixes
+	saved = nil
+	c.SetTokens(chirpyclient.Tokens{RefreshToken: walt.RefreshToken})
+	if _, err := c.MyEntitlements(ctx); !errors.Is(err, chirpyclient.ErrUnauthorized) {
+		t.Fatalf("without an access token: got %v, want ErrUnauthorized", err)
+	}
+	if len(saved) != 0 {
+		t.Fatalf("refreshed without an access token: %+v", saved)
+	}
+
+	// After logging out the refresh token no longer works
+	c.SetTokens(chirpyclient.Tokens{AccessToken: expired, RefreshToken: walt.RefreshToken})
+	if err := c.Logout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c.SetTokens(chirpyclient.Tokens{AccessToken: expired, RefreshToken: walt.RefreshToken})
+	if _, err := c.MyEntitlements(ctx); !errors.Is(err, chirpyclient.ErrInvalidToken) {
+		t.Fatalf("with a revoked refresh token: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestClientUsers(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	loggedIn(t, c, "walt@example.com")
+
+	if _, err := c.ReplaceUser(ctx, "walt@example.com", "hunter3", "wrong"); !errors.Is(err, chirpyclient.ErrInvalidCredentials) {
+		t.Fatalf("wrong current password: got %v, want ErrInvalidCredentials", err)
+	}
+	u, err := c.ReplaceUser(ctx, "heisenberg@example.com", "hunter3", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Email != "heisenberg@example.com" || u.PendingEmail != "" {
+		t.Fatalf("ReplaceUser = %+v", u)
+	}
+	if _, err := c.ConfirmEmailChange(ctx, "unknown"); !errors.Is(err, chirpyclient.ErrInvalidToken) {
+		t.Fatalf("confirming with an unknown token: got %v, want ErrInvalidToken", err)
+	}
+
+	password := "hunter4"
+	if _, err := c.UpdateUser(ctx, chirpyclient.UpdateUserParams{Password: &password}); !errors.Is(err, chirpyclient.ErrValidationFailed) {
+		t.Fatalf("without the current password: got %v, want ErrValidationFailed", err)
+	}
+	if _, err := c.UpdateUser(ctx, chirpyclient.UpdateUserParams{Password: &password, CurrentPassword: "hunter3"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Login(ctx, "heisenberg@example.com", password); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Login(ctx, "heisenberg@example.com", "wrong"); !errors.Is(err, chirpyclient.ErrInvalidCredentials) {
+		t.Fatalf("bad login: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestClientFollowsAndWebhooks(t *testing.T) {
+	ctx := context.Background()
+	url := newTestServer(t)
+	walt := chirpyclient.New(url)
+	loggedIn(t, walt, "walt@example.com")
+	jesseClient := chirpyclient.New(url)
+	jesse := loggedIn(t, jesseClient, "jesse@example.com")
+
+	if err := walt.Follow(ctx, jesse.ID); err != nil {
+		t.Fatal(err)
+	}
+	var posted []chirpyclient.Chirp
+	for i := range 3 {
+		chirp, err := jesseClient.CreateChirp(ctx, fmt.Sprintf("Yeah science %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		posted = append(posted, chirp)
+	}
+	var timeline []chirpyclient.Chirp
+	for chirp, err := range walt.Timeline(ctx, 2) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeline = append(timeline, chirp)
+	}
+	if len(timeline) != 3 || timeline[0].ID != posted[2].ID || timeline[2].ID != posted[0].ID {
+		t.Fatalf("Timeline = %+v, want jesse's chirps newest first", timeline)
+	}
+	if err := walt.Unfollow(ctx, jesse.ID); err != nil {
+		t.Fatal(err)
+	}
+	for chirp, err := range walt.Timeline(ctx, 0) {
+		t.Fatalf("Timeline after unfollowing = %+v, %v", chirp, err)
+	}
+
+	sub, err := walt.CreateWebhook(ctx, "https://93.184.215.14/hooks", chirpyclient.EventChirpCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Secret == "" {
+		t.Fatal("new subscription has no secret")
+	}
+	if _, err := walt.CreateWebhook(ctx, "https://10.0.0.1/hooks", chirpyclient.EventChirpCreated); !errors.Is(err, chirpyclient.ErrValidationFailed) {
+		t.Fatalf("private webhook target: got %v, want ErrValidationFailed", err)
+	}
+	subs, err := walt.ListWebhooks(ctx)
+	if err != nil || len(subs) != 1 || subs[0].ID != sub.ID || subs[0].Secret != "" {
+		t.Fatalf("ListWebhooks = %+v, %v", subs, err)
+	}
+	for _, err := range walt.WebhookDeliveries(ctx, sub.ID) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := walt.DeleteWebhook(ctx, sub.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	ready, err := walt.Ready(ctx)
+	if err != nil || ready.Status != "ok" {
+		t.Fatalf("Ready = %+v, %v", ready, err)
+	}
+}
+
+func TestClientStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	url := newTestServer(t)
+	walt := chirpyclient.New(url)
+	waltUser := loggedIn(t, walt, "walt@example.com")
+	jesse := chirpyclient.New(url)
+	jesseUser := loggedIn(t, jesse, "jesse@example.com")
+	if err := walt.Follow(ctx, jesseUser.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// An expired access token is refreshed before following the stream
+	expired, err := auth.MakeJWT(waltUser.ID, "test-secret", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walt.SetTokens(chirpyclient.Tokens{AccessToken: expired, RefreshToken: waltUser.RefreshToken})
+
+	// There's no telling when the stream is listening, so keep chirping
+	// until it hears one
+	posted := make(chan struct{})
+	go func() {
+		defer close(posted)
+		tick := time.NewTicker(50 * time.Millisecond)
+		defer tick.Stop()
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+			if _, err := jesse.CreateChirp(ctx, fmt.Sprintf("Say my name %d", i)); err != nil && ctx.Err() == nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	defer func() { cancel(); <-posted }()
+
+	for event, err := range walt.Stream(ctx, chirpyclient.StreamOptions{Followed: true}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != chirpyclient.StreamChirpCreated || event.ID == "" || event.Chirp.UserID != jesseUser.ID {
+			t.Fatalf("event = %+v", event)
+		}
+		if walt.Tokens().AccessToken == expired {
+			t.Fatal("access token wasn't refreshed")
+		}
+		return
+	}
+	t.Fatal("stream ended without an event")
+}
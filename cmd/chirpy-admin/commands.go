@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store"
+	"fmt"
+	"github.com/google/uuid"
+	"net/mail"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// roles are the roles the server checks for. Granting anything else would
+// do nothing, so it is most likely a typo.
+var roles = []string{"admin"}
+
+// tokenPrefixLen is how much of a refresh token is shown and the least
+// that --token accepts.
+const tokenPrefixLen = 8
+
+func cmdCreateUser(ctx context.Context, a *admin, args []string) error {
+	flags := subcommandFlags("create-user")
+	email := flags.String("email", "", "the new user's email")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin")
+	role := flags.String("role", "", "grant this role too")
+	if _, err := parse(flags, args, 0); err != nil {
+		return err
+	}
+	if addr, err := mail.ParseAddress(*email); err != nil || addr.Address != *email {
+		return usageError("chirpy-admin create-user: --email must be an email address")
+	}
+	if *role != "" {
+		if err := checkRole(*role); err != nil {
+			return err
+		}
+	}
+	password, generated, err := a.password(*passwordStdin)
+	if err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	var user database.User
+	err = a.store.WithTx(ctx, func(s store.Store) error {
+		var err error
+		user, err = s.Users().CreateUser(ctx, database.CreateUserParams{Email: *email, HashedPassword: hash})
+		if errors.Is(err, store.ErrConflict) {
+			return fmt.Errorf("%s is already taken", *email)
+		}
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", *email, err)
+		}
+		if *role != "" {
+			return s.Roles().GrantRole(ctx, database.GrantRoleParams{UserID: user.ID, Role: *role})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "created %s (%s)\n", user.Email, user.ID)
+	if generated {
+		fmt.Fprintf(a.out, "password: %s\n", password)
+	}
+	return nil
+}
+
+func cmdResetPassword(ctx context.Context, a *admin, args []string) error {
+	flags := subcommandFlags("reset-password")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin")
+	keepSessions := flags.Bool("keep-sessions", false, "don't revoke the user's refresh tokens")
+	args, err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	user, err := a.user(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	password, generated, err := a.password(*passwordStdin)
+	if err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.store.Users().UpdateUserPassword(ctx, database.UpdateUserPasswordParams{ID: user.ID, HashedPassword: hash}); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "reset the password for %s\n", user.Email)
+	if generated {
+		fmt.Fprintf(a.out, "password: %s\n", password)
+	}
+	if *keepSessions {
+		return nil
+	}
+	n, err := a.store.RefreshTokens().RevokeRefreshTokensForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "revoked %d sessions\n", n)
+	return nil
+}
+
+func cmdGrantRed(ctx context.Context, a *admin, args []string) error {
+	flags := subcommandFlags("grant-red")
+	period := flags.Duration("for", 30*24*time.Hour, "how long the subscription lasts")
+	args, err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *period <= 0 {
+		return usageError("chirpy-admin grant-red: --for must be positive")
+	}
+	user, err := a.user(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	sub, err := a.store.Subscriptions().UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:             user.ID,
+		Status:             store.SubscriptionActive,
+		CurrentPeriodStart: now,
+		CurrentPeriodEnd:   now.Add(*period),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%s is Chirpy Red until %s\n", user.Email, sub.CurrentPeriodEnd.Format(time.RFC3339))
+	return nil
+}
+
+func cmdRevokeRed(ctx context.Context, a *admin, args []string) error {
+	args, err := parse(subcommandFlags("revoke-red"), args, 1)
+	if err != nil {
+		return err
+	}
+	user, err := a.user(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = a.store.Subscriptions().EndSubscription(ctx, database.EndSubscriptionParams{UserID: user.ID, Status: store.SubscriptionCanceled})
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("%s has no subscription", user.Email)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "canceled Chirpy Red for %s\n", user.Email)
+	return nil
+}
+
+func cmdGrantRole(ctx context.Context, a *admin, args []string) error {
+	args, err := parse(subcommandFlags("grant-role"), args, 2)
+	if err != nil {
+		return err
+	}
+	if err := checkRole(args[1]); err != nil {
+		return err
+	}
+	user, err := a.user(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := a.store.Roles().GrantRole(ctx, database.GrantRoleParams{UserID: user.ID, Role: args[1]}); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "granted %s to %s\n", args[1], user.Email)
+	return nil
+}
+
+func cmdRevokeRole(ctx context.Context, a *admin, args []string) error {
+	args, err := parse(subcommandFlags("revoke-role"), args, 2)
+	if err != nil {
+		return err
+	}
+	user, err := a.user(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := a.store.Roles().RevokeRole(ctx, database.RevokeRoleParams{UserID: user.ID, Role: args[1]}); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "revoked %s from %s\n", args[1], user.Email)
+	return nil
+}
+
+func cmdSessions(ctx context.Context, a *admin, args []string) error {
+	args, err := parse(subcommandFlags("sessions"), args, 1)
+	if err != nil {
+		return err
+	}
+	user, err := a.user(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	tokens, err := a.store.RefreshTokens().ListRefreshTokensForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// Only a prefix is shown: the full token would be a working credential
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TOKEN\tCREATED\tEXPIRES\tSTATUS")
+	now := time.Now()
+	for _, t := range tokens {
+		status := "active"
+		switch {
+		case t.RevokedAt.Valid:
+			status = "revoked " + t.RevokedAt.Time.Format(time.RFC3339)
+		case !t.ExpiresAt.After(now):
+			status = "expired"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Token[:min(tokenPrefixLen, len(t.Token))],
+			t.CreatedAt.Format(time.RFC3339), t.ExpiresAt.Format(time.RFC3339), status)
+	}
+	return w.Flush()
+}
+
+func cmdRevokeSessions(ctx context.Context, a *admin, args []string) error {
+	flags := subcommandFlags("revoke-sessions")
+	prefix := flags.String("token", "", "revoke only the session whose token starts with this")
+	args, err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *prefix != "" && len(*prefix) < tokenPrefixLen {
+		return usageError(fmt.Sprintf("chirpy-admin revoke-sessions: --token needs at least %d characters", tokenPrefixLen))
+	}
+	user, err := a.user(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if *prefix == "" {
+		n, err := a.store.RefreshTokens().RevokeRefreshTokensForUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "revoked %d sessions for %s\n", n, user.Email)
+		return nil
+	}
+
+	tokens, err := a.store.RefreshTokens().ListRefreshTokensForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	var matches []database.RefreshToken
+	for _, t := range tokens {
+		if strings.HasPrefix(t.Token, *prefix) {
+			matches = append(matches, t)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("%s has no session starting with %s", user.Email, *prefix)
+	case 1:
+	default:
+		return fmt.Errorf("%d sessions start with %s; give more of the token", len(matches), *prefix)
+	}
+	if matches[0].RevokedAt.Valid {
+		fmt.Fprintf(a.out, "session %s was already revoked\n", *prefix)
+		return nil
+	}
+	if err := a.store.RefreshTokens().RevokeRefreshToken(ctx, matches[0].Token); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "revoked session %s\n", *prefix)
+	return nil
+}
+
+func cmdDeleteUser(ctx context.Context, a *admin, args []string) error {
+	flags := subcommandFlags("delete-user")
+	yes := flags.Bool("yes", false, "confirm the deletion")
+	args, err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	user, err := a.user(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		return usageError(fmt.Sprintf("chirpy-admin delete-user: this deletes %s (%s) with their chirps and sessions; pass --yes to confirm", user.Email, user.ID))
+	}
+
+	err = a.store.Users().DeleteUser(ctx, user.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("%s was already deleted", user.Email)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "deleted %s (%s)\n", user.Email, user.ID)
+	return nil
+}
+
+func cmdPurgeTokens(ctx context.Context, a *admin, args []string) error {
+	if _, err := parse(subcommandFlags("purge-tokens"), args, 0); err != nil {
+		return err
+	}
+	n, err := a.store.RefreshTokens().DeleteExpiredRefreshTokens(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "deleted %d expired or revoked refresh tokens\n", n)
+	return nil
+}
+
+func cmdReplayWebhook(ctx context.Context, a *admin, args []string) error {
+	args, err := parse(subcommandFlags("replay-webhook"), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return usageError("chirpy-admin replay-webhook: DELIVERY_ID must be a UUID")
+	}
+
+	delivery, err := a.store.Webhooks().ReplayWebhookDelivery(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no webhook delivery %s", id)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "queued delivery %s of event %s again\n", delivery.ID, delivery.EventID)
+	return nil
+}
+
+// user looks a user up by ID or email.
+func (a *admin) user(ctx context.Context, ref string) (database.User, error) {
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = a.store.Users().GetUserByID(ctx, id)
+	} else {
+		user, err = a.store.Users().GetUserByEmail(ctx, ref)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return user, fmt.Errorf("no user %s", ref)
+	}
+	return user, err
+}
+
+// password returns the password to set: the first line of stdin with
+// --password-stdin, then $CHIRPY_PASSWORD, and otherwise a random one,
+// in which case generated is true and the caller must print it.
+func (a *admin) password(fromStdin bool) (password string, generated bool, err error) {
+	switch {
+	case fromStdin:
+		line, err := bufio.NewReader(a.stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", false, fmt.Errorf("reading password from stdin: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	case os.Getenv("CHIRPY_PASSWORD") != "":
+		password = os.Getenv("CHIRPY_PASSWORD")
+	default:
+		buf := make([]byte, 18)
+		if _, err := rand.Read(buf); err != nil {
+			return "", false, err
+		}
+		return base64.RawURLEncoding.EncodeToString(buf), true, nil
+	}
+	// bcrypt ignores everything after 72 bytes
+	if password == "" || len(password) > 72 {
+		return "", false, errors.New("the password must be 1 to 72 bytes")
+	}
+	return password, false, nil
+}
+
+func checkRole(role string) error {
+	for _, r := range roles {
+		if r == role {
+			return nil
+		}
+	}
+	return usageError(fmt.Sprintf("chirpy-admin: unknown role %q; roles are %s", role, strings.Join(roles, ", ")))
+}
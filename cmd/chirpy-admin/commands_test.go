@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/migrate"
+	"example.com/chirpy/internal/store"
+	"github.com/google/uuid"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sqlfs "example.com/chirpy/sql"
+)
+
+// newTestDB returns the URL of a new, migrated SQLite database and a store
+// on it for setting up and checking the commands' work.
+func newTestDB(t *testing.T) (string, store.Store) {
+	t.Helper()
+	dbURL := "sqlite:" + filepath.Join(t.TempDir(), "chirpy.db")
+	s, db, err := store.Open(dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := migrate.New(db, migrate.SQLite{}, sqlfs.SQLite, "sqlite/schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return dbURL, s
+}
+
+// runAdmin runs chirpy-admin against dbURL and returns its output.
+func runAdmin(t *testing.T, dbURL, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var out strings.Builder
+	err := run(context.Background(), append([]string{"--db-url", dbURL}, args...), strings.NewReader(stdin), &out)
+	return out.String(), err
+}
+
+// mustRun is runAdmin for commands that should succeed.
+func mustRun(t *testing.T, dbURL, stdin string, args ...string) string {
+	t.Helper()
+	out, err := runAdmin(t, dbURL, stdin, args...)
+	if err != nil {
+		t.Fatalf("chirpy-admin %s: %v", strings.Join(args, " "), err)
+	}
+	return out
+}
+
+// wantError checks that err is a usage error or not, and mentions want.
+func wantError(t *testing.T, err error, usage bool, want string) {
+	t.Helper()
+	var u usageError
+	if err == nil || errors.As(err, &u) != usage || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want a usage error %v mentioning %q", err, usage, want)
+	}
+}
+
+func createUser(t *testing.T, s store.Store, email string) database.User {
+	t.Helper()
+	u, err := s.Users().CreateUser(context.Background(), database.CreateUserParams{Email: email, HashedPassword: "unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func createToken(t *testing.T, s store.Store, token string, userID uuid.UUID, expiresIn time.Duration) {
+	t.Helper()
+	err := s.RefreshTokens().CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(expiresIn),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	t.Setenv("DB_URL", "")
+
+	var out strings.Builder
+	if err := run(context.Background(), []string{"help"}, nil, &out); err != nil || !strings.Contains(out.String(), "purge-tokens") {
+		t.Errorf("help = %q, %v", out.String(), err)
+	}
+	err := run(context.Background(), []string{"frobnicate"}, nil, &out)
+	wantError(t, err, true, `unknown command "frobnicate"`)
+	err = run(context.Background(), []string{"purge-tokens"}, nil, &out)
+	wantError(t, err, true, "set DB_URL")
+	err = run(context.Background(), []string{"--db-url", "mysql://localhost/chirpy", "purge-tokens"}, nil, &out)
+	wantError(t, err, false, `unsupported DB_URL scheme "mysql"`)
+}
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+	dbURL, s := newTestDB(t)
+
+	out := mustRun(t, dbURL, "correct horse\n", "create-user", "--email", "ops@example.com", "--role", "admin", "--password-stdin")
+	if !strings.HasPrefix(out, "created ops@example.com (") || strings.Contains(out, "password:") {
+		t.Errorf("got output %q", out)
+	}
+	user, err := s.Users().GetUserByEmail(ctx, "ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.CheckPasswordHash("correct horse", user.HashedPassword); err != nil {
+		t.Errorf("password not set: %v", err)
+	}
+	if admin, _ := s.Roles().UserHasRole(ctx, database.UserHasRoleParams{UserID: user.ID, Role: "admin"}); !admin {
+		t.Error("admin role not granted")
+	}
+
+	t.Setenv("CHIRPY_PASSWORD", "")
+	out = mustRun(t, dbURL, "", "create-user", "--email", "dev@example.com")
+	if !strings.Contains(out, "password: ") {
+		t.Errorf("generated password not printed: %q", out)
+	}
+
+	_, err = runAdmin(t, dbURL, "", "create-user", "--email", "ops@example.com")
+	wantError(t, err, false, "ops@example.com is already taken")
+	_, err = runAdmin(t, dbURL, "", "create-user", "--email", "root@example.com", "--role", "root")
+	wantError(t, err, true, `unknown role "root"`)
+	_, err = runAdmin(t, dbURL, "", "create-user", "--email", "not an email")
+	wantError(t, err, true, "--email must be an email address")
+	if _, err := s.Users().GetUserByEmail(ctx, "root@example.com"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("user with an unknown role was created: %v", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	dbURL, s := newTestDB(t)
+	user := createUser(t, s, "walt@example.com")
+	createToken(t, s, "aaaaaaaa-1", user.ID, time.Hour)
+	createToken(t, s, "aaaaaaaa-2", user.ID, time.Hour)
+
+	out := mustRun(t, dbURL, "kept\n", "reset-password", "walt@example.com", "--password-stdin", "--keep-sessions")
+	if out != "reset the password for walt@example.com\n" {
+		t.Errorf("got output %q", out)
+	}
+	out = mustRun(t, dbURL, "new password\n", "reset-password", user.ID.String(), "--password-stdin")
+	if !strings.Contains(out, "revoked 2 sessions") {
+		t.Errorf("got output %q", out)
+	}
+	user, _ = s.Users().GetUserByID(ctx, user.ID)
+	if err := auth.CheckPasswordHash("new password", user.HashedPassword); err != nil {
+		t.Errorf("password not reset: %v", err)
+	}
+
+	_, err := runAdmin(t, dbURL, "x\n", "reset-password", "nobody@example.com", "--password-stdin")
+	wantError(t, err, false, "no user nobody@example.com")
+}
+
+func TestRed(t *testing.T) {
+	ctx := context.Background()
+	dbURL, s := newTestDB(t)
+	user := createUser(t, s, "walt@example.com")
+	isRed := func() bool {
+		t.Helper()
+		red, err := s.Subscriptions().IsUserChirpyRed(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return red
+	}
+
+	_, err := runAdmin(t, dbURL, "", "revoke-red", "walt@example.com")
+	wantError(t, err, false, "walt@example.com has no subscription")
+
+	out := mustRun(t, dbURL, "", "grant-red", "walt@example.com", "--for", "24h")
+	if !strings.HasPrefix(out, "walt@example.com is Chirpy Red until ") || !isRed() {
+		t.Errorf("got output %q", out)
+	}
+	mustRun(t, dbURL, "", "revoke-red", "walt@example.com")
+	if isRed() {
+		t.Error("still Chirpy Red after revoke-red")
+	}
+
+	_, err = runAdmin(t, dbURL, "", "grant-red", "walt@example.com", "--for", "-1h")
+	wantError(t, err, true, "--for must be positive")
+}
+
+func TestRoles(t *testing.T) {
+	ctx := context.Background()
+	dbURL, s := newTestDB(t)
+	user := createUser(t, s, "walt@example.com")
+	isAdmin := func() bool {
+		t.Helper()
+		admin, err := s.Roles().UserHasRole(ctx, database.UserHasRoleParams{UserID: user.ID, Role: "admin"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return admin
+	}
+
+	mustRun(t, dbURL, "", "grant-role", "walt@example.com", "admin")
+	if !isAdmin() {
+		t.Error("grant-role didn't grant admin")
+	}
+	mustRun(t, dbURL, "", "revoke-role", "walt@example.com", "admin")
+	if isAdmin() {
+		t.Error("revoke-role didn't revoke admin")
+	}
+
+	_, err := runAdmin(t, dbURL, "", "grant-role", "walt@example.com", "root")
+	wantError(t, err, true, `unknown role "root"`)
+	_, err = runAdmin(t, dbURL, "", "grant-role", "walt@example.com")
+	wantError(t, err, true, "usage: chirpy-admin grant-role USER ROLE")
+}
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	dbURL, s := newTestDB(t)
+	user := createUser(t, s, "walt@example.com")
+	createToken(t, s, "aaaaaaaa-live-1", user.ID, time.Hour)
+	createToken(t, s, "aaaaaaaa-live-2", user.ID, time.Hour)
+	createToken(t, s, "bbbbbbbb-expired", user.ID, -time.Hour)
+	createToken(t, s, "cccccccc-live", user.ID, time.Hour)
+
+	out := mustRun(t, dbURL, "", "sessions", "walt@example.com")
+	for _, want := range []string{"aaaaaaaa ", "bbbbbbbb ", "expired", "active"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("sessions output doesn't mention %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "-live") {
+		t.Errorf("sessions shows whole tokens:\n%s", out)
+	}
+
+	_, err := runAdmin(t, dbURL, "", "revoke-sessions", "walt@example.com", "--token", "aaaaaaaa")
+	wantError(t, err, false, "2 sessions start with aaaaaaaa")
+	_, err = runAdmin(t, dbURL, "", "revoke-sessions", "walt@example.com", "--token", "aaaa")
+	wantError(t, err, true, "--token needs at least 8 characters")
+
+	out = mustRun(t, dbURL, "", "revoke-sessions", "walt@example.com", "--token", "cccccccc")
+	if out != "revoked session cccccccc\n" {
+		t.Errorf("got output %q", out)
+	}
+	if tok, _ := s.RefreshTokens().GetRefreshToken(ctx, "cccccccc-live"); !tok.RevokedAt.Valid {
+		t.Error("session not revoked")
+	}
+	out = mustRun(t, dbURL, "", "revoke-sessions", "walt@example.com", "--token", "cccccccc")
+	if out != "session cccccccc was already revoked\n" {
+		t.Errorf("got output %q", out)
+	}
+
+	// The expired token is revoked too, since it has no revoked_at yet
+	out = mustRun(t, dbURL, "", "revoke-sessions", "walt@example.com")
+	if out != "revoked 3 sessions for walt@example.com\n" {
+		t.Errorf("got output %q", out)
+	}
+}
+
+func TestPurgeTokens(t *testing.T) {
+	ctx := context.Background()
+	dbURL, s := newTestDB(t)
+	user := createUser(t, s, "walt@example.com")
+	createToken(t, s, "live", user.ID, time.Hour)
+	createToken(t, s, "expired", user.ID, -time.Hour)
+	createToken(t, s, "revoked", user.ID, time.Hour)
+	if err := s.RefreshTokens().RevokeRefreshToken(ctx, "revoked"); err != nil {
+		t.Fatal(err)
+	}
+
+	out := mustRun(t, dbURL, "", "purge-tokens")
+	if out != "deleted 2 expired or revoked refresh tokens\n" {
+		t.Errorf("got output %q", out)
+	}
+	tokens, err := s.RefreshTokens().ListRefreshTokensForUser(ctx, user.ID)
+	if err != nil || len(tokens) != 1 || tokens[0].Token != "live" {
+		t.Errorf("left %+v, %v", tokens, err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	dbURL, s := newTestDB(t)
+	user := createUser(t, s, "walt@example.com")
+
+	_, err := runAdmin(t, dbURL, "", "delete-user", "walt@example.com")
+	wantError(t, err, true, "pass --yes to confirm")
+	out := mustRun(t, dbURL, "", "delete-user", "walt@example.com", "--yes")
+	if out != "deleted walt@example.com ("+user.ID.String()+")\n" {
+		t.Errorf("got output %q", out)
+	}
+	if _, err := s.Users().GetUserByID(ctx, user.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("user not deleted: %v", err)
+	}
+	_, err = runAdmin(t, dbURL, "", "delete-user", user.ID.String(), "--yes")
+	wantError(t, err, false, "no user "+user.ID.String())
+}
+
+func TestReplayWebhook(t *testing.T) {
+	ctx := context.Background()
+	dbURL, s := newTestDB(t)
+	user := createUser(t, s, "walt@example.com")
+	sub, err := s.Webhooks().CreateWebhookSubscription(ctx, database.CreateWebhookSubscriptionParams{
+		UserID: user.ID,
+		Url:    "https://example.com/hook",
+		Secret: "secret",
+		Events: []string{"chirp.created"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := s.Outbox().InsertOutboxEvent(ctx, database.InsertOutboxEventParams{
+		EventType: "chirp.created",
+		UserID:    user.ID,
+		Payload:   []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Webhooks().CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{SubscriptionID: sub.ID, EventID: event.ID}); err != nil {
+		t.Fatal(err)
+	}
+	due, err := s.Webhooks().ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{LeaseUntil: time.Now().Add(time.Minute), BatchSize: 1})
+	if err != nil || len(due) != 1 {
+		t.Fatalf("ClaimDueWebhookDeliveries = %+v, %v", due, err)
+	}
+	err = s.Webhooks().MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		ID:             due[0].ID,
+		Status:         "dead",
+		NextAttemptAt:  time.Now(),
+		LastStatusCode: sql.NullInt32{Int32: 500, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := mustRun(t, dbURL, "", "replay-webhook", due[0].ID.String())
+	if out != "queued delivery "+due[0].ID.String()+" of event "+event.ID.String()+" again\n" {
+		t.Errorf("got output %q", out)
+	}
+	log, err := s.Webhooks().ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{SubscriptionID: sub.ID, Limit: 1})
+	if err != nil || len(log) != 1 || log[0].Status != "pending" || log[0].Attempts != 0 {
+		t.Errorf("delivery after replay = %+v, %v", log, err)
+	}
+
+	missing := uuid.New()
+	_, err = runAdmin(t, dbURL, "", "replay-webhook", missing.String())
+	wantError(t, err, false, "no webhook delivery "+missing.String())
+	_, err = runAdmin(t, dbURL, "", "replay-webhook", "42")
+	wantError(t, err, true, "DELIVERY_ID must be a UUID")
+}
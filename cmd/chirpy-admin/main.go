@@ -0,0 +1,181 @@
+// Command chirpy-admin runs operational tasks directly against the Chirpy
+// database, for the jobs that would otherwise need raw SQL.
+//
+//	chirpy-admin create-user --email ops@example.com --role admin
+//	chirpy-admin grant-red ops@example.com --for 720h
+//	chirpy-admin revoke-sessions 0b1c...
+//	chirpy-admin purge-tokens
+//
+// Users are named by ID or email. The database comes from --db-url or
+// DB_URL, which may be set in a .env file as for the server, and may be
+// Postgres or SQLite.
+package main
+
+import (
+	"context"
+	"errors"
+	"example.com/chirpy/internal/store"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout)
+	stop()
+	if err == nil {
+		return
+	}
+	var usage usageError
+	if errors.As(err, &usage) {
+		fmt.Fprintln(os.Stderr, usage.Error())
+		os.Exit(2)
+	}
+	fmt.Fprintf(os.Stderr, "chirpy-admin: %v\n", err)
+	os.Exit(1)
+}
+
+// usageError is a mistake in the command line.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// command is one subcommand.
+type command struct {
+	usage string
+	about string
+	run   func(ctx context.Context, a *admin, args []string) error
+}
+
+// commands is filled in by init because the commands print their own
+// usage from it.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"create-user":     {"create-user --email EMAIL [--password-stdin] [--role ROLE]", "create a user", cmdCreateUser},
+		"reset-password":  {"reset-password USER [--password-stdin] [--keep-sessions]", "set a new password and end the user's sessions", cmdResetPassword},
+		"grant-red":       {"grant-red USER [--for DURATION]", "give a user Chirpy Red for a period", cmdGrantRed},
+		"revoke-red":      {"revoke-red USER", "cancel a user's Chirpy Red subscription", cmdRevokeRed},
+		"grant-role":      {"grant-role USER ROLE", "grant a role such as admin", cmdGrantRole},
+		"revoke-role":     {"revoke-role USER ROLE", "revoke a role", cmdRevokeRole},
+		"sessions":        {"sessions USER", "list a user's refresh tokens", cmdSessions},
+		"revoke-sessions": {"revoke-sessions USER [--token PREFIX]", "revoke one or all of a user's sessions", cmdRevokeSessions},
+		"delete-user":     {"delete-user USER --yes", "delete a user and everything they own", cmdDeleteUser},
+		"purge-tokens":    {"purge-tokens", "delete expired and revoked refresh tokens", cmdPurgeTokens},
+		"replay-webhook":  {"replay-webhook DELIVERY_ID", "queue a webhook delivery to be sent again", cmdReplayWebhook},
+	}
+}
+
+// admin is what every command works with.
+type admin struct {
+	store store.Store
+	stdin io.Reader
+	out   io.Writer
+}
+
+// run parses the global flags, connects to the database and runs the
+// subcommand.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("chirpy-admin", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dbURL := flags.String("db-url", "", "postgres:// or sqlite: database URL (default $DB_URL)")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage(stdout)
+			return nil
+		}
+		return usageError(err.Error())
+	}
+	args = flags.Args()
+	if len(args) == 0 || args[0] == "help" {
+		printUsage(stdout)
+		return nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return usageError(fmt.Sprintf("chirpy-admin: unknown command %q; run chirpy-admin help", args[0]))
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("loading .env: %w", err)
+	}
+	if *dbURL == "" {
+		*dbURL = os.Getenv("DB_URL")
+	}
+	if *dbURL == "" {
+		return usageError("chirpy-admin: set DB_URL or pass --db-url")
+	}
+
+	s, db, err := store.Open(*dbURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("connecting to the database: %w", err)
+	}
+
+	a := &admin{store: s, stdin: stdin, out: stdout}
+	return cmd.run(ctx, a, args[1:])
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: chirpy-admin [--db-url URL] COMMAND [ARGS]")
+	fmt.Fprintln(w)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-60s %s\n", commands[name].usage, commands[name].about)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "USER is a user ID or email. Without --password-stdin, CHIRPY_PASSWORD is used")
+	fmt.Fprintln(w, "if set; otherwise a random password is generated and printed.")
+}
+
+// subcommandFlags returns a flag set for a subcommand.
+func subcommandFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+// parse parses a subcommand's flags, which may come before, after or
+// between its positional arguments, and checks that there are exactly
+// want positional arguments, which it returns.
+func parse(flags *flag.FlagSet, args []string, want int) ([]string, error) {
+	cmd := commands[flags.Name()]
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, usageError(fmt.Sprintf("chirpy-admin %s: %v\nusage: chirpy-admin %s", flags.Name(), err, cmd.usage))
+		}
+		rest := flags.Args()
+		// Parse consumes a "--" only when it stops there
+		if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	if len(positional) != want {
+		return nil, usageError("usage: chirpy-admin " + cmd.usage)
+	}
+	return positional, nil
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"example.com/chirpy/pkg/chirpyclient"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"iter"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+func cmdSignup(ctx context.Context, a *app, args []string) error {
+	fs := a.subcommandFlags("signup")
+	email := fs.String("email", "", "account email")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return usageError("chirpy signup: --email is required")
+	}
+	password, err := a.readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.client.CreateUser(ctx, *email, password)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(user)
+	}
+	fmt.Fprintf(a.out, "created %s (%s); run chirpy login to sign in\n", user.Email, user.ID)
+	return nil
+}
+
+func cmdLogin(ctx context.Context, a *app, args []string) error {
+	fs := a.subcommandFlags("login")
+	email := fs.String("email", "", "account email")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	if *email == "" {
+		*email = a.session.Email
+	}
+	if *email == "" {
+		return usageError("chirpy login: --email is required")
+	}
+	password, err := a.readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	a.session.UserID = uuid.Nil
+	a.session.Email = *email
+	// Login saves the tokens through OnTokens, so the ID and email must be
+	// set first for the saved session to be complete
+	user, err := a.client.Login(ctx, *email, password)
+	if err != nil {
+		return err
+	}
+	a.session.UserID = user.ID
+	if err := a.saveSession(); err != nil {
+		return err
+	}
+
+	if a.json {
+		user.Token, user.RefreshToken = "", ""
+		return a.printJSON(user)
+	}
+	fmt.Fprintf(a.out, "logged in to %s as %s\n", a.session.Server, user.Email)
+	return nil
+}
+
+func cmdLogout(ctx context.Context, a *app, args []string) error {
+	if _, err := parse(a.subcommandFlags("logout"), args); err != nil {
+		return err
+	}
+	if a.session.RefreshToken == "" {
+		return nil
+	}
+	err := a.client.Logout(ctx)
+	// An already revoked or expired token still ends the session
+	if errors.Is(err, chirpyclient.ErrInvalidToken) || errors.Is(err, chirpyclient.ErrUnauthorized) {
+		a.client.SetTokens(chirpyclient.Tokens{})
+		a.session = session{Server: a.session.Server}
+		err = a.saveSession()
+	}
+	return err
+}
+
+func cmdWhoami(ctx context.Context, a *app, args []string) error {
+	if _, err := parse(a.subcommandFlags("whoami"), args); err != nil {
+		return err
+	}
+	if err := a.requireLogin(); err != nil {
+		return err
+	}
+	caps, err := a.client.MyEntitlements(ctx)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(map[string]any{
+			"server":       a.session.Server,
+			"user_id":      a.session.UserID,
+			"email":        a.session.Email,
+			"capabilities": caps,
+		})
+	}
+	fmt.Fprintf(a.out, "%s (%s) on %s\ntier %s, chirps up to %d characters\n",
+		a.session.Email, a.session.UserID, a.session.Server, caps.Tier, caps.MaxChirpLength)
+	return nil
+}
+
+func cmdPost(ctx context.Context, a *app, args []string) error {
+	fs := a.subcommandFlags("post")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := a.requireLogin(); err != nil {
+		return err
+	}
+
+	text := strings.Join(args, " ")
+	if text == "-" {
+		data, err := io.ReadAll(a.stdin)
+		if err != nil {
+			return err
+		}
+		text = strings.TrimSpace(string(data))
+	}
+	if text == "" {
+		return usageError("usage: chirpy " + commands["post"].usage)
+	}
+
+	chirp, err := a.client.CreateChirp(ctx, text)
+	if err != nil {
+		return err
+	}
+	return a.printChirp(chirp)
+}
+
+func cmdChirps(ctx context.Context, a *app, args []string) error {
+	fs := a.subcommandFlags("chirps")
+	author := fs.String("author", "", `only chirps by this user ID, or "me"`)
+	limit := fs.Int("limit", 20, "how many chirps to show; 0 shows all")
+	oldest := fs.Bool("oldest", false, "show the oldest chirps first")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+
+	opts := chirpyclient.ListChirpsOptions{Newest: !*oldest}
+	if *author != "" {
+		id, err := a.userID(*author)
+		if err != nil {
+			return err
+		}
+		opts.AuthorID = id
+	}
+	return a.printChirps(a.client.Chirps(ctx, opts), *limit)
+}
+
+func cmdTimeline(ctx context.Context, a *app, args []string) error {
+	fs := a.subcommandFlags("timeline")
+	limit := fs.Int("limit", 20, "how many chirps to show; 0 shows all")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := a.requireLogin(); err != nil {
+		return err
+	}
+	pageSize := 0
+	if *limit > 0 {
+		pageSize = min(*limit, chirpyclient.DefaultPageSize)
+	}
+	return a.printChirps(a.client.Timeline(ctx, pageSize), *limit)
+}
+
+// printChirps prints the first limit chirps of seq, or all of them if
+// limit is 0.
+func (a *app) printChirps(seq iter.Seq2[chirpyclient.Chirp, error], limit int) error {
+	chirps := []chirpyclient.Chirp{}
+	for chirp, err := range seq {
+		if err != nil {
+			return err
+		}
+		chirps = append(chirps, chirp)
+		if limit > 0 && len(chirps) == limit {
+			break
+		}
+	}
+	if a.json {
+		return a.printJSON(chirps)
+	}
+	for _, chirp := range chirps {
+		a.printChirpText(chirp)
+	}
+	return nil
+}
+
+func cmdShow(ctx context.Context, a *app, args []string) error {
+	fs := a.subcommandFlags("show")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := chirpIDArg(args, "show")
+	if err != nil {
+		return err
+	}
+	chirp, err := a.client.GetChirp(ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.printChirp(chirp)
+}
+
+func cmdDelete(ctx context.Context, a *app, args []string) error {
+	fs := a.subcommandFlags("delete")
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := chirpIDArg(args, "delete")
+	if err != nil {
+		return err
+	}
+	if err := a.requireLogin(); err != nil {
+		return err
+	}
+	if err := a.client.DeleteChirp(ctx, id); err != nil {
+		return err
+	}
+	if !a.json {
+		fmt.Fprintf(a.out, "deleted %s\n", id)
+	}
+	return nil
+}
+
+func cmdFollow(ctx context.Context, a *app, args []string) error {
+	return a.follow(ctx, "follow", args, a.client.Follow)
+}
+
+func cmdUnfollow(ctx context.Context, a *app, args []string) error {
+	return a.follow(ctx, "unfollow", args, a.client.Unfollow)
+}
+
+func (a *app) follow(ctx context.Context, name string, args []string, call func(context.Context, uuid.UUID) error) error {
+	fs := a.subcommandFlags(name)
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError("usage: chirpy " + commands[name].usage)
+	}
+	id, err := a.userID(args[0])
+	if err != nil {
+		return err
+	}
+	if err := a.requireLogin(); err != nil {
+		return err
+	}
+	if err := call(ctx, id); err != nil {
+		return err
+	}
+	if !a.json {
+		fmt.Fprintf(a.out, "%sed %s\n", name, id)
+	}
+	return nil
+}
+
+// cmdTail prints chirps posted after it starts, oldest first, until
+// interrupted. It follows the server's event stream, which resumes where
+// it left off after a dropped connection. If the server can no longer
+// replay what was missed, the gap is filled from the chirp list, except
+// with --followed, which the list can't filter by.
+func cmdTail(ctx context.Context, a *app, args []string) error {
+	fs := a.subcommandFlags("tail")
+	author := fs.String("author", "", `only chirps by this user ID, or "me"`)
+	followed := fs.Bool("followed", false, "only chirps by users you follow")
+	if _, err := parse(fs, args); err != nil {
+		return err
+	}
+	var opts chirpyclient.StreamOptions
+	if *author != "" {
+		id, err := a.userID(*author)
+		if err != nil {
+			return err
+		}
+		opts.AuthorID = id
+	}
+	opts.Followed = *followed
+
+	var last chirpyclient.Chirp
+	for event, err := range a.client.Stream(ctx, opts) {
+		if err != nil {
+			return err
+		}
+		switch event.Type {
+		case chirpyclient.StreamChirpCreated:
+			if err := a.printChirpLine(event.Chirp); err != nil {
+				return err
+			}
+			last = event.Chirp
+		case chirpyclient.StreamReset:
+			if last.ID == uuid.Nil || opts.Followed {
+				continue
+			}
+			missed, err := a.chirpsSince(ctx, last, opts.AuthorID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			for _, chirp := range missed {
+				if err := a.printChirpLine(chirp); err != nil {
+					return err
+				}
+				last = chirp
+			}
+		}
+	}
+	return nil
+}
+
+// chirpsSince returns the chirps posted after last, oldest first.
+func (a *app) chirpsSince(ctx context.Context, last chirpyclient.Chirp, authorID uuid.UUID) ([]chirpyclient.Chirp, error) {
+	var missed []chirpyclient.Chirp
+	for chirp, err := range a.client.Chirps(ctx, chirpyclient.ListChirpsOptions{AuthorID: authorID, Newest: true}) {
+		if err != nil {
+			return nil, err
+		}
+		if chirp.ID == last.ID || chirp.CreatedAt.Before(last.CreatedAt) {
+			break
+		}
+		missed = append(missed, chirp)
+	}
+	slices.Reverse(missed)
+	return missed, nil
+}
+
+func chirpIDArg(args []string, name string) (uuid.UUID, error) {
+	if len(args) != 1 {
+		return uuid.Nil, usageError("usage: chirpy " + commands[name].usage)
+	}
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return uuid.Nil, usageError(fmt.Sprintf("chirpy %s: %q is not a chirp ID", name, args[0]))
+	}
+	return id, nil
+}
+
+// readPassword takes the password from stdin, $CHIRPY_PASSWORD or a
+// prompt on the terminal, in that order.
+func (a *app) readPassword(fromStdin bool) (string, error) {
+	if fromStdin {
+		line, err := bufio.NewReader(a.stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	if p := os.Getenv("CHIRPY_PASSWORD"); p != "" {
+		return p, nil
+	}
+
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return "", usageError("chirpy: no terminal to prompt for a password; use --password-stdin or CHIRPY_PASSWORD")
+	}
+	defer tty.Close()
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	// Turn off echo where stty exists; otherwise the password is visible
+	echoOff := exec.Command("stty", "-echo")
+	echoOff.Stdin = tty
+	if echoOff.Run() == nil {
+		defer func() {
+			echoOn := exec.Command("stty", "echo")
+			echoOn.Stdin = tty
+			echoOn.Run()
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,163 @@
+// Command chirpy is a command-line client for the Chirpy API.
+//
+//	chirpy login --email ops@example.com
+//	chirpy post "deploy finished"
+//	chirpy timeline
+//	chirpy chirps --author me
+//	chirpy tail --json | jq .body
+//
+// The session from login is kept in the user's config directory, e.g.
+// ~/.config/chirpy/session.json, and access tokens are refreshed
+// automatically. Every command accepts --json for machine-readable output.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	if err == nil {
+		return
+	}
+	var usage usageError
+	if errors.As(err, &usage) {
+		fmt.Fprintln(os.Stderr, usage.Error())
+		os.Exit(2)
+	}
+	if !errors.Is(err, context.Canceled) {
+		printError(os.Stderr, err, jsonRequested(os.Args[1:]))
+	}
+	os.Exit(1)
+}
+
+// usageError is a mistake in the command line.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// command is one subcommand.
+type command struct {
+	usage string
+	about string
+	run   func(ctx context.Context, a *app, args []string) error
+}
+
+// commands is filled in by init because the commands print their own
+// usage from it.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"signup":   {"signup --email EMAIL [--password-stdin]", "create an account", cmdSignup},
+		"login":    {"login --email EMAIL [--password-stdin]", "log in and save the session", cmdLogin},
+		"logout":   {"logout", "revoke and forget the saved session", cmdLogout},
+		"whoami":   {"whoami", "show the logged-in account", cmdWhoami},
+		"post":     {"post TEXT...", `post a chirp; "-" reads the text from stdin`, cmdPost},
+		"timeline": {"timeline [--limit N]", "list chirps by you and the users you follow, newest first", cmdTimeline},
+		"chirps":   {"chirps [--author ID|me] [--limit N] [--oldest]", "list everyone's chirps, newest first", cmdChirps},
+		"show":     {"show CHIRP_ID", "show one chirp", cmdShow},
+		"delete":   {"delete CHIRP_ID", "delete one of your chirps", cmdDelete},
+		"follow":   {"follow USER_ID", "follow a user", cmdFollow},
+		"unfollow": {"unfollow USER_ID", "stop following a user", cmdUnfollow},
+		"tail":     {"tail [--author ID|me] [--followed]", "print new chirps as they are posted", cmdTail},
+	}
+}
+
+// run parses the global flags and runs the subcommand.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	server := fs.String("server", "", "API base URL (default $CHIRPY_URL, the saved session's server, or http://localhost:8080)")
+	jsonOut := fs.Bool("json", false, "print JSON instead of text")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage(stdout)
+			return nil
+		}
+		return usageError(err.Error())
+	}
+	args = fs.Args()
+	if len(args) == 0 || args[0] == "help" {
+		printUsage(stdout)
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return usageError(fmt.Sprintf("chirpy: unknown command %q; run chirpy help", args[0]))
+	}
+	a, err := newApp(*server, *jsonOut, stdin, stdout)
+	if err != nil {
+		return err
+	}
+	return cmd.run(ctx, a, args[1:])
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: chirpy [--server URL] [--json] COMMAND [ARGS]")
+	fmt.Fprintln(w)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-52s %s\n", commands[name].usage, commands[name].about)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "--json may also follow the command. CHIRPY_PASSWORD is used instead of a prompt when set.")
+}
+
+// subcommandFlags returns a flag set for a subcommand that also accepts
+// the global --json flag after the command name.
+func (a *app) subcommandFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&a.json, "json", a.json, "print JSON instead of text")
+	return fs
+}
+
+// parse parses a subcommand's flags, which may come before, after or
+// between its positional arguments, and returns the positional arguments.
+// Everything after "--" is positional. Mistakes become usage errors.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			cmd := commands[fs.Name()]
+			return nil, usageError(fmt.Sprintf("chirpy %s: %v\nusage: chirpy %s", fs.Name(), err, cmd.usage))
+		}
+		rest := fs.Args()
+		// Parse consumes a "--" only when it stops there
+		if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func jsonRequested(args []string) bool {
+	for _, arg := range args {
+		if arg == "--json" || arg == "-json" || arg == "--json=true" || arg == "-json=true" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"example.com/chirpy/pkg/chirpyclient"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+func (a *app) printJSON(v any) error {
+	enc := json.NewEncoder(a.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printChirp prints one chirp as indented JSON or as text.
+func (a *app) printChirp(c chirpyclient.Chirp) error {
+	if a.json {
+		return a.printJSON(c)
+	}
+	a.printChirpText(c)
+	return nil
+}
+
+// printChirpLine prints one chirp as a single line of JSON or as text,
+// for streams.
+func (a *app) printChirpLine(c chirpyclient.Chirp) error {
+	if a.json {
+		return json.NewEncoder(a.out).Encode(c)
+	}
+	a.printChirpText(c)
+	return nil
+}
+
+func (a *app) printChirpText(c chirpyclient.Chirp) {
+	fmt.Fprintf(a.out, "%s  %s  by %s\n    %s\n",
+		c.CreatedAt.Local().Format(time.DateTime), c.ID, c.UserID,
+		strings.ReplaceAll(c.Body, "\n", "\n    "))
+}
+
+// printError reports a failed command. API errors keep their code and
+// field details; in JSON mode they are printed as JSON for scripts.
+func printError(w io.Writer, err error, jsonOut bool) {
+	var apiErr *chirpyclient.Error
+	isAPI := errors.As(err, &apiErr)
+	if jsonOut {
+		v := map[string]any{"error": err.Error()}
+		if isAPI {
+			v = map[string]any{
+				"error":      apiErr.Detail,
+				"status":     apiErr.StatusCode,
+				"code":       apiErr.Code,
+				"fields":     apiErr.Fields,
+				"request_id": apiErr.RequestID,
+			}
+		}
+		json.NewEncoder(w).Encode(v)
+		return
+	}
+	if !isAPI {
+		fmt.Fprintln(w, "chirpy:", err)
+		return
+	}
+
+	msg := apiErr.Detail
+	if msg == "" {
+		msg = apiErr.Title
+	}
+	fmt.Fprintf(w, "chirpy: %s (%s)\n", msg, apiErr.Code)
+	for _, f := range apiErr.Fields {
+		fmt.Fprintf(w, "  %s: %s\n", f.Field, f.Message)
+	}
+	if apiErr.Code == chirpyclient.CodeUnauthorized || apiErr.Code == chirpyclient.CodeInvalidToken {
+		fmt.Fprintln(w, "  run chirpy login to sign in again")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"example.com/chirpy/pkg/chirpyclient"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// session is what login saves between runs.
+type session struct {
+	Server       string    `json:"server"`
+	UserID       uuid.UUID `json:"user_id"`
+	Email        string    `json:"email"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+// app is the state shared by the subcommands.
+type app struct {
+	client      *chirpyclient.Client
+	session     session
+	sessionPath string
+	json        bool
+	stdin       io.Reader
+	out         io.Writer
+}
+
+// newApp loads the saved session and builds a client for the server
+// chosen by --server, $CHIRPY_URL or the session, in that order.
+func newApp(server string, jsonOut bool, stdin io.Reader, out io.Writer) (*app, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("finding config directory: %w", err)
+	}
+	a := &app{
+		sessionPath: filepath.Join(dir, "chirpy", "session.json"),
+		json:        jsonOut,
+		stdin:       stdin,
+		out:         out,
+	}
+
+	data, err := os.ReadFile(a.sessionPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("reading session: %w", err)
+	default:
+		if err := json.Unmarshal(data, &a.session); err != nil {
+			return nil, fmt.Errorf("reading session %s: %w", a.sessionPath, err)
+		}
+	}
+
+	if server == "" {
+		server = os.Getenv("CHIRPY_URL")
+	}
+	if server == "" {
+		server = a.session.Server
+	}
+	if server == "" {
+		server = defaultServer
+	}
+
+	// A session belongs to the server that issued it
+	if a.session.Server != "" && a.session.Server != server {
+		a.session = session{}
+	}
+	a.session.Server = server
+
+	a.client = chirpyclient.New(server)
+	a.client.UserAgent = "chirpy-cli"
+	a.client.SetTokens(chirpyclient.Tokens{
+		AccessToken:  a.session.AccessToken,
+		RefreshToken: a.session.RefreshToken,
+	})
+	// Keep refreshed access tokens so the next run doesn't refresh again
+	a.client.OnTokens = func(t chirpyclient.Tokens) {
+		a.session.AccessToken = t.AccessToken
+		a.session.RefreshToken = t.RefreshToken
+		if err := a.saveSession(); err != nil {
+			fmt.Fprintln(os.Stderr, "chirpy: warning:", err)
+		}
+	}
+	return a, nil
+}
+
+// saveSession writes the session readable only by the user, or removes
+// it once logged out.
+func (a *app) saveSession() error {
+	if a.session.RefreshToken == "" {
+		err := os.Remove(a.sessionPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.sessionPath), 0o700); err != nil {
+		return fmt.Errorf("saving session: %w", err)
+	}
+	data, err := json.MarshalIndent(a.session, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := a.sessionPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("saving session: %w", err)
+	}
+	return os.Rename(tmp, a.sessionPath)
+}
+
+// requireLogin fails early with a helpful message when there's no session.
+func (a *app) requireLogin() error {
+	if a.session.RefreshToken == "" {
+		return fmt.Errorf("not logged in to %s; run chirpy login", a.session.Server)
+	}
+	return nil
+}
+
+// userID parses a user ID argument, where "me" is the logged-in user.
+func (a *app) userID(s string) (uuid.UUID, error) {
+	if s == "me" {
+		if err := a.requireLogin(); err != nil {
+			return uuid.Nil, err
+		}
+		return a.session.UserID, nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, usageError(fmt.Sprintf("chirpy: %q is not a user ID", s))
+	}
+	return id, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// maxContractBodyBytes bounds how much of a response middlewareContract
+// buffers. Larger bodies, and event streams, only have their status and
+// media type checked.
+const maxContractBodyBytes = 4 << 20
+
+// middlewareContract checks every response to a registered route against
+// the OpenAPI document and logs the ones that don't match, so handlers
+// can't drift from the published contract unnoticed. It buffers response
+// bodies and is meant for development and CI.
+func (cfg *apiConfig) middlewareContract(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &contractRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		body := rec.body.Bytes()
+		if rec.truncated {
+			body = nil
+		}
+		if err := cfg.contract.CheckResponse(pattern, rec.status, w.Header(), body); err != nil {
+			cfg.metrics.contractViolations.With(pattern).Inc()
+			slog.ErrorContext(r.Context(), "response doesn't match the OpenAPI document",
+				"method", r.Method, "status", rec.status, "error", err)
+		}
+	})
+}
+
+// contractRecorder keeps a copy of the response for middlewareContract.
+type contractRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	truncated   bool
+}
+
+func (r *contractRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *contractRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	if !r.truncated && r.body.Len()+len(b) <= maxContractBodyBytes && !r.streaming() {
+		r.body.Write(b)
+	} else {
+		r.truncated = true
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// streaming reports whether the response is an event stream, which has no
+// end to check against the document until the client goes away.
+func (r *contractRecorder) streaming() bool {
+	return strings.HasPrefix(r.Header().Get("Content-Type"), "text/event-stream")
+}
+
+// Hijack records a switch to another protocol, such as WebSocket, and
+// hands over the connection.
+func (r *contractRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && !r.wroteHeader {
+		r.status = http.StatusSwitchingProtocols
+		r.wroteHeader = true
+	}
+	return conn, brw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *contractRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/openapi"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contractClient sends requests to the API and fails the test if a
+// response doesn't match the OpenAPI document. It records which routes
+// were exercised.
+type contractClient struct {
+	t       *testing.T
+	handler http.Handler
+	doc     *openapi.Document
+	covered map[string]bool
+}
+
+type contractRequest struct {
+	pattern string
+	path    string // defaults to the pattern's path
+	token   string
+	body    any
+	header  http.Header
+	timeout time.Duration // for streams, which only end when the client leaves
+	want    int
+}
+
+func (c *contractClient) do(req contractRequest) []byte {
+	c.t.Helper()
+	method, path := openapi.SplitPattern(req.pattern)
+	if req.path != "" {
+		path = req.path
+	}
+
+	var body io.Reader
+	contentType := "application/json"
+	switch b := req.body.(type) {
+	case nil:
+	case url.Values:
+		body = strings.NewReader(b.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		dat, err := json.Marshal(req.body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		body = bytes.NewReader(dat)
+	}
+	r := httptest.NewRequest(method, path, body)
+	for k, v := range req.header {
+		r.Header[k] = v
+	}
+	if req.body != nil {
+		r.Header.Set("Content-Type", contentType)
+	}
+	if req.token != "" {
+		r.Header.Set("Authorization", "Bearer "+req.token)
+	}
+	if req.timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), req.timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+	res := w.Result()
+	dat, _ := io.ReadAll(res.Body)
+
+	c.covered[req.pattern] = true
+	if res.StatusCode != req.want {
+		c.t.Fatalf("%s %s: got status %d, want %d: %s", method, path, res.StatusCode, req.want, dat)
+	}
+	checked := dat
+	if req.timeout > 0 {
+		// The middleware only checks the status and media type of streams
+		checked = nil
+	}
+	if err := c.doc.CheckResponse(req.pattern, res.StatusCode, res.Header, checked); err != nil {
+		c.t.Errorf("%s %s: response %d doesn't match the OpenAPI document: %v\n%s", method, path, res.StatusCode, err, dat)
+	}
+	return dat
+}
+
+func (c *contractClient) decode(req contractRequest, out any) {
+	c.t.Helper()
+	if err := json.Unmarshal(c.do(req), out); err != nil {
+		c.t.Fatalf("%s: %v", req.pattern, err)
+	}
+}
+
+// TestContract drives every registered route and fails on any response the
+// OpenAPI document doesn't describe, including error responses.
+func TestContract(t *testing.T) {
+	apiCfg, handler := newTestAPI(t, "sqlite:"+filepath.Join(t.TempDir(), "chirpy.db"))
+	apiCfg.polkaKeys = []string{testPolkaKey}
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &contractClient{t: t, handler: handler, doc: doc, covered: map[string]bool{}}
+	ctx := context.Background()
+
+	for _, pattern := range []string{"GET /api/healthz", "GET /api/livez", "GET /api/readyz", "GET /api/openapi.json", "GET /api/docs", "GET /metrics", "GET /admin/metrics", "GET /api/graphql/schema"} {
+		c.do(contractRequest{pattern: pattern, want: http.StatusOK})
+	}
+	c.do(contractRequest{pattern: "POST /api/validate_chirp", body: map[string]string{"body": "hello"}, want: http.StatusOK})
+	c.do(contractRequest{pattern: "POST /api/validate_chirp", body: map[string]int{"body": 1}, want: http.StatusBadRequest})
+
+	// Users and sessions
+	walt := map[string]string{"email": "walt@example.com", "password": "hunter2"}
+	var waltUser, jesse struct {
+		ID           uuid.UUID `json:"id"`
+		Token        string    `json:"token"`
+		RefreshToken string    `json:"refresh_token"`
+	}
+	c.do(contractRequest{pattern: "POST /api/users", body: walt, want: http.StatusCreated})
+	c.do(contractRequest{pattern: "POST /api/users", body: walt, want: http.StatusConflict})
+	c.do(contractRequest{pattern: "POST /api/users", body: map[string]string{"email": "jesse@example.com", "password": "hunter2"}, want: http.StatusCreated})
+	c.decode(contractRequest{pattern: "POST /api/login", body: walt, want: http.StatusOK}, &waltUser)
+	c.decode(contractRequest{pattern: "POST /api/login", body: map[string]string{"email": "jesse@example.com", "password": "hunter2"}, want: http.StatusOK}, &jesse)
+	c.do(contractRequest{pattern: "POST /api/login", body: map[string]string{"email": "walt@example.com", "password": "wrong"}, want: http.StatusUnauthorized})
+	c.do(contractRequest{pattern: "POST /api/refresh", token: waltUser.RefreshToken, want: http.StatusOK})
+	c.do(contractRequest{pattern: "POST /api/revoke", token: jesse.RefreshToken, want: http.StatusNoContent})
+	c.do(contractRequest{pattern: "POST /api/refresh", token: jesse.RefreshToken, want: http.StatusUnauthorized})
+	c.do(contractRequest{pattern: "PUT /api/users", token: waltUser.Token, body: map[string]string{
+		"email": "heisenberg@example.com", "password": "hunter2", "current_password": "hunter2",
+	}, want: http.StatusOK})
+	c.do(contractRequest{pattern: "PUT /api/users", body: walt, want: http.StatusUnauthorized})
+	c.do(contractRequest{pattern: "PATCH /api/users/me", token: waltUser.Token, body: map[string]string{
+		"password": "hunter3", "current_password": "hunter2",
+	}, want: http.StatusOK})
+	c.do(contractRequest{pattern: "PATCH /api/users/me", token: waltUser.Token, body: map[string]string{"email": ""}, want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "GET /api/users/email/confirm", want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "GET /api/users/email/confirm", path: "/api/users/email/confirm?token=unknown", want: http.StatusOK})
+	c.do(contractRequest{pattern: "POST /api/users/email/confirm", body: map[string]string{"token": "unknown"}, want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "POST /api/users/email/confirm", body: map[string]string{}, want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "POST /api/users/email/confirm", body: url.Values{"token": {"unknown"}}, want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "GET /api/users/me/entitlements", token: waltUser.Token, want: http.StatusOK})
+	c.do(contractRequest{pattern: "GET /api/users/me/entitlements", want: http.StatusUnauthorized})
+
+	// Chirps
+	var chirp struct {
+		ID uuid.UUID `json:"id"`
+	}
+	c.decode(contractRequest{pattern: "POST /api/chirps", token: waltUser.Token, body: map[string]string{"body": "Say my name"}, want: http.StatusCreated}, &chirp)
+	c.do(contractRequest{pattern: "POST /api/chirps", body: map[string]string{"body": "anonymous"}, want: http.StatusUnauthorized})
+	c.do(contractRequest{pattern: "GET /api/chirps", want: http.StatusOK})
+	c.do(contractRequest{pattern: "GET /api/chirps", path: "/api/chirps?author_id=" + waltUser.ID.String() + "&sort=asc", want: http.StatusOK})
+	chirpPath := "/api/chirps/" + chirp.ID.String()
+	c.do(contractRequest{pattern: "GET /api/chirps/{chirpID}", path: chirpPath, want: http.StatusOK})
+	c.do(contractRequest{pattern: "GET /api/chirps/{chirpID}", path: "/api/chirps/" + uuid.NewString(), want: http.StatusNotFound})
+	c.do(contractRequest{pattern: "GET /api/chirps/{chirpID}", path: "/api/chirps/nope", want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "DELETE /api/chirps/{chirpID}", path: chirpPath, token: jesse.Token, want: http.StatusForbidden})
+	c.do(contractRequest{pattern: "DELETE /api/chirps/{chirpID}", path: chirpPath, token: waltUser.Token, want: http.StatusNoContent})
+
+	// Streaming and GraphQL
+	c.do(contractRequest{pattern: "GET /api/stream", timeout: 50 * time.Millisecond, want: http.StatusOK})
+	c.do(contractRequest{pattern: "GET /api/ws", want: http.StatusUpgradeRequired})
+	c.do(contractRequest{pattern: "GET /api/ws", header: http.Header{
+		"Connection":            {"Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+	}, want: http.StatusUnauthorized})
+	query := `{ chirps(first: 5) { edges { node { id body author { id } } } pageInfo { hasNextPage } } }`
+	c.do(contractRequest{pattern: "GET /api/graphql", path: "/api/graphql?query=" + url.QueryEscape(query), want: http.StatusOK})
+	c.do(contractRequest{pattern: "GET /api/graphql", want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "POST /api/graphql", token: waltUser.Token, body: map[string]string{"query": "{ me { id email } }"}, want: http.StatusOK})
+	c.do(contractRequest{pattern: "POST /api/graphql", body: map[string]string{}, want: http.StatusBadRequest})
+
+	// Follows
+	followPath := "/api/users/" + jesse.ID.String() + "/follow"
+	c.do(contractRequest{pattern: "POST /api/users/{userID}/follow", path: followPath, token: waltUser.Token, want: http.StatusNoContent})
+	c.do(contractRequest{pattern: "POST /api/users/{userID}/follow", path: "/api/users/" + uuid.NewString() + "/follow", token: waltUser.Token, want: http.StatusNotFound})
+	c.do(contractRequest{pattern: "GET /api/users/me/timeline", path: "/api/users/me/timeline?limit=1", token: waltUser.Token, want: http.StatusOK})
+	c.do(contractRequest{pattern: "GET /api/users/me/timeline", path: "/api/users/me/timeline?after=nope", token: waltUser.Token, want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "GET /api/users/me/timeline", want: http.StatusUnauthorized})
+	c.do(contractRequest{pattern: "DELETE /api/users/{userID}/follow", path: followPath, token: waltUser.Token, want: http.StatusNoContent})
+	c.do(contractRequest{pattern: "DELETE /api/users/{userID}/follow", path: followPath, want: http.StatusUnauthorized})
+
+	// Webhook subscriptions
+	var sub struct {
+		ID uuid.UUID `json:"id"`
+	}
+	c.decode(contractRequest{pattern: "POST /api/webhooks", token: waltUser.Token, body: map[string]any{
+		"url": "https://93.184.215.14/hooks", "events": []string{"chirp.created"},
+	}, want: http.StatusCreated}, &sub)
+	c.do(contractRequest{pattern: "POST /api/webhooks", token: waltUser.Token, body: map[string]any{
+		"url": "https://127.0.0.1/hooks", "events": []string{"chirp.created"},
+	}, want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "GET /api/webhooks", token: waltUser.Token, want: http.StatusOK})
+	subPath := "/api/webhooks/" + sub.ID.String()
+	c.do(contractRequest{pattern: "GET /api/webhooks/{webhookID}/deliveries", path: subPath + "/deliveries", token: waltUser.Token, want: http.StatusOK})
+	c.do(contractRequest{pattern: "GET /api/webhooks/{webhookID}/deliveries", path: subPath + "/deliveries", token: jesse.Token, want: http.StatusNotFound})
+	c.do(contractRequest{pattern: "GET /api/webhooks/{webhookID}/deliveries", path: subPath + "/deliveries?limit=0", token: waltUser.Token, want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "DELETE /api/webhooks/{webhookID}", path: subPath, token: waltUser.Token, want: http.StatusNoContent})
+	c.do(contractRequest{pattern: "DELETE /api/webhooks/{webhookID}", path: subPath, token: waltUser.Token, want: http.StatusNotFound})
+
+	// Polka
+	event := map[string]any{"id": "evt_1", "event": "user.upgraded", "data": map[string]any{"user_id": waltUser.ID}}
+	dat, _ := json.Marshal(event)
+	now := time.Now().Unix()
+	c.do(contractRequest{pattern: "POST /api/polka/webhooks", body: event, header: http.Header{
+		auth.WebhookTimestampHeader: {strconv.FormatInt(now, 10)},
+		auth.WebhookSignatureHeader: {"v1=" + auth.SignWebhook(testPolkaKey, now, dat)},
+	}, want: http.StatusNoContent})
+	c.do(contractRequest{pattern: "POST /api/polka/webhooks", body: event, want: http.StatusUnauthorized})
+
+	// Admin
+	if err := apiCfg.store.Roles().GrantRole(ctx, database.GrantRoleParams{UserID: jesse.ID, Role: roleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	entitlementsPath := "/admin/users/" + waltUser.ID.String() + "/entitlements"
+	c.do(contractRequest{pattern: "GET /admin/users/{userID}/entitlements", path: entitlementsPath, token: jesse.Token, want: http.StatusOK})
+	c.do(contractRequest{pattern: "GET /admin/users/{userID}/entitlements", path: entitlementsPath, token: waltUser.Token, want: http.StatusForbidden})
+	c.do(contractRequest{pattern: "PUT /admin/users/{userID}/entitlements", path: entitlementsPath, token: jesse.Token, body: map[string]any{"max_chirp_length": 280}, want: http.StatusOK})
+	c.do(contractRequest{pattern: "PUT /admin/users/{userID}/entitlements", path: entitlementsPath, token: jesse.Token, body: map[string]any{"tier": "platinum"}, want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "DELETE /admin/users/{userID}/entitlements", path: entitlementsPath, token: jesse.Token, want: http.StatusNoContent})
+	c.do(contractRequest{pattern: "DELETE /admin/users/{userID}/entitlements", path: entitlementsPath, want: http.StatusUnauthorized})
+	c.do(contractRequest{pattern: "POST /admin/reset", body: map[string]any{}, want: http.StatusBadRequest})
+	c.do(contractRequest{pattern: "POST /admin/reset", body: map[string]any{"confirm": true}, want: http.StatusOK})
+
+	// Every documented route must have been exercised
+	_, patterns := apiCfg.routes(t.TempDir())
+	var missed []string
+	for _, pattern := range patterns {
+		if method, _ := openapi.SplitPattern(pattern); method != "" && !c.covered[pattern] {
+			missed = append(missed, pattern)
+		}
+	}
+	sort.Strings(missed)
+	if len(missed) > 0 {
+		t.Errorf("routes not exercised: %q", missed)
+	}
+	if got := apiCfg.metrics.contractViolations.Total(); got != 0 {
+		t.Errorf("the contract middleware counted %v violations", got)
+	}
+}
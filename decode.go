@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"example.com/chirpy/internal/problem"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxRequestBodyBytes caps JSON request bodies.
+const maxRequestBodyBytes = 1 << 20
+
+// validator is implemented by request types with checks that struct tags
+// can't express. Its field errors are reported alongside the tag errors.
+type validator interface {
+	Validate() []problem.FieldError
+}
+
+// decodeJSON strictly decodes the request body into dst, which must be a
+// pointer to a struct, and validates it. The body must be a single JSON
+// object of at most maxRequestBodyBytes with no unknown fields, sent as
+// application/json or with no Content-Type. Unknown fields, values of the
+// wrong type and validation failures are all returned at once, as one
+// *apiError listing every field.
+//
+// Fields are validated with a `validate` tag holding comma-separated rules:
+//
+//	required    the field must be non-zero; a non-nil pointer for pointers
+//	email       the string must be a plain email address
+//	min=N       the string or slice must have at least N characters or items
+//	max=N       the string or slice must have at most N characters or items
+//	maxbytes=N  the string must be at most N bytes of UTF-8, for limits
+//	            such as bcrypt's that count bytes rather than characters
+//
+// Rules other than required are skipped for empty values and nil pointers.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+			return newAPIError(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType,
+				"Content-Type must be application/json", err)
+		}
+	}
+
+	return decodeStrict(json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)), dst)
+}
+
+// decodeStrict decodes and validates the single JSON object read by dec
+// into dst, as decodeJSON does for request bodies.
+func decodeStrict(dec *json.Decoder, dst any) error {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return decodeError(err)
+		}
+		return badRequest("Request body must contain a single JSON object", err)
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil || members == nil {
+		return badRequest("Request body must be a JSON object", err)
+	}
+
+	v := reflect.ValueOf(dst).Elem()
+	fields := decodeMembers(v, members)
+	checks := validateStruct(v, "")
+	if vd, ok := dst.(validator); ok {
+		checks = append(checks, vd.Validate()...)
+	}
+	// A field that didn't decode holds its zero value, so checks on it
+	// would only repeat the problem
+	failed := make(map[string]bool, len(fields))
+	for _, fe := range fields {
+		failed[fe.Field] = true
+	}
+	for _, fe := range checks {
+		if !failed[fe.Field] {
+			fields = append(fields, fe)
+		}
+	}
+	if err := validationFailed(fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// decodeMembers decodes each member of a JSON object into the field of the
+// struct v it names. Decoding member by member, rather than the whole
+// object at once, reports every unknown field and type error instead of
+// stopping at the first.
+func decodeMembers(v reflect.Value, members map[string]json.RawMessage) []problem.FieldError {
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fields []problem.FieldError
+	for _, name := range names {
+		fv, ok := fieldByJSONName(v, name)
+		if !ok {
+			fields = append(fields, fieldError(name, problem.FieldUnknown, "unknown field "+strconv.Quote(name)))
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(members[name]))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(fv.Addr().Interface()); err != nil {
+			fields = append(fields, memberError(name, err))
+		}
+	}
+	return fields
+}
+
+// memberError turns the error decoding the member name into a field
+// error. Errors inside a nested object are reported on its field.
+func memberError(name string, err error) problem.FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field != "" {
+			name += "." + typeErr.Field
+		}
+		return fieldError(name, problem.FieldInvalid, fmt.Sprintf("%s must be %s", name, jsonTypeName(typeErr.Type)))
+	}
+	// encoding/json has no typed error for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		unquoted, _ := strconv.Unquote(field)
+		return fieldError(name+"."+unquoted, problem.FieldUnknown, "unknown field "+field)
+	}
+	// Types that decode themselves, such as UUIDs and times, return
+	// errors of their own
+	return fieldError(name, problem.FieldInvalid, name+" is invalid")
+}
+
+// fieldByJSONName returns the field of the struct v that the JSON member
+// name decodes into, matching names the way encoding/json does: exactly
+// if possible, otherwise ignoring case. Fields of embedded structs count
+// as the outer struct's.
+func fieldByJSONName(v reflect.Value, name string) (reflect.Value, bool) {
+	var folded reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if tagName, _, _ := strings.Cut(tag, ","); sf.Anonymous && sf.Type.Kind() == reflect.Struct && tagName == "" {
+			if fv, ok := fieldByJSONName(v.Field(i), name); ok {
+				return fv, true
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		switch fieldName := jsonName(sf); {
+		case fieldName == name:
+			return v.Field(i), true
+		case !folded.IsValid() && strings.EqualFold(fieldName, name):
+			folded = v.Field(i)
+		}
+	}
+	return folded, folded.IsValid()
+}
+
+// decodeError turns a json.Decoder error into an API error.
+func decodeError(err error) error {
+	var (
+		syntaxErr   *json.SyntaxError
+		maxBytesErr *http.MaxBytesError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return badRequest("Request body is empty", err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest("Request body is truncated JSON", err)
+	case errors.As(err, &syntaxErr):
+		return badRequest(fmt.Sprintf("Malformed JSON at offset %d", syntaxErr.Offset), err)
+	case errors.As(err, &maxBytesErr):
+		return newAPIError(http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge,
+			fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit), err)
+	}
+	return badRequest("Couldn't decode parameters", err)
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	}
+	return "an object"
+}
+
+var unmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// validateStruct checks the validate tags of v's fields, recursing into
+// nested structs. prefix is the JSON path of v.
+func validateStruct(v reflect.Value, prefix string) []problem.FieldError {
+	var fields []problem.FieldError
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := jsonName(sf)
+		if name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if rules := sf.Tag.Get("validate"); rules != "" {
+			if fe, ok := checkRules(fv, name, rules); !ok {
+				fields = append(fields, fe)
+				continue
+			}
+		}
+
+		if fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		// Types such as time.Time decode themselves and have nothing to check
+		if fv.Kind() == reflect.Struct && !reflect.PointerTo(fv.Type()).Implements(unmarshalerType) {
+			if sf.Anonymous {
+				name = prefix
+			}
+			fields = append(fields, validateStruct(fv, name)...)
+		}
+	}
+	return fields
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+// checkRules applies the comma-separated rules to v and reports the first
+// failure.
+func checkRules(v reflect.Value, name, rules string) (problem.FieldError, bool) {
+	for _, rule := range strings.Split(rules, ",") {
+		rule, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if rule == "required" {
+			if v.IsZero() {
+				return fieldError(name, problem.FieldRequired, name+" is required"), false
+			}
+			continue
+		}
+
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return problem.FieldError{}, true
+			}
+			v = v.Elem()
+		}
+		if v.IsZero() {
+			continue
+		}
+
+		switch rule {
+		case "email":
+			s := v.String()
+			addr, err := mail.ParseAddress(s)
+			if err != nil || addr.Address != s {
+				return fieldError(name, problem.FieldInvalid, name+" must be an email address"), false
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("decodeJSON: bad %s rule on %s", rule, name))
+			}
+			size := v.Len()
+			unit := "items"
+			if v.Kind() == reflect.String {
+				size = utf8.RuneCountInString(v.String())
+				unit = "characters"
+			}
+			if rule == "min" && size < n {
+				return fieldError(name, problem.FieldInvalid, fmt.Sprintf("%s must have at least %d %s", name, n, unit)), false
+			}
+			if rule == "max" && size > n {
+				return fieldError(name, problem.FieldTooLong, fmt.Sprintf("%s must have at most %d %s", name, n, unit)), false
+			}
+		case "maxbytes":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("decodeJSON: bad %s rule on %s", rule, name))
+			}
+			if len(v.String()) > n {
+				return fieldError(name, problem.FieldTooLong, fmt.Sprintf("%s must be at most %d bytes", name, n)), false
+			}
+		default:
+			panic(fmt.Sprintf("decodeJSON: unknown validate rule %q on %s", rule, name))
+		}
+	}
+	return problem.FieldError{}, true
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"example.com/chirpy/internal/problem"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type decodeTestAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type decodeTestRequest struct {
+	Email   string             `json:"email" validate:"required,email"`
+	Age     int                `json:"age"`
+	Tags    []string           `json:"tags" validate:"max=2"`
+	UserID  uuid.UUID          `json:"user_id"`
+	Address *decodeTestAddress `json:"address"`
+	Nick    string             `json:"nick"`
+}
+
+// Validate rejects a nick equal to the email, to check that these checks
+// are reported alongside the others.
+func (req decodeTestRequest) Validate() []problem.FieldError {
+	if req.Nick != "" && req.Nick == req.Email {
+		return []problem.FieldError{fieldError("nick", problem.FieldInvalid, "nick must differ from email")}
+	}
+	return nil
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type want struct {
+		status int
+		code   problem.Code
+		fields []string // field:code, in order
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        want
+	}{
+		{
+			name: "valid",
+			body: `{"email":"walt@example.com","age":52,"tags":["a"],"user_id":"3311741c-680c-4546-99f3-fc9efac2036c","address":{"city":"Albuquerque"}}`,
+		},
+		{
+			name:        "json with parameters",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"email":"walt@example.com"}`,
+		},
+		{
+			name:        "json suffix",
+			contentType: "application/merge-patch+json",
+			body:        `{"email":"walt@example.com"}`,
+		},
+		{
+			name:        "no content type",
+			contentType: "-",
+			body:        `{"email":"walt@example.com"}`,
+		},
+		{
+			name: "member names ignore case",
+			body: `{"EMAIL":"walt@example.com"}`,
+		},
+		{
+			name:        "wrong content type",
+			contentType: "text/plain",
+			body:        `{"email":"walt@example.com"}`,
+			want:        want{status: http.StatusUnsupportedMediaType, code: problem.CodeUnsupportedMediaType},
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        `email=walt@example.com`,
+			want:        want{status: http.StatusUnsupportedMediaType, code: problem.CodeUnsupportedMediaType},
+		},
+		{
+			name: "empty body",
+			body: ``,
+			want: want{status: http.StatusBadRequest, code: problem.CodeInvalidRequest},
+		},
+		{
+			name: "malformed",
+			body: `{"email":`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeInvalidRequest},
+		},
+		{
+			name: "syntax error",
+			body: `{"email" "walt@example.com"}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeInvalidRequest},
+		},
+		{
+			name: "trailing data",
+			body: `{"email":"walt@example.com"} {}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeInvalidRequest},
+		},
+		{
+			name: "trailing garbage",
+			body: `{"email":"walt@example.com"}x`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeInvalidRequest},
+		},
+		{
+			name: "array",
+			body: `[{"email":"walt@example.com"}]`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeInvalidRequest},
+		},
+		{
+			name: "null",
+			body: `null`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeInvalidRequest},
+		},
+		{
+			name: "oversized",
+			body: `{"email":"` + strings.Repeat("a", maxRequestBodyBytes) + `"}`,
+			want: want{status: http.StatusRequestEntityTooLarge, code: problem.CodeBodyTooLarge},
+		},
+		{
+			name: "oversized after the object",
+			body: `{"email":"walt@example.com"}` + strings.Repeat(" ", maxRequestBodyBytes),
+			want: want{status: http.StatusRequestEntityTooLarge, code: problem.CodeBodyTooLarge},
+		},
+		{
+			name: "every unknown field",
+			body: `{"email":"walt@example.com","admin":true,"role":"boss"}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeValidationFailed, fields: []string{"admin:unknown", "role:unknown"}},
+		},
+		{
+			name: "every type error",
+			body: `{"email":"walt@example.com","age":"old","tags":"a"}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeValidationFailed, fields: []string{"age:invalid", "tags:invalid"}},
+		},
+		{
+			name: "decode and validation errors together",
+			body: `{"email":"nope","age":1.5,"tags":["a","b","c"],"extra":1}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeValidationFailed, fields: []string{"age:invalid", "extra:unknown", "email:invalid", "tags:too_long"}},
+		},
+		{
+			name: "type error on a required field isn't reported twice",
+			body: `{"email":42}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeValidationFailed, fields: []string{"email:invalid"}},
+		},
+		{
+			name: "missing required field",
+			body: `{}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeValidationFailed, fields: []string{"email:required"}},
+		},
+		{
+			name: "invalid uuid",
+			body: `{"email":"walt@example.com","user_id":"nope"}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeValidationFailed, fields: []string{"user_id:invalid"}},
+		},
+		{
+			name: "nested errors",
+			body: `{"email":"walt@example.com","address":{"city":7,"zip":"87104"}}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeValidationFailed, fields: []string{"address.city:invalid"}},
+		},
+		{
+			name: "nested unknown field",
+			body: `{"email":"walt@example.com","address":{"city":"Albuquerque","zip":"87104"}}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeValidationFailed, fields: []string{"address.zip:unknown"}},
+		},
+		{
+			name: "nested required field",
+			body: `{"email":"walt@example.com","address":{}}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeValidationFailed, fields: []string{"address.city:required"}},
+		},
+		{
+			name: "validator errors with the rest",
+			body: `{"email":"walt@example.com","nick":"walt@example.com","age":"old"}`,
+			want: want{status: http.StatusBadRequest, code: problem.CodeValidationFailed, fields: []string{"age:invalid", "nick:invalid"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			switch tt.contentType {
+			case "":
+				r.Header.Set("Content-Type", "application/json")
+			case "-":
+			default:
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var req decodeTestRequest
+			err := decodeJSON(httptest.NewRecorder(), r, &req)
+			if tt.want.status == 0 {
+				if err != nil {
+					t.Fatalf("got %v, want no error", err)
+				}
+				if req.Email != "walt@example.com" {
+					t.Errorf("decoded %+v", req)
+				}
+				return
+			}
+
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got %v, want an *apiError", err)
+			}
+			if apiErr.status != tt.want.status || apiErr.code != tt.want.code {
+				t.Errorf("got %d %s (%s), want %d %s", apiErr.status, apiErr.code, apiErr.detail, tt.want.status, tt.want.code)
+			}
+			var fields []string
+			for _, fe := range apiErr.fields {
+				fields = append(fields, fe.Field+":"+fe.Code)
+			}
+			if !reflect.DeepEqual(fields, tt.want.fields) {
+				t.Errorf("got fields %v, want %v", fields, tt.want.fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"example.com/chirpy/internal/openapi"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// handlerOpenAPI serves the OpenAPI document.
+func handlerOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	if _, err := w.Write(openapi.JSON()); err != nil {
+		slog.ErrorContext(r.Context(), "writing OpenAPI document", "error", err)
+	}
+}
+
+// handlerDocs renders the OpenAPI document as a standalone HTML page, so
+// the reference doesn't depend on a third-party viewer.
+func handlerDocs(w http.ResponseWriter, r *http.Request) {
+	doc, err := openapi.Load()
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't load the API document", err))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := docsTemplate.Execute(&buf, newDocsPage(doc)); err != nil {
+		respondWithError(w, r, internalError("Couldn't render the API reference", err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.ErrorContext(r.Context(), "writing API reference", "error", err)
+	}
+}
+
+type docsPage struct {
+	Info    openapi.Info
+	Groups  []docsGroup
+	Schemas []docsSchema
+}
+
+type docsGroup struct {
+	Tag        openapi.Tag
+	Operations []docsOperation
+}
+
+type docsOperation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Deprecated  bool
+	Auth        string
+	Parameters  []docsField
+	Body        string
+	Responses   []docsResponse
+}
+
+type docsField struct {
+	Name        string
+	In          string
+	Type        string
+	Required    bool
+	Description string
+}
+
+type docsResponse struct {
+	Status      string
+	Description string
+	Media       string
+	Type        string
+}
+
+type docsSchema struct {
+	Name        string
+	Type        string
+	Description string
+	Fields      []docsField
+}
+
+func newDocsPage(doc *openapi.Document) docsPage {
+	page := docsPage{Info: doc.Info}
+
+	groups := make(map[string]*docsGroup)
+	for _, tag := range doc.Tags {
+		page.Groups = append(page.Groups, docsGroup{Tag: tag})
+	}
+	for i := range page.Groups {
+		groups[page.Groups[i].Tag.Name] = &page.Groups[i]
+	}
+
+	for _, ref := range doc.Operations() {
+		op := ref.Operation
+		o := docsOperation{
+			Method:      ref.Method,
+			Path:        ref.Path,
+			Summary:     op.Summary,
+			Description: op.Description,
+			Deprecated:  op.Deprecated,
+		}
+		for _, req := range op.Security {
+			for name := range req {
+				if scheme := doc.Components.SecuritySchemes[name]; scheme != nil {
+					o.Auth = scheme.Description
+				}
+			}
+		}
+		for _, p := range ref.Parameters {
+			o.Parameters = append(o.Parameters, docsField{
+				Name:        p.Name,
+				In:          p.In,
+				Type:        doc.TypeName(p.Schema),
+				Required:    p.Required,
+				Description: p.Description,
+			})
+		}
+		if body := doc.ResolveRequestBody(op.RequestBody); body != nil {
+			for _, media := range body.Content {
+				o.Body = doc.TypeName(media.Schema)
+				if media.Schema != nil && media.Schema.Ref == "" && media.Schema.Type == "object" {
+					o.Body = "object (" + strings.Join(propertyNames(media.Schema), ", ") + ")"
+				}
+			}
+		}
+
+		statuses := make([]string, 0, len(op.Responses))
+		for status := range op.Responses {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			res := doc.ResolveResponse(op.Responses[status])
+			r := docsResponse{Status: status, Description: res.Description}
+			media := make([]string, 0, len(res.Content))
+			for m := range res.Content {
+				media = append(media, m)
+			}
+			sort.Strings(media)
+			if len(media) > 0 {
+				r.Media = strings.Join(media, ", ")
+				r.Type = doc.TypeName(res.Content[media[0]].Schema)
+			}
+			o.Responses = append(o.Responses, r)
+		}
+
+		tag := ""
+		if len(op.Tags) > 0 {
+			tag = op.Tags[0]
+		}
+		g, ok := groups[tag]
+		if !ok {
+			page.Groups = append(page.Groups, docsGroup{Tag: openapi.Tag{Name: tag}})
+			g = &page.Groups[len(page.Groups)-1]
+			groups[tag] = g
+		}
+		g.Operations = append(g.Operations, o)
+	}
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := doc.Components.Schemas[name]
+		ds := docsSchema{Name: name, Type: doc.TypeName(s), Description: s.Description}
+		required := make(map[string]bool, len(s.Required))
+		for _, r := range s.Required {
+			required[r] = true
+		}
+		for _, prop := range propertyNames(s) {
+			p := s.Properties[prop]
+			ds.Fields = append(ds.Fields, docsField{
+				Name:        prop,
+				Type:        doc.TypeName(p),
+				Required:    required[prop],
+				Description: p.Description,
+			})
+		}
+		page.Schemas = append(page.Schemas, ds)
+	}
+	return page
+}
+
+func propertyNames(s *openapi.Schema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var docsTemplate = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"lower": strings.ToLower,
+	"code":  codeSpans,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Info.Title}} {{.Info.Version}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; color: #222; }
+h2 { border-bottom: 1px solid #ddd; padding-bottom: .2em; margin-top: 2em; }
+.op { margin: 1.5em 0; }
+.op h3 { font-family: ui-monospace, monospace; font-size: 1em; margin-bottom: .3em; }
+.method { display: inline-block; min-width: 4.5em; padding: .1em .4em; border-radius: 3px; color: #fff; text-align: center; }
+.get { background: #2b7bb9; } .post { background: #2f9e44; } .put { background: #e67700; } .patch { background: #9c36b5; } .delete { background: #c92a2a; }
+table { border-collapse: collapse; margin: .5em 0; font-size: .9em; }
+th, td { border: 1px solid #ddd; padding: .25em .6em; text-align: left; vertical-align: top; }
+code { background: #f3f3f3; padding: 0 .2em; }
+.muted { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Info.Title}} <span class="muted">{{.Info.Version}}</span></h1>
+<p>{{code .Info.Description}}</p>
+<p>Machine-readable: <a href="/api/openapi.json">/api/openapi.json</a></p>
+{{range .Groups}}{{if .Operations}}
+<h2 id="{{.Tag.Name}}">{{.Tag.Name}}</h2>
+{{with .Tag.Description}}<p class="muted">{{.}}</p>{{end}}
+{{range .Operations}}
+<div class="op">
+<h3><span class="method {{lower .Method}}">{{.Method}}</span> {{.Path}}{{if .Deprecated}} <span class="muted">deprecated</span>{{end}}</h3>
+<p>{{.Summary}}</p>
+{{with .Description}}<p>{{code .}}</p>{{end}}
+{{with .Auth}}<p class="muted">Authorization: {{code .}}</p>{{end}}
+{{if .Parameters}}<table>
+<tr><th>Parameter</th><th>In</th><th>Type</th><th>Description</th></tr>
+{{range .Parameters}}<tr><td><code>{{.Name}}</code>{{if .Required}} *{{end}}</td><td>{{.In}}</td><td>{{.Type}}</td><td>{{code .Description}}</td></tr>
+{{end}}</table>{{end}}
+{{with .Body}}<p>Request body: <code>{{.}}</code></p>{{end}}
+<table>
+<tr><th>Status</th><th>Description</th><th>Body</th></tr>
+{{range .Responses}}<tr><td>{{.Status}}</td><td>{{.Description}}</td><td>{{if .Type}}<code>{{.Type}}</code> <span class="muted">{{.Media}}</span>{{end}}</td></tr>
+{{end}}</table>
+</div>
+{{end}}{{end}}{{end}}
+<h2 id="schemas">Schemas</h2>
+{{range .Schemas}}
+<h3 id="schema-{{.Name}}">{{.Name}}</h3>
+{{with .Description}}<p>{{code .}}</p>{{end}}
+{{if .Fields}}<table>
+<tr><th>Field</th><th>Type</th><th>Description</th></tr>
+{{range .Fields}}<tr><td><code>{{.Name}}</code>{{if .Required}} *{{end}}</td><td>{{.Type}}</td><td>{{code .Description}}</td></tr>
+{{end}}</table>{{else}}<p><code>{{.Type}}</code></p>{{end}}
+{{end}}
+<p class="muted">* required</p>
+</body>
+</html>
+`))
+
+// codeSpans escapes s and renders the backtick spans the document uses
+// for literals as <code>.
+func codeSpans(s string) template.HTML {
+	parts := strings.Split(template.HTMLEscapeString(s), "`")
+	var b strings.Builder
+	for i, part := range parts {
+		if i%2 == 1 && i < len(parts)-1 {
+			b.WriteString("<code>" + part + "</code>")
+			continue
+		}
+		if i%2 == 1 {
+			b.WriteString("`")
+		}
+		b.WriteString(part)
+	}
+	return template.HTML(b.String())
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"example.com/chirpy/internal/problem"
+	"fmt"
+	"net/http"
+)
+
+// apiError is an error response. err is the underlying cause; it is logged
+// but never sent to the client.
+type apiError struct {
+	status int
+	code   problem.Code
+	detail string
+	fields []problem.FieldError
+	err    error
+}
+
+func (e *apiError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %s: %s", e.code, e.detail, e.err)
+	}
+	return fmt.Sprintf("%s: %s", e.code, e.detail)
+}
+
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+// problem returns the problem document describing e for the request to
+// instance.
+func (e *apiError) problem(instance, requestID string) problem.Problem {
+	return problem.Problem{
+		Type:      problem.TypeURI(e.code),
+		Title:     http.StatusText(e.status),
+		Status:    e.status,
+		Detail:    e.detail,
+		Instance:  instance,
+		Code:      e.code,
+		RequestID: requestID,
+		Errors:    e.fields,
+	}
+}
+
+func newAPIError(status int, code problem.Code, detail string, err error) *apiError {
+	return &apiError{status: status, code: code, detail: detail, err: err}
+}
+
+func badRequest(detail string, err error) *apiError {
+	return newAPIError(http.StatusBadRequest, problem.CodeInvalidRequest, detail, err)
+}
+
+func unauthorized(detail string, err error) *apiError {
+	return newAPIError(http.StatusUnauthorized, problem.CodeUnauthorized, detail, err)
+}
+
+func invalidToken(detail string, err error) *apiError {
+	return newAPIError(http.StatusUnauthorized, problem.CodeInvalidToken, detail, err)
+}
+
+func forbidden(detail string, err error) *apiError {
+	return newAPIError(http.StatusForbidden, problem.CodeForbidden, detail, err)
+}
+
+func notFound(detail string, err error) *apiError {
+	return newAPIError(http.StatusNotFound, problem.CodeNotFound, detail, err)
+}
+
+func conflict(code problem.Code, detail string, err error) *apiError {
+	return newAPIError(http.StatusConflict, code, detail, err)
+}
+
+func internalError(detail string, err error) *apiError {
+	return newAPIError(http.StatusInternalServerError, problem.CodeInternal, detail, err)
+}
+
+// validationFailed reports invalid fields. It returns nil if fields is
+// empty, so callers can collect errors and check the result.
+func validationFailed(fields ...problem.FieldError) *apiError {
+	if len(fields) == 0 {
+		return nil
+	}
+	e := newAPIError(http.StatusBadRequest, problem.CodeValidationFailed, "The request has invalid fields", nil)
+	e.fields = fields
+	return e
+}
+
+func fieldError(field, code, message string) problem.FieldError {
+	return problem.FieldError{Field: field, Code: code, Message: message}
+}
@@ -3,13 +3,19 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"example.com/chirpy/internal/auth"
 	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/store"
+	"example.com/chirpy/internal/webhooks"
+	"fmt"
 	"github.com/google/uuid"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -23,56 +29,123 @@ type Chirp struct {
 
 func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body string `json:"body" validate:"required"`
 	}
 
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		respondWithError(w, r, unauthorized("Couldn't find JWT", err))
 		return
 	}
 	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		respondWithError(w, r, invalidToken("Couldn't validate JWT", err))
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+	if err := decodeJSON(w, r, &params); err != nil {
+		respondWithError(w, r, err)
 		return
 	}
 
-	cleaned, err := validateChirp(params.Body)
+	resChirp, err := cfg.createChirp(r.Context(), userID, params.Body)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		respondWithError(w, r, err)
 		return
 	}
+	respondWithJSON(w, http.StatusCreated, resChirp)
+}
 
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		UserID: userID,
-		Body:   cleaned,
-	})
+// createChirp checks body against the user's limits, stores the chirp
+// with its outgoing events and announces it to streams. The WebSocket API
+// posts chirps through it too.
+func (cfg *apiConfig) createChirp(ctx context.Context, userID uuid.UUID, body string) (Chirp, error) {
+	caps, err := cfg.entitlements.For(ctx, userID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
-		return
+		return Chirp{}, internalError("Couldn't look up entitlements", err)
 	}
 
-	respondWithJSON(w, http.StatusCreated, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		UserID:    chirp.UserID,
-		Body:      chirp.Body,
+	cleaned, err := validateChirp(body, caps.MaxChirpLength)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	var resChirp Chirp
+	var created database.OutboxEvent
+	err = cfg.store.WithTx(ctx, func(tx store.Store) error {
+		chirp, err := tx.Chirps().CreateChirp(ctx, database.CreateChirpParams{
+			UserID: userID,
+			Body:   cleaned,
+		})
+		if err != nil {
+			return err
+		}
+		resChirp = Chirp{
+			ID:        chirp.ID,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+			UserID:    chirp.UserID,
+			Body:      chirp.Body,
+		}
+
+		// Outgoing webhook events are committed together with the chirp
+		created, err = webhooks.Enqueue(ctx, tx.Outbox(), webhooks.EventChirpCreated, userID, resChirp)
+		if err != nil {
+			return err
+		}
+		for _, email := range extractMentions(chirp.Body) {
+			mentioned, err := tx.Users().GetUserByEmail(ctx, email)
+			if errors.Is(err, store.ErrNotFound) || mentioned.ID == userID {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := webhooks.Enqueue(ctx, tx.Outbox(), webhooks.EventMention, mentioned.ID, resChirp); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return Chirp{}, conflict(problem.CodeDuplicateChirp, "Duplicate chirp", err)
+		}
+		return Chirp{}, internalError("Couldn't create chirp", err)
+	}
+
+	cfg.metrics.chirpsCreated.Inc()
+	cfg.announce(ctx, created)
+	return resChirp, nil
 }
 
-func validateChirp(body string) (string, error) {
-	const maxChirpLength = 140
+// extractMentions returns the addresses of users mentioned as "@email" in
+// body, without duplicates.
+func extractMentions(body string) []string {
+	var mentions []string
+	seen := map[string]struct{}{}
+	for _, word := range strings.Fields(body) {
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+		email := strings.TrimRight(strings.TrimPrefix(word, "@"), ".,!?:;)")
+		if !strings.Contains(email, "@") {
+			continue
+		}
+		if _, ok := seen[email]; ok {
+			continue
+		}
+		seen[email] = struct{}{}
+		mentions = append(mentions, email)
+	}
+	return mentions
+}
+
+func validateChirp(body string, maxChirpLength int) (string, error) {
 	if len(body) > maxChirpLength {
-		return "", errors.New("Chirp is too long")
+		return "", validationFailed(fieldError("body", problem.FieldTooLong,
+			fmt.Sprintf("Chirp is too long, the limit is %d characters", maxChirpLength)))
 	}
 
 	badWords := map[string]struct{}{
@@ -85,6 +158,12 @@ func validateChirp(body string) (string, error) {
 }
 
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
+	// Paging is opt-in, so clients that don't ask still get every chirp
+	if q := r.URL.Query(); q.Has("limit") || q.Has("after") {
+		cfg.handlerGetChirpsPage(w, r)
+		return
+	}
+
 	authorID := r.URL.Query().Get("author_id")
 	sort := r.URL.Query().Get("sort")
 
@@ -94,21 +173,21 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 		sortOrder = "DESC"
 	}
 
-	var resChirps []Chirp
+	resChirps := []Chirp{}
 
 	// If the author_id is provided, filter by that author
 	if authorID != "" {
 		// Convert the author_id to a UUID
 		authorUUID, err := uuid.Parse(authorID)
 		if err != nil {
-			http.Error(w, "Invalid author_id", http.StatusBadRequest)
+			respondWithError(w, r, validationFailed(fieldError("author_id", problem.FieldInvalid, "author_id must be a UUID")))
 			return
 		}
 
 		// Get chirps for a specific author
-		chirps, err := cfg.db.GetChirpsByAuthorID(context.Background(), authorUUID)
+		chirps, err := cfg.store.Chirps().GetChirpsByAuthorID(r.Context(), authorUUID)
 		if err != nil {
-			http.Error(w, "Error retrieving chirps", http.StatusInternalServerError)
+			respondWithError(w, r, internalError("Couldn't retrieve chirps", err))
 			return
 		}
 
@@ -125,9 +204,9 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		// Get all chirps
-		chirps, err := cfg.db.GetChirps(r.Context())
+		chirps, err := cfg.store.Chirps().GetChirps(r.Context())
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Could get chirps", err)
+			respondWithError(w, r, internalError("Couldn't retrieve chirps", err))
 			return
 		}
 
@@ -151,6 +230,149 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, resChirps)
 }
 
+const (
+	// chirpsPageSize is the page size of GET /api/chirps when after is
+	// given without limit.
+	chirpsPageSize = 50
+	// maxChirpsPageSize caps limit.
+	maxChirpsPageSize = 100
+)
+
+// chirpsPage is a page request parsed from the limit and after query
+// parameters.
+type chirpsPage struct {
+	limit     int
+	afterTime sql.NullTime
+	afterID   uuid.NullUUID
+}
+
+// parseChirpsPage parses the paging parameters of q, appending any
+// problems to fields.
+func parseChirpsPage(q url.Values, fields []problem.FieldError) (chirpsPage, []problem.FieldError) {
+	p := chirpsPage{limit: chirpsPageSize}
+	if q.Has("limit") {
+		n, err := strconv.Atoi(q.Get("limit"))
+		if err != nil || n < 1 || n > maxChirpsPageSize {
+			fields = append(fields, fieldError("limit", problem.FieldInvalid,
+				fmt.Sprintf("limit must be between 1 and %d", maxChirpsPageSize)))
+		}
+		p.limit = n
+	}
+	if s := q.Get("after"); s != "" {
+		t, id, err := decodeCursor(s)
+		if err != nil {
+			fields = append(fields, fieldError("after", problem.FieldInvalid, "after must be a cursor returned by this API"))
+		}
+		p.afterTime = sql.NullTime{Time: t, Valid: err == nil}
+		p.afterID = uuid.NullUUID{UUID: id, Valid: err == nil}
+	}
+	return p, fields
+}
+
+// respondWithChirpsPage writes chirps, fetched with one row more than
+// p.limit, as a page. If there are more chirps, a Link header with
+// rel="next" gives the URL of the next page.
+func respondWithChirpsPage(w http.ResponseWriter, r *http.Request, p chirpsPage, chirps []database.Chirp) {
+	if len(chirps) > p.limit {
+		chirps = chirps[:p.limit]
+		last := chirps[len(chirps)-1]
+		next := r.URL.Query()
+		next.Set("limit", strconv.Itoa(p.limit))
+		next.Set("after", encodeCursor(last.CreatedAt, last.ID))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+
+	resChirps := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		resChirps = append(resChirps, Chirp{
+			ID:        chirp.ID,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+			Body:      chirp.Body,
+			UserID:    chirp.UserID,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, resChirps)
+}
+
+// handlerGetChirpsPage serves one page of GET /api/chirps, ordered by
+// creation time and then ID.
+func (cfg *apiConfig) handlerGetChirpsPage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var fields []problem.FieldError
+	var authorID uuid.NullUUID
+	if s := q.Get("author_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			fields = append(fields, fieldError("author_id", problem.FieldInvalid, "author_id must be a UUID"))
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: err == nil}
+	}
+	p, fields := parseChirpsPage(q, fields)
+	if err := validationFailed(fields...); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	// One extra row tells whether there is a next page
+	var chirps []database.Chirp
+	var err error
+	if q.Get("sort") == "desc" {
+		chirps, err = cfg.store.Chirps().ListChirps(r.Context(), database.ListChirpsParams{
+			UserID:          authorID,
+			BeforeCreatedAt: p.afterTime,
+			BeforeID:        p.afterID,
+			Limit:           int32(p.limit + 1),
+		})
+	} else {
+		chirps, err = cfg.store.Chirps().ListChirpsOldestFirst(r.Context(), database.ListChirpsOldestFirstParams{
+			UserID:         authorID,
+			AfterCreatedAt: p.afterTime,
+			AfterID:        p.afterID,
+			Limit:          int32(p.limit + 1),
+		})
+	}
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't retrieve chirps", err))
+		return
+	}
+	respondWithChirpsPage(w, r, p, chirps)
+}
+
+// handlerGetTimeline serves one page of the caller's timeline: their own
+// chirps and those of the users they follow, newest first.
+func (cfg *apiConfig) handlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, unauthorized("Unauthorized", err))
+		return
+	}
+	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
+		return
+	}
+
+	p, fields := parseChirpsPage(r.URL.Query(), nil)
+	if err := validationFailed(fields...); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	chirps, err := cfg.store.Chirps().ListTimelineChirps(r.Context(), database.ListTimelineChirpsParams{
+		UserID:          userID,
+		BeforeCreatedAt: p.afterTime,
+		BeforeID:        p.afterID,
+		Limit:           int32(p.limit + 1),
+	})
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't retrieve timeline", err))
+		return
+	}
+	respondWithChirpsPage(w, r, p, chirps)
+}
+
 func sortChirpsByCreatedAt(chirps *[]Chirp, sortOrder string) {
 	sort.SliceStable(*chirps, func(i, j int) bool {
 		createdAtI := (*chirps)[i].CreatedAt
@@ -173,17 +395,17 @@ func (cfg *apiConfig) handlerGetChirpByID(w http.ResponseWriter, r *http.Request
 	// Parse chirpID as UUID
 	chirpID, err := uuid.Parse(chirpIDStr)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		respondWithError(w, r, badRequest("Invalid chirp ID", err))
 		return
 	}
 
 	// Query the chirp by ID
-	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	chirp, err := cfg.store.Chirps().GetChirpByID(r.Context(), chirpID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		if errors.Is(err, store.ErrNotFound) {
+			respondWithError(w, r, notFound("Chirp not found", err))
 		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to retrieve chirp", err)
+			respondWithError(w, r, internalError("Failed to retrieve chirp", err))
 		}
 		return
 	}
@@ -205,14 +427,14 @@ func (cfg *apiConfig) handlerDeleteChirpByID(w http.ResponseWriter, r *http.Requ
 	// Get the bearer token from the Authorization header
 	tokenString, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		respondWithError(w, r, unauthorized("Unauthorized", err))
 		return
 	}
 
 	// Validate the JWT and retrieve the user ID
 	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
 		return
 	}
 
@@ -222,34 +444,53 @@ func (cfg *apiConfig) handlerDeleteChirpByID(w http.ResponseWriter, r *http.Requ
 	// Parse chirpID as UUID
 	chirpID, err := uuid.Parse(chirpIDStr)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		respondWithError(w, r, badRequest("Invalid chirp ID", err))
 		return
 	}
 
-	// Query the chirp by ID
-	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err := cfg.deleteChirp(r.Context(), userID, chirpID); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	// Respond with a 204 No Content status
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteChirp deletes userID's chirp and announces it in the same
+// transaction. GraphQL's deleteChirp mutation uses it too.
+func (cfg *apiConfig) deleteChirp(ctx context.Context, userID, chirpID uuid.UUID) error {
+	chirp, err := cfg.store.Chirps().GetChirpByID(ctx, chirpID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			respondWithError(w, http.StatusNotFound, "Chirp not found", err)
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to retrieve chirp", err)
+		if errors.Is(err, store.ErrNotFound) {
+			return notFound("Chirp not found", err)
 		}
-		return
+		return internalError("Failed to retrieve chirp", err)
 	}
 
 	// Validate that the user is the author of the chirp
 	if chirp.UserID != userID {
-		respondWithError(w, http.StatusForbidden, "Unauthorized", err)
-		return
+		return forbidden("You can only delete your own chirps", nil)
 	}
 
-	// Map the chirp from the database to the API response format
-	err = cfg.db.DeleteChirp(context.Background(), chirpID)
+	// Delete the chirp and announce it in the same transaction
+	var deleted database.OutboxEvent
+	err = cfg.store.WithTx(ctx, func(tx store.Store) error {
+		if err := tx.Chirps().DeleteChirp(ctx, chirpID); err != nil {
+			return err
+		}
+		deleted, err = webhooks.Enqueue(ctx, tx.Outbox(), webhooks.EventChirpDeleted, userID, Chirp{
+			ID:        chirp.ID,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+			Body:      chirp.Body,
+			UserID:    chirp.UserID,
+		})
+		return err
+	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not delete chirp", err)
-		return
+		return internalError("Could not delete chirp", err)
 	}
-
-	// Respond with a 204 No Content status
-	w.WriteHeader(http.StatusNoContent)
+	cfg.announce(ctx, deleted)
+	return nil
 }
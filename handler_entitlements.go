@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/entitlements"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/store"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// isChirpyRed reports whether the user's effective tier is Chirpy Red.
+func (cfg *apiConfig) isChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	caps, err := cfg.entitlements.For(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return caps.Tier == entitlements.TierChirpyRed, nil
+}
+
+func (cfg *apiConfig) handlerGetMyEntitlements(w http.ResponseWriter, r *http.Request) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, unauthorized("Unauthorized", err))
+		return
+	}
+	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
+		return
+	}
+
+	caps, err := cfg.entitlements.For(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't look up entitlements", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, caps)
+}
+
+// EntitlementsResponse is returned by the admin entitlements endpoints.
+type EntitlementsResponse struct {
+	UserID       uuid.UUID                 `json:"user_id"`
+	Override     *entitlements.Override    `json:"override"`
+	Capabilities entitlements.Capabilities `json:"capabilities"`
+}
+
+func (cfg *apiConfig) handlerAdminGetEntitlements(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.authenticateAdmin(w, r); !ok {
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, badRequest("Invalid user ID", err))
+		return
+	}
+
+	cfg.respondWithEntitlements(w, r, userID)
+}
+
+func (cfg *apiConfig) handlerAdminSetEntitlements(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.authenticateAdmin(w, r); !ok {
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, badRequest("Invalid user ID", err))
+		return
+	}
+
+	var override entitlements.Override
+	if err := decodeJSON(w, r, &override); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if err := validationFailed(overrideFieldErrors(cfg.entitlements.Validate(override))...); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	if _, err := cfg.store.Users().GetUserByID(r.Context(), userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondWithError(w, r, notFound("User not found", err))
+			return
+		}
+		respondWithError(w, r, internalError("Couldn't retrieve user", err))
+		return
+	}
+
+	_, err = cfg.store.Entitlements().UpsertEntitlementOverride(r.Context(), entitlements.OverrideParams(userID, override))
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't save override", err))
+		return
+	}
+
+	cfg.respondWithEntitlements(w, r, userID)
+}
+
+// overrideFieldErrors converts the errors from entitlements.Validate into
+// field errors.
+func overrideFieldErrors(err error) []problem.FieldError {
+	var fields []problem.FieldError
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		return nil
+	}
+	for _, err := range joined.Unwrap() {
+		var fe *entitlements.FieldError
+		if errors.As(err, &fe) {
+			fields = append(fields, fieldError(fe.Field, problem.FieldInvalid, fe.Message))
+		}
+	}
+	return fields
+}
+
+func (cfg *apiConfig) handlerAdminDeleteEntitlements(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.authenticateAdmin(w, r); !ok {
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, badRequest("Invalid user ID", err))
+		return
+	}
+
+	if err := cfg.store.Entitlements().DeleteEntitlementOverride(r.Context(), userID); err != nil {
+		respondWithError(w, r, internalError("Couldn't delete override", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) respondWithEntitlements(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	caps, err := cfg.entitlements.For(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't look up entitlements", err))
+		return
+	}
+
+	resp := EntitlementsResponse{
+		UserID:       userID,
+		Capabilities: caps,
+	}
+	row, err := cfg.store.Entitlements().GetEntitlementOverride(r.Context(), userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, internalError("Couldn't look up override", err))
+		return
+	}
+	if err == nil {
+		override := entitlements.OverrideFromRow(row)
+		resp.Override = &override
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/entitlements"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminSetEntitlementsFieldErrors(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	admin, err := s.Users().CreateUser(ctx, database.CreateUserParams{Email: "gus@example.com", HashedPassword: "unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Roles().GrantRole(ctx, database.GrantRoleParams{UserID: admin.ID, Role: roleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &apiConfig{
+		store:        s,
+		jwtSecret:    "test-secret",
+		entitlements: entitlements.New(entitlements.NewRepositoryStore(s), nil),
+	}
+	token, err := auth.MakeJWT(admin.ID, cfg.jwtSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"tier":"platinum","max_chirp_length":0,"max_media_per_chirp":-1,"rate_limit_tier":"unlimited"}`
+	r := httptest.NewRequest(http.MethodPut, "/admin/users/"+admin.ID.String()+"/entitlements", strings.NewReader(body))
+	r.SetPathValue("userID", admin.ID.String())
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	cfg.handlerAdminSetEntitlements(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	var p problem.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Code != problem.CodeValidationFailed {
+		t.Errorf("got code %q, want %q", p.Code, problem.CodeValidationFailed)
+	}
+	want := []problem.FieldError{
+		{Field: "tier", Code: problem.FieldInvalid, Message: `unknown tier "platinum"`},
+		{Field: "max_chirp_length", Code: problem.FieldInvalid, Message: "max_chirp_length must be at least 1"},
+		{Field: "max_media_per_chirp", Code: problem.FieldInvalid, Message: "max_media_per_chirp cannot be negative"},
+		{Field: "rate_limit_tier", Code: problem.FieldInvalid, Message: `unknown rate_limit_tier "unlimited"`},
+	}
+	if !reflect.DeepEqual(p.Errors, want) {
+		t.Errorf("got %+v, want %+v", p.Errors, want)
+	}
+	if _, err := s.Entitlements().GetEntitlementOverride(ctx, admin.ID); err == nil {
+		t.Error("invalid override was saved")
+	}
+}
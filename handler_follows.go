@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store"
+	"example.com/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+// FollowEvent is the payload of a user.followed webhook event.
+type FollowEvent struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, unauthorized("Unauthorized", err))
+		return
+	}
+	followerID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, badRequest("Invalid user ID", err))
+		return
+	}
+	if err := cfg.followUser(r.Context(), followerID, followeeID); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, unauthorized("Unauthorized", err))
+		return
+	}
+	followerID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, badRequest("Invalid user ID", err))
+		return
+	}
+
+	if err := cfg.unfollowUser(r.Context(), followerID, followeeID); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// followUser makes followerID follow followeeID, enqueueing a
+// user.followed event the first time. GraphQL's followUser mutation uses
+// it too.
+func (cfg *apiConfig) followUser(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	if followeeID == followerID {
+		return badRequest("You can't follow yourself", nil)
+	}
+
+	err := cfg.store.WithTx(ctx, func(tx store.Store) error {
+		if _, err := tx.Users().GetUserByID(ctx, followeeID); err != nil {
+			return err
+		}
+		inserted, err := tx.Follows().FollowUser(ctx, database.FollowUserParams{
+			FollowerID: followerID,
+			FolloweeID: followeeID,
+		})
+		if err != nil || !inserted {
+			return err
+		}
+		_, err = webhooks.Enqueue(ctx, tx.Outbox(), webhooks.EventUserFollowed, followeeID, FollowEvent{
+			FollowerID: followerID,
+			FolloweeID: followeeID,
+			CreatedAt:  time.Now().UTC(),
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return notFound("User not found", err)
+		}
+		return internalError("Couldn't follow user", err)
+	}
+	return nil
+}
+
+// unfollowUser stops followerID following followeeID. Unfollowing someone
+// who isn't followed is not an error.
+func (cfg *apiConfig) unfollowUser(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	err := cfg.store.Follows().UnfollowUser(ctx, database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		return internalError("Couldn't unfollow user", err)
+	}
+	return nil
+}
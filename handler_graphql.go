@@ -0,0 +1,689 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/graphql"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/store"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// graphQLPageSize is the page size of connections when first is
+	// omitted.
+	graphQLPageSize = 20
+	// graphQLMaxPageSize caps first.
+	graphQLMaxPageSize = 100
+)
+
+// graphQLRequest is the state resolvers share for one request.
+type graphQLRequest struct {
+	userID    uuid.UUID // uuid.Nil for anonymous requests
+	users     *graphql.Loader[uuid.UUID, database.User]
+	reactions *graphql.Loader[uuid.UUID, []database.CountReactionsRow]
+}
+
+type graphQLRequestKey struct{}
+
+func graphQLRequestFrom(ctx context.Context) *graphQLRequest {
+	return ctx.Value(graphQLRequestKey{}).(*graphQLRequest)
+}
+
+// viewer returns the signed-in user's ID, or an error for anonymous
+// requests.
+func (req *graphQLRequest) viewer() (uuid.UUID, error) {
+	if req.userID == uuid.Nil {
+		return uuid.Nil, unauthorized("An access token is required", nil)
+	}
+	return req.userID, nil
+}
+
+// handlerGraphQL runs a GraphQL request sent as JSON with POST, or as
+// query parameters with GET. The access token is optional, but fields
+// that act for a user need it. Errors in the query are reported in the
+// response with status 200, as GraphQL clients expect.
+func (cfg *apiConfig) handlerGraphQL(w http.ResponseWriter, r *http.Request) {
+	var userID uuid.UUID
+	if r.Header.Get("Authorization") != "" {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, r, unauthorized("Couldn't find JWT", err))
+			return
+		}
+		userID, err = auth.ValidateJWT(token, cfg.jwtSecret)
+		if err != nil {
+			respondWithError(w, r, invalidToken("Couldn't validate JWT", err))
+			return
+		}
+	}
+
+	req, err := decodeGraphQLRequest(w, r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), graphQLRequestKey{}, &graphQLRequest{
+		userID:    userID,
+		users:     graphql.NewLoader(cfg.loadUsers),
+		reactions: graphql.NewLoader(cfg.loadReactions(userID)),
+	})
+	respondWithJSON(w, http.StatusOK, cfg.graphql.Execute(ctx, req))
+}
+
+func decodeGraphQLRequest(w http.ResponseWriter, r *http.Request) (graphql.Request, error) {
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req := graphql.Request{
+			Query:         q.Get("query"),
+			OperationName: q.Get("operationName"),
+			ReadOnly:      true,
+		}
+		if req.Query == "" {
+			return req, validationFailed(fieldError("query", problem.FieldRequired, "query is required"))
+		}
+		if vars := q.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				return req, validationFailed(fieldError("variables", problem.FieldInvalid, "variables must be a JSON object"))
+			}
+		}
+		return req, nil
+	}
+
+	// Extensions, such as persisted query hashes, are accepted and ignored
+	var params struct {
+		Query         string         `json:"query" validate:"required"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+		Extensions    map[string]any `json:"extensions"`
+	}
+	if err := decodeJSON(w, r, &params); err != nil {
+		return graphql.Request{}, err
+	}
+	return graphql.Request{
+		Query:         params.Query,
+		OperationName: params.OperationName,
+		Variables:     params.Variables,
+	}, nil
+}
+
+// handlerGraphQLSchema serves the schema in the GraphQL schema definition
+// language, for client code generators.
+func (cfg *apiConfig) handlerGraphQLSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(cfg.graphql.Schema.SDL()))
+}
+
+// graphQLError reports a resolver error the way the REST API would: the
+// message is the problem detail and the code its problem code.
+func graphQLError(ctx context.Context, err error) *graphql.Error {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		apiErr = internalError("Internal server error", err)
+	}
+	if apiErr.status > 499 {
+		slog.ErrorContext(ctx, "graphql resolver failed", "status", apiErr.status, "error", apiErr)
+	}
+	ext := map[string]any{"code": apiErr.code, "status": apiErr.status}
+	if len(apiErr.fields) > 0 {
+		ext["errors"] = apiErr.fields
+	}
+	return &graphql.Error{Message: apiErr.detail, Extensions: ext}
+}
+
+// loadUsers fetches users for the request's user loader, so that the
+// authors of a page of chirps are looked up in one query.
+func (cfg *apiConfig) loadUsers(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]database.User, error) {
+	users, err := cfg.store.Users().GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, internalError("Couldn't look up users", err)
+	}
+	byID := make(map[uuid.UUID]database.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	return byID, nil
+}
+
+// connection is a page of a Relay-style connection. Nodes may be Thunks.
+type connection struct {
+	edges   []edge
+	hasNext bool
+}
+
+type edge struct {
+	cursor string
+	node   any
+}
+
+// pageRequest is a connection's first and after arguments, with after
+// decoded into the (created_at, id) position to continue from.
+type pageRequest struct {
+	first        int
+	afterTime    sql.NullTime
+	afterID      uuid.NullUUID
+	fetchedLimit int32
+}
+
+func parsePage(args map[string]any) (pageRequest, error) {
+	p := pageRequest{first: args["first"].(int)}
+	if p.first < 0 || p.first > graphQLMaxPageSize {
+		return p, validationFailed(fieldError("first", problem.FieldInvalid,
+			"first must be between 0 and "+strconv.Itoa(graphQLMaxPageSize)))
+	}
+	// One extra row tells whether there is a next page
+	p.fetchedLimit = int32(p.first + 1)
+
+	if after, ok := args["after"].(string); ok {
+		t, id, err := decodeCursor(after)
+		if err != nil {
+			return p, validationFailed(fieldError("after", problem.FieldInvalid, "after must be a cursor returned by this API"))
+		}
+		p.afterTime = sql.NullTime{Time: t, Valid: true}
+		p.afterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	return p, nil
+}
+
+// page builds the connection for rows fetched with p.fetchedLimit.
+func page[T any](p pageRequest, rows []T, cursor func(T) (time.Time, uuid.UUID), node func(T) any) *connection {
+	conn := &connection{hasNext: len(rows) > p.first}
+	for _, row := range rows[:min(len(rows), p.first)] {
+		conn.edges = append(conn.edges, edge{cursor: encodeCursor(cursor(row)), node: node(row)})
+	}
+	return conn
+}
+
+// Cursors are opaque to clients; they hold the position of the last row
+// of a page in the (created_at, id) order connections are sorted by.
+func encodeCursor(t time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.UTC().Format(time.RFC3339Nano) + " " + id.String()))
+}
+
+func decodeCursor(s string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	ts, idStr, ok := strings.Cut(string(raw), " ")
+	if !ok {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	id, err := uuid.Parse(idStr)
+	return t, id, err
+}
+
+func chirpCursor(c database.Chirp) (time.Time, uuid.UUID) { return c.CreatedAt, c.ID }
+func chirpNode(c database.Chirp) any                      { return c }
+
+// chirpConnection pages through chirps newest first, optionally only
+// authorID's.
+func (cfg *apiConfig) chirpConnection(ctx context.Context, args map[string]any, authorID uuid.NullUUID) (any, error) {
+	p, err := parsePage(args)
+	if err != nil {
+		return nil, err
+	}
+	chirps, err := cfg.store.Chirps().ListChirps(ctx, database.ListChirpsParams{
+		UserID:          authorID,
+		BeforeCreatedAt: p.afterTime,
+		BeforeID:        p.afterID,
+		Limit:           p.fetchedLimit,
+	})
+	if err != nil {
+		return nil, internalError("Couldn't retrieve chirps", err)
+	}
+	return page(p, chirps, chirpCursor, chirpNode), nil
+}
+
+// timelineConnection pages through the chirps of userID and the users
+// they follow, newest first.
+func (cfg *apiConfig) timelineConnection(ctx context.Context, args map[string]any, userID uuid.UUID) (any, error) {
+	p, err := parsePage(args)
+	if err != nil {
+		return nil, err
+	}
+	chirps, err := cfg.store.Chirps().ListTimelineChirps(ctx, database.ListTimelineChirpsParams{
+		UserID:          userID,
+		BeforeCreatedAt: p.afterTime,
+		BeforeID:        p.afterID,
+		Limit:           p.fetchedLimit,
+	})
+	if err != nil {
+		return nil, internalError("Couldn't retrieve timeline", err)
+	}
+	return page(p, chirps, chirpCursor, chirpNode), nil
+}
+
+// followConnection pages through userID's followers, or the users they
+// follow, most recently followed first. The users are loaded in a batch.
+func (cfg *apiConfig) followConnection(ctx context.Context, args map[string]any, userID uuid.UUID, followers bool) (any, error) {
+	p, err := parsePage(args)
+	if err != nil {
+		return nil, err
+	}
+	users := graphQLRequestFrom(ctx).users
+	type follow struct {
+		userID    uuid.UUID
+		createdAt time.Time
+	}
+	var rows []follow
+	if followers {
+		res, err := cfg.store.Follows().ListFollowers(ctx, database.ListFollowersParams{
+			UserID:          userID,
+			BeforeCreatedAt: p.afterTime,
+			BeforeID:        p.afterID,
+			Limit:           p.fetchedLimit,
+		})
+		if err != nil {
+			return nil, internalError("Couldn't list followers", err)
+		}
+		for _, row := range res {
+			rows = append(rows, follow{row.FollowerID, row.CreatedAt})
+		}
+	} else {
+		res, err := cfg.store.Follows().ListFollowees(ctx, database.ListFolloweesParams{
+			UserID:          userID,
+			BeforeCreatedAt: p.afterTime,
+			BeforeID:        p.afterID,
+			Limit:           p.fetchedLimit,
+		})
+		if err != nil {
+			return nil, internalError("Couldn't list followed users", err)
+		}
+		for _, row := range res {
+			rows = append(rows, follow{row.FolloweeID, row.CreatedAt})
+		}
+	}
+	return page(p, rows,
+		func(f follow) (time.Time, uuid.UUID) { return f.createdAt, f.userID },
+		func(f follow) any { return users.Load(ctx, f.userID) },
+	), nil
+}
+
+// parseID parses an ID argument as a UUID.
+func parseID(args map[string]any, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(args[name].(string))
+	if err != nil {
+		return uuid.Nil, validationFailed(fieldError(name, problem.FieldInvalid, name+" must be a UUID"))
+	}
+	return id, nil
+}
+
+// newGraphQLExecutor builds the schema served at /api/graphql.
+func (cfg *apiConfig) newGraphQLExecutor(maxDepth, maxComplexity int) (*graphql.Executor, error) {
+	pageArgs := func(args ...*graphql.Argument) []*graphql.Argument {
+		return append([]*graphql.Argument{
+			{Name: "first", Type: graphql.Int, Default: graphQLPageSize, Description: "How many edges to return, at most " + strconv.Itoa(graphQLMaxPageSize)},
+			{Name: "after", Type: graphql.String, Description: "The endCursor of the previous page"},
+		}, args...)
+	}
+	pageSize := func(args map[string]any) int {
+		n, _ := args["first"].(int)
+		return n
+	}
+
+	var userType, chirpType *graphql.Object
+	reactionType := &graphql.Object{
+		Name: "Reaction",
+		Fields: func() []*graphql.Field {
+			return []*graphql.Field{
+				{Name: "kind", Type: graphql.NewNonNull(graphql.String), Description: "One of " + strings.Join(reactionKinds, ", "), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.CountReactionsRow).Kind, nil
+				}},
+				{Name: "count", Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return int(p.Source.(database.CountReactionsRow).Count), nil
+				}},
+				{Name: "viewerHasReacted", Type: graphql.NewNonNull(graphql.Boolean), Description: "Whether the signed-in user reacted with this kind; false for anonymous requests", Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.CountReactionsRow).ViewerReacted, nil
+				}},
+			}
+		},
+	}
+	pageInfoType := &graphql.Object{
+		Name: "PageInfo",
+		Fields: func() []*graphql.Field {
+			return []*graphql.Field{
+				{Name: "hasNextPage", Type: graphql.NewNonNull(graphql.Boolean), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*connection).hasNext, nil
+				}},
+				{Name: "endCursor", Type: graphql.String, Description: "Pass as after to get the next page; null if the page is empty", Resolve: func(p graphql.ResolveParams) (any, error) {
+					conn := p.Source.(*connection)
+					if len(conn.edges) == 0 {
+						return nil, nil
+					}
+					return conn.edges[len(conn.edges)-1].cursor, nil
+				}},
+			}
+		},
+	}
+	connectionType := func(name string, node func() *graphql.Object) *graphql.Object {
+		edgeType := &graphql.Object{
+			Name: name + "Edge",
+			Fields: func() []*graphql.Field {
+				return []*graphql.Field{
+					{Name: "cursor", Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(edge).cursor, nil
+					}},
+					{Name: "node", Type: graphql.NewNonNull(node()), Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(edge).node, nil
+					}},
+				}
+			},
+		}
+		return &graphql.Object{
+			Name: name + "Connection",
+			Fields: func() []*graphql.Field {
+				return []*graphql.Field{
+					{Name: "edges", Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType))), Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source.(*connection).edges, nil
+					}},
+					{Name: "pageInfo", Type: graphql.NewNonNull(pageInfoType), Resolve: func(p graphql.ResolveParams) (any, error) {
+						return p.Source, nil
+					}},
+				}
+			},
+		}
+	}
+	chirpConnectionType := connectionType("Chirp", func() *graphql.Object { return chirpType })
+	userConnectionType := connectionType("User", func() *graphql.Object { return userType })
+
+	userType = &graphql.Object{
+		Name: "User",
+		Fields: func() []*graphql.Field {
+			return []*graphql.Field{
+				{Name: "id", Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.User).ID, nil
+				}},
+				{Name: "createdAt", Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.User).CreatedAt, nil
+				}},
+				{Name: "updatedAt", Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.User).UpdatedAt, nil
+				}},
+				{Name: "email", Type: graphql.String, Description: "Only visible to the user themselves", Resolve: func(p graphql.ResolveParams) (any, error) {
+					u := p.Source.(database.User)
+					if graphQLRequestFrom(p.Context).userID != u.ID {
+						return nil, nil
+					}
+					return u.Email, nil
+				}},
+				{Name: "chirps", Type: graphql.NewNonNull(chirpConnectionType), Description: "The user's chirps, newest first", Args: pageArgs(), Multiplier: pageSize, Resolve: func(p graphql.ResolveParams) (any, error) {
+					u := p.Source.(database.User)
+					return cfg.chirpConnection(p.Context, p.Args, uuid.NullUUID{UUID: u.ID, Valid: true})
+				}},
+				{Name: "followers", Type: graphql.NewNonNull(userConnectionType), Description: "Users following this user, most recent first.", Args: pageArgs(), Multiplier: pageSize, Resolve: func(p graphql.ResolveParams) (any, error) {
+					return cfg.followConnection(p.Context, p.Args, p.Source.(database.User).ID, true)
+				}},
+				{Name: "following", Type: graphql.NewNonNull(userConnectionType), Description: "Users this user follows, most recent first.", Args: pageArgs(), Multiplier: pageSize, Resolve: func(p graphql.ResolveParams) (any, error) {
+					return cfg.followConnection(p.Context, p.Args, p.Source.(database.User).ID, false)
+				}},
+			}
+		},
+	}
+
+	chirpType = &graphql.Object{
+		Name: "Chirp",
+		Fields: func() []*graphql.Field {
+			return []*graphql.Field{
+				{Name: "id", Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.Chirp).ID, nil
+				}},
+				{Name: "createdAt", Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.Chirp).CreatedAt, nil
+				}},
+				{Name: "updatedAt", Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.Chirp).UpdatedAt, nil
+				}},
+				{Name: "body", Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.Chirp).Body, nil
+				}},
+				{Name: "authorId", Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(database.Chirp).UserID, nil
+				}},
+				{Name: "author", Type: graphql.NewNonNull(userType), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return graphQLRequestFrom(p.Context).users.Load(p.Context, p.Source.(database.Chirp).UserID), nil
+				}},
+				{Name: "reactions", Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(reactionType))), Description: "Reaction counts by kind, most used first", Resolve: func(p graphql.ResolveParams) (any, error) {
+					return graphQLRequestFrom(p.Context).reactions.Load(p.Context, p.Source.(database.Chirp).ID), nil
+				}},
+			}
+		},
+	}
+
+	idArg := func(description string) []*graphql.Argument {
+		return []*graphql.Argument{{Name: "id", Type: graphql.NewNonNull(graphql.ID), Description: description}}
+	}
+	reactionArgs := []*graphql.Argument{
+		{Name: "chirpId", Type: graphql.NewNonNull(graphql.ID), Description: "The chirp's ID"},
+		{Name: "kind", Type: graphql.NewNonNull(graphql.String), Description: "One of " + strings.Join(reactionKinds, ", ")},
+	}
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: func() []*graphql.Field {
+			return []*graphql.Field{
+				{Name: "me", Type: userType, Description: "The signed-in user", Resolve: func(p graphql.ResolveParams) (any, error) {
+					req := graphQLRequestFrom(p.Context)
+					userID, err := req.viewer()
+					if err != nil {
+						return nil, err
+					}
+					return req.users.Load(p.Context, userID), nil
+				}},
+				{Name: "user", Type: userType, Args: idArg("The user's ID"), Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, err := parseID(p.Args, "id")
+					if err != nil {
+						return nil, err
+					}
+					return graphQLRequestFrom(p.Context).users.Load(p.Context, id), nil
+				}},
+				{Name: "chirp", Type: chirpType, Args: idArg("The chirp's ID"), Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, err := parseID(p.Args, "id")
+					if err != nil {
+						return nil, err
+					}
+					chirp, err := cfg.store.Chirps().GetChirpByID(p.Context, id)
+					if errors.Is(err, store.ErrNotFound) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, internalError("Failed to retrieve chirp", err)
+					}
+					return chirp, nil
+				}},
+				{
+					Name:        "chirps",
+					Type:        graphql.NewNonNull(chirpConnectionType),
+					Description: "Chirps, newest first",
+					Args:        pageArgs(&graphql.Argument{Name: "authorId", Type: graphql.ID, Description: "Only chirps by this user"}),
+					Multiplier:  pageSize,
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						var authorID uuid.NullUUID
+						if _, ok := p.Args["authorId"].(string); ok {
+							id, err := parseID(p.Args, "authorId")
+							if err != nil {
+								return nil, err
+							}
+							authorID = uuid.NullUUID{UUID: id, Valid: true}
+						}
+						return cfg.chirpConnection(p.Context, p.Args, authorID)
+					},
+				},
+				{
+					Name:        "timeline",
+					Type:        graphql.NewNonNull(chirpConnectionType),
+					Description: "Chirps by the signed-in user and the users they follow, newest first.",
+					Args:        pageArgs(),
+					Multiplier:  pageSize,
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						userID, err := graphQLRequestFrom(p.Context).viewer()
+						if err != nil {
+							return nil, err
+						}
+						return cfg.timelineConnection(p.Context, p.Args, userID)
+					},
+				},
+			}
+		},
+	}
+
+	mutation := &graphql.Object{
+		Name: "Mutation",
+		Fields: func() []*graphql.Field {
+			return []*graphql.Field{
+				{
+					Name:        "createChirp",
+					Type:        graphql.NewNonNull(chirpType),
+					Description: "Posts a chirp as the signed-in user, under the same limits as POST /api/chirps.",
+					Args:        []*graphql.Argument{{Name: "body", Type: graphql.NewNonNull(graphql.String)}},
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						userID, err := graphQLRequestFrom(p.Context).viewer()
+						if err != nil {
+							return nil, err
+						}
+						tier := cfg.rateLimitTier(p.Context, userID)
+						if res, _, ok := cfg.takeRateLimit(p.Context, rateLimitChirpsCreate, "user:"+userID.String(), tier); ok && !res.Allowed {
+							return nil, rateLimitExceeded(res)
+						}
+						chirp, err := cfg.createChirp(p.Context, userID, p.Args["body"].(string))
+						if err != nil {
+							return nil, err
+						}
+						return database.Chirp(chirp), nil
+					},
+				},
+				{
+					Name:        "deleteChirp",
+					Type:        graphql.NewNonNull(graphql.ID),
+					Description: "Deletes one of the signed-in user's chirps and returns its ID.",
+					Args:        idArg("The chirp's ID"),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						userID, err := graphQLRequestFrom(p.Context).viewer()
+						if err != nil {
+							return nil, err
+						}
+						chirpID, err := parseID(p.Args, "id")
+						if err != nil {
+							return nil, err
+						}
+						if err := cfg.deleteChirp(p.Context, userID, chirpID); err != nil {
+							return nil, err
+						}
+						return chirpID, nil
+					},
+				},
+				{
+					Name:        "followUser",
+					Type:        graphql.NewNonNull(userType),
+					Description: "Follows a user and returns them.",
+					Args:        idArg("The user to follow"),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return cfg.resolveFollow(p, cfg.followUser)
+					},
+				},
+				{
+					Name:        "unfollowUser",
+					Type:        graphql.NewNonNull(userType),
+					Description: "Stops following a user and returns them.",
+					Args:        idArg("The user to unfollow"),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return cfg.resolveFollow(p, cfg.unfollowUser)
+					},
+				},
+				{
+					Name:        "addReaction",
+					Type:        graphql.NewNonNull(chirpType),
+					Description: "Reacts to a chirp as the signed-in user and returns the chirp.",
+					Args:        reactionArgs,
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return cfg.resolveReaction(p, cfg.addReaction)
+					},
+				},
+				{
+					Name:        "removeReaction",
+					Type:        graphql.NewNonNull(chirpType),
+					Description: "Takes back the signed-in user's reaction to a chirp and returns the chirp.",
+					Args:        reactionArgs,
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return cfg.resolveReaction(p, cfg.removeReaction)
+					},
+				},
+			}
+		},
+	}
+
+	schema, err := graphql.NewSchema(query, mutation)
+	if err != nil {
+		return nil, err
+	}
+	return &graphql.Executor{
+		Schema:        schema,
+		MaxDepth:      maxDepth,
+		MaxComplexity: maxComplexity,
+		FormatError:   graphQLError,
+	}, nil
+}
+
+// resolveFollow runs the followUser or unfollowUser mutation with fn and
+// returns the other user.
+func (cfg *apiConfig) resolveFollow(p graphql.ResolveParams, fn func(ctx context.Context, followerID, followeeID uuid.UUID) error) (any, error) {
+	req := graphQLRequestFrom(p.Context)
+	userID, err := req.viewer()
+	if err != nil {
+		return nil, err
+	}
+	followeeID, err := parseID(p.Args, "id")
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(p.Context, userID, followeeID); err != nil {
+		return nil, err
+	}
+	return req.users.Load(p.Context, followeeID), nil
+}
+
+// resolveReaction runs the addReaction or removeReaction mutation with fn
+// and returns the chirp, with its reactions reloaded.
+func (cfg *apiConfig) resolveReaction(p graphql.ResolveParams, fn func(ctx context.Context, userID, chirpID uuid.UUID, kind string) error) (any, error) {
+	req := graphQLRequestFrom(p.Context)
+	userID, err := req.viewer()
+	if err != nil {
+		return nil, err
+	}
+	chirpID, err := parseID(p.Args, "chirpId")
+	if err != nil {
+		return nil, err
+	}
+	kind, err := parseReactionKind(p.Args["kind"].(string))
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(p.Context, userID, chirpID, kind); err != nil {
+		return nil, err
+	}
+	chirp, err := cfg.store.Chirps().GetChirpByID(p.Context, chirpID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, notFound("Chirp not found", err)
+	}
+	if err != nil {
+		return nil, internalError("Failed to retrieve chirp", err)
+	}
+	req.reactions.Clear(chirpID)
+	return chirp, nil
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/stream"
+	"example.com/chirpy/internal/websocket"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// socketPingInterval is how often the server pings a socket. A client
+	// that sends nothing, not even a pong, for twice as long is dropped.
+	socketPingInterval = 30 * time.Second
+	// socketWriteTimeout bounds each write to a socket.
+	socketWriteTimeout = 10 * time.Second
+	// socketQueue is how many messages may wait to be written to a socket
+	// before its client is considered too slow and disconnected.
+	socketQueue = 256
+	// socketMaxMessageBytes caps the messages clients send.
+	socketMaxMessageBytes = 64 << 10
+	// socketMaxTopics caps the subscriptions on one socket.
+	socketMaxTopics = 32
+)
+
+// closeTokenExpired closes a socket when its access token expires. The
+// client should refresh the token and reconnect.
+const closeTokenExpired = 4001
+
+// Message types of the WebSocket API.
+const (
+	socketPing        = "ping"
+	socketSubscribe   = "subscribe"
+	socketUnsubscribe = "unsubscribe"
+	socketCreateChirp = "create_chirp"
+
+	socketReady        = "ready"
+	socketPong         = "pong"
+	socketSubscribed   = "subscribed"
+	socketUnsubscribed = "unsubscribed"
+	socketEvent        = "event"
+	socketCreated      = "created"
+	socketError        = "error"
+)
+
+// socketRequest is a message from the client. Ref, if set, is echoed in
+// the reply so the client can match them up.
+type socketRequest struct {
+	Type        string `json:"type" validate:"required"`
+	Ref         string `json:"ref" validate:"max=64"`
+	Topic       string `json:"topic" validate:"max=128"`
+	LastEventID string `json:"last_event_id"`
+	Body        string `json:"body"`
+}
+
+// socketMessage is a message to the client. Type says which other fields
+// are set.
+type socketMessage struct {
+	Type      string           `json:"type"`
+	Ref       string           `json:"ref,omitempty"`
+	UserID    *uuid.UUID       `json:"user_id,omitempty"`
+	ExpiresAt *time.Time       `json:"expires_at,omitempty"`
+	Topic     string           `json:"topic,omitempty"`
+	Reset     bool             `json:"reset,omitempty"`
+	ID        *uuid.UUID       `json:"id,omitempty"`
+	Event     string           `json:"event,omitempty"`
+	Data      any              `json:"data,omitempty"`
+	Error     *problem.Problem `json:"error,omitempty"`
+}
+
+// handlerSocket serves the WebSocket API: chirp events by topic, as on
+// /api/stream, and posting chirps over the same connection. The access
+// token is checked on connect and may be sent as access_token in the
+// query string, since browsers can't set headers on WebSocket requests.
+func (cfg *apiConfig) handlerSocket(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsUpgrade(r) {
+		w.Header().Set("Upgrade", "websocket")
+		respondWithError(w, r, newAPIError(http.StatusUpgradeRequired, problem.CodeInvalidRequest, "This endpoint only speaks WebSocket", nil))
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		respondWithError(w, r, unauthorized("Couldn't find JWT", err))
+		return
+	}
+	userID, expires, err := auth.ParseAccessToken(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, invalidToken("Couldn't validate JWT", err))
+		return
+	}
+
+	select {
+	case <-cfg.stream.Done():
+		respondWithError(w, r, newAPIError(http.StatusServiceUnavailable, problem.CodeUnavailable, "The server is shutting down", stream.ErrClosed))
+		return
+	default:
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if errors.Is(err, websocket.ErrBadHandshake) {
+		respondWithError(w, r, badRequest("Invalid WebSocket handshake", err))
+		return
+	}
+	if err != nil {
+		// The connection may already be taken over, so there is no one to
+		// respond to
+		slog.WarnContext(r.Context(), "upgrading to websocket", "error", err)
+		return
+	}
+	conn.ReadLimit = socketMaxMessageBytes
+	conn.IdleTimeout = 2 * socketPingInterval
+	conn.WriteTimeout = socketWriteTimeout
+
+	s := &socket{
+		cfg:       cfg,
+		conn:      conn,
+		userID:    userID,
+		path:      r.URL.Path,
+		requestID: w.Header().Get(requestIDHeader),
+		out:       make(chan []byte, socketQueue),
+		done:      make(chan struct{}),
+		topics:    map[string]*socketTopic{},
+	}
+	s.serve(r.Context(), expires)
+}
+
+// socket is one client connection to the WebSocket API. Messages are
+// handled one at a time by the goroutine reading them, and written by
+// another from a bounded queue.
+type socket struct {
+	cfg       *apiConfig
+	conn      *websocket.Conn
+	userID    uuid.UUID
+	path      string
+	requestID string
+
+	out     chan []byte
+	done    chan struct{}
+	dropped atomic.Bool
+
+	// topics is only used by the reading goroutine
+	topics map[string]*socketTopic
+}
+
+// socketTopic is a subscription and the goroutine forwarding its events.
+type socketTopic struct {
+	sub  *stream.Subscription
+	done chan struct{}
+}
+
+func (s *socket) serve(ctx context.Context, expires time.Time) {
+	go s.writeLoop(expires)
+	defer func() {
+		close(s.done)
+		for topic := range s.topics {
+			s.unsubscribe(topic)
+		}
+		s.conn.Close(websocket.CloseNormal, "")
+	}()
+
+	ready := socketMessage{Type: socketReady, UserID: &s.userID}
+	if !expires.IsZero() {
+		ready.ExpiresAt = &expires
+	}
+	s.send(ready)
+
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			logSocketClose(ctx, err)
+			return
+		}
+		if messageType != websocket.TextMessage {
+			s.conn.Close(websocket.CloseUnsupportedData, "messages must be JSON text")
+			return
+		}
+		s.handle(ctx, data)
+	}
+}
+
+// writeLoop writes queued messages and pings until the socket is done. It
+// also closes the socket when the access token expires or the server
+// shuts down.
+func (s *socket) writeLoop(expires time.Time) {
+	ping := time.NewTicker(socketPingInterval)
+	defer ping.Stop()
+	var expired <-chan time.Time
+	if !expires.IsZero() {
+		timer := time.NewTimer(time.Until(expires))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-s.done:
+			return
+		case msg := <-s.out:
+			err = s.conn.WriteMessage(websocket.TextMessage, msg)
+		case <-ping.C:
+			err = s.conn.Ping()
+		case <-expired:
+			s.conn.Close(closeTokenExpired, "access token expired")
+			return
+		case <-s.cfg.stream.Done():
+			s.conn.Close(websocket.CloseGoingAway, "server shutting down")
+			return
+		}
+		if err != nil {
+			s.conn.Close(websocket.CloseGoingAway, "")
+			return
+		}
+	}
+}
+
+// send queues msg for the client. A client that lets the queue fill up
+// isn't reading fast enough and is disconnected rather than allowed to
+// hold messages in memory; it can catch up by subscribing again with
+// last_event_id.
+func (s *socket) send(msg socketMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("encoding socket message", "type", msg.Type, "error", err)
+		return
+	}
+	select {
+	case s.out <- data:
+	default:
+		s.drop()
+	}
+}
+
+// drop disconnects a client that can't keep up.
+func (s *socket) drop() {
+	if s.dropped.CompareAndSwap(false, true) {
+		slog.Info("dropped slow socket client", "user_id", s.userID)
+		s.conn.Close(websocket.ClosePolicyViolation, "client too slow")
+	}
+}
+
+func (s *socket) handle(ctx context.Context, data []byte) {
+	var req socketRequest
+	if err := decodeStrict(json.NewDecoder(bytes.NewReader(data)), &req); err != nil {
+		s.sendError(ctx, req.Ref, err)
+		return
+	}
+
+	var err error
+	switch req.Type {
+	case socketPing:
+		s.send(socketMessage{Type: socketPong, Ref: req.Ref})
+	case socketSubscribe:
+		err = s.handleSubscribe(ctx, req)
+	case socketUnsubscribe:
+		err = s.handleUnsubscribe(req)
+	case socketCreateChirp:
+		err = s.handleCreateChirp(ctx, req)
+	default:
+		err = validationFailed(fieldError("type", problem.FieldInvalid,
+			fmt.Sprintf("type must be one of %s, %s, %s or %s", socketPing, socketSubscribe, socketUnsubscribe, socketCreateChirp)))
+	}
+	if err != nil {
+		s.sendError(ctx, req.Ref, err)
+	}
+}
+
+func (s *socket) handleSubscribe(ctx context.Context, req socketRequest) error {
+	if req.Topic == "" {
+		return validationFailed(fieldError("topic", problem.FieldRequired, "topic is required"))
+	}
+	if _, ok := s.topics[req.Topic]; ok {
+		return conflict(problem.CodeConflict, "Already subscribed to "+req.Topic, nil)
+	}
+	if len(s.topics) >= socketMaxTopics {
+		return badRequest(fmt.Sprintf("A socket may subscribe to at most %d topics", socketMaxTopics), nil)
+	}
+
+	filter, err := s.cfg.topicFilter(ctx, s.userID, req.Topic)
+	if err != nil {
+		return err
+	}
+	var lastEventID uuid.UUID
+	if req.LastEventID != "" {
+		lastEventID, err = uuid.Parse(req.LastEventID)
+		if err != nil {
+			return validationFailed(fieldError("last_event_id", problem.FieldInvalid, "last_event_id must be a UUID"))
+		}
+	}
+
+	sub, replay, resumed, err := s.cfg.stream.Subscribe(filter, lastEventID, streamQueue)
+	if errors.Is(err, stream.ErrClosed) {
+		return newAPIError(http.StatusServiceUnavailable, problem.CodeUnavailable, "The server is shutting down", err)
+	}
+	if err != nil {
+		return internalError("Couldn't subscribe to events", err)
+	}
+
+	t := &socketTopic{sub: sub, done: make(chan struct{})}
+	s.topics[req.Topic] = t
+	s.send(socketMessage{Type: socketSubscribed, Ref: req.Ref, Topic: req.Topic, Reset: !resumed})
+	for _, e := range replay {
+		s.send(eventMessage(req.Topic, e))
+	}
+	go func() {
+		defer close(t.done)
+		for e := range sub.Events() {
+			s.send(eventMessage(req.Topic, e))
+		}
+		if sub.Lagged() {
+			s.drop()
+		}
+	}()
+	return nil
+}
+
+func (s *socket) handleUnsubscribe(req socketRequest) error {
+	if req.Topic == "" {
+		return validationFailed(fieldError("topic", problem.FieldRequired, "topic is required"))
+	}
+	if !s.unsubscribe(req.Topic) {
+		return notFound("Not subscribed to "+req.Topic, nil)
+	}
+	s.send(socketMessage{Type: socketUnsubscribed, Ref: req.Ref, Topic: req.Topic})
+	return nil
+}
+
+// unsubscribe ends the subscription to topic, if any, once its pending
+// events have been queued, so none follow the reply.
+func (s *socket) unsubscribe(topic string) bool {
+	t, ok := s.topics[topic]
+	if !ok {
+		return false
+	}
+	t.sub.Close()
+	<-t.done
+	delete(s.topics, topic)
+	return true
+}
+
+// handleCreateChirp posts a chirp as handlerChirpsCreate does, under the
+// same rate limit.
+func (s *socket) handleCreateChirp(ctx context.Context, req socketRequest) error {
+	if req.Body == "" {
+		return validationFailed(fieldError("body", problem.FieldRequired, "body is required"))
+	}
+	tier := s.cfg.rateLimitTier(ctx, s.userID)
+	if res, _, ok := s.cfg.takeRateLimit(ctx, rateLimitChirpsCreate, "user:"+s.userID.String(), tier); ok && !res.Allowed {
+		return rateLimitExceeded(res)
+	}
+
+	chirp, err := s.cfg.createChirp(ctx, s.userID, req.Body)
+	if err != nil {
+		return err
+	}
+	s.send(socketMessage{Type: socketCreated, Ref: req.Ref, Data: chirp})
+	return nil
+}
+
+// sendError replies with the problem document the HTTP API would send.
+// The connection stays open.
+func (s *socket) sendError(ctx context.Context, ref string, err error) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		apiErr = internalError("Internal server error", err)
+	}
+	if apiErr.status > 499 {
+		slog.ErrorContext(ctx, "socket request failed", "status", apiErr.status, "error", apiErr)
+	}
+	p := apiErr.problem(s.path, s.requestID)
+	s.send(socketMessage{Type: socketError, Ref: ref, Error: &p})
+}
+
+func eventMessage(topic string, e stream.Event) socketMessage {
+	return socketMessage{
+		Type:  socketEvent,
+		Topic: topic,
+		ID:    &e.ID,
+		Event: e.Type,
+		Data:  json.RawMessage(e.Data),
+	}
+}
+
+// topicFilter returns the events a topic covers: "chirps" for every
+// chirp, "author:<user ID>", "hashtag:<tag>", or "followed" for the users
+// the caller follows.
+func (cfg *apiConfig) topicFilter(ctx context.Context, userID uuid.UUID, topic string) (stream.Filter, error) {
+	kind, arg, _ := strings.Cut(topic, ":")
+	switch {
+	case topic == "chirps":
+		return stream.Filter{}, nil
+	case topic == "followed":
+		authors, err := cfg.followees(ctx, userID)
+		return stream.Filter{Authors: authors}, err
+	case kind == "author":
+		if id, err := uuid.Parse(arg); err == nil {
+			return stream.Filter{Author: id}, nil
+		}
+	case kind == "hashtag":
+		if tag, ok := parseHashtag(arg); ok {
+			return stream.Filter{Hashtag: tag}, nil
+		}
+	}
+	return stream.Filter{}, validationFailed(fieldError("topic", problem.FieldInvalid,
+		"topic must be chirps, followed, author:<user ID> or hashtag:<tag>"))
+}
+
+// logSocketClose logs why a socket ended, unless it was closed normally.
+func logSocketClose(ctx context.Context, err error) {
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		switch ce.Code {
+		case websocket.CloseNormal, websocket.CloseGoingAway, websocket.CloseNoStatus:
+			return
+		}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return
+	}
+	slog.InfoContext(ctx, "socket closed", "error", err)
+}
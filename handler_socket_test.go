@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store"
+	"example.com/chirpy/internal/stream"
+	"example.com/chirpy/internal/webhooks"
+	"example.com/chirpy/internal/websocket"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestSocket returns a socket on hub whose write loop isn't running,
+// so the tests read its queue directly. conn may be nil if the socket is
+// never closed.
+func newTestSocket(t *testing.T, hub *stream.Hub, conn *websocket.Conn) *socket {
+	t.Helper()
+	s := &socket{
+		cfg:    &apiConfig{store: store.NewMemory(), stream: hub},
+		conn:   conn,
+		userID: uuid.New(),
+		path:   "/api/ws",
+		out:    make(chan []byte, socketQueue),
+		done:   make(chan struct{}),
+		topics: map[string]*socketTopic{},
+	}
+	t.Cleanup(func() {
+		for topic := range s.topics {
+			s.unsubscribe(topic)
+		}
+	})
+	return s
+}
+
+// socketChirpEvent returns a chirp.created outbox event for a chirp by
+// author.
+func socketChirpEvent(t *testing.T, author uuid.UUID, body string) database.OutboxEvent {
+	t.Helper()
+	payload, err := json.Marshal(map[string]any{"id": uuid.New(), "user_id": author, "body": body})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database.OutboxEvent{ID: uuid.New(), EventType: webhooks.EventChirpCreated, Payload: payload, CreatedAt: time.Now()}
+}
+
+// receive returns the next message queued on s, waiting briefly for one
+// sent by a forwarding goroutine.
+func receive(t *testing.T, s *socket) socketMessage {
+	t.Helper()
+	select {
+	case data := <-s.out:
+		var msg socketMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message queued")
+		return socketMessage{}
+	}
+}
+
+// queuedEventIDs returns the IDs of the event messages already queued on
+// s, stopping at the first other message or an empty queue.
+func queuedEventIDs(t *testing.T, s *socket) []uuid.UUID {
+	t.Helper()
+	var ids []uuid.UUID
+	for len(s.out) > 0 {
+		msg := receive(t, s)
+		if msg.Type != socketEvent {
+			t.Fatalf("got a %s message among the replayed events", msg.Type)
+		}
+		ids = append(ids, *msg.ID)
+	}
+	return ids
+}
+
+func TestSocketSubscribeResume(t *testing.T) {
+	walt, jesse := uuid.New(), uuid.New()
+	topic := "author:" + walt.String()
+
+	tests := []struct {
+		name string
+		// lastEventID picks the last_event_id to send from the published
+		// events
+		lastEventID func(events []database.OutboxEvent) string
+		wantReset   bool
+		wantReplay  func(events []database.OutboxEvent) []uuid.UUID
+	}{
+		{
+			name:        "new subscription",
+			lastEventID: func([]database.OutboxEvent) string { return "" },
+		},
+		{
+			name:        "resumed from a buffered event",
+			lastEventID: func(events []database.OutboxEvent) string { return events[0].ID.String() },
+			wantReplay: func(events []database.OutboxEvent) []uuid.UUID {
+				return []uuid.UUID{events[2].ID, events[3].ID}
+			},
+		},
+		{
+			name:        "resumed from the latest event",
+			lastEventID: func(events []database.OutboxEvent) string { return events[3].ID.String() },
+		},
+		{
+			name:        "unknown event",
+			lastEventID: func([]database.OutboxEvent) string { return uuid.NewString() },
+			wantReset:   true,
+		},
+		{
+			name:        "event evicted from the buffer",
+			lastEventID: func(events []database.OutboxEvent) string { return events[len(events)-1].ID.String() },
+			wantReset:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := stream.NewHub(4)
+			t.Cleanup(hub.Close)
+			// The last event is published first, so that it has been
+			// evicted by the time the others are buffered
+			events := []database.OutboxEvent{
+				socketChirpEvent(t, walt, "one"),
+				socketChirpEvent(t, jesse, "two"),
+				socketChirpEvent(t, walt, "three"),
+				socketChirpEvent(t, walt, "four"),
+				socketChirpEvent(t, walt, "evicted"),
+			}
+			ctx := context.Background()
+			if err := hub.Publish(ctx, events[4]); err != nil {
+				t.Fatal(err)
+			}
+			if err := hub.Publish(ctx, events[:4]...); err != nil {
+				t.Fatal(err)
+			}
+
+			s := newTestSocket(t, hub, nil)
+			err := s.handleSubscribe(ctx, socketRequest{Type: socketSubscribe, Ref: "r1", Topic: topic, LastEventID: tt.lastEventID(events)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := receive(t, s)
+			want := socketMessage{Type: socketSubscribed, Ref: "r1", Topic: topic, Reset: tt.wantReset}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+			var wantReplay []uuid.UUID
+			if tt.wantReplay != nil {
+				wantReplay = tt.wantReplay(events)
+			}
+			if got := queuedEventIDs(t, s); !reflect.DeepEqual(got, wantReplay) {
+				t.Errorf("replayed %v, want %v", got, wantReplay)
+			}
+
+			// Whether or not it resumed, the subscription is live
+			live := socketChirpEvent(t, walt, "live")
+			if err := hub.Publish(ctx, socketChirpEvent(t, jesse, "filtered"), live); err != nil {
+				t.Fatal(err)
+			}
+			if msg := receive(t, s); msg.Type != socketEvent || *msg.ID != live.ID {
+				t.Errorf("got %+v, want the live event", msg)
+			}
+		})
+	}
+}
+
+func TestSocketSubscribeInvalidLastEventID(t *testing.T) {
+	s := newTestSocket(t, stream.NewHub(4), nil)
+	err := s.handleSubscribe(context.Background(), socketRequest{Type: socketSubscribe, Topic: "chirps", LastEventID: "nope"})
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || len(apiErr.fields) != 1 || apiErr.fields[0].Field != "last_event_id" {
+		t.Fatalf("got %v, want a last_event_id field error", err)
+	}
+	if len(s.topics) != 0 {
+		t.Error("subscribed despite the error")
+	}
+}
+
+// upgradedConn returns the server end of a WebSocket connection and a
+// reader for the client end.
+func upgradedConn(t *testing.T) (*websocket.Conn, *bufio.Reader) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			t.Error(err)
+			close(conns)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	// No test should wait anywhere near this long
+	client.SetDeadline(time.Now().Add(10 * time.Second))
+	fmt.Fprint(client, "GET / HTTP/1.1\r\nHost: chirpy\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	br := bufio.NewReader(client)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("got status %d", resp.StatusCode)
+	}
+	conn, ok := <-conns
+	if !ok {
+		t.FailNow()
+	}
+	return conn, br
+}
+
+func TestSocketDropsSlowClient(t *testing.T) {
+	hub := stream.NewHub(socketQueue + 1)
+	t.Cleanup(hub.Close)
+	ctx := context.Background()
+	author := uuid.New()
+	var events []database.OutboxEvent
+	for i := range socketQueue + 1 {
+		events = append(events, socketChirpEvent(t, author, fmt.Sprintf("chirp %d", i)))
+	}
+	if err := hub.Publish(ctx, events...); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reply and the socketQueue replayed events are one message more
+	// than the queue holds, and nothing is writing them out
+	conn, client := upgradedConn(t)
+	s := newTestSocket(t, hub, conn)
+	err := s.handleSubscribe(ctx, socketRequest{Type: socketSubscribe, Topic: "chirps", LastEventID: events[0].ID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.dropped.Load() {
+		t.Fatal("client not dropped")
+	}
+	if len(s.out) != socketQueue {
+		t.Errorf("queued %d messages, want %d", len(s.out), socketQueue)
+	}
+
+	// Only the close frame reached the client
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(client, header); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != 0x88 {
+		t.Fatalf("got frame %#x, want a close frame", header[0])
+	}
+	payload := make([]byte, header[1])
+	if _, err := io.ReadFull(client, payload); err != nil {
+		t.Fatal(err)
+	}
+	if code := binary.BigEndian.Uint16(payload); code != websocket.ClosePolicyViolation || string(payload[2:]) != "client too slow" {
+		t.Errorf("closed with %d %q, want %d", code, payload[2:], websocket.ClosePolicyViolation)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/stream"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// streamHeartbeat is how often an idle stream sends a comment, so
+	// proxies don't close it and clients notice dead connections.
+	streamHeartbeat = 15 * time.Second
+	// streamQueue is how many events a client may fall behind by before
+	// it is disconnected to resume with Last-Event-ID.
+	streamQueue = 64
+	// streamRetry is the reconnection delay suggested to clients.
+	streamRetry = 2 * time.Second
+)
+
+// eventStreamReset is sent when Last-Event-ID is no longer in the replay
+// buffer: the client may have missed events and should reload.
+const eventStreamReset = "stream.reset"
+
+// announce publishes events committed by a handler to stream clients. The
+// change is already committed, so a failure only delays live updates and
+// is logged rather than returned.
+func (cfg *apiConfig) announce(ctx context.Context, events ...database.OutboxEvent) {
+	if err := cfg.events.Publish(context.WithoutCancel(ctx), events...); err != nil {
+		slog.ErrorContext(ctx, "publishing events to streams", "error", err)
+	}
+}
+
+// handlerStream sends chirp.created and chirp.deleted events as
+// server-sent events until the client disconnects.
+func (cfg *apiConfig) handlerStream(w http.ResponseWriter, r *http.Request) {
+	filter, err := cfg.streamFilter(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	var lastEventID uuid.UUID
+	if s := r.Header.Get("Last-Event-ID"); s != "" {
+		// An ID that isn't ours can't be resumed from; treat it like one
+		// that has left the buffer
+		lastEventID, err = uuid.Parse(s)
+		if err != nil {
+			lastEventID = uuid.New()
+		}
+	}
+
+	sub, replay, resumed, err := cfg.stream.Subscribe(filter, lastEventID, streamQueue)
+	if errors.Is(err, stream.ErrClosed) {
+		respondWithError(w, r, newAPIError(http.StatusServiceUnavailable, problem.CodeUnavailable, "The server is shutting down", err))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't subscribe to events", err))
+		return
+	}
+	defer sub.Close()
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.WarnContext(r.Context(), "clearing write deadline for stream", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(format string, args ...any) bool {
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	sendEvent := func(e stream.Event) bool {
+		return send("id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	}
+
+	if !send("retry: %d\n\n", streamRetry.Milliseconds()) {
+		return
+	}
+	if !resumed && !send("event: %s\ndata: {}\n\n", eventStreamReset) {
+		return
+	}
+	for _, e := range replay {
+		if !sendEvent(e) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !send(": keepalive\n\n") {
+				return
+			}
+		case e, ok := <-sub.Events():
+			if !ok {
+				if sub.Lagged() {
+					slog.InfoContext(r.Context(), "dropped slow stream client")
+				}
+				return
+			}
+			if !sendEvent(e) {
+				return
+			}
+		}
+	}
+}
+
+// streamFilter reads the stream's filters from the query string:
+// author_id, hashtag and followed, which needs an access token.
+func (cfg *apiConfig) streamFilter(r *http.Request) (stream.Filter, error) {
+	var f stream.Filter
+	query := r.URL.Query()
+
+	if s := query.Get("author_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return f, validationFailed(fieldError("author_id", problem.FieldInvalid, "author_id must be a UUID"))
+		}
+		f.Author = id
+	}
+
+	if s := query.Get("hashtag"); s != "" {
+		tag, ok := parseHashtag(s)
+		if !ok {
+			return f, validationFailed(fieldError("hashtag", problem.FieldInvalid, "hashtag must be letters, digits and underscores"))
+		}
+		f.Hashtag = tag
+	}
+
+	if s := query.Get("followed"); s != "" {
+		followed, err := strconv.ParseBool(s)
+		if err != nil {
+			return f, validationFailed(fieldError("followed", problem.FieldInvalid, "followed must be true or false"))
+		}
+		if !followed {
+			return f, nil
+		}
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			return f, unauthorized("followed=true needs an access token", err)
+		}
+		userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+		if err != nil {
+			return f, invalidToken("Couldn't validate JWT", err)
+		}
+		f.Authors, err = cfg.followees(r.Context(), userID)
+		if err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+// parseHashtag returns tag lower-cased and without a leading #, and
+// whether it is a valid hashtag.
+func parseHashtag(s string) (string, bool) {
+	tag := strings.ToLower(strings.TrimPrefix(s, "#"))
+	// A valid tag is read back whole
+	tags := stream.Hashtags("#" + tag)
+	return tag, len(tags) == 1 && tags[0] == tag
+}
+
+// followees returns the users userID follows, for Filter.Authors. They are
+// read once; a client picks up new follows when it subscribes again.
+func (cfg *apiConfig) followees(ctx context.Context, userID uuid.UUID) (map[uuid.UUID]bool, error) {
+	ids, err := cfg.store.Follows().ListFolloweeIDs(ctx, userID)
+	if err != nil {
+		return nil, internalError("Couldn't list followed users", err)
+	}
+	authors := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		authors[id] = true
+	}
+	return authors, nil
+}
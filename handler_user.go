@@ -1,13 +1,20 @@
 package main
 
 import (
-	"context"
+	"bytes"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"example.com/chirpy/internal/auth"
 	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/mailer"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/store"
 	"github.com/google/uuid"
+	"html/template"
+	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -18,95 +25,115 @@ type User struct {
 	Email        string    `json:"email"`
 	Token        string    `json:"token"`
 	RefreshToken string    `json:"refresh_token"`
+	PendingEmail string    `json:"pending_email,omitempty"`
 	IsChirpyRed  bool      `json:"is_chirpy_red"`
 }
 
 // UserCreationParams holds the request parameters for user creation.
 type UserParams struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,maxbytes=72"`
 }
 
+// UpdateUserRequest replaces the user's email and password. PUT /api/users
+// is deprecated in favour of PATCH /api/users/me but keeps its original
+// contract: the access token is enough, and a new email takes effect at
+// once. current_password is checked when it is sent.
 type UpdateUserRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email           string `json:"email" validate:"required,email,max=254"`
+	Password        string `json:"password" validate:"required,maxbytes=72"`
+	CurrentPassword string `json:"current_password"`
 }
 
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
 	var params UserParams
-	err := json.NewDecoder(r.Body).Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+	if err := decodeJSON(w, r, &params); err != nil {
+		respondWithError(w, r, err)
 		return
 	}
 
 	// Hash the password before saving it to the database
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to hash password", err)
+		respondWithError(w, r, internalError("Failed to hash password", err))
 		return
 	}
 
 	// Insert the new user into the database
-	user, err := cfg.db.CreateUser(context.Background(), database.CreateUserParams{
+	user, err := cfg.store.Users().CreateUser(r.Context(), database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to create user", err)
+		if errors.Is(err, store.ErrConflict) {
+			respondWithError(w, r, conflict(problem.CodeEmailInUse, "Email is already in use", err))
+			return
+		}
+		respondWithError(w, r, internalError("Failed to create user", err))
 		return
 	}
 
 	// Don't return the hashed password in the response
 	respondWithJSON(w, http.StatusCreated, User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Email:     user.Email,
 	})
 }
 
 func (cfg *apiConfig) handlerLoginUser(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		Password string `json:"password"`
-		Email    string `json:"email"`
+		Password string `json:"password" validate:"required"`
+		Email    string `json:"email" validate:"required"`
 	}
-	err := json.NewDecoder(r.Body).Decode(&body)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+	if err := decodeJSON(w, r, &body); err != nil {
+		respondWithError(w, r, err)
 		return
 	}
 
 	// Fetch the user by email
-	user, err := cfg.db.GetUserByEmail(context.Background(), body.Email)
+	user, err := cfg.store.Users().GetUserByEmail(r.Context(), body.Email)
 	if err != nil || auth.CheckPasswordHash(body.Password, user.HashedPassword) != nil {
-		respondWithError(w, 401, "Incorrect email or password", err)
+		cfg.metrics.logins.With(outcomeFailure).Inc()
+		respondWithError(w, r, newAPIError(http.StatusUnauthorized, problem.CodeInvalidCredentials, "Incorrect email or password", err))
 		return
 	}
 
 	// Create access token
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour)
+	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, cfg.accessTokenTTL)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not generate token", err)
+		respondWithError(w, r, internalError("Could not generate token", err))
 		return
 	}
 
 	// Create refresh token
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not generate refresh token", err)
+		respondWithError(w, r, internalError("Could not generate refresh token", err))
 		return
 	}
 
 	// Store refresh token in the database
-	expiresAt := time.Now().Add(60 * 24 * time.Hour) // 60 days
-	err = cfg.db.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+	expiresAt := time.Now().Add(cfg.refreshTokenTTL)
+	err = cfg.store.RefreshTokens().CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
 		Token:     refreshToken,
 		UserID:    user.ID,
 		ExpiresAt: expiresAt,
 		RevokedAt: sql.NullTime{},
 	})
+	if err != nil {
+		respondWithError(w, r, internalError("Could not save refresh token", err))
+		return
+	}
+
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't look up subscription", err))
+		return
+	}
+
+	cfg.metrics.logins.With(outcomeSuccess).Inc()
 
 	// Password matched, return user data (without hashed password)
 	respondWithJSON(w, http.StatusOK, User{
@@ -116,7 +143,7 @@ func (cfg *apiConfig) handlerLoginUser(w http.ResponseWriter, r *http.Request) {
 		Email:        user.Email,
 		Token:        token,
 		RefreshToken: refreshToken,
-		IsChirpyRed:  user.IsChirpyRed,
+		IsChirpyRed:  isChirpyRed,
 	})
 }
 
@@ -124,21 +151,21 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	// Extract the bearer token from the header
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		respondWithError(w, r, invalidToken("Invalid token", err))
 		return
 	}
 
 	// Lookup the refresh token in the database
-	storedToken, err := cfg.db.GetRefreshToken(context.Background(), refreshToken)
+	storedToken, err := cfg.store.RefreshTokens().GetRefreshToken(r.Context(), refreshToken)
 	if err != nil || storedToken.RevokedAt.Valid || storedToken.ExpiresAt.Before(time.Now()) {
-		respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token", err)
+		respondWithError(w, r, invalidToken("Invalid or expired refresh token", err))
 		return
 	}
 
 	// Generate a new access token
-	token, err := auth.MakeJWT(storedToken.UserID, cfg.jwtSecret, time.Hour)
+	token, err := auth.MakeJWT(storedToken.UserID, cfg.jwtSecret, cfg.accessTokenTTL)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not generate new token", err)
+		respondWithError(w, r, internalError("Could not generate new token", err))
 		return
 	}
 
@@ -154,14 +181,14 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 	// Extract the bearer token from the header
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		respondWithError(w, r, invalidToken("Invalid token", err))
 		return
 	}
 
 	// Revoke the refresh token in the database
-	err = cfg.db.RevokeRefreshToken(context.Background(), refreshToken)
+	err = cfg.store.RefreshTokens().RevokeRefreshToken(r.Context(), refreshToken)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not revoke token", err)
+		respondWithError(w, r, internalError("Could not revoke token", err))
 		return
 	}
 
@@ -173,59 +200,424 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 	// Get the bearer token from the Authorization header
 	tokenString, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		respondWithError(w, r, unauthorized("Unauthorized", err))
 		return
 	}
 
 	// Validate the JWT and retrieve the user ID
 	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
 		return
 	}
 
 	// Parse the request body
+	// PUT replaces the whole user, so both fields are required
 	var req UpdateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Bad Request", err)
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithError(w, r, err)
 		return
 	}
 
-	// Hash the new password
-	hashedPassword, err := auth.HashPassword(req.Password)
+	// Point existing clients at the replacement
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</api/users/me>; rel="successor-version"`)
+
+	updatedUser, _, err := cfg.changeUser(r, userID, userChange{
+		email:           &req.Email,
+		password:        &req.Password,
+		currentPassword: req.CurrentPassword,
+		legacy:          true,
+	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Internal Server Error", err)
+		respondWithError(w, r, err)
 		return
 	}
 
-	// Update the user in the database
-	err = cfg.db.UpdateUser(context.Background(), database.UpdateUserParams{
-		Email:          req.Email,
-		HashedPassword: hashedPassword,
-		ID:             userID,
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), updatedUser.ID)
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't look up subscription", err))
+		return
+	}
+
+	// Return user data (without hashed password)
+	respondWithJSON(w, http.StatusOK, User{
+		ID:          updatedUser.ID,
+		CreatedAt:   updatedUser.CreatedAt,
+		UpdatedAt:   updatedUser.UpdatedAt,
+		Email:       updatedUser.Email,
+		Token:       tokenString,
+		IsChirpyRed: isChirpyRed,
 	})
+}
+
+// PatchUserRequest holds the fields of a partial user update. Nil fields are
+// left unchanged.
+type PatchUserRequest struct {
+	Email           *string `json:"email" validate:"email,max=254"`
+	Password        *string `json:"password" validate:"maxbytes=72"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// Validate rejects empty values, which the tags skip, and password changes
+// without the current password.
+func (req PatchUserRequest) Validate() []problem.FieldError {
+	var fields []problem.FieldError
+	if req.Email != nil && *req.Email == "" {
+		fields = append(fields, fieldError("email", problem.FieldRequired, "email cannot be empty"))
+	}
+	if req.Password != nil && *req.Password == "" {
+		fields = append(fields, fieldError("password", problem.FieldRequired, "password cannot be empty"))
+	}
+	if req.Password != nil && req.CurrentPassword == "" {
+		fields = append(fields, fieldError("current_password", problem.FieldRequired, "current_password is required to change the password"))
+	}
+	return fields
+}
+
+const emailChangeTTL = 24 * time.Hour
+
+var (
+	errWrongPassword = errors.New("current password is incorrect")
+	errEmailInUse    = errors.New("email is already in use")
+)
+
+func (cfg *apiConfig) handlerPatchUser(w http.ResponseWriter, r *http.Request) {
+	// Get the bearer token from the Authorization header
+	tokenString, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Internal Server Error", err)
+		respondWithError(w, r, unauthorized("Unauthorized", err))
 		return
 	}
 
-	// Respond with the updated user information (omit the password)
-	updatedUser, err := cfg.db.GetUserByEmail(context.Background(), req.Email) // Assume you have a GetUserByID function
+	// Validate the JWT and retrieve the user ID
+	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
 		return
 	}
 
-	// Omit the password from the response
-	updatedUser.HashedPassword = "" // Ensure password is not included
+	var req PatchUserRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if req.Email == nil && req.Password == nil {
+		respondWithError(w, r, badRequest("No fields to update", nil))
+		return
+	}
+
+	updatedUser, pendingEmail, err := cfg.changeUser(r, userID, userChange{
+		email:           req.Email,
+		password:        req.Password,
+		currentPassword: req.CurrentPassword,
+	})
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), updatedUser.ID)
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't look up subscription", err))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, User{
+		ID:           updatedUser.ID,
+		CreatedAt:    updatedUser.CreatedAt,
+		UpdatedAt:    updatedUser.UpdatedAt,
+		Email:        updatedUser.Email,
+		PendingEmail: pendingEmail,
+		IsChirpyRed:  isChirpyRed,
+	})
+}
+
+// userChange is an update to a user's email and password. Nil fields are
+// left unchanged.
+type userChange struct {
+	email           *string
+	password        *string
+	currentPassword string
+	// legacy applies the change the way PUT /api/users always has: the
+	// current password is only checked if given, and a new email takes
+	// effect without confirmation.
+	legacy bool
+}
+
+// changeUser applies change to the user. A new password takes effect
+// immediately and needs the current password; a new email is only
+// recorded as pending, and a confirmation link is mailed to it. It
+// returns the updated user and the pending email, if any.
+func (cfg *apiConfig) changeUser(r *http.Request, userID uuid.UUID, change userChange) (database.User, string, error) {
+	var (
+		updatedUser  database.User
+		pendingEmail string
+		confirmToken string
+	)
+	err := cfg.store.WithTx(r.Context(), func(tx store.Store) error {
+		user, err := tx.Users().GetUserByID(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		updatedUser = user
+
+		if change.password != nil {
+			checkCurrent := !change.legacy || change.currentPassword != ""
+			if checkCurrent && auth.CheckPasswordHash(change.currentPassword, user.HashedPassword) != nil {
+				return errWrongPassword
+			}
+			// Skip the update entirely if the password is unchanged
+			if auth.CheckPasswordHash(*change.password, user.HashedPassword) != nil {
+				hashedPassword, err := auth.HashPassword(*change.password)
+				if err != nil {
+					return err
+				}
+				updatedUser, err = tx.Users().UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+					HashedPassword: hashedPassword,
+					ID:             userID,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if change.email != nil && *change.email != user.Email && change.legacy {
+			updatedUser, err = tx.Users().UpdateUserEmail(r.Context(), database.UpdateUserEmailParams{
+				Email: *change.email,
+				ID:    userID,
+			})
+			if errors.Is(err, store.ErrConflict) {
+				return errEmailInUse
+			}
+			if err != nil {
+				return err
+			}
+			// A change waiting for confirmation would undo this one
+			return tx.EmailChanges().DeleteEmailChangeRequestsForUser(r.Context(), userID)
+		}
+
+		// Email changes only take effect once the new address is confirmed
+		if change.email != nil && *change.email != user.Email {
+			_, err := tx.Users().GetUserByEmail(r.Context(), *change.email)
+			if err == nil {
+				return errEmailInUse
+			}
+			if !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+
+			confirmToken, err = auth.MakeRefreshToken()
+			if err != nil {
+				return err
+			}
+			// Only the latest request for a user stays valid
+			err = tx.EmailChanges().DeleteEmailChangeRequestsForUser(r.Context(), userID)
+			if err != nil {
+				return err
+			}
+			_, err = tx.EmailChanges().CreateEmailChangeRequest(r.Context(), database.CreateEmailChangeRequestParams{
+				TokenHash: auth.HashToken(confirmToken),
+				UserID:    userID,
+				NewEmail:  *change.email,
+				ExpiresAt: time.Now().Add(emailChangeTTL),
+			})
+			if err != nil {
+				return err
+			}
+			pendingEmail = *change.email
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errWrongPassword):
+			return database.User{}, "", newAPIError(http.StatusUnauthorized, problem.CodeInvalidCredentials, "Current password is incorrect", err)
+		case errors.Is(err, errEmailInUse):
+			return database.User{}, "", conflict(problem.CodeEmailInUse, "Email is already in use", err)
+		case errors.Is(err, store.ErrNotFound):
+			return database.User{}, "", notFound("User not found", err)
+		}
+		return database.User{}, "", internalError("Couldn't update user", err)
+	}
+
+	if pendingEmail != "" {
+		link := cfg.baseURL + "/api/users/email/confirm?token=" + url.QueryEscape(confirmToken)
+		err = cfg.mailer.Send(r.Context(), mailer.Message{
+			To:      pendingEmail,
+			Subject: "Confirm your new Chirpy email address",
+			Body:    "Open this link to confirm your new email address:\n\n" + link + "\n\nThe link expires in 24 hours.",
+		})
+		if err != nil {
+			return database.User{}, "", internalError("Couldn't send confirmation email", err)
+		}
+	}
+	return updatedUser, pendingEmail, nil
+}
+
+var errInvalidConfirmation = errors.New("invalid or expired confirmation token")
+
+// ConfirmEmailChangeRequest is the body of POST /api/users/email/confirm.
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// handlerConfirmEmailChangePage serves the page the confirmation link
+// opens. It changes nothing itself: the page's button posts the token
+// back, so mail scanners and prefetchers that follow the link can't
+// confirm the change.
+func (cfg *apiConfig) handlerConfirmEmailChangePage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, r, validationFailed(fieldError("token", problem.FieldRequired, "token is required")))
+		return
+	}
+	renderConfirmEmailPage(w, r, http.StatusOK, confirmEmailPage{Token: token})
+}
+
+// handlerConfirmEmailChange applies the email change a token stands for.
+// API clients send the token as JSON and get the user back; the
+// confirmation page sends it as a form and gets a page back.
+func (cfg *apiConfig) handlerConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		cfg.confirmEmailChangeForm(w, r)
+		return
+	}
+
+	var req ConfirmEmailChangeRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	updatedUser, err := cfg.confirmEmailChange(r, req.Token)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), updatedUser.ID)
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't look up subscription", err))
+		return
+	}
 
-	// Password matched, return user data (without hashed password)
 	respondWithJSON(w, http.StatusOK, User{
 		ID:          updatedUser.ID,
 		CreatedAt:   updatedUser.CreatedAt,
 		UpdatedAt:   updatedUser.UpdatedAt,
 		Email:       updatedUser.Email,
-		Token:       tokenString,
-		IsChirpyRed: updatedUser.IsChirpyRed,
+		IsChirpyRed: isChirpyRed,
+	})
+}
+
+// confirmEmailChangeForm handles the confirmation page's form.
+func (cfg *apiConfig) confirmEmailChangeForm(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	if err := r.ParseForm(); err != nil {
+		apiErr := recordError(w, r, badRequest("Couldn't read the form", err))
+		renderConfirmEmailPage(w, r, apiErr.status, confirmEmailPage{Error: apiErr.detail})
+		return
+	}
+
+	updatedUser, err := cfg.confirmEmailChange(r, r.PostForm.Get("token"))
+	if err != nil {
+		apiErr := recordError(w, r, err)
+		renderConfirmEmailPage(w, r, apiErr.status, confirmEmailPage{Error: apiErr.detail})
+		return
+	}
+	renderConfirmEmailPage(w, r, http.StatusOK, confirmEmailPage{Done: true, Email: updatedUser.Email})
+}
+
+// confirmEmailChange moves the user the token was issued to onto the new
+// email and discards their pending changes.
+func (cfg *apiConfig) confirmEmailChange(r *http.Request, token string) (database.User, error) {
+	if token == "" {
+		return database.User{}, validationFailed(fieldError("token", problem.FieldRequired, "token is required"))
+	}
+
+	var updatedUser database.User
+	err := cfg.store.WithTx(r.Context(), func(tx store.Store) error {
+		change, err := tx.EmailChanges().GetEmailChangeRequestForUpdate(r.Context(), auth.HashToken(token))
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errInvalidConfirmation
+			}
+			return err
+		}
+		if change.ExpiresAt.Before(time.Now()) {
+			return errInvalidConfirmation
+		}
+
+		updatedUser, err = tx.Users().UpdateUserEmail(r.Context(), database.UpdateUserEmailParams{
+			Email: change.NewEmail,
+			ID:    change.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.EmailChanges().DeleteEmailChangeRequestsForUser(r.Context(), change.UserID)
 	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidConfirmation):
+			return database.User{}, newAPIError(http.StatusBadRequest, problem.CodeInvalidToken, "Invalid or expired confirmation token", err)
+		case errors.Is(err, store.ErrConflict):
+			return database.User{}, conflict(problem.CodeEmailInUse, "Email is already in use", err)
+		}
+		return database.User{}, internalError("Couldn't confirm email change", err)
+	}
+	return updatedUser, nil
+}
+
+// confirmEmailPage is what the confirmation page shows: a button posting
+// Token, the confirmed Email once Done, or an Error.
+type confirmEmailPage struct {
+	Token string
+	Done  bool
+	Email string
+	Error string
 }
+
+func renderConfirmEmailPage(w http.ResponseWriter, r *http.Request, status int, page confirmEmailPage) {
+	var buf bytes.Buffer
+	if err := confirmEmailTemplate.Execute(&buf, page); err != nil {
+		respondWithError(w, r, internalError("Couldn't render the confirmation page", err))
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	// The page holds the token, so keep it out of caches and referrers
+	h.Set("Cache-Control", "no-store")
+	h.Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.ErrorContext(r.Context(), "writing confirmation page", "error", err)
+	}
+}
+
+var confirmEmailTemplate = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Confirm your email address - Chirpy</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 32em; margin: 4em auto; padding: 0 1em; color: #222; }
+button { font-size: 1em; padding: .4em 1.2em; }
+</style>
+</head>
+<body>
+{{if .Done}}<h1>Email address confirmed</h1>
+<p>Your Chirpy account now uses {{.Email}}.</p>
+{{else if .Error}}<h1>Couldn't confirm your email address</h1>
+<p>{{.Error}}</p>
+{{else}}<h1>Confirm your new email address</h1>
+<form method="post" action="/api/users/email/confirm">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Confirm</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
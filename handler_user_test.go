@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/entitlements"
+	"example.com/chirpy/internal/mailer"
+	"example.com/chirpy/internal/store"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sentMail keeps the messages it is asked to send.
+type sentMail struct {
+	messages []mailer.Message
+}
+
+func (m *sentMail) Send(ctx context.Context, msg mailer.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// newUserTestServer serves the API over the Memory store with one user,
+// walt@example.com with password hunter2, and returns their access token.
+func newUserTestServer(t *testing.T) (*httptest.Server, *apiConfig, *sentMail, string) {
+	t.Helper()
+	s := store.NewMemory()
+	hash, err := auth.HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := s.Users().CreateUser(context.Background(), database.CreateUserParams{Email: "walt@example.com", HashedPassword: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mail := &sentMail{}
+	cfg := &apiConfig{
+		store:        s,
+		jwtSecret:    "test-secret",
+		mailer:       mail,
+		entitlements: entitlements.New(entitlements.NewRepositoryStore(s), nil),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /api/users", cfg.handlerUpdateUser)
+	mux.HandleFunc("PATCH /api/users/me", cfg.handlerPatchUser)
+	mux.HandleFunc("GET /api/users/email/confirm", cfg.handlerConfirmEmailChangePage)
+	mux.HandleFunc("POST /api/users/email/confirm", cfg.handlerConfirmEmailChange)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	cfg.baseURL = srv.URL
+
+	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, cfg, mail, token
+}
+
+func sendUserRequest(t *testing.T, method, url, token, contentType string, body io.Reader) (*http.Response, string) {
+	t.Helper()
+	r, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	dat, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, string(dat)
+}
+
+var confirmLink = regexp.MustCompile(`\S+/api/users/email/confirm\?token=\S+`)
+
+// requestEmailChange asks for walt's email to change to email and returns
+// the confirmation link mailed to it.
+func requestEmailChange(t *testing.T, srv *httptest.Server, mail *sentMail, token, email string) string {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"email": email})
+	res, dat := sendUserRequest(t, http.MethodPatch, srv.URL+"/api/users/me", token, "application/json", bytes.NewReader(body))
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH: got status %d: %s", res.StatusCode, dat)
+	}
+	if len(mail.messages) == 0 || mail.messages[len(mail.messages)-1].To != email {
+		t.Fatalf("no confirmation sent to %s: %+v", email, mail.messages)
+	}
+	link := confirmLink.FindString(mail.messages[len(mail.messages)-1].Body)
+	if link == "" {
+		t.Fatalf("no link in %q", mail.messages[len(mail.messages)-1].Body)
+	}
+	return link
+}
+
+// userEmail returns the current email of the user token belongs to.
+func userEmail(t *testing.T, cfg *apiConfig, token string) string {
+	t.Helper()
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := cfg.store.Users().GetUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.Email
+}
+
+func TestConfirmEmailChangePage(t *testing.T) {
+	srv, cfg, mail, token := newUserTestServer(t)
+	link := requestEmailChange(t, srv, mail, token, "heisenberg@example.com")
+
+	// Following the link, as a scanner or prefetcher would, changes nothing
+	for range 2 {
+		res, page := sendUserRequest(t, http.MethodGet, link, "", "", nil)
+		if res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+			t.Fatalf("GET: got status %d, Content-Type %q", res.StatusCode, res.Header.Get("Content-Type"))
+		}
+		if !strings.Contains(page, `<form method="post"`) {
+			t.Fatalf("page has no form: %s", page)
+		}
+		if got := res.Header.Get("Cache-Control"); got != "no-store" {
+			t.Errorf("Cache-Control = %q, want no-store", got)
+		}
+	}
+	if got := userEmail(t, cfg, token); got != "walt@example.com" {
+		t.Fatalf("email changed by GET: %s", got)
+	}
+
+	// The page's form posts the token back
+	u, _ := url.Parse(link)
+	form := url.Values{"token": {u.Query().Get("token")}}
+	res, page := sendUserRequest(t, http.MethodPost, srv.URL+"/api/users/email/confirm", "", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if res.StatusCode != http.StatusOK || !strings.Contains(page, "Email address confirmed") {
+		t.Fatalf("form POST: got status %d: %s", res.StatusCode, page)
+	}
+	if got := userEmail(t, cfg, token); got != "heisenberg@example.com" {
+		t.Fatalf("email after confirming = %s", got)
+	}
+
+	// The token is used up
+	res, page = sendUserRequest(t, http.MethodPost, srv.URL+"/api/users/email/confirm", "", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if res.StatusCode != http.StatusBadRequest || !strings.Contains(page, "Invalid or expired confirmation token") {
+		t.Fatalf("second form POST: got status %d: %s", res.StatusCode, page)
+	}
+}
+
+func TestConfirmEmailChangeJSON(t *testing.T) {
+	srv, cfg, mail, token := newUserTestServer(t)
+	link := requestEmailChange(t, srv, mail, token, "heisenberg@example.com")
+	u, _ := url.Parse(link)
+	body, _ := json.Marshal(ConfirmEmailChangeRequest{Token: u.Query().Get("token")})
+
+	res, dat := sendUserRequest(t, http.MethodPost, srv.URL+"/api/users/email/confirm", "", "application/json", bytes.NewReader(body))
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d: %s", res.StatusCode, dat)
+	}
+	var got User
+	if err := json.Unmarshal([]byte(dat), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != "heisenberg@example.com" || userEmail(t, cfg, token) != "heisenberg@example.com" {
+		t.Errorf("got %+v, want the new email", got)
+	}
+
+	res, dat = sendUserRequest(t, http.MethodPost, srv.URL+"/api/users/email/confirm", "", "application/json", bytes.NewReader(body))
+	if res.StatusCode != http.StatusBadRequest || !strings.Contains(dat, `"code":"invalid_token"`) {
+		t.Errorf("reused token: got status %d: %s", res.StatusCode, dat)
+	}
+}
+
+func TestReplaceUserIsDeprecated(t *testing.T) {
+	srv, cfg, mail, token := newUserTestServer(t)
+
+	// A pending change doesn't survive a direct one
+	link := requestEmailChange(t, srv, mail, token, "jesse@example.com")
+
+	body, _ := json.Marshal(UpdateUserRequest{Email: "heisenberg@example.com", Password: "hunter3"})
+	res, dat := sendUserRequest(t, http.MethodPut, srv.URL+"/api/users", token, "application/json", bytes.NewReader(body))
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d: %s", res.StatusCode, dat)
+	}
+	if got := res.Header.Get("Deprecation"); got != "true" {
+		t.Errorf("Deprecation = %q, want true", got)
+	}
+	if got := res.Header.Get("Link"); got != `</api/users/me>; rel="successor-version"` {
+		t.Errorf("Link = %q", got)
+	}
+	if got := userEmail(t, cfg, token); got != "heisenberg@example.com" {
+		t.Fatalf("email after PUT = %s", got)
+	}
+
+	u, _ := url.Parse(link)
+	form := url.Values{"token": {u.Query().Get("token")}}
+	res, _ = sendUserRequest(t, http.MethodPost, srv.URL+"/api/users/email/confirm", "", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("confirming the superseded change: got status %d, want 400", res.StatusCode)
+	}
+}
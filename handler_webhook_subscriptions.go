@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/store"
+	"example.com/chirpy/internal/webhooks"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// WebhookSubscription is the API representation of an outgoing webhook. The
+// signing secret is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+// WebhookDelivery is one entry in a subscription's delivery log.
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EventID        uuid.UUID  `json:"event_id"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int32     `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+const (
+	defaultDeliveryLogLimit = 50
+	maxDeliveryLogLimit     = 500
+)
+
+func webhookSubscriptionFromDB(sub database.WebhookSubscription) WebhookSubscription {
+	return WebhookSubscription{
+		ID:        sub.ID,
+		CreatedAt: sub.CreatedAt,
+		UpdatedAt: sub.UpdatedAt,
+		URL:       sub.Url,
+		Events:    sub.Events,
+	}
+}
+
+// CreateWebhookSubscriptionRequest is the body of POST /api/webhooks.
+type CreateWebhookSubscriptionRequest struct {
+	URL    string   `json:"url" validate:"required,max=2048"`
+	Events []string `json:"events" validate:"required,max=32"`
+}
+
+func (req CreateWebhookSubscriptionRequest) Validate() []problem.FieldError {
+	var fields []problem.FieldError
+	if req.URL != "" {
+		target, err := url.Parse(req.URL)
+		if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+			fields = append(fields, fieldError("url", problem.FieldInvalid, "url must be an absolute http(s) URL"))
+		}
+	}
+	for i, event := range req.Events {
+		if !webhooks.IsEventType(event) {
+			fields = append(fields, fieldError(fmt.Sprintf("events[%d]", i), problem.FieldInvalid, "unknown event "+strconv.Quote(event)))
+		}
+	}
+	return fields
+}
+
+func webhookURLMessage(err error) string {
+	switch {
+	case errors.Is(err, webhooks.ErrInsecureURL):
+		return "url must use https"
+	case errors.Is(err, webhooks.ErrDisallowedAddress):
+		return "url must not point at a loopback, private or link-local address"
+	}
+	return "url host couldn't be resolved"
+}
+
+func (cfg *apiConfig) handlerCreateWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, unauthorized("Unauthorized", err))
+		return
+	}
+	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
+		return
+	}
+
+	var params CreateWebhookSubscriptionRequest
+	if err := decodeJSON(w, r, &params); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	// Validate has already rejected unparseable URLs
+	target, _ := url.Parse(params.URL)
+	if err := webhooks.CheckURL(r.Context(), target.String(), cfg.platform == "dev"); err != nil {
+		respondWithError(w, r, validationFailed(fieldError("url", problem.FieldInvalid, webhookURLMessage(err))))
+		return
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't generate secret", err))
+		return
+	}
+
+	sub, err := cfg.store.Webhooks().CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+		UserID: userID,
+		Url:    target.String(),
+		Secret: secret,
+		Events: params.Events,
+	})
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't create webhook", err))
+		return
+	}
+
+	resp := webhookSubscriptionFromDB(sub)
+	resp.Secret = sub.Secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handlerListWebhookSubscriptions(w http.ResponseWriter, r *http.Request) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, unauthorized("Unauthorized", err))
+		return
+	}
+	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
+		return
+	}
+
+	subs, err := cfg.store.Webhooks().ListWebhookSubscriptionsForUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't list webhooks", err))
+		return
+	}
+
+	resp := make([]WebhookSubscription, 0, len(subs))
+	for _, sub := range subs {
+		resp = append(resp, webhookSubscriptionFromDB(sub))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerDeleteWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, unauthorized("Unauthorized", err))
+		return
+	}
+	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
+		return
+	}
+
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, r, badRequest("Invalid webhook ID", err))
+		return
+	}
+
+	err = cfg.store.Webhooks().DeleteWebhookSubscription(r.Context(), database.DeleteWebhookSubscriptionParams{
+		ID:     webhookID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondWithError(w, r, notFound("Webhook not found", err))
+			return
+		}
+		respondWithError(w, r, internalError("Couldn't delete webhook", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, r, unauthorized("Unauthorized", err))
+		return
+	}
+	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, r, invalidToken("Invalid or expired access token", err))
+		return
+	}
+
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, r, badRequest("Invalid webhook ID", err))
+		return
+	}
+
+	limit := defaultDeliveryLogLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxDeliveryLogLimit {
+			respondWithError(w, r, validationFailed(fieldError("limit", problem.FieldInvalid,
+				fmt.Sprintf("limit must be between 1 and %d", maxDeliveryLogLimit))))
+			return
+		}
+	}
+
+	sub, err := cfg.store.Webhooks().GetWebhookSubscription(r.Context(), webhookID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, internalError("Couldn't retrieve webhook", err))
+		return
+	}
+	// Don't reveal whether someone else's webhook exists
+	if err != nil || sub.UserID != userID {
+		respondWithError(w, r, notFound("Webhook not found", err))
+		return
+	}
+
+	deliveries, err := cfg.store.Webhooks().ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		SubscriptionID: webhookID,
+		Limit:          int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, r, internalError("Couldn't list deliveries", err))
+		return
+	}
+
+	resp := make([]WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		item := WebhookDelivery{
+			ID:        d.ID,
+			CreatedAt: d.CreatedAt,
+			EventID:   d.EventID,
+			Status:    d.Status,
+			Attempts:  d.Attempts,
+			LastError: d.LastError.String,
+		}
+		if d.Status == webhooks.StatusPending {
+			item.NextAttemptAt = &d.NextAttemptAt
+		}
+		if d.LastStatusCode.Valid {
+			item.LastStatusCode = &d.LastStatusCode.Int32
+		}
+		if d.DeliveredAt.Valid {
+			item.DeliveredAt = &d.DeliveredAt.Time
+		}
+		resp = append(resp, item)
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
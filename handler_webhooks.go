@@ -1,72 +1,203 @@
 package main
 
 import (
-	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store"
 	"github.com/google/uuid"
+	"io"
+	"log/slog"
 	"net/http"
-	"os"
+	"time"
 )
 
-func (cfg *apiConfig) handlerWebhooks(w http.ResponseWriter, r *http.Request) {
-	type PolkaWebhookRequest struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
-	}
+const (
+	maxWebhookBodyBytes       = 1 << 20
+	webhookTimestampTolerance = 5 * time.Minute
+)
 
-	var req PolkaWebhookRequest
+// PolkaWebhookRequest is the payload Polka sends for subscription events.
+type PolkaWebhookRequest struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID      uuid.UUID  `json:"user_id"`
+		PeriodStart *time.Time `json:"period_start"`
+		PeriodEnd   *time.Time `json:"period_end"`
+	} `json:"data"`
+}
 
-	// Extract the API key from the header
-	apiKey, err := auth.GetAPIKey(r.Header)
+func (cfg *apiConfig) handlerWebhooks(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		respondWithError(w, r, badRequest("Couldn't read body", err))
 		return
 	}
 
-	// Compare the extracted key with the one from the environment
-	if apiKey != os.Getenv("POLKA_KEY") {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Verify the signature before looking at the payload
+	err = auth.VerifyWebhook(
+		body,
+		r.Header.Get(auth.WebhookTimestampHeader),
+		r.Header.Get(auth.WebhookSignatureHeader),
+		cfg.polkaKeys,
+		webhookTimestampTolerance,
+		time.Now(),
+	)
+	if err != nil {
+		cfg.metrics.polkaWebhooks.With(polkaInvalidSignature).Inc()
+		respondWithError(w, r, invalidToken("Invalid webhook signature", err))
 		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+	var req PolkaWebhookRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		cfg.metrics.polkaWebhooks.With(polkaInvalid).Inc()
+		respondWithError(w, r, badRequest("Couldn't decode parameters", err))
+		return
+	}
+	if req.ID == "" {
+		cfg.metrics.polkaWebhooks.With(polkaInvalid).Inc()
+		respondWithError(w, r, badRequest("Missing event id", nil))
 		return
 	}
 
-	// Only handle user.upgraded event
-	if req.Event != "user.upgraded" {
+	apply, ok := subscriptionEventHandlers[req.Event]
+	if !ok {
+		// Acknowledge events we don't care about so Polka stops retrying
+		cfg.metrics.polkaWebhooks.With(polkaIgnored).Inc()
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	// Get user by ID
-	_, err = cfg.db.GetUserByID(context.Background(), req.Data.UserID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			respondWithError(w, http.StatusNotFound, "User not found", err)
-		} else {
-			respondWithError(w, http.StatusInternalServerError, "Failed to retrieve user", err)
+	duplicate, orphaned := false, false
+	err = cfg.store.WithTx(r.Context(), func(tx store.Store) error {
+		// Record the event first so a retried delivery becomes a no-op
+		inserted, err := tx.PolkaEvents().RecordPolkaEvent(r.Context(), database.RecordPolkaEventParams{
+			ID:    req.ID,
+			Event: req.Event,
+		})
+		if err != nil {
+			return err
+		}
+		if !inserted {
+			duplicate = true
+			return nil
 		}
-		return
-	}
 
-	// Upgrade user to Chirpy Red
-	err = cfg.db.UpgradeUserToChirpRed(context.Background(), req.Data.UserID)
+		if _, err := tx.Users().GetUserByID(r.Context(), req.Data.UserID); err != nil {
+			return err
+		}
+		err = apply(r, tx, req)
+		if errors.Is(err, errNoSubscription) {
+			// Polka can send these for a subscription we never saw start.
+			// Keep the event so retries are deduplicated, and acknowledge it
+			// so Polka stops retrying.
+			orphaned = true
+			return nil
+		}
+		return err
+	})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) { // Handle case where user doesn't exist
-			respondWithError(w, http.StatusNotFound, "User not found", err)
+		cfg.metrics.polkaWebhooks.With(polkaError).Inc()
+		if errors.Is(err, store.ErrNotFound) {
+			respondWithError(w, r, notFound("User not found", err))
 			return
 		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to upgrade chirp to red", err)
+		respondWithError(w, r, internalError("Failed to apply subscription event", err))
 		return
 	}
 
+	switch {
+	case duplicate:
+		cfg.metrics.polkaWebhooks.With(polkaDuplicate).Inc()
+	case orphaned:
+		slog.WarnContext(r.Context(), "polka event for a user without a subscription",
+			"event_id", req.ID, "event", req.Event, "user_id", req.Data.UserID)
+		cfg.metrics.polkaWebhooks.With(polkaOrphaned).Inc()
+	default:
+		cfg.metrics.polkaWebhooks.With(polkaProcessed).Inc()
+	}
+
 	// Respond with 204 No Content on success
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// errNoSubscription is returned by event handlers that need an existing
+// subscription when the user has none.
+var errNoSubscription = errors.New("user has no subscription")
+
+type subscriptionEventHandler func(r *http.Request, s store.Store, req PolkaWebhookRequest) error
+
+var subscriptionEventHandlers = map[string]subscriptionEventHandler{
+	"user.upgraded":        handleSubscriptionStarted,
+	"subscription.renewed": handleSubscriptionRenewed,
+	"payment.failed":       handlePaymentFailed,
+	"user.downgraded":      handleSubscriptionEnded(store.SubscriptionCanceled),
+	"payment.refunded":     handleSubscriptionEnded(store.SubscriptionRefunded),
+}
+
+// handleSubscriptionStarted starts a new billing period from now, or from the
+// period given in the payload.
+func handleSubscriptionStarted(r *http.Request, s store.Store, req PolkaWebhookRequest) error {
+	start := time.Now().UTC()
+	if req.Data.PeriodStart != nil {
+		start = *req.Data.PeriodStart
+	}
+	end := start.Add(subscriptionPeriod)
+	if req.Data.PeriodEnd != nil {
+		end = *req.Data.PeriodEnd
+	}
+
+	_, err := s.Subscriptions().UpsertSubscription(r.Context(), database.UpsertSubscriptionParams{
+		UserID:             req.Data.UserID,
+		Status:             store.SubscriptionActive,
+		CurrentPeriodStart: start,
+		CurrentPeriodEnd:   end,
+	})
+	return err
+}
+
+// handleSubscriptionRenewed extends the subscription by one period, picking
+// up where the current one ends if it hasn't lapsed yet.
+func handleSubscriptionRenewed(r *http.Request, s store.Store, req PolkaWebhookRequest) error {
+	if req.Data.PeriodStart == nil {
+		sub, err := s.Subscriptions().GetSubscriptionByUserID(r.Context(), req.Data.UserID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		if err == nil && sub.CurrentPeriodEnd.After(time.Now()) {
+			start := sub.CurrentPeriodEnd
+			req.Data.PeriodStart = &start
+		}
+	}
+	return handleSubscriptionStarted(r, s, req)
+}
+
+// handlePaymentFailed keeps the user on Chirpy Red until the paid period
+// ends, giving Polka a chance to retry the charge.
+func handlePaymentFailed(r *http.Request, s store.Store, req PolkaWebhookRequest) error {
+	_, err := s.Subscriptions().SetSubscriptionStatus(r.Context(), database.SetSubscriptionStatusParams{
+		UserID: req.Data.UserID,
+		Status: store.SubscriptionPastDue,
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return errNoSubscription
+	}
+	return err
+}
+
+// handleSubscriptionEnded ends the subscription immediately with status.
+func handleSubscriptionEnded(status string) subscriptionEventHandler {
+	return func(r *http.Request, s store.Store, req PolkaWebhookRequest) error {
+		_, err := s.Subscriptions().EndSubscription(r.Context(), database.EndSubscriptionParams{
+			UserID: req.Data.UserID,
+			Status: status,
+		})
+		if errors.Is(err, store.ErrNotFound) {
+			return errNoSubscription
+		}
+		return err
+	}
+}
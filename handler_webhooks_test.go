@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testPolkaKey = "polka-test-key"
+
+func newWebhookTestConfig(t *testing.T) (*apiConfig, uuid.UUID) {
+	t.Helper()
+	s := store.NewMemory()
+	user, err := s.Users().CreateUser(context.Background(), database.CreateUserParams{
+		Email:          "walt@example.com",
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &apiConfig{
+		store:     s,
+		metrics:   newAppMetrics(nil),
+		polkaKeys: []string{testPolkaKey},
+	}, user.ID
+}
+
+// sendPolkaEvent delivers a signed event to the handler and returns the
+// response status.
+func sendPolkaEvent(t *testing.T, cfg *apiConfig, id, event string, userID uuid.UUID) int {
+	t.Helper()
+	var req PolkaWebhookRequest
+	req.ID = id
+	req.Event = event
+	req.Data.UserID = userID
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	r := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", bytes.NewReader(body))
+	r.Header.Set(auth.WebhookTimestampHeader, strconv.FormatInt(now, 10))
+	r.Header.Set(auth.WebhookSignatureHeader, "v1="+auth.SignWebhook(testPolkaKey, now, body))
+	w := httptest.NewRecorder()
+	cfg.handlerWebhooks(w, r)
+	return w.Code
+}
+
+func TestPolkaEventWithoutSubscription(t *testing.T) {
+	for _, event := range []string{"payment.failed", "user.downgraded", "payment.refunded"} {
+		t.Run(event, func(t *testing.T) {
+			cfg, userID := newWebhookTestConfig(t)
+
+			if got := sendPolkaEvent(t, cfg, "evt_1", event, userID); got != http.StatusNoContent {
+				t.Fatalf("got status %d, want 204", got)
+			}
+			if got := cfg.metrics.polkaWebhooks.With(polkaOrphaned).Value(); got != 1 {
+				t.Errorf("orphaned count = %v, want 1", got)
+			}
+
+			// The event is recorded, so a retry is a duplicate
+			if got := sendPolkaEvent(t, cfg, "evt_1", event, userID); got != http.StatusNoContent {
+				t.Fatalf("retry: got status %d, want 204", got)
+			}
+			if got := cfg.metrics.polkaWebhooks.With(polkaDuplicate).Value(); got != 1 {
+				t.Errorf("duplicate count = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestPolkaEventLifecycle(t *testing.T) {
+	cfg, userID := newWebhookTestConfig(t)
+	ctx := context.Background()
+
+	if got := sendPolkaEvent(t, cfg, "evt_1", "user.upgraded", userID); got != http.StatusNoContent {
+		t.Fatalf("upgrade: got status %d", got)
+	}
+	if red, _ := cfg.store.Subscriptions().IsUserChirpyRed(ctx, userID); !red {
+		t.Fatal("user not on Chirpy Red after upgrade")
+	}
+	if got := sendPolkaEvent(t, cfg, "evt_2", "user.downgraded", userID); got != http.StatusNoContent {
+		t.Fatalf("downgrade: got status %d", got)
+	}
+	if red, _ := cfg.store.Subscriptions().IsUserChirpyRed(ctx, userID); red {
+		t.Fatal("user still on Chirpy Red after downgrade")
+	}
+	if got := cfg.metrics.polkaWebhooks.With(polkaOrphaned).Value(); got != 0 {
+		t.Errorf("orphaned count = %v, want 0", got)
+	}
+}
+
+func TestPolkaEventUnknownUser(t *testing.T) {
+	cfg, _ := newWebhookTestConfig(t)
+	if got := sendPolkaEvent(t, cfg, "evt_1", "payment.failed", uuid.New()); got != http.StatusNotFound {
+		t.Fatalf("got status %d, want 404", got)
+	}
+}
+
+func TestPolkaEventReplayIgnored(t *testing.T) {
+	cfg, userID := newWebhookTestConfig(t)
+	ctx := context.Background()
+
+	if got := sendPolkaEvent(t, cfg, "evt_1", "user.upgraded", userID); got != http.StatusNoContent {
+		t.Fatalf("upgrade: got status %d", got)
+	}
+	if got := sendPolkaEvent(t, cfg, "evt_2", "user.downgraded", userID); got != http.StatusNoContent {
+		t.Fatalf("downgrade: got status %d", got)
+	}
+
+	// A replayed upgrade is acknowledged but must not upgrade the user again
+	if got := sendPolkaEvent(t, cfg, "evt_1", "user.upgraded", userID); got != http.StatusNoContent {
+		t.Fatalf("replay: got status %d, want 204", got)
+	}
+	if red, _ := cfg.store.Subscriptions().IsUserChirpyRed(ctx, userID); red {
+		t.Error("replayed upgrade put the user back on Chirpy Red")
+	}
+	if got := cfg.metrics.polkaWebhooks.With(polkaDuplicate).Value(); got != 1 {
+		t.Errorf("duplicate count = %v, want 1", got)
+	}
+	if got := cfg.metrics.polkaWebhooks.With(polkaProcessed).Value(); got != 2 {
+		t.Errorf("processed count = %v, want 2", got)
+	}
+}
+
+func TestPolkaEventBadSignature(t *testing.T) {
+	cfg, userID := newWebhookTestConfig(t)
+	body, err := json.Marshal(map[string]any{"id": "evt_1", "event": "user.upgraded", "data": map[string]any{"user_id": userID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+	}{
+		{name: "unsigned"},
+		{name: "wrong key", timestamp: strconv.FormatInt(now, 10), signature: "v1=" + auth.SignWebhook("other-key", now, body)},
+		{name: "stale", timestamp: strconv.FormatInt(now-3600, 10), signature: "v1=" + auth.SignWebhook(testPolkaKey, now-3600, body)},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", bytes.NewReader(body))
+			if tt.timestamp != "" {
+				r.Header.Set(auth.WebhookTimestampHeader, tt.timestamp)
+			}
+			if tt.signature != "" {
+				r.Header.Set(auth.WebhookSignatureHeader, tt.signature)
+			}
+			w := httptest.NewRecorder()
+			cfg.handlerWebhooks(w, r)
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("got status %d, want 401", w.Code)
+			}
+			if got := cfg.metrics.polkaWebhooks.With(polkaInvalidSignature).Value(); got != float64(i+1) {
+				t.Errorf("invalid signature count = %v, want %d", got, i+1)
+			}
+		})
+	}
+	if red, _ := cfg.store.Subscriptions().IsUserChirpyRed(context.Background(), userID); red {
+		t.Error("unsigned upgrade put the user on Chirpy Red")
+	}
+}
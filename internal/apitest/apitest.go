@@ -0,0 +1,528 @@
+// Package apitest is an HTTP-level integration suite for the chirpy API.
+// The same suite is run against a server on Postgres and on SQLite.
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Run executes the suite. newHandler must return the API handler backed by
+// an empty database each time it is called.
+func Run(t *testing.T, newHandler func(t *testing.T) http.Handler) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, c *client)
+	}{
+		{"Users", testUsers},
+		{"Login", testLogin},
+		{"Chirps", testChirps},
+		{"ChirpPaging", testChirpPaging},
+		{"RefreshAndRevoke", testRefreshAndRevoke},
+		{"UpdateUser", testUpdateUser},
+		{"PatchUser", testPatchUser},
+		{"PasswordLength", testPasswordLength},
+		{"Follows", testFollows},
+		{"Timeline", testTimeline},
+		{"GraphQLReactions", testGraphQLReactions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(newHandler(t))
+			defer srv.Close()
+			tt.fn(t, &client{t: t, baseURL: srv.URL})
+		})
+	}
+}
+
+type client struct {
+	t       *testing.T
+	baseURL string
+}
+
+// do sends a JSON request and decodes a JSON response into out, failing the
+// test unless the status matches want. It returns the response headers.
+func (c *client) do(method, path, token string, body any, want int, out any) http.Header {
+	c.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		dat, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(dat)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	dat, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != want {
+		c.t.Fatalf("%s %s: got status %d, want %d: %s", method, path, resp.StatusCode, want, dat)
+	}
+	if out != nil {
+		if err := json.Unmarshal(dat, out); err != nil {
+			c.t.Fatalf("%s %s: decoding %s: %v", method, path, dat, err)
+		}
+	}
+	return resp.Header
+}
+
+type user struct {
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	IsChirpyRed  bool   `json:"is_chirpy_red"`
+}
+
+type chirp struct {
+	ID     string `json:"id"`
+	Body   string `json:"body"`
+	UserID string `json:"user_id"`
+}
+
+func credentials(email string) map[string]string {
+	return map[string]string{"email": email, "password": "hunter2"}
+}
+
+func (c *client) signUp(email string) user {
+	c.t.Helper()
+	var u user
+	c.do("POST", "/api/users", "", credentials(email), http.StatusCreated, &u)
+	return u
+}
+
+func (c *client) login(email string) user {
+	c.t.Helper()
+	var u user
+	c.do("POST", "/api/login", "", credentials(email), http.StatusOK, &u)
+	return u
+}
+
+func testUsers(t *testing.T, c *client) {
+	u := c.signUp("walt@example.com")
+	if u.ID == "" || u.Email != "walt@example.com" || u.IsChirpyRed {
+		t.Fatalf("unexpected user: %+v", u)
+	}
+	c.do("POST", "/api/users", "", credentials("walt@example.com"), http.StatusConflict, nil)
+}
+
+func testLogin(t *testing.T, c *client) {
+	c.signUp("walt@example.com")
+	u := c.login("walt@example.com")
+	if u.Token == "" || u.RefreshToken == "" {
+		t.Fatalf("login returned no tokens: %+v", u)
+	}
+	c.do("POST", "/api/login", "", map[string]string{
+		"email":    "walt@example.com",
+		"password": "wrong",
+	}, http.StatusUnauthorized, nil)
+}
+
+func testChirps(t *testing.T, c *client) {
+	c.signUp("walt@example.com")
+	c.signUp("jesse@example.com")
+	walt := c.login("walt@example.com")
+	jesse := c.login("jesse@example.com")
+
+	var first, second, third chirp
+	c.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "I am the one who knocks"}, http.StatusCreated, &first)
+	c.do("POST", "/api/chirps", jesse.Token, map[string]string{"body": "What a kerfuffle"}, http.StatusCreated, &second)
+	c.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name"}, http.StatusCreated, &third)
+	if second.Body != "What a ****" {
+		t.Fatalf("profanity not cleaned: %q", second.Body)
+	}
+	c.do("POST", "/api/chirps", "", map[string]string{"body": "anonymous"}, http.StatusUnauthorized, nil)
+	c.do("POST", "/api/chirps", walt.Token, map[string]string{"body": string(make([]byte, 141))}, http.StatusBadRequest, nil)
+
+	var all []chirp
+	c.do("GET", "/api/chirps", "", nil, http.StatusOK, &all)
+	wantChirps(t, all, first.ID, second.ID, third.ID)
+
+	var desc []chirp
+	c.do("GET", "/api/chirps?sort=desc", "", nil, http.StatusOK, &desc)
+	wantChirps(t, desc, third.ID, second.ID, first.ID)
+
+	var byWalt []chirp
+	c.do("GET", "/api/chirps?author_id="+walt.ID, "", nil, http.StatusOK, &byWalt)
+	wantChirps(t, byWalt, first.ID, third.ID)
+
+	var got chirp
+	c.do("GET", "/api/chirps/"+first.ID, "", nil, http.StatusOK, &got)
+	if got.Body != first.Body || got.UserID != walt.ID {
+		t.Fatalf("GET chirp = %+v, want %+v", got, first)
+	}
+
+	c.do("DELETE", "/api/chirps/"+first.ID, jesse.Token, nil, http.StatusForbidden, nil)
+	c.do("DELETE", "/api/chirps/"+first.ID, walt.Token, nil, http.StatusNoContent, nil)
+	c.do("GET", "/api/chirps/"+first.ID, "", nil, http.StatusNotFound, nil)
+}
+
+func testChirpPaging(t *testing.T, c *client) {
+	c.signUp("walt@example.com")
+	walt := c.login("walt@example.com")
+	var ids []string
+	for i := range 5 {
+		var ch chirp
+		c.do("POST", "/api/chirps", walt.Token, map[string]string{"body": fmt.Sprintf("chirp %d", i)}, http.StatusCreated, &ch)
+		ids = append(ids, ch.ID)
+	}
+
+	// Following the next links visits every chirp once, in order
+	for _, tt := range []struct {
+		path string
+		want []string
+	}{
+		{"/api/chirps?limit=2", ids},
+		{"/api/chirps?limit=2&sort=desc", []string{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+		{"/api/chirps?limit=3&author_id=" + walt.ID, ids},
+	} {
+		var all []chirp
+		pages := 0
+		for path := tt.path; path != ""; pages++ {
+			var page []chirp
+			h := c.do("GET", path, "", nil, http.StatusOK, &page)
+			all = append(all, page...)
+			path = nextLink(h)
+		}
+		wantChirps(t, all, tt.want...)
+		if pages < 2 {
+			t.Errorf("%s: got %d pages", tt.path, pages)
+		}
+	}
+
+	c.do("GET", "/api/chirps?limit=0", "", nil, http.StatusBadRequest, nil)
+	c.do("GET", "/api/chirps?limit=101", "", nil, http.StatusBadRequest, nil)
+	c.do("GET", "/api/chirps?after=nope", "", nil, http.StatusBadRequest, nil)
+}
+
+// nextLink returns the target of the rel="next" Link header, or "".
+func nextLink(h http.Header) string {
+	for _, link := range h.Values("Link") {
+		target, params, _ := strings.Cut(link, ";")
+		if strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+func wantChirps(t *testing.T, got []chirp, ids ...string) {
+	t.Helper()
+	var gotIDs []string
+	for _, c := range got {
+		gotIDs = append(gotIDs, c.ID)
+	}
+	if fmt.Sprint(gotIDs) != fmt.Sprint(ids) {
+		t.Fatalf("got chirps %v, want %v", gotIDs, ids)
+	}
+}
+
+func testRefreshAndRevoke(t *testing.T, c *client) {
+	c.signUp("walt@example.com")
+	u := c.login("walt@example.com")
+
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	c.do("POST", "/api/refresh", u.RefreshToken, nil, http.StatusOK, &refreshed)
+	if refreshed.Token == "" {
+		t.Fatal("refresh returned no token")
+	}
+
+	c.do("POST", "/api/revoke", u.RefreshToken, nil, http.StatusNoContent, nil)
+	c.do("POST", "/api/refresh", u.RefreshToken, nil, http.StatusUnauthorized, nil)
+}
+
+func testUpdateUser(t *testing.T, c *client) {
+	c.signUp("walt@example.com")
+	c.signUp("jesse@example.com")
+	u := c.login("walt@example.com")
+
+	c.do("PUT", "/api/users", u.Token, map[string]string{
+		"email": "heisenberg@example.com",
+	}, http.StatusBadRequest, nil)
+	c.do("PUT", "/api/users", u.Token, map[string]string{
+		"email":            "heisenberg@example.com",
+		"password":         "hunter3",
+		"current_password": "wrong",
+	}, http.StatusUnauthorized, nil)
+	c.do("PUT", "/api/users", u.Token, map[string]string{
+		"email":    "jesse@example.com",
+		"password": "hunter3",
+	}, http.StatusConflict, nil)
+
+	// PUT keeps its original contract: the token is enough and both
+	// changes take effect at once
+	var updated struct {
+		user
+		PendingEmail string `json:"pending_email"`
+	}
+	c.do("PUT", "/api/users", u.Token, map[string]string{
+		"email":    "heisenberg@example.com",
+		"password": "hunter3",
+	}, http.StatusOK, &updated)
+	if updated.Email != "heisenberg@example.com" || updated.PendingEmail != "" {
+		t.Fatalf("PUT didn't change the email at once: %+v", updated)
+	}
+	c.do("POST", "/api/login", "", map[string]string{
+		"email":    "heisenberg@example.com",
+		"password": "hunter3",
+	}, http.StatusOK, nil)
+	c.do("POST", "/api/login", "", map[string]string{
+		"email":    "walt@example.com",
+		"password": "hunter3",
+	}, http.StatusUnauthorized, nil)
+
+	// current_password is checked when it is sent
+	c.do("PUT", "/api/users", u.Token, map[string]string{
+		"email":            "heisenberg@example.com",
+		"password":         "hunter4",
+		"current_password": "hunter3",
+	}, http.StatusOK, nil)
+}
+
+func testPatchUser(t *testing.T, c *client) {
+	c.signUp("walt@example.com")
+	u := c.login("walt@example.com")
+
+	c.do("PATCH", "/api/users/me", u.Token, map[string]string{
+		"password":         "hunter3",
+		"current_password": "wrong",
+	}, http.StatusUnauthorized, nil)
+	c.do("PATCH", "/api/users/me", u.Token, map[string]string{
+		"password":         "hunter3",
+		"current_password": "hunter2",
+	}, http.StatusOK, nil)
+	c.do("POST", "/api/login", "", map[string]string{
+		"email":    "walt@example.com",
+		"password": "hunter3",
+	}, http.StatusOK, nil)
+}
+
+// testPasswordLength checks that passwords are limited to bcrypt's 72
+// bytes, not 72 characters.
+func testPasswordLength(t *testing.T, c *client) {
+	long := strings.Repeat("é", 40) // 40 characters, 80 bytes
+	c.signUp("walt@example.com")
+	u := c.login("walt@example.com")
+
+	var p problem
+	c.do("POST", "/api/users", "", map[string]string{
+		"email":    "jesse@example.com",
+		"password": long,
+	}, http.StatusBadRequest, &p)
+	p.wantField(t, "password", "too_long")
+	c.do("PUT", "/api/users", u.Token, map[string]string{
+		"email":            "walt@example.com",
+		"password":         long,
+		"current_password": "hunter2",
+	}, http.StatusBadRequest, &p)
+	p.wantField(t, "password", "too_long")
+	c.do("PATCH", "/api/users/me", u.Token, map[string]string{
+		"password":         long,
+		"current_password": "hunter2",
+	}, http.StatusBadRequest, &p)
+	p.wantField(t, "password", "too_long")
+
+	c.do("POST", "/api/users", "", map[string]string{
+		"email":    "jesse@example.com",
+		"password": strings.Repeat("é", 36),
+	}, http.StatusCreated, nil)
+}
+
+type problem struct {
+	Errors []struct {
+		Field string `json:"field"`
+		Code  string `json:"code"`
+	} `json:"errors"`
+}
+
+func (p problem) wantField(t *testing.T, field, code string) {
+	t.Helper()
+	for _, fe := range p.Errors {
+		if fe.Field == field && fe.Code == code {
+			return
+		}
+	}
+	t.Errorf("want a %s error on %s, got %+v", code, field, p.Errors)
+}
+
+func testFollows(t *testing.T, c *client) {
+	c.signUp("walt@example.com")
+	jesse := c.signUp("jesse@example.com")
+	walt := c.login("walt@example.com")
+
+	c.do("POST", "/api/users/"+jesse.ID+"/follow", walt.Token, nil, http.StatusNoContent, nil)
+	c.do("POST", "/api/users/"+jesse.ID+"/follow", walt.Token, nil, http.StatusNoContent, nil)
+	c.do("POST", "/api/users/"+jesse.ID+"/follow", "", nil, http.StatusUnauthorized, nil)
+	c.do("POST", "/api/users/00000000-0000-0000-0000-000000000000/follow", walt.Token, nil, http.StatusNotFound, nil)
+	c.do("DELETE", "/api/users/"+jesse.ID+"/follow", walt.Token, nil, http.StatusNoContent, nil)
+}
+
+func testTimeline(t *testing.T, c *client) {
+	c.signUp("walt@example.com")
+	jesse := c.signUp("jesse@example.com")
+	c.signUp("skyler@example.com")
+	walt := c.login("walt@example.com")
+	jesseLogin := c.login("jesse@example.com")
+	skyler := c.login("skyler@example.com")
+
+	post := func(token, body string) string {
+		var ch chirp
+		c.do("POST", "/api/chirps", token, map[string]string{"body": body}, http.StatusCreated, &ch)
+		return ch.ID
+	}
+	beforeFollow := post(jesseLogin.Token, "Yeah science!")
+	own := post(walt.Token, "Say my name")
+	post(skyler.Token, "I'm the one who counts")
+	c.do("POST", "/api/users/"+jesse.ID+"/follow", walt.Token, nil, http.StatusNoContent, nil)
+	followed := post(jesseLogin.Token, "Yeah, Mr. White!")
+
+	// The timeline holds the caller's chirps and those of everyone they
+	// follow, including chirps from before the follow, newest first
+	var all []chirp
+	pages := 0
+	for path := "/api/users/me/timeline?limit=2"; path != ""; pages++ {
+		var page []chirp
+		h := c.do("GET", path, walt.Token, nil, http.StatusOK, &page)
+		all = append(all, page...)
+		path = nextLink(h)
+	}
+	wantChirps(t, all, followed, own, beforeFollow)
+	if pages != 2 {
+		t.Errorf("got %d pages, want 2", pages)
+	}
+
+	var timeline []chirp
+	c.do("GET", "/api/users/me/timeline", jesseLogin.Token, nil, http.StatusOK, &timeline)
+	wantChirps(t, timeline, followed, beforeFollow)
+
+	c.do("GET", "/api/users/me/timeline", "", nil, http.StatusUnauthorized, nil)
+	c.do("GET", "/api/users/me/timeline?limit=0", walt.Token, nil, http.StatusBadRequest, nil)
+	c.do("GET", "/api/users/me/timeline?after=nope", walt.Token, nil, http.StatusBadRequest, nil)
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+// graphQL runs query with variables and returns the response, which has
+// status 200 even when it holds errors.
+func (c *client) graphQL(token, query string, variables map[string]any) graphQLResponse {
+	c.t.Helper()
+	var res graphQLResponse
+	c.do("POST", "/api/graphql", token, map[string]any{"query": query, "variables": variables}, http.StatusOK, &res)
+	return res
+}
+
+// wantCode fails the test unless the response's only error has code.
+func (r graphQLResponse) wantCode(t *testing.T, code string) {
+	t.Helper()
+	if len(r.Errors) != 1 || r.Errors[0].Extensions["code"] != code {
+		t.Fatalf("want a %s error, got %+v", code, r.Errors)
+	}
+}
+
+func testGraphQLReactions(t *testing.T, c *client) {
+	c.signUp("walt@example.com")
+	c.signUp("jesse@example.com")
+	walt := c.login("walt@example.com")
+	jesse := c.login("jesse@example.com")
+	var ch chirp
+	c.do("POST", "/api/chirps", walt.Token, map[string]string{"body": "Say my name"}, http.StatusCreated, &ch)
+
+	const react = `mutation($id: ID!, $kind: String!) {
+		addReaction(chirpId: $id, kind: $kind) { reactions { kind count viewerHasReacted } }
+	}`
+	const unreact = `mutation($id: ID!, $kind: String!) {
+		removeReaction(chirpId: $id, kind: $kind) { reactions { kind count viewerHasReacted } }
+	}`
+	reactions := func(r graphQLResponse, field string) string {
+		t.Helper()
+		if len(r.Errors) > 0 {
+			t.Fatalf("%s: %+v", field, r.Errors)
+		}
+		var data map[string]struct {
+			Reactions []struct {
+				Kind             string `json:"kind"`
+				Count            int    `json:"count"`
+				ViewerHasReacted bool   `json:"viewerHasReacted"`
+			} `json:"reactions"`
+		}
+		if err := json.Unmarshal(r.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		var s []string
+		for _, re := range data[field].Reactions {
+			s = append(s, fmt.Sprintf("%s=%d/%v", re.Kind, re.Count, re.ViewerHasReacted))
+		}
+		return strings.Join(s, " ")
+	}
+	vars := func(kind string) map[string]any { return map[string]any{"id": ch.ID, "kind": kind} }
+
+	if got := reactions(c.graphQL(walt.Token, react, vars("like")), "addReaction"); got != "like=1/true" {
+		t.Fatalf("after liking: %s", got)
+	}
+	// Reacting twice is not an error and doesn't count twice
+	if got := reactions(c.graphQL(walt.Token, react, vars("like")), "addReaction"); got != "like=1/true" {
+		t.Fatalf("after liking again: %s", got)
+	}
+	reactions(c.graphQL(jesse.Token, react, vars("laugh")), "addReaction")
+	if got := reactions(c.graphQL(jesse.Token, react, vars("like")), "addReaction"); got != "like=2/true laugh=1/true" {
+		t.Fatalf("after jesse reacted: %s", got)
+	}
+
+	// Reactions are visible without an access token, as nobody's
+	query := `query($id: ID!) { chirp(id: $id) { reactions { kind count viewerHasReacted } } }`
+	if got := reactions(c.graphQL("", query, map[string]any{"id": ch.ID}), "chirp"); got != "like=2/false laugh=1/false" {
+		t.Fatalf("anonymous reactions: %s", got)
+	}
+
+	// The mutation's result isn't the one read earlier in the same request
+	both := `mutation($id: ID!) {
+		first: addReaction(chirpId: $id, kind: "sad") { reactions { kind count } }
+		second: removeReaction(chirpId: $id, kind: "sad") { reactions { kind count } }
+	}`
+	if got := reactions(c.graphQL(walt.Token, both, map[string]any{"id": ch.ID}), "second"); got != "like=2/false laugh=1/false" {
+		t.Fatalf("after adding and removing in one request: %s", got)
+	}
+
+	if got := reactions(c.graphQL(jesse.Token, unreact, vars("laugh")), "removeReaction"); got != "like=2/true" {
+		t.Fatalf("after removing: %s", got)
+	}
+	if got := reactions(c.graphQL(jesse.Token, unreact, vars("laugh")), "removeReaction"); got != "like=2/true" {
+		t.Fatalf("after removing again: %s", got)
+	}
+
+	c.graphQL("", react, vars("like")).wantCode(t, "unauthorized")
+	c.graphQL(walt.Token, react, vars("meh")).wantCode(t, "validation_failed")
+	missing := map[string]any{"id": "00000000-0000-0000-0000-000000000000", "kind": "like"}
+	c.graphQL(walt.Token, react, missing).wantCode(t, "not_found")
+	c.graphQL(walt.Token, unreact, missing).wantCode(t, "not_found")
+}
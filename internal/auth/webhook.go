@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookTimestampHeader carries the Unix time at which a webhook was signed.
+	WebhookTimestampHeader = "X-Polka-Timestamp"
+	// WebhookSignatureHeader carries one or more "v1=<hex>" signatures,
+	// separated by commas.
+	WebhookSignatureHeader = "X-Polka-Signature"
+)
+
+var (
+	// ErrMissingSignature -
+	ErrMissingSignature = errors.New("missing webhook signature or timestamp")
+	// ErrStaleTimestamp -
+	ErrStaleTimestamp = errors.New("webhook timestamp outside tolerance window")
+	// ErrInvalidSignature -
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// SignWebhook returns the hex-encoded HMAC-SHA256 of "<timestamp>.<body>".
+func SignWebhook(key string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks that the signature header matches body under at least
+// one of keys, and that the timestamp is within tolerance of now. Accepting
+// several keys allows a secret to be rotated without dropping deliveries.
+func VerifyWebhook(body []byte, timestampHeader, signatureHeader string, keys []string, tolerance time.Duration, now time.Time) error {
+	if timestampHeader == "" || signatureHeader == "" {
+		return ErrMissingSignature
+	}
+
+	ts, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrStaleTimestamp
+	}
+
+	var candidates [][]byte
+	for _, part := range strings.Split(signatureHeader, ",") {
+		scheme, sig, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || scheme != "v1" {
+			continue
+		}
+		decoded, err := hex.DecodeString(sig)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, decoded)
+	}
+
+	valid := false
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		expected, _ := hex.DecodeString(SignWebhook(key, ts, body))
+		for _, candidate := range candidates {
+			// Keep comparing after a match so timing doesn't reveal which key matched
+			if hmac.Equal(expected, candidate) {
+				valid = true
+			}
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	const (
+		oldKey = "old-key"
+		newKey = "new-key"
+	)
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"id":"evt_1","event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	ts := func(d time.Duration) string { return strconv.FormatInt(now.Add(d).Unix(), 10) }
+	sig := func(key string, d time.Duration) string { return "v1=" + SignWebhook(key, now.Add(d).Unix(), body) }
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		keys      []string
+		body      []byte
+		want      error
+	}{
+		{name: "valid", timestamp: ts(0), signature: sig(newKey, 0), keys: []string{newKey}},
+		{name: "slightly old", timestamp: ts(-4 * time.Minute), signature: sig(newKey, -4*time.Minute), keys: []string{newKey}},
+		{name: "slightly ahead", timestamp: ts(4 * time.Minute), signature: sig(newKey, 4*time.Minute), keys: []string{newKey}},
+		{name: "rotated key, old signature", timestamp: ts(0), signature: sig(oldKey, 0), keys: []string{newKey, oldKey}},
+		{name: "rotated key, new signature", timestamp: ts(0), signature: sig(newKey, 0), keys: []string{newKey, oldKey}},
+		{name: "several signatures", timestamp: ts(0), signature: "v1=00ff, " + sig(newKey, 0), keys: []string{newKey}},
+		{name: "unknown scheme skipped", timestamp: ts(0), signature: "v0=abc," + sig(newKey, 0), keys: []string{newKey}},
+
+		{name: "retired key", timestamp: ts(0), signature: sig(oldKey, 0), keys: []string{newKey}, want: ErrInvalidSignature},
+		{name: "wrong signature", timestamp: ts(0), signature: "v1=" + SignWebhook(newKey, now.Unix(), []byte("{}")), keys: []string{newKey}, want: ErrInvalidSignature},
+		{name: "tampered body", timestamp: ts(0), signature: sig(newKey, 0), keys: []string{newKey}, body: []byte(`{"id":"evt_2"}`), want: ErrInvalidSignature},
+		{name: "signature for another timestamp", timestamp: ts(time.Minute), signature: sig(newKey, 0), keys: []string{newKey}, want: ErrInvalidSignature},
+		{name: "empty key never matches", timestamp: ts(0), signature: "v1=" + SignWebhook("", now.Unix(), body), keys: []string{""}, want: ErrInvalidSignature},
+		{name: "no keys", timestamp: ts(0), signature: sig(newKey, 0), want: ErrInvalidSignature},
+		{name: "not hex", timestamp: ts(0), signature: "v1=zz", keys: []string{newKey}, want: ErrInvalidSignature},
+		{name: "no scheme", timestamp: ts(0), signature: SignWebhook(newKey, now.Unix(), body), keys: []string{newKey}, want: ErrInvalidSignature},
+
+		{name: "stale", timestamp: ts(-6 * time.Minute), signature: sig(newKey, -6*time.Minute), keys: []string{newKey}, want: ErrStaleTimestamp},
+		{name: "future", timestamp: ts(6 * time.Minute), signature: sig(newKey, 6*time.Minute), keys: []string{newKey}, want: ErrStaleTimestamp},
+
+		{name: "no timestamp", signature: sig(newKey, 0), keys: []string{newKey}, want: ErrMissingSignature},
+		{name: "no signature", timestamp: ts(0), keys: []string{newKey}, want: ErrMissingSignature},
+		{name: "malformed timestamp", timestamp: "yesterday", signature: sig(newKey, 0), keys: []string{newKey}, want: ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := body
+			if tt.body != nil {
+				b = tt.body
+			}
+			err := VerifyWebhook(b, tt.timestamp, tt.signature, tt.keys, 5*time.Minute, now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
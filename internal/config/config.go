@@ -0,0 +1,512 @@
+// Package config loads the server configuration. Each setting is taken from,
+// in increasing order of precedence: its default, a JSON config file, an
+// environment variable, and a command-line flag. Secrets can also be read
+// from the file named by the variable with a _FILE suffix, e.g.
+// JWT_SECRET_FILE.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/chirpy/internal/logging"
+	"example.com/chirpy/internal/ratelimit"
+)
+
+// Config holds every server setting.
+type Config struct {
+	Port         string
+	FilepathRoot string
+	Platform     string
+	BaseURL      string
+	LogLevel     string
+	// FixturesDir, if set, is where /admin/reset looks for fixture sets
+	// instead of the built-in ones.
+	FixturesDir string
+
+	TraceExporter string
+	OTLPEndpoint  string
+	// ValidateResponses checks every response against the OpenAPI
+	// document and logs mismatches. It buffers response bodies.
+	ValidateResponses bool
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	ShutdownDelay     time.Duration
+
+	DBURL             string
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+	AutoMigrate       bool
+
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// MailTransport is how email is sent: "log" only logs the recipient
+	// and subject, "smtp" sends through SMTPAddr and "http" posts to
+	// MailHTTPURL.
+	MailTransport string
+	MailFrom      string
+	SMTPAddr      string
+	SMTPUsername  string
+	SMTPPassword  string
+	MailHTTPURL   string
+	MailHTTPToken string
+
+	// PolkaKeys holds every accepted Polka signing key so keys can be
+	// rotated without downtime. It includes polka-key.
+	PolkaKeys []string
+	// polkaKey is the single-key setting. It is kept apart until every
+	// source is applied, so that polka-keys from one source doesn't drop a
+	// polka-key from another.
+	polkaKey string
+
+	FreeMaxChirpLength int
+	RedMaxChirpLength  int
+
+	// RateLimitStore is "memory" or "postgres". Use postgres when running
+	// more than one replica.
+	RateLimitStore        string
+	RateLimitSignup       ratelimit.Policy
+	RateLimitLogin        ratelimit.Policy
+	RateLimitChirpsCreate ratelimit.Policy
+	// TrustForwardedFor takes the client IP from the last X-Forwarded-For
+	// entry. Only enable it behind a single proxy that appends to the
+	// header.
+	TrustForwardedFor bool
+
+	// StreamReplayEvents is how many recent events /api/stream keeps for
+	// clients that reconnect with Last-Event-ID.
+	StreamReplayEvents int
+
+	// GraphQLMaxDepth and GraphQLMaxComplexity bound the queries
+	// /api/graphql accepts. See package graphql for how complexity is
+	// counted.
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+}
+
+// Default returns the configuration used when nothing is set.
+func Default() Config {
+	return Config{
+		Port:               "8080",
+		FilepathRoot:       ".",
+		LogLevel:           "info",
+		TraceExporter:      "none",
+		OTLPEndpoint:       "http://localhost:4318/v1/traces",
+		ReadTimeout:        15 * time.Second,
+		ReadHeaderTimeout:  5 * time.Second,
+		WriteTimeout:       30 * time.Second,
+		IdleTimeout:        2 * time.Minute,
+		ShutdownTimeout:    30 * time.Second,
+		DBMaxOpenConns:     25,
+		DBMaxIdleConns:     25,
+		DBConnMaxLifetime:  30 * time.Minute,
+		DBConnMaxIdleTime:  5 * time.Minute,
+		AutoMigrate:        true,
+		AccessTokenTTL:     time.Hour,
+		RefreshTokenTTL:    60 * 24 * time.Hour,
+		MailTransport:      "log",
+		FreeMaxChirpLength: 140,
+		RedMaxChirpLength:  560,
+		RateLimitStore:     "memory",
+		StreamReplayEvents: 1000,
+		RateLimitSignup: ratelimit.Policy{
+			Anonymous: ratelimit.Limit{Requests: 10, Per: time.Hour},
+			Standard:  ratelimit.Limit{Requests: 10, Per: time.Hour},
+			Premium:   ratelimit.Limit{Requests: 10, Per: time.Hour},
+		},
+		RateLimitLogin: ratelimit.Policy{
+			Anonymous: ratelimit.Limit{Requests: 10, Per: time.Minute},
+			Standard:  ratelimit.Limit{Requests: 10, Per: time.Minute},
+			Premium:   ratelimit.Limit{Requests: 10, Per: time.Minute},
+		},
+		RateLimitChirpsCreate: ratelimit.Policy{
+			Anonymous: ratelimit.Limit{Requests: 10, Per: time.Minute},
+			Standard:  ratelimit.Limit{Requests: 30, Per: time.Minute},
+			Premium:   ratelimit.Limit{Requests: 120, Per: time.Minute},
+		},
+		GraphQLMaxDepth:      10,
+		GraphQLMaxComplexity: 5000,
+	}
+}
+
+// setting describes one configuration value and where it can come from.
+type setting struct {
+	key    string // config file key and flag name
+	env    string
+	secret bool // also readable from env+"_FILE"
+	usage  string
+	set    func(c *Config, v string) error
+}
+
+var settings = []setting{
+	{key: "port", env: "PORT", usage: "port to listen on", set: setString(func(c *Config) *string { return &c.Port })},
+	{key: "filepath-root", env: "FILEPATH_ROOT", usage: "directory served under /app/", set: setString(func(c *Config) *string { return &c.FilepathRoot })},
+	{key: "platform", env: "PLATFORM", usage: `deployment platform; "dev" enables /admin/reset`, set: setString(func(c *Config) *string { return &c.Platform })},
+	{key: "fixtures-dir", env: "FIXTURES_DIR", usage: "directory of JSON fixture sets /admin/reset can load (default the built-in sets)", set: setString(func(c *Config) *string { return &c.FixturesDir })},
+	{key: "base-url", env: "BASE_URL", usage: "public URL used in links sent to users (default http://localhost:<port>)", set: setString(func(c *Config) *string { return &c.BaseURL })},
+	{key: "log-level", env: "LOG_LEVEL", usage: "minimum log level: debug, info, warn or error", set: setString(func(c *Config) *string { return &c.LogLevel })},
+	{key: "trace-exporter", env: "TRACE_EXPORTER", usage: "where to send traces: none, stdout or otlp", set: setString(func(c *Config) *string { return &c.TraceExporter })},
+	{key: "otlp-endpoint", env: "OTLP_ENDPOINT", usage: "OTLP/HTTP traces endpoint used by the otlp exporter", set: setString(func(c *Config) *string { return &c.OTLPEndpoint })},
+	{key: "validate-responses", env: "VALIDATE_RESPONSES", usage: "log responses that don't match the OpenAPI document (development and CI)", set: setBool(func(c *Config) *bool { return &c.ValidateResponses })},
+
+	{key: "read-timeout", env: "READ_TIMEOUT", usage: "maximum time to read a request including its body", set: setDuration(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{key: "read-header-timeout", env: "READ_HEADER_TIMEOUT", usage: "maximum time to read request headers", set: setDuration(func(c *Config) *time.Duration { return &c.ReadHeaderTimeout })},
+	{key: "write-timeout", env: "WRITE_TIMEOUT", usage: "maximum time to write a response", set: setDuration(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{key: "idle-timeout", env: "IDLE_TIMEOUT", usage: "how long idle keep-alive connections stay open", set: setDuration(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{key: "shutdown-timeout", env: "SHUTDOWN_TIMEOUT", usage: "how long to wait for requests and workers to finish on shutdown", set: setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{key: "shutdown-delay", env: "SHUTDOWN_DELAY", usage: "how long to report not-ready before closing the listener on shutdown", set: setDuration(func(c *Config) *time.Duration { return &c.ShutdownDelay })},
+
+	{key: "db-url", env: "DB_URL", secret: true, usage: "postgres:// or sqlite: database URL", set: setString(func(c *Config) *string { return &c.DBURL })},
+	{key: "db-max-open-conns", env: "DB_MAX_OPEN_CONNS", usage: "maximum open database connections", set: setInt(func(c *Config) *int { return &c.DBMaxOpenConns })},
+	{key: "db-max-idle-conns", env: "DB_MAX_IDLE_CONNS", usage: "maximum idle database connections", set: setInt(func(c *Config) *int { return &c.DBMaxIdleConns })},
+	{key: "db-conn-max-lifetime", env: "DB_CONN_MAX_LIFETIME", usage: "maximum lifetime of a database connection", set: setDuration(func(c *Config) *time.Duration { return &c.DBConnMaxLifetime })},
+	{key: "db-conn-max-idle-time", env: "DB_CONN_MAX_IDLE_TIME", usage: "maximum idle time of a database connection", set: setDuration(func(c *Config) *time.Duration { return &c.DBConnMaxIdleTime })},
+	{key: "auto-migrate", env: "AUTO_MIGRATE", usage: "apply pending migrations at startup", set: setBool(func(c *Config) *bool { return &c.AutoMigrate })},
+
+	{key: "jwt-secret", env: "JWT_SECRET", secret: true, usage: "secret used to sign access tokens", set: setString(func(c *Config) *string { return &c.JWTSecret })},
+	{key: "access-token-ttl", env: "ACCESS_TOKEN_TTL", usage: "lifetime of access tokens", set: setDuration(func(c *Config) *time.Duration { return &c.AccessTokenTTL })},
+	{key: "refresh-token-ttl", env: "REFRESH_TOKEN_TTL", usage: "lifetime of refresh tokens", set: setDuration(func(c *Config) *time.Duration { return &c.RefreshTokenTTL })},
+
+	{key: "mail-transport", env: "MAIL_TRANSPORT", usage: "how email is sent: log, smtp or http", set: setString(func(c *Config) *string { return &c.MailTransport })},
+	{key: "mail-from", env: "MAIL_FROM", usage: "sender address of outgoing email", set: setString(func(c *Config) *string { return &c.MailFrom })},
+	{key: "smtp-addr", env: "SMTP_ADDR", usage: "host:port of the SMTP relay used by the smtp transport", set: setString(func(c *Config) *string { return &c.SMTPAddr })},
+	{key: "smtp-username", env: "SMTP_USERNAME", usage: "SMTP relay username", set: setString(func(c *Config) *string { return &c.SMTPUsername })},
+	{key: "smtp-password", env: "SMTP_PASSWORD", secret: true, usage: "SMTP relay password", set: setString(func(c *Config) *string { return &c.SMTPPassword })},
+	{key: "mail-http-url", env: "MAIL_HTTP_URL", usage: "mail API endpoint used by the http transport", set: setString(func(c *Config) *string { return &c.MailHTTPURL })},
+	{key: "mail-http-token", env: "MAIL_HTTP_TOKEN", secret: true, usage: "bearer token for the mail API", set: setString(func(c *Config) *string { return &c.MailHTTPToken })},
+
+	{key: "polka-keys", env: "POLKA_KEYS", secret: true, usage: "comma-separated Polka webhook signing keys", set: setList(func(c *Config) *[]string { return &c.PolkaKeys })},
+	{key: "polka-key", env: "POLKA_KEY", secret: true, usage: "Polka webhook signing key, accepted alongside polka-keys", set: setString(func(c *Config) *string { return &c.polkaKey })},
+
+	{key: "free-max-chirp-length", env: "FREE_MAX_CHIRP_LENGTH", usage: "maximum chirp length on the free tier", set: setInt(func(c *Config) *int { return &c.FreeMaxChirpLength })},
+	{key: "red-max-chirp-length", env: "RED_MAX_CHIRP_LENGTH", usage: "maximum chirp length for Chirpy Red", set: setInt(func(c *Config) *int { return &c.RedMaxChirpLength })},
+
+	{key: "rate-limit-store", env: "RATE_LIMIT_STORE", usage: "where rate limit buckets are kept: memory or postgres", set: setString(func(c *Config) *string { return &c.RateLimitStore })},
+	{key: "rate-limit-signup", env: "RATE_LIMIT_SIGNUP", usage: "signup limits, e.g. anonymous=10/h", set: setPolicy(func(c *Config) *ratelimit.Policy { return &c.RateLimitSignup })},
+	{key: "rate-limit-login", env: "RATE_LIMIT_LOGIN", usage: "login limits, e.g. anonymous=10/m", set: setPolicy(func(c *Config) *ratelimit.Policy { return &c.RateLimitLogin })},
+	{key: "rate-limit-chirps-create", env: "RATE_LIMIT_CHIRPS_CREATE", usage: "chirp creation limits, e.g. standard=30/m,premium=120/m", set: setPolicy(func(c *Config) *ratelimit.Policy { return &c.RateLimitChirpsCreate })},
+	{key: "trust-forwarded-for", env: "TRUST_FORWARDED_FOR", usage: "take the client IP from the last X-Forwarded-For entry", set: setBool(func(c *Config) *bool { return &c.TrustForwardedFor })},
+	{key: "stream-replay-events", env: "STREAM_REPLAY_EVENTS", usage: "recent events /api/stream replays to reconnecting clients", set: setInt(func(c *Config) *int { return &c.StreamReplayEvents })},
+	{key: "graphql-max-depth", env: "GRAPHQL_MAX_DEPTH", usage: "deepest field nesting /api/graphql accepts", set: setInt(func(c *Config) *int { return &c.GraphQLMaxDepth })},
+	{key: "graphql-max-complexity", env: "GRAPHQL_MAX_COMPLEXITY", usage: "highest query complexity /api/graphql accepts", set: setInt(func(c *Config) *int { return &c.GraphQLMaxComplexity })},
+}
+
+// Load builds the configuration from a config file, the environment and
+// args, which should not include the program name. The config file is
+// named by the -config flag or CHIRPY_CONFIG. It returns the arguments
+// left after the flags, and every validation error joined together.
+func Load(args []string, getenv func(string) string) (Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configPath := fs.String("config", getenv("CHIRPY_CONFIG"), "path to a JSON config file")
+	flagValues := map[string]*string{}
+	for _, s := range settings {
+		flagValues[s.key] = fs.String(s.key, "", s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	var errs []error
+
+	if *configPath != "" {
+		if err := loadFile(&cfg, *configPath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, s := range settings {
+		v, ok, err := lookupEnv(s, getenv)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := s.set(&cfg, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, s := range settings {
+		if !set[s.key] {
+			continue
+		}
+		if err := s.set(&cfg, *flagValues[s.key]); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", s.key, err))
+		}
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:" + cfg.Port
+	}
+	if cfg.polkaKey != "" && !slices.Contains(cfg.PolkaKeys, cfg.polkaKey) {
+		cfg.PolkaKeys = append(cfg.PolkaKeys, cfg.polkaKey)
+	}
+
+	errs = append(errs, cfg.validate()...)
+	return cfg, fs.Args(), errors.Join(errs...)
+}
+
+// Usage writes the flag documentation to w.
+func Usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: chirpy [flags] [migrate up|down|status]")
+	fmt.Fprintln(w, "  -config string\n    \tpath to a JSON config file (env CHIRPY_CONFIG)")
+	for _, s := range settings {
+		env := s.env
+		if s.secret {
+			env += ", " + s.env + "_FILE"
+		}
+		fmt.Fprintf(w, "  -%s\n    \t%s (env %s)\n", s.key, s.usage, env)
+	}
+}
+
+// lookupEnv reads a setting from the environment, falling back to the file
+// named by its _FILE variable for secrets.
+func lookupEnv(s setting, getenv func(string) string) (string, bool, error) {
+	if v := getenv(s.env); v != "" {
+		return v, true, nil
+	}
+	if !s.secret {
+		return "", false, nil
+	}
+	path := getenv(s.env + "_FILE")
+	if path == "" {
+		return "", false, nil
+	}
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", s.env, err)
+	}
+	return strings.TrimSpace(string(dat)), true, nil
+}
+
+// loadFile applies a JSON object keyed by setting name. Values may be
+// strings, numbers or booleans; lists may also be JSON arrays.
+func loadFile(cfg *Config, path string) error {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	var values map[string]any
+	dec := json.NewDecoder(strings.NewReader(string(dat)))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	byKey := map[string]setting{}
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	var errs []error
+	for key, raw := range values {
+		s, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+		var v string
+		switch raw := raw.(type) {
+		case string:
+			v = raw
+		case json.Number:
+			v = raw.String()
+		case bool:
+			v = strconv.FormatBool(raw)
+		case []any:
+			parts := make([]string, 0, len(raw))
+			for _, p := range raw {
+				parts = append(parts, fmt.Sprint(p))
+			}
+			v = strings.Join(parts, ",")
+		default:
+			errs = append(errs, fmt.Errorf("config file %s: %s: unsupported value %v", path, key, raw))
+			continue
+		}
+		if err := s.set(cfg, v); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", path, key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c Config) validate() []error {
+	var errs []error
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.TraceExporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("trace-exporter %q must be none, stdout or otlp", c.TraceExporter))
+	}
+	if c.DBURL == "" {
+		errs = append(errs, errors.New("DB_URL is required"))
+	}
+	if c.JWTSecret == "" {
+		errs = append(errs, errors.New("JWT_SECRET is required"))
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("port %q must be a number between 1 and 65535", c.Port))
+	}
+	timeouts := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"read-timeout", c.ReadTimeout},
+		{"read-header-timeout", c.ReadHeaderTimeout},
+		{"write-timeout", c.WriteTimeout},
+		{"idle-timeout", c.IdleTimeout},
+		{"shutdown-timeout", c.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		if t.d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", t.name))
+		}
+	}
+	if c.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("shutdown-delay must not be negative"))
+	}
+	if c.DBMaxOpenConns < 0 {
+		errs = append(errs, errors.New("db-max-open-conns must not be negative"))
+	}
+	if c.DBMaxIdleConns < 0 {
+		errs = append(errs, errors.New("db-max-idle-conns must not be negative"))
+	}
+	if c.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("access-token-ttl must be positive"))
+	}
+	if c.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("refresh-token-ttl must be positive"))
+	}
+	if c.FreeMaxChirpLength <= 0 {
+		errs = append(errs, errors.New("free-max-chirp-length must be positive"))
+	}
+	switch c.MailTransport {
+	case "log":
+	case "smtp":
+		if c.SMTPAddr == "" || c.MailFrom == "" {
+			errs = append(errs, errors.New("mail-transport smtp needs smtp-addr and mail-from"))
+		}
+	case "http":
+		if c.MailHTTPURL == "" || c.MailFrom == "" {
+			errs = append(errs, errors.New("mail-transport http needs mail-http-url and mail-from"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mail-transport %q must be log, smtp or http", c.MailTransport))
+	}
+	switch c.RateLimitStore {
+	case "memory":
+	case "postgres":
+		if !strings.HasPrefix(c.DBURL, "postgres") {
+			errs = append(errs, errors.New("rate-limit-store postgres needs a Postgres DB_URL"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("rate-limit-store %q must be memory or postgres", c.RateLimitStore))
+	}
+	if c.RedMaxChirpLength < c.FreeMaxChirpLength {
+		errs = append(errs, errors.New("red-max-chirp-length must be at least free-max-chirp-length"))
+	}
+	if c.StreamReplayEvents <= 0 {
+		errs = append(errs, errors.New("stream-replay-events must be positive"))
+	}
+	if c.GraphQLMaxDepth <= 0 {
+		errs = append(errs, errors.New("graphql-max-depth must be positive"))
+	}
+	if c.GraphQLMaxComplexity <= 0 {
+		errs = append(errs, errors.New("graphql-max-complexity must be positive"))
+	}
+	return errs
+}
+
+func setString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setBool(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setDuration(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func setList(field func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = splitList(v)
+		return nil
+	}
+}
+
+func setPolicy(field func(*Config) *ratelimit.Policy) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		p, err := ratelimit.ParsePolicy(v, *field(c))
+		if err != nil {
+			return err
+		}
+		*field(c) = p
+		return nil
+	}
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,344 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// required holds the settings Load refuses to run without.
+var required = map[string]string{
+	"DB_URL":     "postgres://localhost/chirpy",
+	"JWT_SECRET": "secret",
+}
+
+// env returns a getenv over the required settings and vars.
+func env(vars map[string]string) func(string) string {
+	return func(key string) string {
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return required[key]
+	}
+}
+
+// writeFile writes content to a file in a temporary directory and returns
+// its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "chirpy.json", `{"port": "8081", "log-level": "debug", "write-timeout": "10s", "db-max-open-conns": 5}`)
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want func(c *Config)
+	}{
+		{
+			name: "defaults",
+			want: func(c *Config) {},
+		},
+		{
+			name: "file over defaults",
+			args: []string{"-config", file},
+			want: func(c *Config) {
+				c.Port = "8081"
+				c.LogLevel = "debug"
+				c.WriteTimeout = 10 * time.Second
+				c.DBMaxOpenConns = 5
+			},
+		},
+		{
+			name: "file named by the environment",
+			env:  map[string]string{"CHIRPY_CONFIG": file},
+			want: func(c *Config) {
+				c.Port = "8081"
+				c.LogLevel = "debug"
+				c.WriteTimeout = 10 * time.Second
+				c.DBMaxOpenConns = 5
+			},
+		},
+		{
+			name: "environment over file",
+			args: []string{"-config", file},
+			env:  map[string]string{"PORT": "8082", "WRITE_TIMEOUT": "20s"},
+			want: func(c *Config) {
+				c.Port = "8082"
+				c.LogLevel = "debug"
+				c.WriteTimeout = 20 * time.Second
+				c.DBMaxOpenConns = 5
+			},
+		},
+		{
+			name: "flags over environment",
+			args: []string{"-config", file, "-port", "8083", "-db-max-open-conns", "7"},
+			env:  map[string]string{"PORT": "8082", "WRITE_TIMEOUT": "20s"},
+			want: func(c *Config) {
+				c.Port = "8083"
+				c.LogLevel = "debug"
+				c.WriteTimeout = 20 * time.Second
+				c.DBMaxOpenConns = 7
+			},
+		},
+		{
+			name: "empty environment variable is unset",
+			args: []string{"-config", file},
+			env:  map[string]string{"PORT": ""},
+			want: func(c *Config) {
+				c.Port = "8081"
+				c.LogLevel = "debug"
+				c.WriteTimeout = 10 * time.Second
+				c.DBMaxOpenConns = 5
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := Load(tt.args, env(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Default()
+			want.DBURL = required["DB_URL"]
+			want.JWTSecret = required["JWT_SECRET"]
+			tt.want(&want)
+			want.BaseURL = "http://localhost:" + want.Port
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got  %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadArgs(t *testing.T) {
+	_, args, err := Load([]string{"-port", "9000", "migrate", "up"}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(args, []string{"migrate", "up"}) {
+		t.Errorf("got args %v, want [migrate up]", args)
+	}
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	secret := writeFile(t, "jwt", "from-file\n")
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{name: "file", env: map[string]string{"JWT_SECRET": "", "JWT_SECRET_FILE": secret}, want: "from-file"},
+		{name: "variable over file", env: map[string]string{"JWT_SECRET": "from-env", "JWT_SECRET_FILE": secret}, want: "from-env"},
+		{name: "flag over file", args: []string{"-jwt-secret", "from-flag"}, env: map[string]string{"JWT_SECRET": "", "JWT_SECRET_FILE": secret}, want: "from-flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := Load(tt.args, env(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.JWTSecret != tt.want {
+				t.Errorf("got %q, want %q", cfg.JWTSecret, tt.want)
+			}
+		})
+	}
+
+	t.Run("not a secret", func(t *testing.T) {
+		cfg, _, err := Load(nil, env(map[string]string{"PORT_FILE": writeFile(t, "port", "9000")}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Port != "8080" {
+			t.Errorf("PORT_FILE was read: port %q", cfg.Port)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, _, err := Load(nil, env(map[string]string{"JWT_SECRET": "", "JWT_SECRET_FILE": filepath.Join(t.TempDir(), "nope")}))
+		if err == nil || !strings.Contains(err.Error(), "JWT_SECRET_FILE") || !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("got %v, want a JWT_SECRET_FILE not found error", err)
+		}
+	})
+}
+
+func TestLoadPolkaKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		args []string
+		env  map[string]string
+		want []string
+	}{
+		{name: "none"},
+		{name: "single key", env: map[string]string{"POLKA_KEY": "a"}, want: []string{"a"}},
+		{name: "key list", env: map[string]string{"POLKA_KEYS": "a, b,,c"}, want: []string{"a", "b", "c"}},
+		{name: "both", env: map[string]string{"POLKA_KEYS": "a,b", "POLKA_KEY": "c"}, want: []string{"a", "b", "c"}},
+		{name: "key already listed", env: map[string]string{"POLKA_KEYS": "a,b", "POLKA_KEY": "b"}, want: []string{"a", "b"}},
+		{name: "array in file", file: `{"polka-keys": ["a", "b"]}`, want: []string{"a", "b"}},
+		{
+			name: "key in file and list in environment",
+			file: `{"polka-key": "a"}`,
+			env:  map[string]string{"POLKA_KEYS": "b,c"},
+			want: []string{"b", "c", "a"},
+		},
+		{
+			name: "list in file and key in environment",
+			file: `{"polka-keys": ["a", "b"]}`,
+			env:  map[string]string{"POLKA_KEY": "c"},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "list in environment replaces list in file",
+			file: `{"polka-keys": ["a", "b"], "polka-key": "c"}`,
+			env:  map[string]string{"POLKA_KEYS": "d"},
+			want: []string{"d", "c"},
+		},
+		{
+			name: "key flag replaces key in environment",
+			args: []string{"-polka-key", "b"},
+			env:  map[string]string{"POLKA_KEY": "a"},
+			want: []string{"b"},
+		},
+		{
+			name: "key from a file",
+			env:  map[string]string{"POLKA_KEY_FILE": "secret"},
+			want: []string{"from-file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, "chirpy.json", tt.file)}, args...)
+			}
+			vars := tt.env
+			if vars["POLKA_KEY_FILE"] != "" {
+				vars = map[string]string{"POLKA_KEY_FILE": writeFile(t, vars["POLKA_KEY_FILE"], "from-file")}
+			}
+			cfg, _, err := Load(args, env(vars))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.PolkaKeys, tt.want) {
+				t.Errorf("got %q, want %q", cfg.PolkaKeys, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		args []string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "required settings",
+			env:  map[string]string{"DB_URL": "", "JWT_SECRET": ""},
+			want: []string{"DB_URL is required", "JWT_SECRET is required"},
+		},
+		{
+			name: "every source",
+			file: `{"db-max-open-conns": "many", "colour": "blue"}`,
+			args: []string{"-auto-migrate", "maybe"},
+			env:  map[string]string{"WRITE_TIMEOUT": "soon"},
+			want: []string{
+				`db-max-open-conns: invalid integer "many"`,
+				`unknown setting "colour"`,
+				`WRITE_TIMEOUT: invalid duration "soon"`,
+				`-auto-migrate: invalid boolean "maybe"`,
+			},
+		},
+		{
+			name: "parsed but invalid",
+			env: map[string]string{
+				"PORT":                  "99999",
+				"TRACE_EXPORTER":        "jaeger",
+				"MAIL_TRANSPORT":        "smtp",
+				"RATE_LIMIT_STORE":      "postgres",
+				"DB_URL":                "sqlite:chirpy.db",
+				"RED_MAX_CHIRP_LENGTH":  "100",
+				"STREAM_REPLAY_EVENTS":  "0",
+				"READ_HEADER_TIMEOUT":   "0s",
+				"SHUTDOWN_DELAY":        "-1s",
+				"GRAPHQL_MAX_DEPTH":     "0",
+				"FREE_MAX_CHIRP_LENGTH": "140",
+			},
+			want: []string{
+				`port "99999" must be a number between 1 and 65535`,
+				`trace-exporter "jaeger" must be none, stdout or otlp`,
+				"mail-transport smtp needs smtp-addr and mail-from",
+				"rate-limit-store postgres needs a Postgres DB_URL",
+				"red-max-chirp-length must be at least free-max-chirp-length",
+				"stream-replay-events must be positive",
+				"read-header-timeout must be positive",
+				"shutdown-delay must not be negative",
+				"graphql-max-depth must be positive",
+			},
+		},
+		{
+			name: "missing config file",
+			args: []string{"-config", "/nonexistent/chirpy.json"},
+			want: []string{"config file:"},
+		},
+		{
+			name: "malformed config file",
+			file: `{"port": `,
+			want: []string{"config file "},
+		},
+		{
+			name: "bad rate limit",
+			env:  map[string]string{"RATE_LIMIT_LOGIN": "anonymous=lots"},
+			want: []string{"RATE_LIMIT_LOGIN:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, "chirpy.json", tt.file)}, args...)
+			}
+			_, _, err := Load(args, env(tt.env))
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error doesn't mention %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadUnknownFlag(t *testing.T) {
+	if _, _, err := Load([]string{"-colour", "blue"}, env(nil)); err == nil {
+		t.Error("got no error for an unknown flag")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	var b strings.Builder
+	Usage(&b)
+	for _, want := range []string{"-config string", "-port\n", "(env PORT)", "(env JWT_SECRET, JWT_SECRET_FILE)"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("usage doesn't mention %q", want)
+		}
+	}
+}
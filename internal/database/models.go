@@ -6,6 +6,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,6 +20,60 @@ type Chirp struct {
 	UserID    uuid.UUID
 }
 
+type EmailChangeRequest struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	NewEmail  string
+	ExpiresAt time.Time
+}
+
+type EntitlementOverride struct {
+	UserID            uuid.UUID
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Tier              sql.NullString
+	MaxChirpLength    sql.NullInt32
+	CanEditChirps     sql.NullBool
+	MaxMediaPerChirp  sql.NullInt32
+	CanScheduleChirps sql.NullBool
+	RateLimitTier     sql.NullString
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type OutboxEvent struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	EventType   string
+	UserID      uuid.UUID
+	Payload     json.RawMessage
+	ProcessedAt sql.NullTime
+}
+
+type PolkaEvent struct {
+	ID         string
+	Event      string
+	ReceivedAt time.Time
+}
+
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+type Reaction struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Kind      string
+	CreatedAt time.Time
+}
+
 type RefreshToken struct {
 	Token     string
 	CreatedAt time.Time
@@ -28,11 +83,50 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Subscription struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	UserID             uuid.UUID
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassword string
-	IsChirpyRed    bool
+}
+
+type UserRole struct {
+	UserID    uuid.UUID
+	Role      string
+	CreatedAt time.Time
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookSubscription struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	Events    []string
 }
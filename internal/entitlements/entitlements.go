@@ -0,0 +1,207 @@
+// Package entitlements maps account tiers to the capabilities a user has.
+// Handlers ask this package what a user may do instead of checking the
+// subscription themselves.
+package entitlements
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Tier is an account tier.
+type Tier string
+
+const (
+	// TierFree -
+	TierFree Tier = "free"
+	// TierChirpyRed -
+	TierChirpyRed Tier = "chirpy_red"
+)
+
+// RateLimitTier selects which rate limit policy applies to a caller.
+type RateLimitTier string
+
+const (
+	// RateLimitAnonymous applies to unauthenticated callers.
+	RateLimitAnonymous RateLimitTier = "anonymous"
+	// RateLimitStandard applies to signed-in free users.
+	RateLimitStandard RateLimitTier = "standard"
+	// RateLimitPremium applies to Chirpy Red users.
+	RateLimitPremium RateLimitTier = "premium"
+)
+
+// Capabilities describes what a user is allowed to do.
+type Capabilities struct {
+	Tier              Tier          `json:"tier"`
+	MaxChirpLength    int           `json:"max_chirp_length"`
+	CanEditChirps     bool          `json:"can_edit_chirps"`
+	MaxMediaPerChirp  int           `json:"max_media_per_chirp"`
+	CanScheduleChirps bool          `json:"can_schedule_chirps"`
+	RateLimitTier     RateLimitTier `json:"rate_limit_tier"`
+}
+
+// DefaultTiers are the capabilities granted by each tier.
+var DefaultTiers = map[Tier]Capabilities{
+	TierFree: {
+		Tier:              TierFree,
+		MaxChirpLength:    140,
+		CanEditChirps:     false,
+		MaxMediaPerChirp:  0,
+		CanScheduleChirps: false,
+		RateLimitTier:     RateLimitStandard,
+	},
+	TierChirpyRed: {
+		Tier:              TierChirpyRed,
+		MaxChirpLength:    560,
+		CanEditChirps:     true,
+		MaxMediaPerChirp:  4,
+		CanScheduleChirps: true,
+		RateLimitTier:     RateLimitPremium,
+	},
+}
+
+// Override replaces individual capabilities for one user. Nil fields keep
+// the value from the user's tier. Setting Tier switches the base tier
+// before the other fields are applied.
+type Override struct {
+	Tier              *Tier          `json:"tier,omitempty"`
+	MaxChirpLength    *int           `json:"max_chirp_length,omitempty"`
+	CanEditChirps     *bool          `json:"can_edit_chirps,omitempty"`
+	MaxMediaPerChirp  *int           `json:"max_media_per_chirp,omitempty"`
+	CanScheduleChirps *bool          `json:"can_schedule_chirps,omitempty"`
+	RateLimitTier     *RateLimitTier `json:"rate_limit_tier,omitempty"`
+}
+
+// ErrUnknownTier -
+var ErrUnknownTier = errors.New("unknown tier")
+
+// Store provides the facts entitlements are computed from.
+type Store interface {
+	// IsChirpyRed reports whether the user has an active subscription.
+	IsChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error)
+	// GetOverride returns the admin override for a user, if any.
+	GetOverride(ctx context.Context, userID uuid.UUID) (Override, bool, error)
+}
+
+// Service resolves a user's capabilities.
+type Service struct {
+	store Store
+	tiers map[Tier]Capabilities
+}
+
+// New returns a Service backed by store. If tiers is nil, DefaultTiers is
+// used.
+func New(store Store, tiers map[Tier]Capabilities) *Service {
+	if tiers == nil {
+		tiers = DefaultTiers
+	}
+	return &Service{store: store, tiers: tiers}
+}
+
+// Anonymous returns the capabilities of a caller who is not signed in.
+func (s *Service) Anonymous() Capabilities {
+	c := s.tiers[TierFree]
+	c.RateLimitTier = RateLimitAnonymous
+	return c
+}
+
+// Tier returns the base capabilities of tier.
+func (s *Service) Tier(tier Tier) (Capabilities, error) {
+	c, ok := s.tiers[tier]
+	if !ok {
+		return Capabilities{}, ErrUnknownTier
+	}
+	return c, nil
+}
+
+// For returns the capabilities of userID, with any override applied.
+func (s *Service) For(ctx context.Context, userID uuid.UUID) (Capabilities, error) {
+	red, err := s.store.IsChirpyRed(ctx, userID)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	tier := TierFree
+	if red {
+		tier = TierChirpyRed
+	}
+
+	override, ok, err := s.store.GetOverride(ctx, userID)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	if !ok {
+		return s.Tier(tier)
+	}
+	return s.apply(tier, override)
+}
+
+// FieldError reports one Override field the service doesn't accept.
+type FieldError struct {
+	// Field is the field's JSON name.
+	Field   string
+	Message string
+	// Err is the underlying error, if any, e.g. ErrUnknownTier.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that an override only refers to known tiers and sane
+// limits. It returns a *FieldError for every bad field, joined together.
+func (s *Service) Validate(o Override) error {
+	var errs []error
+	if o.Tier != nil {
+		if _, ok := s.tiers[*o.Tier]; !ok {
+			errs = append(errs, &FieldError{Field: "tier", Message: fmt.Sprintf("unknown tier %q", *o.Tier), Err: ErrUnknownTier})
+		}
+	}
+	if o.MaxChirpLength != nil && *o.MaxChirpLength < 1 {
+		errs = append(errs, &FieldError{Field: "max_chirp_length", Message: "max_chirp_length must be at least 1"})
+	}
+	if o.MaxMediaPerChirp != nil && *o.MaxMediaPerChirp < 0 {
+		errs = append(errs, &FieldError{Field: "max_media_per_chirp", Message: "max_media_per_chirp cannot be negative"})
+	}
+	if o.RateLimitTier != nil {
+		switch *o.RateLimitTier {
+		case RateLimitAnonymous, RateLimitStandard, RateLimitPremium:
+		default:
+			errs = append(errs, &FieldError{Field: "rate_limit_tier", Message: fmt.Sprintf("unknown rate_limit_tier %q", *o.RateLimitTier)})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) apply(tier Tier, o Override) (Capabilities, error) {
+	if o.Tier != nil {
+		tier = *o.Tier
+	}
+	c, err := s.Tier(tier)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	if o.MaxChirpLength != nil {
+		c.MaxChirpLength = *o.MaxChirpLength
+	}
+	if o.CanEditChirps != nil {
+		c.CanEditChirps = *o.CanEditChirps
+	}
+	if o.MaxMediaPerChirp != nil {
+		c.MaxMediaPerChirp = *o.MaxMediaPerChirp
+	}
+	if o.CanScheduleChirps != nil {
+		c.CanScheduleChirps = *o.CanScheduleChirps
+	}
+	if o.RateLimitTier != nil {
+		c.RateLimitTier = *o.RateLimitTier
+	}
+	return c, nil
+}
@@ -0,0 +1,278 @@
+package entitlements
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestForTiers(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	svc := New(s, nil)
+
+	free, red := uuid.New(), uuid.New()
+	s.SetChirpyRed(red, true)
+
+	got, err := svc.For(ctx, free)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != DefaultTiers[TierFree] {
+		t.Errorf("free user: got %+v, want %+v", got, DefaultTiers[TierFree])
+	}
+
+	got, err = svc.For(ctx, red)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != DefaultTiers[TierChirpyRed] {
+		t.Errorf("red user: got %+v, want %+v", got, DefaultTiers[TierChirpyRed])
+	}
+
+	s.SetChirpyRed(red, false)
+	got, err = svc.For(ctx, red)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Tier != TierFree {
+		t.Errorf("after the subscription ended: got tier %q, want free", got.Tier)
+	}
+}
+
+func TestForCustomTiers(t *testing.T) {
+	tiers := map[Tier]Capabilities{
+		TierFree:      {Tier: TierFree, MaxChirpLength: 100, RateLimitTier: RateLimitStandard},
+		TierChirpyRed: {Tier: TierChirpyRed, MaxChirpLength: 1000, RateLimitTier: RateLimitPremium},
+	}
+	svc := New(NewMemoryStore(), tiers)
+
+	got, err := svc.For(context.Background(), uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MaxChirpLength != 100 {
+		t.Errorf("MaxChirpLength = %d, want 100", got.MaxChirpLength)
+	}
+	if anon := svc.Anonymous(); anon.MaxChirpLength != 100 || anon.RateLimitTier != RateLimitAnonymous {
+		t.Errorf("Anonymous() = %+v", anon)
+	}
+}
+
+func TestForOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		red      bool
+		override Override
+		want     func(c *Capabilities)
+	}{
+		{
+			name:     "empty override keeps the tier",
+			override: Override{},
+			want:     func(c *Capabilities) {},
+		},
+		{
+			name:     "single field on free",
+			override: Override{MaxChirpLength: ptr(280)},
+			want:     func(c *Capabilities) { c.MaxChirpLength = 280 },
+		},
+		{
+			name:     "tier upgrade",
+			override: Override{Tier: ptr(TierChirpyRed)},
+			want:     func(c *Capabilities) { *c = DefaultTiers[TierChirpyRed] },
+		},
+		{
+			name:     "tier downgrade of a paying user",
+			red:      true,
+			override: Override{Tier: ptr(TierFree)},
+			want:     func(c *Capabilities) { *c = DefaultTiers[TierFree] },
+		},
+		{
+			name:     "fields apply on top of the overridden tier",
+			override: Override{Tier: ptr(TierChirpyRed), CanEditChirps: ptr(false), MaxMediaPerChirp: ptr(0)},
+			want: func(c *Capabilities) {
+				*c = DefaultTiers[TierChirpyRed]
+				c.CanEditChirps = false
+				c.MaxMediaPerChirp = 0
+			},
+		},
+		{
+			name: "every field",
+			red:  true,
+			override: Override{
+				MaxChirpLength:    ptr(10),
+				CanEditChirps:     ptr(false),
+				MaxMediaPerChirp:  ptr(1),
+				CanScheduleChirps: ptr(false),
+				RateLimitTier:     ptr(RateLimitStandard),
+			},
+			want: func(c *Capabilities) {
+				*c = Capabilities{
+					Tier:             TierChirpyRed,
+					MaxChirpLength:   10,
+					MaxMediaPerChirp: 1,
+					RateLimitTier:    RateLimitStandard,
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore()
+			userID := uuid.New()
+			s.SetChirpyRed(userID, tt.red)
+			s.SetOverride(userID, tt.override)
+
+			base := TierFree
+			if tt.red {
+				base = TierChirpyRed
+			}
+			want := DefaultTiers[base]
+			tt.want(&want)
+
+			got, err := New(s, nil).For(context.Background(), userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestForUnknownOverrideTier(t *testing.T) {
+	s := NewMemoryStore()
+	userID := uuid.New()
+	s.SetOverride(userID, Override{Tier: ptr(Tier("platinum"))})
+
+	_, err := New(s, nil).For(context.Background(), userID)
+	if !errors.Is(err, ErrUnknownTier) {
+		t.Fatalf("got %v, want ErrUnknownTier", err)
+	}
+}
+
+func TestApplyLeavesTiersAlone(t *testing.T) {
+	svc := New(NewMemoryStore(), nil)
+	before := DefaultTiers[TierFree]
+	if _, err := svc.apply(TierFree, Override{MaxChirpLength: ptr(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if DefaultTiers[TierFree] != before {
+		t.Errorf("apply changed DefaultTiers: %+v", DefaultTiers[TierFree])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		o      Override
+		fields []string
+	}{
+		{"empty", Override{}, nil},
+		{"known tier", Override{Tier: ptr(TierChirpyRed)}, nil},
+		{"unknown tier", Override{Tier: ptr(Tier("platinum"))}, []string{"tier"}},
+		{"chirp length 1", Override{MaxChirpLength: ptr(1)}, nil},
+		{"chirp length 0", Override{MaxChirpLength: ptr(0)}, []string{"max_chirp_length"}},
+		{"no media", Override{MaxMediaPerChirp: ptr(0)}, nil},
+		{"negative media", Override{MaxMediaPerChirp: ptr(-1)}, []string{"max_media_per_chirp"}},
+		{"known rate limit tier", Override{RateLimitTier: ptr(RateLimitAnonymous)}, nil},
+		{"unknown rate limit tier", Override{RateLimitTier: ptr(RateLimitTier("unlimited"))}, []string{"rate_limit_tier"}},
+		{
+			"every field",
+			Override{Tier: ptr(Tier("platinum")), MaxChirpLength: ptr(0), MaxMediaPerChirp: ptr(-1), RateLimitTier: ptr(RateLimitTier("unlimited"))},
+			[]string{"tier", "max_chirp_length", "max_media_per_chirp", "rate_limit_tier"},
+		},
+	}
+	svc := New(NewMemoryStore(), nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.Validate(tt.o)
+			if (err != nil) != (tt.fields != nil) {
+				t.Fatalf("Validate() = %v, want errors for %v", err, tt.fields)
+			}
+			if err == nil {
+				return
+			}
+			var fields []string
+			for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+				var fe *FieldError
+				if !errors.As(e, &fe) {
+					t.Fatalf("got %T, want *FieldError", e)
+				}
+				fields = append(fields, fe.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("got errors for %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+	if err := svc.Validate(Override{Tier: ptr(Tier("platinum"))}); !errors.Is(err, ErrUnknownTier) {
+		t.Errorf("unknown tier: got %v, want ErrUnknownTier", err)
+	}
+}
+
+// TestRepositoryStoreExpiry checks that subscriptions stop granting Chirpy
+// Red once their period ends.
+func TestRepositoryStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	user, err := s.Users().CreateUser(ctx, database.CreateUserParams{Email: "walt@example.com", HashedPassword: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := New(NewRepositoryStore(s), nil)
+
+	tests := []struct {
+		name   string
+		status string
+		end    time.Time
+		want   Tier
+	}{
+		{"active", "active", time.Now().Add(time.Hour), TierChirpyRed},
+		{"past due within the period", "past_due", time.Now().Add(time.Hour), TierChirpyRed},
+		{"period ended", "active", time.Now().Add(-time.Minute), TierFree},
+		{"canceled", "canceled", time.Now().Add(time.Hour), TierFree},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Subscriptions().UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+				UserID:             user.ID,
+				Status:             tt.status,
+				CurrentPeriodStart: tt.end.Add(-30 * 24 * time.Hour),
+				CurrentPeriodEnd:   tt.end,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := svc.For(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Tier != tt.want {
+				t.Errorf("got tier %q, want %q", got.Tier, tt.want)
+			}
+		})
+	}
+
+	// An override survives the subscription ending
+	_, err = s.Entitlements().UpsertEntitlementOverride(ctx, OverrideParams(user.ID, Override{MaxChirpLength: ptr(1000)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := svc.For(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Tier != TierFree || got.MaxChirpLength != 1000 {
+		t.Errorf("with override: got %+v", got)
+	}
+}
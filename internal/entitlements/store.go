@@ -0,0 +1,136 @@
+package entitlements
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// RepositoryStore reads subscriptions and overrides through the storage
+// layer, so it works on every backend.
+type RepositoryStore struct {
+	s store.Store
+}
+
+// NewRepositoryStore -
+func NewRepositoryStore(s store.Store) *RepositoryStore {
+	return &RepositoryStore{s: s}
+}
+
+// IsChirpyRed -
+func (s *RepositoryStore) IsChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return s.s.Subscriptions().IsUserChirpyRed(ctx, userID)
+}
+
+// GetOverride -
+func (s *RepositoryStore) GetOverride(ctx context.Context, userID uuid.UUID) (Override, bool, error) {
+	row, err := s.s.Entitlements().GetEntitlementOverride(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return Override{}, false, nil
+	}
+	if err != nil {
+		return Override{}, false, err
+	}
+	return OverrideFromRow(row), true, nil
+}
+
+// OverrideFromRow converts a database row into an Override.
+func OverrideFromRow(row database.EntitlementOverride) Override {
+	var o Override
+	if row.Tier.Valid {
+		t := Tier(row.Tier.String)
+		o.Tier = &t
+	}
+	if row.MaxChirpLength.Valid {
+		n := int(row.MaxChirpLength.Int32)
+		o.MaxChirpLength = &n
+	}
+	if row.CanEditChirps.Valid {
+		o.CanEditChirps = &row.CanEditChirps.Bool
+	}
+	if row.MaxMediaPerChirp.Valid {
+		n := int(row.MaxMediaPerChirp.Int32)
+		o.MaxMediaPerChirp = &n
+	}
+	if row.CanScheduleChirps.Valid {
+		o.CanScheduleChirps = &row.CanScheduleChirps.Bool
+	}
+	if row.RateLimitTier.Valid {
+		t := RateLimitTier(row.RateLimitTier.String)
+		o.RateLimitTier = &t
+	}
+	return o
+}
+
+// OverrideParams converts an Override into upsert parameters for userID.
+func OverrideParams(userID uuid.UUID, o Override) database.UpsertEntitlementOverrideParams {
+	p := database.UpsertEntitlementOverrideParams{UserID: userID}
+	if o.Tier != nil {
+		p.Tier = sql.NullString{String: string(*o.Tier), Valid: true}
+	}
+	if o.MaxChirpLength != nil {
+		p.MaxChirpLength = sql.NullInt32{Int32: int32(*o.MaxChirpLength), Valid: true}
+	}
+	if o.CanEditChirps != nil {
+		p.CanEditChirps = sql.NullBool{Bool: *o.CanEditChirps, Valid: true}
+	}
+	if o.MaxMediaPerChirp != nil {
+		p.MaxMediaPerChirp = sql.NullInt32{Int32: int32(*o.MaxMediaPerChirp), Valid: true}
+	}
+	if o.CanScheduleChirps != nil {
+		p.CanScheduleChirps = sql.NullBool{Bool: *o.CanScheduleChirps, Valid: true}
+	}
+	if o.RateLimitTier != nil {
+		p.RateLimitTier = sql.NullString{String: string(*o.RateLimitTier), Valid: true}
+	}
+	return p
+}
+
+// MemoryStore is an in-memory Store for tests.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	red       map[uuid.UUID]bool
+	overrides map[uuid.UUID]Override
+}
+
+// NewMemoryStore -
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		red:       map[uuid.UUID]bool{},
+		overrides: map[uuid.UUID]Override{},
+	}
+}
+
+// SetChirpyRed -
+func (s *MemoryStore) SetChirpyRed(userID uuid.UUID, red bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.red[userID] = red
+}
+
+// SetOverride -
+func (s *MemoryStore) SetOverride(userID uuid.UUID, o Override) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[userID] = o
+}
+
+// IsChirpyRed -
+func (s *MemoryStore) IsChirpyRed(_ context.Context, userID uuid.UUID) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.red[userID], nil
+}
+
+// GetOverride -
+func (s *MemoryStore) GetOverride(_ context.Context, userID uuid.UUID) (Override, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o, ok := s.overrides[userID]
+	return o, ok, nil
+}
@@ -0,0 +1,118 @@
+// Package fixtures loads the named seed data sets that the development
+// reset endpoint can load after emptying the database. A set is a JSON
+// file, <name>.json, listing users, chirps and follows; chirps and follows
+// name users by email.
+package fixtures
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+)
+
+//go:embed *.json
+var builtin embed.FS
+
+// Builtin returns the sets that ship with the server.
+func Builtin() fs.FS {
+	return builtin
+}
+
+// ErrNotFound is returned by Load for a set that doesn't exist.
+var ErrNotFound = errors.New("fixture set not found")
+
+// Set is one named set of seed data.
+type Set struct {
+	Users   []User   `json:"users"`
+	Chirps  []Chirp  `json:"chirps"`
+	Follows []Follow `json:"follows"`
+}
+
+// User is a user to create. The password is stored hashed as usual.
+type User struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Chirp is a chirp to create, in order, for the user with email Author.
+type Chirp struct {
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+// Follow makes the user with email Follower follow Followee.
+type Follow struct {
+	Follower string `json:"follower"`
+	Followee string `json:"followee"`
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Load reads the set called name from fsys and checks that it is
+// consistent, so a bad set fails before the database is touched.
+func Load(fsys fs.FS, name string) (*Set, error) {
+	if !validName.MatchString(name) {
+		return nil, ErrNotFound
+	}
+	data, err := fs.ReadFile(fsys, name+".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var set Set
+	if err := dec.Decode(&set); err != nil {
+		return nil, fmt.Errorf("fixture set %s: %w", name, err)
+	}
+	if err := set.check(); err != nil {
+		return nil, fmt.Errorf("fixture set %s: %w", name, err)
+	}
+	return &set, nil
+}
+
+// check reports the first reference to an unknown user or repeated value.
+func (s *Set) check() error {
+	users := make(map[string]bool, len(s.Users))
+	for i, u := range s.Users {
+		if u.Email == "" || u.Password == "" {
+			return fmt.Errorf("users[%d] needs an email and a password", i)
+		}
+		if users[u.Email] {
+			return fmt.Errorf("users[%d]: %s is listed twice", i, u.Email)
+		}
+		users[u.Email] = true
+	}
+
+	bodies := make(map[string]bool, len(s.Chirps))
+	for i, c := range s.Chirps {
+		if !users[c.Author] {
+			return fmt.Errorf("chirps[%d]: unknown author %q", i, c.Author)
+		}
+		if c.Body == "" {
+			return fmt.Errorf("chirps[%d] has no body", i)
+		}
+		// Chirp bodies are unique
+		if bodies[c.Body] {
+			return fmt.Errorf("chirps[%d]: body is repeated", i)
+		}
+		bodies[c.Body] = true
+	}
+
+	for i, f := range s.Follows {
+		if !users[f.Follower] || !users[f.Followee] {
+			return fmt.Errorf("follows[%d]: unknown user", i)
+		}
+		if f.Follower == f.Followee {
+			return fmt.Errorf("follows[%d]: users can't follow themselves", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package graphql
+
+// Location is a position in the query text, counted from 1.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// document is a parsed query.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	loc          Location
+	kind         string // "query" or "mutation"
+	name         string
+	variables    []*variableDefinition
+	directives   []*directive
+	selectionSet []selection
+}
+
+type variableDefinition struct {
+	loc      Location
+	name     string
+	typ      *typeRef
+	defValue value
+}
+
+// typeRef is a type as written in a variable definition.
+type typeRef struct {
+	name    string   // set for named types
+	elem    *typeRef // set for list types
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+type fragment struct {
+	loc           Location
+	name          string
+	typeCondition string
+	directives    []*directive
+	selectionSet  []selection
+}
+
+// selection is a *field, *fragmentSpread or *inlineFragment.
+type selection interface {
+	location() Location
+}
+
+type field struct {
+	loc          Location
+	alias        string
+	name         string
+	arguments    []*argument
+	directives   []*directive
+	selectionSet []selection
+}
+
+// responseKey is the name of the field in the response.
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	loc        Location
+	name       string
+	directives []*directive
+}
+
+type inlineFragment struct {
+	loc           Location
+	typeCondition string // empty if omitted
+	directives    []*directive
+	selectionSet  []selection
+}
+
+func (f *field) location() Location          { return f.loc }
+func (f *fragmentSpread) location() Location { return f.loc }
+func (f *inlineFragment) location() Location { return f.loc }
+
+type argument struct {
+	loc   Location
+	name  string
+	value value
+}
+
+type directive struct {
+	loc       Location
+	name      string
+	arguments []*argument
+}
+
+// value is a literal in the query text. Variables are left unresolved
+// until execution.
+type value interface {
+	location() Location
+}
+
+type (
+	variableValue struct {
+		loc  Location
+		name string
+	}
+	intValue struct {
+		loc Location
+		raw string
+	}
+	floatValue struct {
+		loc Location
+		raw string
+	}
+	stringValue struct {
+		loc Location
+		s   string
+	}
+	booleanValue struct {
+		loc Location
+		b   bool
+	}
+	nullValue struct {
+		loc Location
+	}
+	enumValue struct {
+		loc  Location
+		name string
+	}
+	listValue struct {
+		loc    Location
+		values []value
+	}
+	objectValue struct {
+		loc    Location
+		fields []*objectField
+	}
+)
+
+type objectField struct {
+	name  string
+	value value
+}
+
+func (v *variableValue) location() Location { return v.loc }
+func (v *intValue) location() Location      { return v.loc }
+func (v *floatValue) location() Location    { return v.loc }
+func (v *stringValue) location() Location   { return v.loc }
+func (v *booleanValue) location() Location  { return v.loc }
+func (v *nullValue) location() Location     { return v.loc }
+func (v *enumValue) location() Location     { return v.loc }
+func (v *listValue) location() Location     { return v.loc }
+func (v *objectValue) location() Location   { return v.loc }
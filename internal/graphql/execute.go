@@ -0,0 +1,429 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type execution struct {
+	ctx  context.Context
+	x    *Executor
+	doc  *document
+	vars map[string]any
+
+	errs       []*Error
+	errorPaths map[string]bool
+	// pending holds the completions waiting on Thunks returned on the
+	// current level.
+	pending []func()
+}
+
+// resultObject is a completed object waiting to be serialized. Null
+// checks are left until then, when they can bubble up to the nearest
+// nullable field.
+type resultObject struct {
+	fields []resultField
+}
+
+type resultField struct {
+	key   string
+	typ   Type
+	loc   Location
+	value any // nil, a serialized scalar, *resultObject or *resultList
+}
+
+type resultList struct {
+	items []any
+}
+
+func (e *execution) run(op *operation, root *Object) *Response {
+	data := e.executeFields(root, nil, op.selectionSet, nil, op.kind == "mutation")
+	e.drain()
+
+	out, ok := e.finalize(root, data, nil, op.loc)
+	resp := &Response{Errors: e.errs, executed: true}
+	if ok {
+		resp.Data = out
+	}
+	return resp
+}
+
+// drain forces pending Thunks level by level until none are left.
+func (e *execution) drain() {
+	for len(e.pending) > 0 {
+		level := e.pending
+		e.pending = nil
+		for _, complete := range level {
+			complete()
+		}
+	}
+}
+
+// fieldGroup is the fields of a selection set that share a response key.
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+// collectFields flattens set's fragments and applies @skip and @include,
+// grouping fields by response key in the order they first appear.
+func (e *execution) collectFields(obj *Object, set []selection, groups []*fieldGroup, visited map[string]bool) []*fieldGroup {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *field:
+			if !e.included(sel.directives) {
+				continue
+			}
+			key := sel.responseKey()
+			found := false
+			for _, g := range groups {
+				if g.key == key {
+					g.fields = append(g.fields, sel)
+					found = true
+					break
+				}
+			}
+			if !found {
+				groups = append(groups, &fieldGroup{key: key, fields: []*field{sel}})
+			}
+		case *inlineFragment:
+			if !e.included(sel.directives) || (sel.typeCondition != "" && sel.typeCondition != obj.Name) {
+				continue
+			}
+			groups = e.collectFields(obj, sel.selectionSet, groups, visited)
+		case *fragmentSpread:
+			if !e.included(sel.directives) || visited[sel.name] {
+				continue
+			}
+			visited[sel.name] = true
+			frag := e.doc.fragments[sel.name]
+			if frag == nil || frag.typeCondition != obj.Name || !e.included(frag.directives) {
+				continue
+			}
+			groups = e.collectFields(obj, frag.selectionSet, groups, visited)
+		}
+	}
+	return groups
+}
+
+func (e *execution) included(dirs []*directive) bool {
+	for _, d := range dirs {
+		args, err := coerceArgs(directiveArgs, d.arguments, e.vars)
+		if err != nil {
+			continue
+		}
+		if cond, _ := args["if"].(bool); cond == (d.name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+// executeFields resolves set on source. Root mutation fields are run
+// serially, each one including its Thunks before the next starts.
+func (e *execution) executeFields(obj *Object, source any, set []selection, path []any, serial bool) *resultObject {
+	groups := e.collectFields(obj, set, nil, map[string]bool{})
+	res := &resultObject{fields: make([]resultField, len(groups))}
+	for i, g := range groups {
+		rf := &res.fields[i]
+		rf.key = g.key
+		rf.loc = g.fields[0].loc
+		if g.fields[0].name == "__typename" {
+			rf.typ = NewNonNull(String)
+			rf.value = obj.Name
+			continue
+		}
+		def := obj.field(g.fields[0].name)
+		rf.typ = def.Type
+		e.resolveField(def, source, g.fields, appendPath(path, g.key), &rf.value)
+		if serial {
+			e.drain()
+		}
+	}
+	return res
+}
+
+func (e *execution) resolveField(def *Field, source any, fields []*field, path []any, slot *any) {
+	args, err := coerceArgs(def.Args, fields[0].arguments, e.vars)
+	if err != nil {
+		e.fieldError(err, fields, path)
+		return
+	}
+	if def.Resolve == nil {
+		e.fieldError(fmt.Errorf("graphql: field %s has no resolver", def.Name), fields, path)
+		return
+	}
+	v, err := protect(func() (any, error) {
+		return def.Resolve(ResolveParams{Context: e.ctx, Source: source, Args: args})
+	})
+	if err != nil {
+		e.fieldError(err, fields, path)
+		return
+	}
+	e.complete(def.Type, fields, v, path, slot)
+}
+
+// complete stores v, resolved for a field of type t, in slot. Objects and
+// lists are executed further; Thunks are queued for the next level.
+func (e *execution) complete(t Type, fields []*field, v any, path []any, slot *any) {
+	if thunk, ok := v.(Thunk); ok {
+		e.pending = append(e.pending, func() {
+			v, err := protect(thunk)
+			if err != nil {
+				e.fieldError(err, fields, path)
+				return
+			}
+			e.complete(t, fields, v, path, slot)
+		})
+		return
+	}
+	if nn, ok := t.(*NonNull); ok {
+		t = nn.OfType
+	}
+	if isNil(v) {
+		*slot = nil
+		return
+	}
+
+	switch t := t.(type) {
+	case *Scalar:
+		out, err := t.Serialize(v)
+		if err != nil {
+			e.fieldError(err, fields, path)
+			return
+		}
+		*slot = out
+	case *Object:
+		var set []selection
+		for _, f := range fields {
+			set = append(set, f.selectionSet...)
+		}
+		*slot = e.executeFields(t, v, set, path, false)
+	case *List:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.fieldError(fmt.Errorf("graphql: resolver returned %T for a list", v), fields, path)
+			return
+		}
+		list := &resultList{items: make([]any, rv.Len())}
+		*slot = list
+		for i := range list.items {
+			e.complete(t.OfType, fields, rv.Index(i).Interface(), appendPath(path, i), &list.items[i])
+		}
+	}
+}
+
+// finalize checks non-null types and builds the JSON value for v. ok is
+// false if v is null where t doesn't allow it, in which case the null
+// propagates to the nearest nullable parent.
+func (e *execution) finalize(t Type, v any, path []any, loc Location) (out any, ok bool) {
+	if nn, isNonNull := t.(*NonNull); isNonNull {
+		if v == nil {
+			if !e.errorPaths[pathKey(path)] {
+				e.addError(&Error{
+					Message:   fmt.Sprintf("Cannot return null for non-nullable field %s.", pathKey(path)),
+					Locations: []Location{loc},
+					Path:      path,
+				}, path)
+			}
+			return nil, false
+		}
+		out, ok := e.finalize(nn.OfType, v, path, loc)
+		if !ok || out == nil {
+			return nil, false
+		}
+		return out, true
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil, true
+	case *resultObject:
+		obj := &orderedObject{keys: make([]string, len(v.fields)), values: make([]any, len(v.fields))}
+		for i, f := range v.fields {
+			val, ok := e.finalize(f.typ, f.value, appendPath(path, f.key), f.loc)
+			if !ok {
+				return nil, true
+			}
+			obj.keys[i] = f.key
+			obj.values[i] = val
+		}
+		return obj, true
+	case *resultList:
+		elem := t.(*List).OfType
+		items := make([]any, len(v.items))
+		for i, item := range v.items {
+			val, ok := e.finalize(elem, item, appendPath(path, i), loc)
+			if !ok {
+				return nil, true
+			}
+			items[i] = val
+		}
+		return items, true
+	}
+	return v, true
+}
+
+func (e *execution) fieldError(err error, fields []*field, path []any) {
+	var gqlErr *Error
+	switch {
+	case isError(err, &gqlErr):
+		c := *gqlErr
+		gqlErr = &c
+	case e.x.FormatError != nil:
+		gqlErr = e.x.FormatError(e.ctx, err)
+	default:
+		gqlErr = &Error{Message: err.Error()}
+	}
+	if gqlErr.err == nil {
+		gqlErr.err = err
+	}
+	gqlErr.Locations = []Location{fields[0].loc}
+	gqlErr.Path = path
+	e.addError(gqlErr, path)
+}
+
+// isError reports whether err is an *Error made by this package, such as
+// an invalid argument, rather than one returned by a resolver.
+func isError(err error, target **Error) bool {
+	e, ok := err.(*Error)
+	if ok {
+		*target = e
+	}
+	return ok
+}
+
+func (e *execution) addError(err *Error, path []any) {
+	e.errs = append(e.errs, err)
+	e.errorPaths[pathKey(path)] = true
+}
+
+// protect calls fn, turning a panic into an error.
+func protect(fn func() (any, error)) (v any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("graphql: resolver panicked: %v", r)
+		}
+	}()
+	return fn()
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func appendPath(path []any, elem any) []any {
+	out := make([]any, len(path)+1)
+	copy(out, path)
+	out[len(path)] = elem
+	return out
+}
+
+func pathKey(path []any) string {
+	var b strings.Builder
+	for i, p := range path {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		fmt.Fprint(&b, p)
+	}
+	return b.String()
+}
+
+// orderedObject is a JSON object that keeps the order of its keys, which
+// GraphQL responses must follow.
+type orderedObject struct {
+	keys   []string
+	values []any
+}
+
+// MarshalJSON writes the keys in the order they were set.
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// checkLimits measures the operation's depth and complexity, stopping as
+// soon as either limit is passed so that deeply nested fragments can't
+// make the check itself expensive.
+func (x *Executor) checkLimits(doc *document, vars map[string]any, root *Object, op *operation) error {
+	if x.MaxDepth <= 0 && x.MaxComplexity <= 0 {
+		return nil
+	}
+	e := &execution{doc: doc, vars: vars}
+	m := &measure{e: e, maxDepth: x.MaxDepth, maxCost: x.MaxComplexity}
+	m.cost(root, op.selectionSet, 1)
+	return m.err
+}
+
+type measure struct {
+	e                 *execution
+	maxDepth, maxCost int
+	// spent counts every field visited, so the walk stops even when
+	// multipliers are small.
+	spent int
+	err   error
+}
+
+func (m *measure) cost(obj *Object, set []selection, depth int) int {
+	if m.maxDepth > 0 && depth > m.maxDepth {
+		m.err = &Error{Message: fmt.Sprintf("The query is nested more deeply than the limit of %d.", m.maxDepth)}
+		return 0
+	}
+	total := 0
+	for _, g := range m.e.collectFields(obj, set, nil, map[string]bool{}) {
+		if m.err != nil {
+			return 0
+		}
+		m.spent++
+		fieldCost := 1
+		f := g.fields[0]
+		if def := obj.field(f.name); def != nil {
+			if child, ok := namedType(def.Type).(*Object); ok {
+				var sub []selection
+				for _, f := range g.fields {
+					sub = append(sub, f.selectionSet...)
+				}
+				n := 1
+				if def.Multiplier != nil {
+					if args, err := coerceArgs(def.Args, f.arguments, m.e.vars); err == nil {
+						n = max(def.Multiplier(args), 1)
+					}
+				}
+				fieldCost += n * m.cost(child, sub, depth+1)
+			}
+		}
+		total += fieldCost
+		if m.maxCost > 0 && (total > m.maxCost || m.spent > m.maxCost) {
+			m.err = &Error{Message: fmt.Sprintf("The query is more complex than the limit of %d.", m.maxCost)}
+			return 0
+		}
+	}
+	return total
+}
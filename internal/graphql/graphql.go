@@ -0,0 +1,185 @@
+// Package graphql executes GraphQL queries against a schema of Go
+// resolvers. It implements the executable part of the language, that is
+// operations, variables, aliases, fragments and the @skip and @include
+// directives, with scalar and object types. Interfaces, unions, enums,
+// input objects, subscriptions and introspection are not supported;
+// Schema.SDL prints the schema for tooling instead.
+//
+// Resolvers can return a Thunk instead of a value. Thunks are forced one
+// level of the response at a time, after every field on that level has
+// been resolved, which lets a Loader fetch the values for a whole level in
+// one batch rather than once per object.
+//
+// Before executing, an Executor can bound how deeply a query nests and its
+// complexity: each field costs 1 plus the cost of its selections, times
+// the field's Multiplier, so a connection's page size is paid for by
+// everything selected in each node.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Codes set in the "code" extension of request errors.
+const (
+	CodeParseFailed      = "graphql_parse_failed"
+	CodeValidationFailed = "graphql_validation_failed"
+	CodeTooComplex       = "query_too_complex"
+)
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	// ReadOnly rejects mutations, for requests made with GET.
+	ReadOnly bool `json:"-"`
+}
+
+// Response is the result of a request. Data is nil if the request failed
+// before execution, in which case it is left out of the JSON.
+type Response struct {
+	Data   any
+	Errors []*Error
+
+	executed bool
+}
+
+// Executed reports whether execution started, meaning the request was
+// well formed and passed validation.
+func (r *Response) Executed() bool {
+	return r.executed
+}
+
+// MarshalJSON leaves data out when the request failed before execution
+// started, as the spec requires, and writes errors only when there are any.
+func (r *Response) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	if r.executed {
+		data, err := json.Marshal(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`"data":`)
+		buf.Write(data)
+	}
+	if len(r.Errors) > 0 {
+		errs, err := json.Marshal(r.Errors)
+		if err != nil {
+			return nil, err
+		}
+		if r.executed {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`"errors":`)
+		buf.Write(errs)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Error is an error in a response. Field errors have a Path; request
+// errors, which stop the request before execution, don't.
+type Error struct {
+	Message    string         `json:"message"`
+	Locations  []Location     `json:"locations,omitempty"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+
+	err error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("graphql: ")
+	if len(e.Path) > 0 {
+		for i, p := range e.Path {
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			fmt.Fprint(&b, p)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// Unwrap returns the resolver error e was made from, if any.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+func newError(loc Location, format string, args ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}}
+}
+
+func withCode(e *Error, code string) *Error {
+	if e.Extensions == nil {
+		e.Extensions = map[string]any{}
+	}
+	if _, ok := e.Extensions["code"]; !ok {
+		e.Extensions["code"] = code
+	}
+	return e
+}
+
+// Executor runs requests against a schema.
+type Executor struct {
+	Schema *Schema
+	// MaxDepth and MaxComplexity reject queries that nest fields more
+	// deeply or cost more. Zero means no limit.
+	MaxDepth      int
+	MaxComplexity int
+	// FormatError turns an error returned by a resolver or Thunk into the
+	// error sent to the client; the path and location are filled in
+	// afterwards. By default the message is err.Error().
+	FormatError func(ctx context.Context, err error) *Error
+}
+
+// Execute parses, validates and executes req. Errors are reported in the
+// response.
+func (x *Executor) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return requestError(err, CodeParseFailed)
+	}
+
+	op, vars, errs := validate(x.Schema, doc, req)
+	if len(errs) > 0 {
+		for _, e := range errs {
+			withCode(e, CodeValidationFailed)
+		}
+		return &Response{Errors: errs}
+	}
+
+	root := x.Schema.Query
+	if op.kind == "mutation" {
+		root = x.Schema.Mutation
+	}
+	if err := x.checkLimits(doc, vars, root, op); err != nil {
+		return requestError(err, CodeTooComplex)
+	}
+
+	e := &execution{
+		ctx:        ctx,
+		x:          x,
+		doc:        doc,
+		vars:       vars,
+		errorPaths: map[string]bool{},
+	}
+	return e.run(op, root)
+}
+
+func requestError(err error, code string) *Response {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Message: err.Error()}
+	}
+	return &Response{Errors: []*Error{withCode(e, code)}}
+}
@@ -0,0 +1,392 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testUser struct {
+	id, name string
+	friends  []string
+}
+
+var testUsers = map[string]testUser{
+	"1": {"1", "Walt", []string{"2", "3"}},
+	"2": {"2", "Jesse", []string{"1"}},
+	"3": {"3", "Skyler", nil},
+}
+
+type loaderKey struct{}
+
+// newTestExecutor returns an Executor for a small schema of users whose
+// friends are loaded in batches. Each request needs a Loader in its
+// context, see run.
+func newTestExecutor(t *testing.T) *Executor {
+	t.Helper()
+	load := func(p ResolveParams, id string) Thunk {
+		return p.Context.Value(loaderKey{}).(*Loader[string, testUser]).Load(p.Context, id)
+	}
+	first := func(args map[string]any) int { return args["first"].(int) }
+
+	var userType *Object
+	userType = &Object{
+		Name: "User",
+		Fields: func() []*Field {
+			return []*Field{
+				{Name: "id", Type: NewNonNull(ID), Resolve: func(p ResolveParams) (any, error) {
+					return p.Source.(testUser).id, nil
+				}},
+				{Name: "name", Type: NewNonNull(String), Resolve: func(p ResolveParams) (any, error) {
+					return p.Source.(testUser).name, nil
+				}},
+				{
+					Name:       "friends",
+					Type:       NewNonNull(NewList(NewNonNull(userType))),
+					Args:       []*Argument{{Name: "first", Type: Int, Default: 10}},
+					Multiplier: first,
+					Resolve: func(p ResolveParams) (any, error) {
+						ids := p.Source.(testUser).friends
+						friends := []any{}
+						for _, id := range ids[:min(len(ids), p.Args["first"].(int))] {
+							friends = append(friends, load(p, id))
+						}
+						return friends, nil
+					},
+				},
+				{Name: "broken", Type: String, Resolve: func(p ResolveParams) (any, error) {
+					return nil, errors.New("it broke")
+				}},
+				{Name: "required", Type: NewNonNull(String), Resolve: func(p ResolveParams) (any, error) {
+					return nil, nil
+				}},
+			}
+		},
+	}
+	query := &Object{
+		Name: "Query",
+		Fields: func() []*Field {
+			return []*Field{
+				{Name: "user", Type: userType, Args: []*Argument{{Name: "id", Type: NewNonNull(ID)}}, Resolve: func(p ResolveParams) (any, error) {
+					return load(p, p.Args["id"].(string)), nil
+				}},
+				{Name: "echo", Type: String, Args: []*Argument{{Name: "text", Type: String, Default: "hello"}, {Name: "times", Type: Int}}, Resolve: func(p ResolveParams) (any, error) {
+					n, ok := p.Args["times"].(int)
+					if !ok {
+						n = 1
+					}
+					return strings.Repeat(p.Args["text"].(string), n), nil
+				}},
+			}
+		},
+	}
+	mutation := &Object{
+		Name: "Mutation",
+		Fields: func() []*Field {
+			return []*Field{
+				{Name: "echo", Type: NewNonNull(String), Args: []*Argument{{Name: "text", Type: NewNonNull(String)}}, Resolve: func(p ResolveParams) (any, error) {
+					return p.Args["text"], nil
+				}},
+			}
+		},
+	}
+	s, err := NewSchema(query, mutation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Executor{Schema: s}
+}
+
+// run executes req with a fresh Loader and returns the response as JSON
+// and how many times the loader fetched.
+func run(t *testing.T, x *Executor, req Request) (string, int) {
+	t.Helper()
+	fetches := 0
+	loader := NewLoader(func(ctx context.Context, ids []string) (map[string]testUser, error) {
+		fetches++
+		users := map[string]testUser{}
+		for _, id := range ids {
+			if u, ok := testUsers[id]; ok {
+				users[id] = u
+			}
+		}
+		return users, nil
+	})
+	ctx := context.WithValue(context.Background(), loaderKey{}, loader)
+	out, err := json.Marshal(x.Execute(ctx, req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), fetches
+}
+
+func TestExecute(t *testing.T) {
+	x := newTestExecutor(t)
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "shorthand",
+			req:  Request{Query: `{ echo }`},
+			want: `{"data":{"echo":"hello"}}`,
+		},
+		{
+			name: "aliases and arguments",
+			req:  Request{Query: `{ a: echo(text: "ab", times: 2) b: echo(text: """  "block" """) }`},
+			want: `{"data":{"a":"abab","b":"  \"block\" "}}`,
+		},
+		{
+			name: "variables",
+			req: Request{
+				Query:     `query Echo($text: String = "default", $times: Int) { echo(text: $text, times: $times) }`,
+				Variables: map[string]any{"times": json.Number("3")},
+			},
+			want: `{"data":{"echo":"defaultdefaultdefault"}}`,
+		},
+		{
+			name: "operation name",
+			req: Request{
+				Query:         `query A { a: echo } query B { b: echo }`,
+				OperationName: "B",
+			},
+			want: `{"data":{"b":"hello"}}`,
+		},
+		{
+			name: "fragments and directives",
+			req: Request{
+				Query: `query($skip: Boolean!) {
+					user(id: "1") { ...names friends { ... on User { id } } }
+				}
+				fragment names on User {
+					__typename
+					name @skip(if: $skip)
+					id @include(if: $skip)
+				}`,
+				Variables: map[string]any{"skip": true},
+			},
+			want: `{"data":{"user":{"__typename":"User","id":"1","friends":[{"id":"2"},{"id":"3"}]}}}`,
+		},
+		{
+			name: "fields merged by response key",
+			req:  Request{Query: `{ user(id: "2") { id } user(id: "2") { name } }`},
+			want: `{"data":{"user":{"id":"2","name":"Jesse"}}}`,
+		},
+		{
+			name: "missing object",
+			req:  Request{Query: `{ user(id: "9") { id } }`},
+			want: `{"data":{"user":null}}`,
+		},
+		{
+			name: "resolver error",
+			req:  Request{Query: `{ user(id: "1") { id broken } }`},
+			want: `{"data":{"user":{"id":"1","broken":null}},"errors":[{"message":"it broke","locations":[{"line":1,"column":22}],"path":["user","broken"]}]}`,
+		},
+		{
+			name: "null propagates to the nearest nullable field",
+			req:  Request{Query: `{ user(id: "1") { id required } }`},
+			want: `{"data":{"user":null},"errors":[{"message":"Cannot return null for non-nullable field user.required.","locations":[{"line":1,"column":22}],"path":["user","required"]}]}`,
+		},
+		{
+			name: "mutation",
+			req:  Request{Query: `mutation { a: echo(text: "a") b: echo(text: "b") }`},
+			want: `{"data":{"a":"a","b":"b"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := run(t, x, tt.req)
+			if got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteBatchesLoads(t *testing.T) {
+	x := newTestExecutor(t)
+	// One fetch for Walt and one for both his friends. Their friend is
+	// Walt, who is already loaded
+	got, fetches := run(t, x, Request{Query: `{ user(id: "1") { friends { name friends { name } } } }`})
+	want := `{"data":{"user":{"friends":[{"name":"Jesse","friends":[{"name":"Walt"}]},{"name":"Skyler","friends":[]}]}}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if fetches != 2 {
+		t.Errorf("fetched %d times, want 2", fetches)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+		loc   Location
+	}{
+		{``, "The query has no operations.", Location{1, 1}},
+		{`{ echo`, `Syntax error: expected a name, found end of query.`, Location{1, 7}},
+		{"{\n  echo(text: $x) }\nfragment on on User { id }", `Syntax error: a fragment can't be named "on".`, Location{3, 1}},
+		{`{ echo(text: "unterminated) }`, "Syntax error: unterminated string.", Location{1, 14}},
+		{`{ echo(times: 01) }`, "Syntax error: invalid number, unexpected digit after 0.", Location{1, 15}},
+		{`{ echo ? }`, `Syntax error: unexpected character '?'.`, Location{1, 8}},
+		{`query($x: Int = $y) { echo }`, "Syntax error: variables aren't allowed here.", Location{1, 17}},
+		{`fragment f on User { id } fragment f on User { id }`, `There can be only one fragment named "f".`, Location{1, 27}},
+		{`type User { id: ID }`, `Syntax error: unexpected "type".`, Location{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := parse(tt.query)
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("parse returned %v, want an *Error", err)
+			}
+			if e.Message != tt.want || len(e.Locations) != 1 || e.Locations[0] != tt.loc {
+				t.Errorf("got %q at %v, want %q at %v", e.Message, e.Locations, tt.want, tt.loc)
+			}
+		})
+	}
+
+	got, _ := run(t, newTestExecutor(t), Request{Query: `{`})
+	want := `{"errors":[{"message":"Syntax error: expected a name, found end of query.","locations":[{"line":1,"column":2}],"extensions":{"code":"graphql_parse_failed"}}]}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+		# A comment, and commas are whitespace
+		query Q($id: ID! = "1", $ids: [ID!]) @include(if: true) {
+			user(id: $id) { ...f, ... on User @skip(if: false) { name } }
+			echo(text: """
+				indented
+				  block
+			""")
+		}
+		fragment f on User { id }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.operations) != 1 || len(doc.fragments) != 1 {
+		t.Fatalf("parsed %d operations and %d fragments", len(doc.operations), len(doc.fragments))
+	}
+	op := doc.operations[0]
+	if op.kind != "query" || op.name != "Q" || len(op.variables) != 2 || len(op.directives) != 1 || len(op.selectionSet) != 2 {
+		t.Fatalf("operation = %+v", op)
+	}
+	if typ := op.variables[1].typ.String(); typ != "[ID!]" {
+		t.Errorf("$ids has type %s", typ)
+	}
+	echo := op.selectionSet[1].(*field)
+	if s := echo.arguments[0].value.(*stringValue).s; s != "indented\n  block" {
+		t.Errorf("block string = %q", s)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	x := newTestExecutor(t)
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"unknown field", Request{Query: `{ nope }`}, `Cannot query field "nope" on type "Query".`},
+		{"unknown argument", Request{Query: `{ echo(loud: true) }`}, `Unknown argument "loud" on "Query.echo".`},
+		{"missing argument", Request{Query: `{ user { id } }`}, `Argument "id" of type "ID!" is required on "Query.user" but not provided.`},
+		{"invalid literal", Request{Query: `{ echo(times: "two") }`}, `Argument "times" has an invalid value: Int cannot represent "two".`},
+		{"missing selection", Request{Query: `{ user(id: "1") }`}, `Field "user" of type "User" must have a selection of subfields.`},
+		{"selection on a scalar", Request{Query: `{ echo { length } }`}, `Field "echo" must not have a selection since type "String" has no subfields.`},
+		{"unused variable", Request{Query: `query($x: Int) { echo }`}, `Variable "$x" is never used.`},
+		{"undefined variable", Request{Query: `{ echo(times: $x) }`}, `Variable "$x" is not defined.`},
+		{"variable type", Request{Query: `query($x: String) { echo(times: $x) }`}, `Variable "$x" of type "String" used in position expecting type "Int".`},
+		{"nullable variable in a non-null position", Request{Query: `query($id: ID) { user(id: $id) { id } }`}, `Variable "$id" of type "ID" used in position expecting type "ID!".`},
+		{"unknown fragment", Request{Query: `{ user(id: "1") { ...f } }`}, `Unknown fragment "f".`},
+		{"fragment cycle", Request{Query: `{ user(id: "1") { ...f } } fragment f on User { friends { ...f } }`}, `Cannot spread fragment "f" within itself.`},
+		{"fragment on another type", Request{Query: `{ ... on User { id } }`}, `Fragment on "User" can never be spread within "Query".`},
+		{"unknown directive", Request{Query: `{ echo @defer }`}, `Unknown directive "@defer".`},
+		{"mutation with GET", Request{Query: `mutation { echo(text: "a") }`, ReadOnly: true}, `Mutations can't be sent with GET.`},
+		{"unknown operation", Request{Query: `query A { echo }`, OperationName: "B"}, `Unknown operation named "B".`},
+		{"ambiguous operation", Request{Query: `query A { echo } query B { echo }`}, `The query has more than one operation, so operationName is required.`},
+		{"missing variable", Request{Query: `query($id: ID!) { user(id: $id) { id } }`}, `Variable "$id" of required type "ID!" was not provided.`},
+		{"invalid variable", Request{Query: `query($n: Int) { echo(times: $n) }`, Variables: map[string]any{"n": "two"}}, `Variable "$n" got invalid value "two"; Int cannot represent "two".`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := x.Execute(context.Background(), tt.req)
+			if resp.Executed() {
+				t.Fatal("executed an invalid request")
+			}
+			if len(resp.Errors) == 0 || resp.Errors[0].Message != tt.want {
+				t.Fatalf("errors = %v, want %q", resp.Errors, tt.want)
+			}
+			if code := resp.Errors[0].Extensions["code"]; code != CodeValidationFailed {
+				t.Errorf("code = %v, want %s", code, CodeValidationFailed)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	x := newTestExecutor(t)
+	tests := []struct {
+		name          string
+		maxDepth      int
+		maxComplexity int
+		query         string
+		want          string // the error, if any
+	}{
+		{"no limits", 0, 0, `{ user(id: "1") { friends { friends { friends { id } } } } }`, ""},
+		{"at the depth limit", 3, 0, `{ user(id: "1") { friends { id } } }`, ""},
+		{"too deep", 3, 0, `{ user(id: "1") { friends { friends { id } } } }`, "The query is nested more deeply than the limit of 3."},
+		{"too deep through a fragment", 3, 0, `{ user(id: "1") { ...f } } fragment f on User { friends { friends { id } } }`, "The query is nested more deeply than the limit of 3."},
+		// user 1 + friends 1 + 10 * id 1
+		{"at the complexity limit", 0, 12, `{ user(id: "1") { friends { id } } }`, ""},
+		{"too complex", 0, 11, `{ user(id: "1") { friends { id } } }`, "The query is more complex than the limit of 11."},
+		// user 1 + friends (1 + 2 * (id 1 + friends (1 + 3 * id 1)))
+		{"multiplied by arguments", 0, 12, `{ user(id: "1") { friends(first: 2) { id friends(first: 3) { id } } } }`, ""},
+		{"multiplied by variables", 0, 11, `query($n: Int) { user(id: "1") { friends(first: $n) { id friends(first: 3) { id } } } }`, "The query is more complex than the limit of 11."},
+		{"skipped fields are free", 0, 2, `{ user(id: "1") { id friends @skip(if: true) { id } } }`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := *x
+			x.MaxDepth, x.MaxComplexity = tt.maxDepth, tt.maxComplexity
+			resp := x.Execute(context.WithValue(context.Background(), loaderKey{}, NewLoader(func(ctx context.Context, ids []string) (map[string]testUser, error) {
+				return testUsers, nil
+			})), Request{Query: tt.query, Variables: map[string]any{"n": json.Number("3")}})
+			if tt.want == "" {
+				if len(resp.Errors) > 0 {
+					t.Fatalf("errors = %v", resp.Errors)
+				}
+				return
+			}
+			if resp.Executed() || len(resp.Errors) != 1 || resp.Errors[0].Message != tt.want {
+				t.Fatalf("errors = %v, want %q", resp.Errors, tt.want)
+			}
+			if code := resp.Errors[0].Extensions["code"]; code != CodeTooComplex {
+				t.Errorf("code = %v, want %s", code, CodeTooComplex)
+			}
+		})
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	x := newTestExecutor(t)
+	x.FormatError = func(ctx context.Context, err error) *Error {
+		return &Error{Message: "formatted: " + err.Error(), Extensions: map[string]any{"code": "internal"}}
+	}
+	resp := x.Execute(context.WithValue(context.Background(), loaderKey{}, NewLoader(func(ctx context.Context, ids []string) (map[string]testUser, error) {
+		return testUsers, nil
+	})), Request{Query: `{ user(id: "1") { broken } }`})
+	if len(resp.Errors) != 1 {
+		t.Fatalf("errors = %v", resp.Errors)
+	}
+	e := resp.Errors[0]
+	if e.Message != "formatted: it broke" || e.Extensions["code"] != "internal" || len(e.Path) != 2 || e.Unwrap() == nil {
+		t.Errorf("error = %+v", e)
+	}
+}
@@ -0,0 +1,91 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+)
+
+// Loader batches and caches lookups by key for the duration of one
+// request. Load queues a key and returns a Thunk; when the first of the
+// queued Thunks is forced, every queued key is fetched in one call.
+type Loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	queued  []K
+	results map[K]*loadResult[V]
+}
+
+type loadResult[V any] struct {
+	done  bool
+	found bool
+	value V
+	err   error
+}
+
+// NewLoader returns a Loader that looks keys up with fetch. Keys missing
+// from fetch's result resolve to null.
+func NewLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, results: map[K]*loadResult[V]{}}
+}
+
+// Load returns a Thunk for the value of key.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) Thunk {
+	l.mu.Lock()
+	if _, ok := l.results[key]; !ok {
+		l.results[key] = &loadResult[V]{}
+		l.queued = append(l.queued, key)
+	}
+	l.mu.Unlock()
+
+	return func() (any, error) {
+		r := l.get(ctx, key)
+		if r.err != nil || !r.found {
+			return nil, r.err
+		}
+		return r.value, nil
+	}
+}
+
+// Prime caches value for key, such as a user just loaded by other means.
+func (l *Loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.results[key]; !ok || !r.done {
+		l.results[key] = &loadResult[V]{done: true, found: true, value: value}
+	}
+}
+
+// Clear forgets the value of key, so that the next Load fetches it again,
+// such as after a mutation changed it.
+func (l *Loader[K, V]) Clear(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.results[key]; ok && r.done {
+		delete(l.results, key)
+	}
+}
+
+func (l *Loader[K, V]) get(ctx context.Context, key K) *loadResult[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r := l.results[key]; r.done {
+		return r
+	}
+
+	var keys []K
+	for _, k := range l.queued {
+		if !l.results[k].done {
+			keys = append(keys, k)
+		}
+	}
+	l.queued = nil
+	values, err := l.fetch(ctx, keys)
+	for _, k := range keys {
+		r := l.results[k]
+		r.done = true
+		r.err = err
+		r.value, r.found = values[k]
+	}
+	return l.results[key]
+}
@@ -0,0 +1,110 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// countingLoader returns a Loader of squares that records each fetch's
+// keys. Negative keys are missing.
+func countingLoader() (*Loader[int, int], *[][]int) {
+	var fetches [][]int
+	l := NewLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		fetches = append(fetches, slices.Clone(keys))
+		values := map[int]int{}
+		for _, k := range keys {
+			if k >= 0 {
+				values[k] = k * k
+			}
+		}
+		return values, nil
+	})
+	return l, &fetches
+}
+
+func force(t *testing.T, thunk Thunk) any {
+	t.Helper()
+	v, err := thunk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestLoaderBatches(t *testing.T) {
+	ctx := context.Background()
+	l, fetches := countingLoader()
+
+	thunks := []Thunk{l.Load(ctx, 1), l.Load(ctx, 2), l.Load(ctx, 1), l.Load(ctx, -1)}
+	if len(*fetches) != 0 {
+		t.Fatal("Load fetched before a Thunk was forced")
+	}
+	got := []any{force(t, thunks[0]), force(t, thunks[1]), force(t, thunks[2]), force(t, thunks[3])}
+	if want := []any{1, 4, 1, nil}; !slices.Equal(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+	if want := [][]int{{1, 2, -1}}; !slices.EqualFunc(*fetches, want, slices.Equal) {
+		t.Errorf("fetches = %v, want %v", *fetches, want)
+	}
+
+	// Cached keys aren't fetched again; new ones are batched on their own
+	if v := force(t, l.Load(ctx, 2)); v != 4 {
+		t.Errorf("cached value = %v", v)
+	}
+	a, b := l.Load(ctx, 3), l.Load(ctx, 2)
+	force(t, b)
+	force(t, a)
+	if want := [][]int{{1, 2, -1}, {3}}; !slices.EqualFunc(*fetches, want, slices.Equal) {
+		t.Errorf("fetches = %v, want %v", *fetches, want)
+	}
+}
+
+func TestLoaderPrimeAndClear(t *testing.T) {
+	ctx := context.Background()
+	l, fetches := countingLoader()
+
+	l.Prime(5, 0)
+	if v := force(t, l.Load(ctx, 5)); v != 0 {
+		t.Errorf("primed value = %v", v)
+	}
+	if len(*fetches) != 0 {
+		t.Errorf("fetched a primed key: %v", *fetches)
+	}
+
+	// Priming doesn't replace a value already loaded
+	force(t, l.Load(ctx, 6))
+	l.Prime(6, 0)
+	if v := force(t, l.Load(ctx, 6)); v != 36 {
+		t.Errorf("value after priming a loaded key = %v", v)
+	}
+
+	l.Clear(5)
+	if v := force(t, l.Load(ctx, 5)); v != 25 {
+		t.Errorf("value after clearing = %v", v)
+	}
+	// Clearing a queued key leaves it in the batch
+	thunk := l.Load(ctx, 7)
+	l.Clear(7)
+	if v := force(t, thunk); v != 49 {
+		t.Errorf("value of a cleared queued key = %v", v)
+	}
+	if want := [][]int{{6}, {5}, {7}}; !slices.EqualFunc(*fetches, want, slices.Equal) {
+		t.Errorf("fetches = %v, want %v", *fetches, want)
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	ctx := context.Background()
+	errFetch := errors.New("fetch failed")
+	l := NewLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		return nil, errFetch
+	})
+	a, b := l.Load(ctx, 1), l.Load(ctx, 2)
+	for _, thunk := range []Thunk{a, b} {
+		if _, err := thunk(); !errors.Is(err, errFetch) {
+			t.Errorf("got %v, want the fetch error for every key", err)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package graphql
+
+import "fmt"
+
+// parse parses an executable document: operations and fragments. Type
+// system definitions are rejected.
+func parse(src string) (*document, error) {
+	p := &parser{lex: newLexer(src)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunct, "{"):
+			op := &operation{loc: p.tok.loc, kind: "query"}
+			var err error
+			if op.selectionSet, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"), p.peek(tokenName, "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peek(tokenName, "fragment"):
+			frag, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[frag.name]; ok {
+				return nil, newError(frag.loc, "There can be only one fragment named %q.", frag.name)
+			}
+			doc.fragments[frag.name] = frag
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, newError(Location{Line: 1, Column: 1}, "The query has no operations.")
+	}
+	return doc, nil
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(kind tokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+// skip advances past the punctuator s if it is next.
+func (p *parser) skip(s string) (bool, error) {
+	if !p.peek(tokenPunct, s) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) expect(s string) error {
+	if !p.peek(tokenPunct, s) {
+		return syntaxError(p.tok.loc, "expected %q, found %s", s, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *parser) expectKeyword(s string) error {
+	if !p.peek(tokenName, s) {
+		return syntaxError(p.tok.loc, "expected %q, found %s", s, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", syntaxError(p.tok.loc, "expected a name, found %s", p.tok)
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) unexpected() error {
+	return syntaxError(p.tok.loc, "unexpected %s", p.tok)
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{loc: p.tok.loc, kind: p.tok.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if p.tok.kind == tokenName {
+		if op.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if op.variables, err = p.variableDefinitions(); err != nil {
+		return nil, err
+	}
+	if op.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if op.selectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (p *parser) variableDefinitions() ([]*variableDefinition, error) {
+	if ok, err := p.skip("("); !ok || err != nil {
+		return nil, err
+	}
+	var defs []*variableDefinition
+	for {
+		def := &variableDefinition{loc: p.tok.loc}
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		var err error
+		if def.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if def.typ, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.skip("="); err != nil {
+			return nil, err
+		} else if ok {
+			if def.defValue, err = p.value(true); err != nil {
+				return nil, err
+			}
+		}
+		// Directives on variable definitions are allowed but unused
+		if _, err := p.directives(); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+
+		if ok, err := p.skip(")"); err != nil || ok {
+			return defs, err
+		}
+	}
+}
+
+func (p *parser) typeRef() (*typeRef, error) {
+	t := &typeRef{}
+	if ok, err := p.skip("["); err != nil {
+		return nil, err
+	} else if ok {
+		if t.elem, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else if t.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	ok, err := p.skip("!")
+	t.nonNull = ok
+	return t, err
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	frag := &fragment{loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if frag.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if frag.name == "on" {
+		return nil, syntaxError(frag.loc, "a fragment can't be named \"on\"")
+	}
+	if err := p.expectKeyword("on"); err != nil {
+		return nil, err
+	}
+	if frag.typeCondition, err = p.name(); err != nil {
+		return nil, err
+	}
+	if frag.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if frag.selectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return frag, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var set []selection
+	for {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, sel)
+		if ok, err := p.skip("}"); err != nil || ok {
+			return set, err
+		}
+	}
+}
+
+func (p *parser) selection() (selection, error) {
+	if !p.peek(tokenPunct, "...") {
+		return p.field()
+	}
+
+	loc := p.tok.loc
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName && p.tok.value != "on" {
+		spread := &fragmentSpread{loc: loc}
+		var err error
+		if spread.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if spread.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		return spread, nil
+	}
+
+	inline := &inlineFragment{loc: loc}
+	var err error
+	if p.peek(tokenName, "on") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if inline.typeCondition, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if inline.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if inline.selectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return inline, nil
+}
+
+func (p *parser) field() (*field, error) {
+	f := &field{loc: p.tok.loc}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.alias = name
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	f.name = name
+
+	if f.arguments, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokenPunct, "{") {
+		if f.selectionSet, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments(constant bool) ([]*argument, error) {
+	if ok, err := p.skip("("); !ok || err != nil {
+		return nil, err
+	}
+	var args []*argument
+	for {
+		arg := &argument{loc: p.tok.loc}
+		var err error
+		if arg.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		for _, other := range args {
+			if other.name == arg.name {
+				return nil, newError(arg.loc, "There can be only one argument named %q.", arg.name)
+			}
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if arg.value, err = p.value(constant); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if ok, err := p.skip(")"); err != nil || ok {
+			return args, err
+		}
+	}
+}
+
+func (p *parser) directives() ([]*directive, error) {
+	var dirs []*directive
+	for p.peek(tokenPunct, "@") {
+		d := &directive{loc: p.tok.loc}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var err error
+		if d.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if d.arguments, err = p.arguments(false); err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, nil
+}
+
+// value parses a literal. Variables aren't allowed in constant values such
+// as variable defaults.
+func (p *parser) value(constant bool) (value, error) {
+	tok := p.tok
+	var v value
+	switch tok.kind {
+	case tokenInt:
+		v = &intValue{loc: tok.loc, raw: tok.value}
+	case tokenFloat:
+		v = &floatValue{loc: tok.loc, raw: tok.value}
+	case tokenString:
+		v = &stringValue{loc: tok.loc, s: tok.value}
+	case tokenName:
+		switch tok.value {
+		case "true", "false":
+			v = &booleanValue{loc: tok.loc, b: tok.value == "true"}
+		case "null":
+			v = &nullValue{loc: tok.loc}
+		default:
+			v = &enumValue{loc: tok.loc, name: tok.value}
+		}
+	case tokenPunct:
+		switch tok.value {
+		case "$":
+			if constant {
+				return nil, syntaxError(tok.loc, "variables aren't allowed here")
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return &variableValue{loc: tok.loc, name: name}, nil
+		case "[":
+			return p.listValue(constant)
+		case "{":
+			return p.objectValue(constant)
+		}
+	}
+	if v == nil {
+		return nil, syntaxError(tok.loc, "expected a value, found %s", tok)
+	}
+	return v, p.advance()
+}
+
+func (p *parser) listValue(constant bool) (value, error) {
+	list := &listValue{loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for {
+		if ok, err := p.skip("]"); err != nil || ok {
+			return list, err
+		}
+		v, err := p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		list.values = append(list.values, v)
+	}
+}
+
+func (p *parser) objectValue(constant bool) (value, error) {
+	obj := &objectValue{loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for {
+		if ok, err := p.skip("}"); err != nil || ok {
+			return obj, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		obj.fields = append(obj.fields, &objectField{name: name, value: v})
+	}
+}
+
+func syntaxError(loc Location, format string, args ...any) *Error {
+	return newError(loc, "Syntax error: "+fmt.Sprintf(format, args...)+".")
+}
@@ -0,0 +1,428 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is a GraphQL type: a *Scalar, *Object, *List or *NonNull.
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type.
+type Scalar struct {
+	Name        string
+	Description string
+	// Serialize turns a resolved value into its JSON form.
+	Serialize func(v any) (any, error)
+	// ParseValue coerces an input value. Literals in the query arrive in
+	// the form a JSON decoder would produce, except that numbers are
+	// json.Numbers.
+	ParseValue func(v any) (any, error)
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+// Object is an output type with fields.
+type Object struct {
+	Name        string
+	Description string
+	// Fields is a function so that types can refer to each other.
+	Fields func() []*Field
+
+	fields []*Field
+	byName map[string]*Field
+}
+
+func (o *Object) String() string { return o.Name }
+
+func (o *Object) field(name string) *Field {
+	return o.byName[name]
+}
+
+// Field is a field of an Object.
+type Field struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*Argument
+	// Resolve returns the field's value for p.Source. It may return a
+	// Thunk to defer the work, so that a Loader can batch it with the
+	// same field of sibling objects.
+	Resolve func(p ResolveParams) (any, error)
+	// Multiplier returns how many times the field's selections will be
+	// resolved, such as a connection's page size, for the complexity
+	// limit. It defaults to 1.
+	Multiplier func(args map[string]any) int
+}
+
+// Argument is an argument of a Field. A nil Default means the argument
+// has none.
+type Argument struct {
+	Name        string
+	Description string
+	Type        Type
+	Default     any
+}
+
+// List is a list of OfType.
+type List struct{ OfType Type }
+
+func (l *List) String() string { return "[" + l.OfType.String() + "]" }
+
+// NonNull is OfType without null.
+type NonNull struct{ OfType Type }
+
+func (n *NonNull) String() string { return n.OfType.String() + "!" }
+
+// NewList -
+func NewList(t Type) *List { return &List{OfType: t} }
+
+// NewNonNull -
+func NewNonNull(t Type) *NonNull { return &NonNull{OfType: t} }
+
+// ResolveParams are the inputs to a resolver.
+type ResolveParams struct {
+	Context context.Context
+	// Source is the value resolved for the parent field, or nil for the
+	// root fields.
+	Source any
+	// Args are the coerced arguments, with defaults applied. Arguments
+	// that were omitted and have no default are absent.
+	Args map[string]any
+}
+
+// Thunk is a deferred field value.
+type Thunk func() (any, error)
+
+// Schema is the set of types reachable from the root operation types.
+type Schema struct {
+	Query    *Object
+	Mutation *Object
+	types    map[string]Type
+}
+
+// NewSchema checks the types reachable from query and mutation, which
+// may be nil, and builds their field tables.
+func NewSchema(query, mutation *Object) (*Schema, error) {
+	s := &Schema{Query: query, Mutation: mutation, types: map[string]Type{}}
+	if query == nil {
+		return nil, errors.New("graphql: schema has no query type")
+	}
+	for _, scalar := range []*Scalar{Int, Float, String, Boolean, ID} {
+		s.types[scalar.Name] = scalar
+	}
+	var errs []error
+	var visit func(t Type)
+	visit = func(t Type) {
+		switch t := t.(type) {
+		case *List:
+			visit(t.OfType)
+		case *NonNull:
+			if _, ok := t.OfType.(*NonNull); ok {
+				errs = append(errs, fmt.Errorf("graphql: %s is doubly non-null", t))
+			}
+			visit(t.OfType)
+		case *Scalar:
+			if other, ok := s.types[t.Name]; ok && other != t {
+				errs = append(errs, fmt.Errorf("graphql: two types are named %s", t.Name))
+			}
+			s.types[t.Name] = t
+		case *Object:
+			if other, ok := s.types[t.Name]; ok {
+				if other != t {
+					errs = append(errs, fmt.Errorf("graphql: two types are named %s", t.Name))
+				}
+				return
+			}
+			s.types[t.Name] = t
+			t.fields = t.Fields()
+			t.byName = make(map[string]*Field, len(t.fields))
+			for _, f := range t.fields {
+				if _, ok := t.byName[f.Name]; ok || strings.HasPrefix(f.Name, "__") {
+					errs = append(errs, fmt.Errorf("graphql: %s has a duplicate or reserved field %s", t.Name, f.Name))
+				}
+				t.byName[f.Name] = f
+				for _, arg := range f.Args {
+					if !isInputType(arg.Type) {
+						errs = append(errs, fmt.Errorf("graphql: argument %s.%s(%s) is not an input type", t.Name, f.Name, arg.Name))
+					}
+					visit(arg.Type)
+				}
+				visit(f.Type)
+			}
+		}
+	}
+	visit(query)
+	if mutation != nil {
+		visit(mutation)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return s, nil
+}
+
+func isInputType(t Type) bool {
+	switch t := t.(type) {
+	case *List:
+		return isInputType(t.OfType)
+	case *NonNull:
+		return isInputType(t.OfType)
+	case *Scalar:
+		return true
+	}
+	return false
+}
+
+// namedType strips List and NonNull from t.
+func namedType(t Type) Type {
+	for {
+		switch u := t.(type) {
+		case *List:
+			t = u.OfType
+		case *NonNull:
+			t = u.OfType
+		default:
+			return t
+		}
+	}
+}
+
+// SDL prints the schema in the schema definition language, for clients
+// that generate code from it.
+func (s *Schema) SDL() string {
+	var b strings.Builder
+	names := make([]string, 0, len(s.types))
+	for name := range s.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b.WriteString("schema {\n  query: " + s.Query.Name + "\n")
+	if s.Mutation != nil {
+		b.WriteString("  mutation: " + s.Mutation.Name + "\n")
+	}
+	b.WriteString("}\n")
+
+	for _, name := range names {
+		switch t := s.types[name].(type) {
+		case *Scalar:
+			if builtinScalar(t) {
+				continue
+			}
+			b.WriteString("\n")
+			writeDescription(&b, "", t.Description)
+			b.WriteString("scalar " + t.Name + "\n")
+		case *Object:
+			b.WriteString("\n")
+			writeDescription(&b, "", t.Description)
+			b.WriteString("type " + t.Name + " {\n")
+			for _, f := range t.fields {
+				writeDescription(&b, "  ", f.Description)
+				b.WriteString("  " + f.Name)
+				if len(f.Args) > 0 {
+					b.WriteString("(")
+					for i, arg := range f.Args {
+						if i > 0 {
+							b.WriteString(", ")
+						}
+						b.WriteString(arg.Name + ": " + arg.Type.String())
+						if arg.Default != nil {
+							def, _ := json.Marshal(arg.Default)
+							b.WriteString(" = " + string(def))
+						}
+					}
+					b.WriteString(")")
+				}
+				b.WriteString(": " + f.Type.String() + "\n")
+			}
+			b.WriteString("}\n")
+		}
+	}
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, indent, desc string) {
+	if desc == "" {
+		return
+	}
+	b.WriteString(indent + `"""` + strings.ReplaceAll(desc, `"""`, `\"""`) + `"""` + "\n")
+}
+
+func builtinScalar(s *Scalar) bool {
+	return s == Int || s == Float || s == String || s == Boolean || s == ID
+}
+
+// The built-in scalars, and DateTime for timestamps.
+var (
+	Int = &Scalar{
+		Name:      "Int",
+		Serialize: serializeInt,
+		ParseValue: func(v any) (any, error) {
+			var f float64
+			switch v := v.(type) {
+			case json.Number:
+				n, err := strconv.ParseInt(string(v), 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("Int cannot represent %s", v)
+				}
+				return int(n), nil
+			case float64:
+				f = v
+			case int:
+				f = float64(v)
+			default:
+				return nil, fmt.Errorf("Int cannot represent %s", describe(v))
+			}
+			if f != math.Trunc(f) || f < math.MinInt32 || f > math.MaxInt32 {
+				return nil, fmt.Errorf("Int cannot represent %v", f)
+			}
+			return int(f), nil
+		},
+	}
+	Float = &Scalar{
+		Name: "Float",
+		Serialize: func(v any) (any, error) {
+			switch v := v.(type) {
+			case float64:
+				return v, nil
+			case float32:
+				return float64(v), nil
+			}
+			return serializeInt(v)
+		},
+		ParseValue: func(v any) (any, error) {
+			switch v := v.(type) {
+			case json.Number:
+				return v.Float64()
+			case float64:
+				return v, nil
+			case int:
+				return float64(v), nil
+			}
+			return nil, fmt.Errorf("Float cannot represent %s", describe(v))
+		},
+	}
+	String = &Scalar{
+		Name: "String",
+		Serialize: func(v any) (any, error) {
+			switch v := v.(type) {
+			case string:
+				return v, nil
+			case fmt.Stringer:
+				return v.String(), nil
+			}
+			return nil, fmt.Errorf("String cannot represent %s", describe(v))
+		},
+		ParseValue: func(v any) (any, error) {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+			return nil, fmt.Errorf("String cannot represent %s", describe(v))
+		},
+	}
+	Boolean = &Scalar{
+		Name: "Boolean",
+		Serialize: func(v any) (any, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %s", describe(v))
+		},
+		ParseValue: func(v any) (any, error) {
+			if b, ok := v.(bool); ok {
+				return b, nil
+			}
+			return nil, fmt.Errorf("Boolean cannot represent %s", describe(v))
+		},
+	}
+	ID = &Scalar{
+		Name: "ID",
+		Serialize: func(v any) (any, error) {
+			switch v := v.(type) {
+			case string:
+				return v, nil
+			case fmt.Stringer:
+				return v.String(), nil
+			}
+			n, err := serializeInt(v)
+			if err != nil {
+				return nil, fmt.Errorf("ID cannot represent %s", describe(v))
+			}
+			return fmt.Sprint(n), nil
+		},
+		ParseValue: func(v any) (any, error) {
+			switch v := v.(type) {
+			case string:
+				return v, nil
+			case json.Number:
+				if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+					return string(v), nil
+				}
+			case float64:
+				if v == math.Trunc(v) {
+					return strconv.FormatFloat(v, 'f', -1, 64), nil
+				}
+			}
+			return nil, fmt.Errorf("ID cannot represent %s", describe(v))
+		},
+	}
+	DateTime = &Scalar{
+		Name:        "DateTime",
+		Description: "An RFC 3339 timestamp.",
+		Serialize: func(v any) (any, error) {
+			if t, ok := v.(time.Time); ok {
+				return t.Format(time.RFC3339Nano), nil
+			}
+			return nil, fmt.Errorf("DateTime cannot represent %s", describe(v))
+		},
+		ParseValue: func(v any) (any, error) {
+			if s, ok := v.(string); ok {
+				if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					return t, nil
+				}
+			}
+			return nil, fmt.Errorf("DateTime cannot represent %s", describe(v))
+		},
+	}
+)
+
+func serializeInt(v any) (any, error) {
+	switch v := v.(type) {
+	case int:
+		return v, nil
+	case int32:
+		return int(v), nil
+	case int64:
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int(v), nil
+		}
+	}
+	return nil, fmt.Errorf("Int cannot represent %s", describe(v))
+}
+
+// describe names an input value for error messages.
+func describe(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case json.Number:
+		return string(v)
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "a list"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,508 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// validate picks the operation to run, checks it against the schema and
+// coerces the request's variables. It checks the rules that matter for
+// safe execution; in particular, fields with the same response key are
+// merged without checking that their arguments agree.
+func validate(s *Schema, doc *document, req Request) (*operation, map[string]any, []*Error) {
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return nil, nil, []*Error{err}
+	}
+
+	var root *Object
+	switch op.kind {
+	case "query":
+		root = s.Query
+	case "mutation":
+		if req.ReadOnly {
+			return nil, nil, []*Error{newError(op.loc, "Mutations can't be sent with GET.")}
+		}
+		root = s.Mutation
+	}
+	if root == nil {
+		return nil, nil, []*Error{newError(op.loc, "The schema doesn't support %s operations.", op.kind)}
+	}
+
+	v := &validator{
+		schema:    s,
+		doc:       doc,
+		varDefs:   map[string]*variableDefinition{},
+		varTypes:  map[string]Type{},
+		usedVars:  map[string]bool{},
+		fragments: map[string]bool{},
+	}
+	for _, def := range op.variables {
+		if _, ok := v.varDefs[def.name]; ok {
+			v.errorf(def.loc, "There can be only one variable named \"$%s\".", def.name)
+			continue
+		}
+		v.varDefs[def.name] = def
+		t, ok := v.inputType(def.typ)
+		if !ok {
+			v.errorf(def.loc, "Variable \"$%s\" cannot be of non-input type %q.", def.name, def.typ)
+			continue
+		}
+		v.varTypes[def.name] = t
+		if def.defValue != nil {
+			if _, err := coerceLiteral(t, def.defValue, nil); err != nil {
+				v.errorf(def.defValue.location(), "Variable \"$%s\" has an invalid default value: %s.", def.name, err)
+			}
+		}
+	}
+	v.directives(op.directives)
+	v.selectionSet(root, op.selectionSet)
+	for _, def := range op.variables {
+		if !v.usedVars[def.name] {
+			v.errorf(def.loc, "Variable \"$%s\" is never used.", def.name)
+		}
+	}
+	if len(v.errs) > 0 {
+		return nil, nil, v.errs
+	}
+
+	vars, errs := coerceVariables(op, v.varTypes, req.Variables)
+	return op, vars, errs
+}
+
+func selectOperation(doc *document, name string) (*operation, *Error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, &Error{Message: "The query has more than one operation, so operationName is required."}
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+}
+
+type validator struct {
+	schema   *Schema
+	doc      *document
+	varDefs  map[string]*variableDefinition
+	varTypes map[string]Type
+	usedVars map[string]bool
+	// fragments holds the fragments being or already validated, which is
+	// false while the fragment is on the stack, to catch cycles.
+	fragments map[string]bool
+	errs      []*Error
+}
+
+func (v *validator) errorf(loc Location, format string, args ...any) {
+	v.errs = append(v.errs, newError(loc, format, args...))
+}
+
+func (v *validator) inputType(ref *typeRef) (Type, bool) {
+	var t Type
+	if ref.elem != nil {
+		elem, ok := v.inputType(ref.elem)
+		if !ok {
+			return nil, false
+		}
+		t = NewList(elem)
+	} else {
+		named, ok := v.schema.types[ref.name].(*Scalar)
+		if !ok {
+			return nil, false
+		}
+		t = named
+	}
+	if ref.nonNull {
+		t = NewNonNull(t)
+	}
+	return t, true
+}
+
+func (v *validator) selectionSet(obj *Object, set []selection) {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *field:
+			v.field(obj, sel)
+		case *inlineFragment:
+			v.directives(sel.directives)
+			if sel.typeCondition != "" && !v.typeCondition(obj, sel.typeCondition, sel.loc) {
+				continue
+			}
+			v.selectionSet(obj, sel.selectionSet)
+		case *fragmentSpread:
+			v.directives(sel.directives)
+			frag, ok := v.doc.fragments[sel.name]
+			if !ok {
+				v.errorf(sel.loc, "Unknown fragment %q.", sel.name)
+				continue
+			}
+			if !v.typeCondition(obj, frag.typeCondition, sel.loc) {
+				continue
+			}
+			done, seen := v.fragments[frag.name]
+			if seen {
+				if !done {
+					v.errorf(sel.loc, "Cannot spread fragment %q within itself.", frag.name)
+				}
+				continue
+			}
+			v.fragments[frag.name] = false
+			v.directives(frag.directives)
+			v.selectionSet(obj, frag.selectionSet)
+			v.fragments[frag.name] = true
+		}
+	}
+}
+
+// typeCondition checks that a fragment on cond can apply to obj. Every
+// type is an object type, so cond must be obj itself.
+func (v *validator) typeCondition(obj *Object, cond string, loc Location) bool {
+	t, ok := v.schema.types[cond]
+	if !ok {
+		v.errorf(loc, "Unknown type %q.", cond)
+		return false
+	}
+	if t != obj {
+		v.errorf(loc, "Fragment on %q can never be spread within %q.", cond, obj.Name)
+		return false
+	}
+	return true
+}
+
+func (v *validator) field(obj *Object, f *field) {
+	v.directives(f.directives)
+	if f.name == "__typename" {
+		if len(f.arguments) > 0 || f.selectionSet != nil {
+			v.errorf(f.loc, "Field \"__typename\" takes no arguments or selections.")
+		}
+		return
+	}
+	def := obj.field(f.name)
+	if def == nil {
+		v.errorf(f.loc, "Cannot query field %q on type %q.", f.name, obj.Name)
+		return
+	}
+	v.arguments(def.Args, f.arguments, f.loc, fmt.Sprintf("%s.%s", obj.Name, f.name))
+
+	switch t := namedType(def.Type).(type) {
+	case *Scalar:
+		if f.selectionSet != nil {
+			v.errorf(f.loc, "Field %q must not have a selection since type %q has no subfields.", f.name, def.Type)
+		}
+	case *Object:
+		if f.selectionSet == nil {
+			v.errorf(f.loc, "Field %q of type %q must have a selection of subfields.", f.name, def.Type)
+			return
+		}
+		v.selectionSet(t, f.selectionSet)
+	}
+}
+
+func (v *validator) directives(dirs []*directive) {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		v.arguments(directiveArgs, d.arguments, d.loc, "@"+d.name)
+	}
+}
+
+var directiveArgs = []*Argument{{Name: "if", Type: NewNonNull(Boolean)}}
+
+func (v *validator) arguments(defs []*Argument, args []*argument, loc Location, owner string) {
+	for _, arg := range args {
+		var def *Argument
+		for _, d := range defs {
+			if d.Name == arg.name {
+				def = d
+			}
+		}
+		if def == nil {
+			v.errorf(arg.loc, "Unknown argument %q on %q.", arg.name, owner)
+			continue
+		}
+		v.value(def, arg.value)
+	}
+	for _, def := range defs {
+		if _, ok := def.Type.(*NonNull); !ok || def.Default != nil {
+			continue
+		}
+		provided := false
+		for _, arg := range args {
+			if arg.name == def.Name {
+				provided = true
+			}
+		}
+		if !provided {
+			v.errorf(loc, "Argument %q of type %q is required on %q but not provided.", def.Name, def.Type, owner)
+		}
+	}
+}
+
+// value checks a literal for the argument def, and that any variables in
+// it are defined with a type that fits where they are used.
+func (v *validator) value(def *Argument, val value) {
+	if !v.variables(val, def.Type, def.Default != nil) {
+		return
+	}
+	if _, err := coerceLiteral(def.Type, val, nil); err != nil {
+		if _, isVar := err.(errVariable); !isVar {
+			v.errorf(val.location(), "Argument %q has an invalid value: %s.", def.Name, err)
+		}
+	}
+}
+
+func (v *validator) variables(val value, locType Type, locDefault bool) bool {
+	switch val := val.(type) {
+	case *variableValue:
+		v.usedVars[val.name] = true
+		def, ok := v.varDefs[val.name]
+		if !ok {
+			v.errorf(val.loc, "Variable \"$%s\" is not defined.", val.name)
+			return false
+		}
+		varType, ok := v.varTypes[val.name]
+		if !ok {
+			return false // already reported
+		}
+		if !variableAllowed(varType, def.defValue, locType, locDefault) {
+			v.errorf(val.loc, "Variable \"$%s\" of type %q used in position expecting type %q.", val.name, varType, locType)
+			return false
+		}
+	case *listValue:
+		elem := locType
+		if nn, ok := elem.(*NonNull); ok {
+			elem = nn.OfType
+		}
+		if l, ok := elem.(*List); ok {
+			elem = l.OfType
+		}
+		ok := true
+		for _, item := range val.values {
+			ok = v.variables(item, elem, false) && ok
+		}
+		return ok
+	}
+	return true
+}
+
+// variableAllowed reports whether a variable of varType can be used where
+// locType is expected. A nullable variable may fill a non-null position
+// if either side has a default.
+func variableAllowed(varType Type, varDefault value, locType Type, locDefault bool) bool {
+	if nn, ok := locType.(*NonNull); ok {
+		if _, varNonNull := varType.(*NonNull); !varNonNull {
+			_, nullDefault := varDefault.(*nullValue)
+			if (varDefault == nil || nullDefault) && !locDefault {
+				return false
+			}
+			return typeFits(varType, nn.OfType)
+		}
+	}
+	return typeFits(varType, locType)
+}
+
+func typeFits(varType, locType Type) bool {
+	if nn, ok := locType.(*NonNull); ok {
+		varNN, ok := varType.(*NonNull)
+		return ok && typeFits(varNN.OfType, nn.OfType)
+	}
+	if nn, ok := varType.(*NonNull); ok {
+		return typeFits(nn.OfType, locType)
+	}
+	if l, ok := locType.(*List); ok {
+		varList, ok := varType.(*List)
+		return ok && typeFits(varList.OfType, l.OfType)
+	}
+	if _, ok := varType.(*List); ok {
+		return false
+	}
+	return varType == locType
+}
+
+// coerceVariables applies defaults to the request's variables and coerces
+// them to their declared types.
+func coerceVariables(op *operation, types map[string]Type, input map[string]any) (map[string]any, []*Error) {
+	vars := map[string]any{}
+	var errs []*Error
+	for _, def := range op.variables {
+		t := types[def.name]
+		raw, ok := input[def.name]
+		if !ok {
+			if def.defValue != nil {
+				vars[def.name], _ = coerceLiteral(t, def.defValue, nil)
+			} else if _, nonNull := t.(*NonNull); nonNull {
+				errs = append(errs, newError(def.loc, "Variable \"$%s\" of required type %q was not provided.", def.name, t))
+			}
+			continue
+		}
+		val, err := coerceInput(t, raw)
+		if err != nil {
+			errs = append(errs, newError(def.loc, "Variable \"$%s\" got invalid value %s; %s.", def.name, describeJSON(raw), err))
+			continue
+		}
+		vars[def.name] = val
+	}
+	return vars, errs
+}
+
+func describeJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return describe(v)
+	}
+	return string(b)
+}
+
+// coerceInput coerces a variable's JSON value to t.
+func coerceInput(t Type, v any) (any, error) {
+	if nn, ok := t.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("expected non-null %s", t)
+		}
+		return coerceInput(nn.OfType, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *List:
+		items, ok := v.([]any)
+		if !ok {
+			item, err := coerceInput(t.OfType, v)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		out := make([]any, len(items))
+		for i, item := range items {
+			var err error
+			if out[i], err = coerceInput(t.OfType, item); err != nil {
+				return nil, fmt.Errorf("at index %d: %w", i, err)
+			}
+		}
+		return out, nil
+	case *Scalar:
+		return t.ParseValue(v)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+// errVariable is returned by coerceLiteral for a variable that has no
+// value, which during validation just means it isn't known yet.
+type errVariable string
+
+func (e errVariable) Error() string { return "variable $" + string(e) + " has no value" }
+
+// coerceLiteral coerces a literal in the query to t, taking variables
+// from vars.
+func coerceLiteral(t Type, val value, vars map[string]any) (any, error) {
+	if vv, ok := val.(*variableValue); ok {
+		v, ok := vars[vv.name]
+		if !ok {
+			return nil, errVariable(vv.name)
+		}
+		if _, nonNull := t.(*NonNull); nonNull && v == nil {
+			return nil, fmt.Errorf("expected non-null %s, found null", t)
+		}
+		return v, nil
+	}
+
+	if nn, ok := t.(*NonNull); ok {
+		if _, isNull := val.(*nullValue); isNull {
+			return nil, fmt.Errorf("expected non-null %s, found null", t)
+		}
+		return coerceLiteral(nn.OfType, val, vars)
+	}
+	if _, isNull := val.(*nullValue); isNull {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		lv, ok := val.(*listValue)
+		if !ok {
+			item, err := coerceLiteral(t.OfType, val, vars)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		out := make([]any, len(lv.values))
+		for i, item := range lv.values {
+			var err error
+			if out[i], err = coerceLiteral(t.OfType, item, vars); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case *Scalar:
+		var raw any
+		switch val := val.(type) {
+		case *intValue:
+			raw = json.Number(val.raw)
+		case *floatValue:
+			raw = json.Number(val.raw)
+			if t == Int || t == ID {
+				return nil, fmt.Errorf("%s cannot represent non-integer value %s", t.Name, val.raw)
+			}
+		case *stringValue:
+			raw = val.s
+		case *booleanValue:
+			raw = val.b
+		default:
+			return nil, fmt.Errorf("%s cannot represent %s", t.Name, literalKind(val))
+		}
+		return t.ParseValue(raw)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+func literalKind(val value) string {
+	switch val := val.(type) {
+	case *enumValue:
+		return "enum value " + val.name
+	case *listValue:
+		return "a list"
+	case *objectValue:
+		return "an object"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", val), "*graphql.")
+}
+
+// coerceArgs coerces a field's arguments, applying defaults. Arguments
+// without a value or default are left out.
+func coerceArgs(defs []*Argument, args []*argument, vars map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(defs))
+	for _, def := range defs {
+		var lit value
+		for _, arg := range args {
+			if arg.name == def.Name {
+				lit = arg.value
+			}
+		}
+		if lit != nil {
+			v, err := coerceLiteral(def.Type, lit, vars)
+			if err == nil {
+				out[def.Name] = v
+				continue
+			}
+			if _, missing := err.(errVariable); !missing {
+				return nil, newError(lit.location(), "Argument %q has an invalid value: %s.", def.Name, err)
+			}
+		}
+		if def.Default != nil {
+			out[def.Name] = def.Default
+		} else if _, nonNull := def.Type.(*NonNull); nonNull {
+			return nil, fmt.Errorf("Argument %q of required type %q was not provided.", def.Name, def.Type)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,164 @@
+// Package logging sets up structured JSON logging. Loggers built with New
+// add the request ID, route, user ID, trace ID and latency of the request
+// in the context to every line, and redact secrets: the values of
+// sensitive keys such as password, and tokens or credentials found inside
+// any other string or error.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/chirpy/internal/tracing"
+	"github.com/google/uuid"
+)
+
+// Redacted replaces the value of sensitive attributes.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never logged. Keys are
+// compared case-insensitively.
+var sensitiveKeys = map[string]bool{
+	"password":         true,
+	"current_password": true,
+	"token":            true,
+	"access_token":     true,
+	"refresh_token":    true,
+	"authorization":    true,
+	"secret":           true,
+	"jwt_secret":       true,
+	"api_key":          true,
+	"polka_key":        true,
+}
+
+// New returns a JSON logger writing to w at level and above.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	})
+	return slog.New(contextHandler{h})
+}
+
+// ParseLevel parses debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+// secretPatterns match secrets embedded in free-form values, such as an
+// error that quotes a URL or a header. The first group, if any, is kept.
+var secretPatterns = []*regexp.Regexp{
+	// Authorization header values
+	regexp.MustCompile(`(?i)(bearer\s+|basic\s+|apikey\s+)[A-Za-z0-9\-._~+/]+=*`),
+	// JWTs anywhere
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	// Query parameters and key=value or "key": "value" pairs
+	regexp.MustCompile(`(?i)((?:password|passwd|token|secret|api_key|apikey|key|signature)"?\s*[=:]\s*"?)[^&\s",}]+`),
+	// Credentials in URLs
+	regexp.MustCompile(`(://)[^/@\s:]+:[^/@\s]+@`),
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, RedactString(err.Error()))
+		}
+	}
+	return a
+}
+
+// RedactString replaces any secrets found in s with Redacted.
+func RedactString(s string) string {
+	for _, p := range secretPatterns {
+		if p.NumSubexp() > 0 {
+			s = p.ReplaceAllString(s, "${1}"+Redacted)
+		} else {
+			s = p.ReplaceAllString(s, Redacted)
+		}
+	}
+	return s
+}
+
+// Request holds the request-scoped fields added to log lines.
+type Request struct {
+	ID    string
+	Route string
+	Start time.Time
+
+	mu     sync.Mutex
+	userID uuid.UUID
+}
+
+// SetUserID records the authenticated user once it is known.
+func (r *Request) SetUserID(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.userID = id
+}
+
+// UserID returns the authenticated user, or uuid.Nil.
+func (r *Request) UserID() uuid.UUID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.userID
+}
+
+type requestKey struct{}
+
+// WithRequest returns a copy of ctx carrying req.
+func WithRequest(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// FromContext returns the request in ctx, or nil.
+func FromContext(ctx context.Context) *Request {
+	req, _ := ctx.Value(requestKey{}).(*Request)
+	return req
+}
+
+// contextHandler adds the fields of the request in the context to each
+// record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if req := FromContext(ctx); req != nil {
+		r.AddAttrs(
+			slog.String("request_id", req.ID),
+			slog.String("route", req.Route),
+		)
+		if id := req.UserID(); id != uuid.Nil {
+			r.AddAttrs(slog.String("user_id", id.String()))
+		}
+		if span := tracing.SpanFromContext(ctx); span != nil {
+			r.AddAttrs(slog.String("trace_id", span.Context.TraceID.String()))
+		}
+		r.AddAttrs(slog.Float64("latency_ms", float64(time.Since(req.Start).Microseconds())/1000))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
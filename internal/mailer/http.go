@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPMailer sends messages by POSTing them as JSON to a mail provider's
+// HTTP API:
+//
+//	{"from": "...", "to": "...", "subject": "...", "text": "..."}
+//
+// with the token, if set, as a bearer token. Any 2xx response counts as
+// sent.
+type HTTPMailer struct {
+	URL   string
+	Token string
+	From  string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type httpMessage struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+}
+
+// Send -
+func (m HTTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := checkHeaders(m.From, msg.To, msg.Subject); err != nil {
+		return err
+	}
+	body, err := json.Marshal(httpMessage{From: m.From, To: msg.To, Subject: msg.Subject, Text: msg.Body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.Token)
+	}
+
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("mail API returned %s", resp.Status)
+	}
+	return nil
+}
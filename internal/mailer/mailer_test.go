@@ -0,0 +1,159 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var msg = Message{
+	To:      "walt@example.com",
+	Subject: "Confirm your email",
+	Body:    "Open https://chirpy.test/confirm?token=secret\n",
+}
+
+func TestLogMailerOmitsBody(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(prev)
+
+	if err := (LogMailer{}).Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), msg.To) {
+		t.Errorf("log %q doesn't name the recipient", buf.String())
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("log %q contains the body", buf.String())
+	}
+}
+
+func TestHTTPMailer(t *testing.T) {
+	var got httpMessage
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	m := HTTPMailer{URL: srv.URL, Token: "key", From: "chirpy@example.com"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	want := httpMessage{From: "chirpy@example.com", To: msg.To, Subject: msg.Subject, Text: msg.Body}
+	if got != want {
+		t.Errorf("sent %+v, want %+v", got, want)
+	}
+	if auth != "Bearer key" {
+		t.Errorf("Authorization = %q", auth)
+	}
+}
+
+func TestHTTPMailerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer srv.Close()
+
+	m := HTTPMailer{URL: srv.URL, From: "chirpy@example.com"}
+	if err := m.Send(context.Background(), msg); err == nil {
+		t.Fatal("Send succeeded on a 422")
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	bad := msg
+	bad.Subject = "Hi\r\nBcc: everyone@example.com"
+	mailers := []Mailer{
+		HTTPMailer{URL: "http://127.0.0.1:0", From: "chirpy@example.com"},
+		SMTPMailer{Addr: "127.0.0.1:0", From: "chirpy@example.com"},
+	}
+	for _, m := range mailers {
+		if err := m.Send(context.Background(), bad); err != errHeaderInjection {
+			t.Errorf("%T: got %v, want errHeaderInjection", m, err)
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	transcript := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		transcript <- fakeSMTP(conn)
+	}()
+
+	m := SMTPMailer{Addr: ln.Addr().String(), From: "chirpy@example.com"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	lines := <-transcript
+	got := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<chirpy@example.com>",
+		"RCPT TO:<walt@example.com>",
+		"Subject: Confirm your email",
+		"Open https://chirpy.test/confirm?token=secret",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("transcript missing %q:\n%s", want, got)
+		}
+	}
+}
+
+// fakeSMTP accepts one message without any extensions and returns the
+// lines the client sent.
+func fakeSMTP(conn net.Conn) []string {
+	r := bufio.NewReader(conn)
+	reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+	var lines []string
+	reply("220 localhost ready")
+	inData := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return lines
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		switch {
+		case inData:
+			if line == "." {
+				inData = false
+				reply("250 queued")
+			}
+		case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+			reply("250 localhost")
+		case line == "DATA":
+			inData = true
+			reply("354 go ahead")
+		case line == "QUIT":
+			reply("221 bye")
+			return lines
+		default:
+			reply("250 ok")
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Package metrics is a small metrics registry that renders the Prometheus
+// text exposition format. It supports counters, histograms and gauges
+// computed on scrape, each optionally split by labels.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds suited to HTTP handlers.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metrics in registration order.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// NewRegistry -
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes every metric in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Reset sets every counter and histogram back to zero. Gauges and
+// counters computed on scrape are unaffected.
+func (r *Registry) Reset() {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		if m, ok := m.(interface{ Reset() }); ok {
+			m.Reset()
+		}
+	}
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// series is the shared bookkeeping for labelled metrics.
+type series[T any] struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu       sync.Mutex
+	children map[string]*child[T]
+	newChild func() *T
+}
+
+type child[T any] struct {
+	values []string
+	value  *T
+}
+
+func (s *series[T]) with(values []string) *T {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", s.name, len(s.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.children[key]
+	if !ok {
+		c = &child[T]{values: append([]string(nil), values...), value: s.newChild()}
+		s.children[key] = c
+	}
+	return c.value
+}
+
+// sorted returns the children ordered by label values so output is stable.
+func (s *series[T]) sorted() []*child[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*child[T], 0, len(s.children))
+	for _, c := range s.children {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.Join(out[i].values, "\xff") < strings.Join(out[j].values, "\xff")
+	})
+	return out
+}
+
+func (s *series[T]) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", s.name, escapeHelp(s.help), s.name, s.typ)
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	mu sync.Mutex
+	v  float64
+}
+
+// Inc adds one.
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add adds v, which must not be negative.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.mu.Lock()
+	c.v += v
+	c.mu.Unlock()
+}
+
+// Value returns the current count.
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.v
+}
+
+// Reset sets the counter back to zero.
+func (c *Counter) Reset() {
+	c.mu.Lock()
+	c.v = 0
+	c.mu.Unlock()
+}
+
+// CounterVec is a counter split by labels.
+type CounterVec struct {
+	s *series[Counter]
+}
+
+// NewCounterVec registers a counter with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{s: &series[Counter]{
+		name: name, help: help, typ: "counter", labels: labels,
+		children: map[string]*child[Counter]{},
+		newChild: func() *Counter { return &Counter{} },
+	}}
+	r.register(name, v)
+	return v
+}
+
+// NewCounter registers a counter without labels.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+// With returns the counter for the label values, creating it if needed.
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.s.with(values)
+}
+
+// Total sums the counter across all label values.
+func (v *CounterVec) Total() float64 {
+	var total float64
+	for _, c := range v.s.sorted() {
+		total += c.value.Value()
+	}
+	return total
+}
+
+// Reset sets every counter back to zero.
+func (v *CounterVec) Reset() {
+	for _, c := range v.s.sorted() {
+		c.value.Reset()
+	}
+}
+
+func (v *CounterVec) write(w io.Writer) {
+	v.s.header(w)
+	for _, c := range v.s.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", v.s.name, labelString(v.s.labels, c.values), formatFloat(c.value.Value()))
+	}
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Reset discards every observation.
+func (h *Histogram) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.counts)
+	h.sum = 0
+	h.count = 0
+}
+
+// HistogramVec is a histogram split by labels.
+type HistogramVec struct {
+	s *series[Histogram]
+}
+
+// NewHistogramVec registers a histogram with the given upper bucket
+// bounds, which must be sorted, and label names.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &HistogramVec{s: &series[Histogram]{
+		name: name, help: help, typ: "histogram", labels: labels,
+		children: map[string]*child[Histogram]{},
+		newChild: func() *Histogram {
+			return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		},
+	}}
+	r.register(name, v)
+	return v
+}
+
+// With returns the histogram for the label values, creating it if needed.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.s.with(values)
+}
+
+// Reset discards every observation.
+func (v *HistogramVec) Reset() {
+	for _, c := range v.s.sorted() {
+		c.value.Reset()
+	}
+}
+
+func (v *HistogramVec) write(w io.Writer) {
+	v.s.header(w)
+	labels := append(append([]string(nil), v.s.labels...), "le")
+	for _, c := range v.s.sorted() {
+		h := c.value
+		h.mu.Lock()
+		for i, upper := range h.buckets {
+			values := append(append([]string(nil), c.values...), formatFloat(upper))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.s.name, labelString(labels, values), h.counts[i])
+		}
+		values := append(append([]string(nil), c.values...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.s.name, labelString(labels, values), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.s.name, labelString(v.s.labels, c.values), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.s.name, labelString(v.s.labels, c.values), h.count)
+		h.mu.Unlock()
+	}
+}
+
+// funcMetric is a gauge or counter whose value is read on every scrape.
+type funcMetric struct {
+	name string
+	help string
+	typ  string
+	fn   func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is fn() at scrape time.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{name: name, help: help, typ: "gauge", fn: fn})
+}
+
+// NewCounterFunc registers a counter whose value is fn() at scrape time,
+// for totals kept elsewhere such as sql.DBStats.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{name: name, help: help, typ: "counter", fn: fn})
+}
+
+func (m *funcMetric) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", m.name, escapeHelp(m.help), m.name, m.typ, m.name, formatFloat(m.fn()))
+}
+
+func labelString(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
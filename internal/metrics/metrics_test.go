@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"bytes"
+	"flag"
+	"math"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/name.golden.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output doesn't match %s\n--- got:\n%s\n--- want:\n%s", path, got, want)
+	}
+}
+
+func render(r *Registry) []byte {
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	return buf.Bytes()
+}
+
+func TestCounterText(t *testing.T) {
+	r := NewRegistry()
+	hits := r.NewCounter("hits_total", "Requests served.")
+	hits.Add(3)
+
+	requests := r.NewCounterVec("requests_total", "Requests by route and status.", "route", "status")
+	requests.With("GET /api/chirps", "200").Inc()
+	requests.With("GET /api/chirps", "200").Inc()
+	requests.With("POST /api/chirps", "201").Add(0.5)
+	requests.With("DELETE /api/chirps/{chirpID}", "404").Inc()
+
+	// Registered but never used
+	r.NewCounterVec("unused_total", "Never incremented.", "outcome")
+
+	checkGolden(t, "counter", render(r))
+}
+
+func TestEscaping(t *testing.T) {
+	r := NewRegistry()
+	v := r.NewCounterVec("escaped_total", "Help with a backslash \\ and a\nnewline; \"quotes\" stay.", "value")
+	v.With(`back\slash`).Inc()
+	v.With("new\nline").Inc()
+	v.With(`"quoted"`).Inc()
+	v.With("unicode ✓").Inc()
+
+	checkGolden(t, "escaping", render(r))
+}
+
+func TestHistogramText(t *testing.T) {
+	r := NewRegistry()
+	latency := r.NewHistogramVec("latency_seconds", "Request latency.", []float64{0.1, 0.5, 1}, "route")
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		latency.With("GET /a").Observe(v)
+	}
+	latency.With("GET /b").Observe(0.2)
+
+	sizes := r.NewHistogramVec("size_bytes", "Unlabelled histogram.", []float64{100, 1000})
+	sizes.With().Observe(1e6)
+
+	checkGolden(t, "histogram", render(r))
+}
+
+func TestFuncMetricsText(t *testing.T) {
+	r := NewRegistry()
+	r.NewGaugeFunc("open_connections", "Open connections.", func() float64 { return 7 })
+	r.NewCounterFunc("wait_seconds_total", "Time spent waiting.", func() float64 { return 1.25 })
+	r.NewGaugeFunc("infinite", "Special values.", func() float64 { return math.Inf(1) })
+	r.NewGaugeFunc("not_a_number", "Special values.", func() float64 { return math.NaN() })
+
+	checkGolden(t, "func", render(r))
+}
+
+func TestReset(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("c_total", "Counter.", "k")
+	c.With("a").Add(5)
+	h := r.NewHistogramVec("h", "Histogram.", []float64{1}, "k")
+	h.With("a").Observe(0.5)
+	r.NewGaugeFunc("g", "Gauge.", func() float64 { return 3 })
+
+	r.Reset()
+	checkGolden(t, "reset", render(r))
+	if c.Total() != 0 {
+		t.Errorf("Total() after Reset = %v", c.Total())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("hits_total", "Hits.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), render(r)) {
+		t.Errorf("Handler body differs from WriteText:\n%s", rec.Body.String())
+	}
+}
+
+func TestPanics(t *testing.T) {
+	tests := map[string]func(){
+		"duplicate name": func() {
+			r := NewRegistry()
+			r.NewCounter("x_total", "X.")
+			r.NewCounter("x_total", "X.")
+		},
+		"wrong label count": func() {
+			NewRegistry().NewCounterVec("x_total", "X.", "a", "b").With("a")
+		},
+		"negative counter add": func() {
+			NewRegistry().NewCounter("x_total", "X.").Add(-1)
+		},
+	}
+	for name, fn := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("didn't panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
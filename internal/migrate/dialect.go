@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+)
+
+// lockID is the Postgres advisory lock key used while migrating. It is
+// goose's own key, so chirpy and the goose CLI exclude each other too.
+const lockID int64 = 5887940537704921958
+
+// Dialect holds the database-specific parts of migrating.
+type Dialect interface {
+	// Lock blocks until no other process is migrating and returns a
+	// function that releases the lock.
+	Lock(ctx context.Context, db *sql.DB) (unlock func(), err error)
+
+	versionTableExists() string
+	createVersionTable() string
+	insertVersion() string
+	deleteVersion() string
+}
+
+// Postgres -
+type Postgres struct{}
+
+// Lock takes a session-level advisory lock on a dedicated connection.
+func (Postgres) Lock(ctx context.Context, db *sql.DB) (func(), error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+		conn.Close()
+	}, nil
+}
+
+func (Postgres) versionTableExists() string {
+	return "SELECT to_regclass('goose_db_version') IS NOT NULL"
+}
+
+func (Postgres) createVersionTable() string {
+	return `CREATE TABLE IF NOT EXISTS goose_db_version (
+		id serial NOT NULL,
+		version_id bigint NOT NULL,
+		is_applied boolean NOT NULL,
+		tstamp timestamp NULL default now(),
+		PRIMARY KEY(id)
+	)`
+}
+
+func (Postgres) insertVersion() string {
+	return "INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, $2)"
+}
+
+func (Postgres) deleteVersion() string {
+	return "DELETE FROM goose_db_version WHERE version_id = $1"
+}
+
+// SQLite -
+type SQLite struct{}
+
+// Lock is a no-op: SQLite deployments run a single replica, and SQLite
+// serialises the migration transactions itself.
+func (SQLite) Lock(ctx context.Context, db *sql.DB) (func(), error) {
+	return func() {}, nil
+}
+
+func (SQLite) versionTableExists() string {
+	return "SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'goose_db_version'"
+}
+
+func (SQLite) createVersionTable() string {
+	return `CREATE TABLE IF NOT EXISTS goose_db_version (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		version_id INTEGER NOT NULL,
+		is_applied INTEGER NOT NULL,
+		tstamp TIMESTAMP DEFAULT (datetime('now'))
+	)`
+}
+
+func (SQLite) insertVersion() string {
+	return "INSERT INTO goose_db_version (version_id, is_applied) VALUES (?, ?)"
+}
+
+func (SQLite) deleteVersion() string {
+	return "DELETE FROM goose_db_version WHERE version_id = ?"
+}
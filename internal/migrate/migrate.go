@@ -0,0 +1,313 @@
+// Package migrate applies goose-format SQL migrations from an fs.FS. It
+// records applied versions in goose's goose_db_version table, so databases
+// migrated with the goose CLI are picked up as-is.
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSchemaBehind is returned by Check when migrations are pending.
+var ErrSchemaBehind = errors.New("database schema is behind the expected version")
+
+// Migration is one numbered migration file.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New loads every *.sql file in dir of fsys.
+func New(db *sql.DB, dialect Dialect, fsys fs.FS, dir string) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := map[int64]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		m, err := parseFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[m.Version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, m.Name, m.Version)
+		}
+		seen[m.Version] = m.Name
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// parseFile reads a goose migration. The version is the numeric prefix of
+// the file name and the Up and Down sections follow "-- +goose Up" and
+// "-- +goose Down".
+func parseFile(fsys fs.FS, name string) (Migration, error) {
+	base := path.Base(name)
+	prefix, _, ok := strings.Cut(base, "_")
+	if !ok {
+		return Migration{}, fmt.Errorf("migration %s: name must look like 001_description.sql", base)
+	}
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return Migration{}, fmt.Errorf("migration %s: invalid version: %w", base, err)
+	}
+
+	dat, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	var up, down strings.Builder
+	var section *strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(string(dat)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch strings.TrimSpace(line) {
+		case "-- +goose Up":
+			section = &up
+			continue
+		case "-- +goose Down":
+			section = &down
+			continue
+		case "-- +goose StatementBegin", "-- +goose StatementEnd":
+			// Each section runs as a single Exec, so statement
+			// boundaries don't need special handling
+			continue
+		}
+		if section != nil {
+			section.WriteString(line)
+			section.WriteString("\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Migration{}, err
+	}
+	if strings.TrimSpace(up.String()) == "" {
+		return Migration{}, fmt.Errorf("migration %s: missing -- +goose Up section", base)
+	}
+
+	return Migration{
+		Version: version,
+		Name:    base,
+		Up:      up.String(),
+		Down:    down.String(),
+	}, nil
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the highest applied migration version. Like Check and
+// Status it only reads, so it is safe to call from health checks and on a
+// database nothing has migrated yet.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var version int64
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Check returns ErrSchemaBehind if any known migration is not applied.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version < m.Latest() {
+		return fmt.Errorf("%w: at %d, want %d", ErrSchemaBehind, version, m.Latest())
+	}
+	return nil
+}
+
+// Up applies every pending migration in order. It holds the dialect's
+// lock for the duration so concurrent replicas don't race.
+func (m *Migrator) Up(ctx context.Context) (applied []Migration, err error) {
+	err = m.withLock(ctx, func() error {
+		done, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, mig, mig.Up, true); err != nil {
+				return err
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the most recently applied migration. It returns false if
+// nothing was applied.
+func (m *Migrator) Down(ctx context.Context) (rolledBack Migration, ok bool, err error) {
+	err = m.withLock(ctx, func() error {
+		done, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, applied := done[mig.Version]; !applied {
+				continue
+			}
+			if err := m.run(ctx, mig, mig.Down, false); err != nil {
+				return err
+			}
+			rolledBack, ok = mig, true
+			return nil
+		}
+		return nil
+	})
+	return rolledBack, ok, err
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		at, applied := done[mig.Version]
+		statuses = append(statuses, Status{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Applied:   applied,
+			AppliedAt: at,
+		})
+	}
+	return statuses, nil
+}
+
+// withLock runs fn holding the dialect's lock. The version table is
+// created under the lock, so concurrent first runs don't race to create
+// it.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	unlock, err := m.dialect.Lock(ctx, m.db)
+	if err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer unlock()
+
+	if _, err := m.db.ExecContext(ctx, m.dialect.createVersionTable()); err != nil {
+		return fmt.Errorf("creating version table: %w", err)
+	}
+	return fn()
+}
+
+func (m *Migrator) run(ctx context.Context, mig Migration, script string, up bool) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(script) != "" {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return fmt.Errorf("migration %s: %w", mig.Name, err)
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, m.dialect.insertVersion(), mig.Version, true)
+	} else {
+		_, err = tx.ExecContext(ctx, m.dialect.deleteVersion(), mig.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("migration %s: recording version: %w", mig.Name, err)
+	}
+	return tx.Commit()
+}
+
+// applied returns the applied versions and when they were applied, using
+// goose's rule that the most recent row for a version wins. A database
+// without a version table has nothing applied.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, m.dialect.versionTableExists()).Scan(&exists); err != nil {
+		return nil, err
+	}
+	applied := map[int64]time.Time{}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version_id, is_applied, tstamp FROM goose_db_version ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := map[int64]bool{}
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+			tstamp    sql.NullTime
+		)
+		if err := rows.Scan(&version, &isApplied, &tstamp); err != nil {
+			return nil, err
+		}
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+		if isApplied && version > 0 {
+			applied[version] = tstamp.Time
+		}
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,198 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+var testMigrations = fstest.MapFS{
+	"schema/001_users.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE users (id INTEGER PRIMARY KEY);
+
+-- +goose Down
+DROP TABLE users;
+`)},
+	"schema/002_chirps.sql": {Data: []byte(`-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE chirps (id INTEGER PRIMARY KEY);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE chirps;
+`)},
+	"schema/README.md": {Data: []byte("not a migration")},
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestReadsDontCreateTheVersionTable(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	m, err := New(db, SQLite{}, testMigrations, "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := m.Version(ctx)
+	if err != nil || version != 0 {
+		t.Fatalf("Version() = %d, %v; want 0, nil", version, err)
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrSchemaBehind) {
+		t.Fatalf("Check() = %v, want ErrSchemaBehind", err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].Applied || statuses[1].Applied {
+		t.Fatalf("Status() = %+v", statuses)
+	}
+	if tableExists(t, db, "goose_db_version") {
+		t.Fatal("a read created goose_db_version")
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	m, err := New(db, SQLite{}, testMigrations, "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Latest() != 2 {
+		t.Fatalf("Latest() = %d, want 2", m.Latest())
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0].Name != "001_users.sql" || applied[1].Name != "002_chirps.sql" {
+		t.Fatalf("Up() applied %+v", applied)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("Check() after Up = %v", err)
+	}
+	if !tableExists(t, db, "chirps") {
+		t.Fatal("chirps wasn't created")
+	}
+
+	// A second run has nothing to do
+	applied, err = m.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("second Up() = %+v, %v", applied, err)
+	}
+
+	rolledBack, ok, err := m.Down(ctx)
+	if err != nil || !ok || rolledBack.Version != 2 {
+		t.Fatalf("Down() = %+v, %v, %v", rolledBack, ok, err)
+	}
+	if tableExists(t, db, "chirps") {
+		t.Fatal("chirps wasn't dropped")
+	}
+	version, err := m.Version(ctx)
+	if err != nil || version != 1 {
+		t.Fatalf("Version() after Down = %d, %v; want 1", version, err)
+	}
+}
+
+func TestFailedMigrationIsNotRecorded(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := fstest.MapFS{
+		"schema/001_users.sql": testMigrations["schema/001_users.sql"],
+		"schema/002_broken.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE broken (;
+`)},
+	}
+	m, err := New(db, SQLite{}, fsys, "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err == nil {
+		t.Fatal("Up() succeeded with a broken migration")
+	}
+	version, err := m.Version(ctx)
+	if err != nil || version != 1 {
+		t.Fatalf("Version() = %d, %v; want 1", version, err)
+	}
+}
+
+// lockRecorder is the SQLite dialect with a Lock that notes whether the
+// version table already existed when the lock was taken.
+type lockRecorder struct {
+	SQLite
+	t          *testing.T
+	tableFirst bool
+	locked     bool
+}
+
+func (d *lockRecorder) Lock(ctx context.Context, db *sql.DB) (func(), error) {
+	d.locked = true
+	d.tableFirst = tableExists(d.t, db, "goose_db_version")
+	return func() {}, nil
+}
+
+func TestVersionTableCreatedUnderLock(t *testing.T) {
+	db := openDB(t)
+	d := &lockRecorder{t: t}
+	m, err := New(db, d, testMigrations, "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !d.locked {
+		t.Fatal("Up() didn't take the lock")
+	}
+	if d.tableFirst {
+		t.Fatal("goose_db_version was created before the lock was taken")
+	}
+}
+
+func TestNewRejectsBadFiles(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"duplicate version": {
+			"schema/001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+			"schema/001_b.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		},
+		"bad name": {
+			"schema/users.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		},
+		"no up section": {
+			"schema/001_a.sql": {Data: []byte("-- +goose Down\nSELECT 1;\n")},
+		},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(nil, SQLite{}, fsys, "schema"); err == nil {
+				t.Fatal("New() succeeded")
+			}
+		})
+	}
+}
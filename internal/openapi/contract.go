@@ -0,0 +1,108 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SplitPattern turns a net/http ServeMux pattern such as
+// "GET /api/chirps/{chirpID}" into a method and a document path. Wildcards
+// like {path...} become {path} and a trailing {$} is dropped. The method
+// is empty for patterns that match every method.
+func SplitPattern(pattern string) (method, path string) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	}
+	path = strings.TrimSpace(path)
+	// Patterns may name a host before the path
+	if i := strings.Index(path, "/"); i > 0 {
+		path = path[i:]
+	}
+	path = strings.TrimSuffix(path, "{$}")
+	path = strings.ReplaceAll(path, "...}", "}")
+	return method, path
+}
+
+// CheckRoutes reports every ServeMux pattern the document doesn't
+// describe. Patterns without a method, such as the file server's subtree,
+// aren't part of the API and are skipped.
+func (d *Document) CheckRoutes(patterns []string) error {
+	var errs []error
+	for _, pattern := range patterns {
+		method, path := SplitPattern(pattern)
+		if method == "" {
+			continue
+		}
+		if _, ok := d.Operation(method, path); !ok {
+			errs = append(errs, fmt.Errorf("%s %s is not documented", method, path))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckResponse reports how a response to the route registered as
+// pattern differs from the document: an undocumented status, a media type
+// the response doesn't list, or a body that doesn't match its schema.
+// Responses for HEAD requests and routes the document doesn't describe
+// aren't checked.
+func (d *Document) CheckResponse(pattern string, status int, header http.Header, body []byte) error {
+	method, path := SplitPattern(pattern)
+	if method == "" || method == http.MethodHead {
+		return nil
+	}
+	op, ok := d.Operation(method, path)
+	if !ok {
+		return nil
+	}
+
+	res := d.ResolveResponse(op.Responses[strconv.Itoa(status)])
+	if res == nil {
+		res = d.ResolveResponse(op.Responses["default"])
+	}
+	if res == nil {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+
+	if len(res.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("status %d is documented without a body but has %d bytes", status, len(body))
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("status %d: bad Content-Type %q", status, header.Get("Content-Type"))
+	}
+	media, ok := res.Content[mediaType]
+	if !ok {
+		documented := make([]string, 0, len(res.Content))
+		for t := range res.Content {
+			documented = append(documented, t)
+		}
+		sort.Strings(documented)
+		return fmt.Errorf("status %d: Content-Type %s is not one of %s", status, mediaType, strings.Join(documented, ", "))
+	}
+	if media.Schema == nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("status %d: body is not JSON: %w", status, err)
+	}
+	if problems := d.Validate(media.Schema, value); len(problems) > 0 {
+		return fmt.Errorf("status %d: body doesn't match %s:\n%s", status, d.TypeName(media.Schema), strings.Join(problems, "\n"))
+	}
+	return nil
+}
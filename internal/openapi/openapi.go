@@ -0,0 +1,334 @@
+// Package openapi holds the API's OpenAPI 3 document and checks the server
+// against it: that every registered route is documented and that responses
+// match the documented status codes, media types and schemas.
+//
+// The document is written by hand in openapi.json. Only the parts of
+// OpenAPI the checks and the docs page need are modelled here.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// JSON returns the document as served at /api/openapi.json.
+func JSON() []byte {
+	return spec
+}
+
+var loadOnce = sync.OnceValues(func() (*Document, error) {
+	return Parse(spec)
+})
+
+// Load returns the embedded document.
+func Load() (*Document, error) {
+	return loadOnce()
+}
+
+// Document is an OpenAPI 3.0 document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Tags       []Tag                `json:"tags"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// Tag groups operations.
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Components holds the definitions that $ref can point at.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Responses       map[string]*Response       `json:"responses"`
+	Parameters      map[string]*Parameter      `json:"parameters"`
+	RequestBodies   map[string]*RequestBody    `json:"requestBodies"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+// PathItem holds the operations on one path, keyed by upper-case method.
+type PathItem struct {
+	Parameters []*Parameter
+	Operations map[string]*Operation
+}
+
+var methods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH", "TRACE"}
+
+func (p *PathItem) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	p.Operations = make(map[string]*Operation)
+	for key, value := range raw {
+		if key == "parameters" {
+			if err := json.Unmarshal(value, &p.Parameters); err != nil {
+				return err
+			}
+			continue
+		}
+		method := strings.ToUpper(key)
+		for _, m := range methods {
+			if m == method {
+				op := &Operation{}
+				if err := json.Unmarshal(value, op); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				p.Operations[method] = op
+			}
+		}
+	}
+	return nil
+}
+
+// Operation is one method on a path.
+type Operation struct {
+	Tags        []string              `json:"tags"`
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description"`
+	Parameters  []*Parameter          `json:"parameters"`
+	RequestBody *RequestBody          `json:"requestBody"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+	Deprecated  bool                  `json:"deprecated"`
+}
+
+// Parameter is a path, query or header parameter.
+type Parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes an operation's request body.
+type RequestBody struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Required    bool                 `json:"required"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Response describes one response of an operation.
+type Response struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Headers     map[string]*Header   `json:"headers"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Header describes a response header.
+type Header struct {
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType gives the schema of a body in one media type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// SecurityScheme describes how requests authenticate.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat"`
+	Description  string `json:"description"`
+}
+
+// Parse decodes a document and checks that its references resolve.
+func Parse(data []byte) (*Document, error) {
+	var d Document
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI document: %w", err)
+	}
+	if err := d.checkRefs(); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Operation returns the operation for method on path, where path is
+// written as in the document, e.g. /api/chirps/{chirpID}.
+func (d *Document) Operation(method, path string) (*Operation, bool) {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil, false
+	}
+	op, ok := item.Operations[strings.ToUpper(method)]
+	return op, ok
+}
+
+// OperationRef locates an operation in the document.
+type OperationRef struct {
+	Method    string
+	Path      string
+	Operation *Operation
+	// Parameters holds the path item's parameters followed by the
+	// operation's own, with references resolved.
+	Parameters []*Parameter
+}
+
+// Operations lists every operation sorted by path and then method.
+func (d *Document) Operations() []OperationRef {
+	var ops []OperationRef
+	for path, item := range d.Paths {
+		for method, op := range item.Operations {
+			var params []*Parameter
+			for _, p := range append(append([]*Parameter{}, item.Parameters...), op.Parameters...) {
+				params = append(params, d.ResolveParameter(p))
+			}
+			ops = append(ops, OperationRef{Method: method, Path: path, Operation: op, Parameters: params})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return methodOrder(ops[i].Method) < methodOrder(ops[j].Method)
+	})
+	return ops
+}
+
+func methodOrder(method string) int {
+	for i, m := range methods {
+		if m == method {
+			return i
+		}
+	}
+	return len(methods)
+}
+
+// refName returns the component name a local reference points at.
+func refName(ref, kind string) (string, bool) {
+	return strings.CutPrefix(ref, "#/components/"+kind+"/")
+}
+
+// ResolveSchema follows s's reference, if any.
+func (d *Document) ResolveSchema(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		name, _ := refName(s.Ref, "schemas")
+		s = d.Components.Schemas[name]
+	}
+	return s
+}
+
+// ResolveResponse follows r's reference, if any.
+func (d *Document) ResolveResponse(r *Response) *Response {
+	if r != nil && r.Ref != "" {
+		name, _ := refName(r.Ref, "responses")
+		return d.Components.Responses[name]
+	}
+	return r
+}
+
+// ResolveParameter follows p's reference, if any.
+func (d *Document) ResolveParameter(p *Parameter) *Parameter {
+	if p != nil && p.Ref != "" {
+		name, _ := refName(p.Ref, "parameters")
+		return d.Components.Parameters[name]
+	}
+	return p
+}
+
+// ResolveRequestBody follows b's reference, if any.
+func (d *Document) ResolveRequestBody(b *RequestBody) *RequestBody {
+	if b != nil && b.Ref != "" {
+		name, _ := refName(b.Ref, "requestBodies")
+		return d.Components.RequestBodies[name]
+	}
+	return b
+}
+
+// checkRefs reports references to components that don't exist, so a typo
+// in the document fails at startup rather than passing every check.
+func (d *Document) checkRefs() error {
+	var missing []string
+	check := func(ref, kind string, exists func(string) bool) {
+		if ref == "" {
+			return
+		}
+		name, ok := refName(ref, kind)
+		if !ok || !exists(name) {
+			missing = append(missing, ref)
+		}
+	}
+	hasSchema := func(n string) bool { return d.Components.Schemas[n] != nil }
+
+	var walk func(s *Schema)
+	walk = func(s *Schema) {
+		if s == nil {
+			return
+		}
+		check(s.Ref, "schemas", hasSchema)
+		for _, p := range s.Properties {
+			walk(p)
+		}
+		walk(s.Items)
+		walk(s.AdditionalProperties)
+		for _, sub := range s.AllOf {
+			walk(sub)
+		}
+	}
+	content := func(c map[string]MediaType) {
+		for _, m := range c {
+			walk(m.Schema)
+		}
+	}
+	params := func(ps []*Parameter) {
+		for _, p := range ps {
+			check(p.Ref, "parameters", func(n string) bool { return d.Components.Parameters[n] != nil })
+			walk(p.Schema)
+		}
+	}
+
+	for _, s := range d.Components.Schemas {
+		walk(s)
+	}
+	for _, r := range d.Components.Responses {
+		content(r.Content)
+	}
+	for _, b := range d.Components.RequestBodies {
+		content(b.Content)
+	}
+	for _, item := range d.Paths {
+		params(item.Parameters)
+		for _, op := range item.Operations {
+			params(op.Parameters)
+			if op.RequestBody != nil {
+				check(op.RequestBody.Ref, "requestBodies", func(n string) bool { return d.Components.RequestBodies[n] != nil })
+				content(op.RequestBody.Content)
+			}
+			for _, r := range op.Responses {
+				check(r.Ref, "responses", func(n string) bool { return d.Components.Responses[n] != nil })
+				content(r.Content)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("OpenAPI document has unresolved references: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
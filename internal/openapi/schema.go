@@ -0,0 +1,273 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema is the subset of OpenAPI 3.0 schema objects the document uses.
+type Schema struct {
+	Ref         string             `json:"$ref"`
+	Type        string             `json:"type"`
+	Format      string             `json:"format"`
+	Description string             `json:"description"`
+	Enum        []any              `json:"enum"`
+	Default     any                `json:"default"`
+	Nullable    bool               `json:"nullable"`
+	Properties  map[string]*Schema `json:"properties"`
+	Required    []string           `json:"required"`
+	Items       *Schema            `json:"items"`
+	AllOf       []*Schema          `json:"allOf"`
+	MinLength   *int               `json:"minLength"`
+	MaxLength   *int               `json:"maxLength"`
+	MinItems    *int               `json:"minItems"`
+	MaxItems    *int               `json:"maxItems"`
+	Minimum     *float64           `json:"minimum"`
+	Maximum     *float64           `json:"maximum"`
+
+	// AdditionalProperties is the schema of properties not listed in
+	// Properties. ClosedProperties is set by "additionalProperties": false.
+	AdditionalProperties *Schema `json:"-"`
+	ClosedProperties     bool    `json:"-"`
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	var aux struct {
+		*plain
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}
+	aux.plain = (*plain)(s)
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	switch raw := strings.TrimSpace(string(aux.AdditionalProperties)); raw {
+	case "", "true":
+	case "false":
+		s.ClosedProperties = true
+	default:
+		s.AdditionalProperties = &Schema{}
+		return json.Unmarshal(aux.AdditionalProperties, s.AdditionalProperties)
+	}
+	return nil
+}
+
+// Validate checks a value decoded with json.Decoder.UseNumber against s
+// and returns every mismatch, each prefixed with its JSON path.
+func (d *Document) Validate(s *Schema, value any) []string {
+	var problems []string
+	d.validate(s, value, "$", &problems)
+	return problems
+}
+
+func (d *Document) validate(s *Schema, value any, path string, problems *[]string) {
+	s = d.ResolveSchema(s)
+	if s == nil {
+		return
+	}
+	fail := func(format string, args ...any) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if value == nil {
+		if !s.Nullable {
+			fail("is null")
+		}
+		return
+	}
+	for _, sub := range s.AllOf {
+		d.validate(sub, value, path, problems)
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		fail("%v is not one of %v", value, s.Enum)
+	}
+
+	switch s.Type {
+	case "":
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("is %s, want object", jsonType(value))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := path + "." + name
+			switch prop, ok := s.Properties[name]; {
+			case ok:
+				d.validate(prop, obj[name], child, problems)
+			case s.AdditionalProperties != nil:
+				d.validate(s.AdditionalProperties, obj[name], child, problems)
+			case s.ClosedProperties:
+				*problems = append(*problems, child+": undocumented property")
+			}
+		}
+
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			fail("is %s, want array", jsonType(value))
+			return
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			fail("has %d items, want at least %d", len(arr), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			fail("has %d items, want at most %d", len(arr), *s.MaxItems)
+		}
+		for i, item := range arr {
+			d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("is %s, want string", jsonType(value))
+			return
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("has length %d, want at least %d", n, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("has length %d, want at most %d", n, *s.MaxLength)
+		}
+		if err := checkFormat(s.Format, str); err != nil {
+			fail("%q is not a valid %s: %v", str, s.Format, err)
+		}
+
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			fail("is %s, want %s", jsonType(value), s.Type)
+			return
+		}
+		f, err := num.Float64()
+		if err != nil {
+			fail("%s is not a number", num)
+			return
+		}
+		if s.Type == "integer" {
+			if _, err := num.Int64(); err != nil {
+				fail("%s is not an integer", num)
+			}
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("%s is less than %v", num, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("%s is greater than %v", num, *s.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("is %s, want boolean", jsonType(value))
+		}
+
+	default:
+		fail("schema has unknown type %q", s.Type)
+	}
+}
+
+func checkFormat(format, s string) error {
+	switch format {
+	case "uuid":
+		_, err := uuid.Parse(s)
+		return err
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, s)
+		return err
+	case "email":
+		_, err := mail.ParseAddress(s)
+		return err
+	case "uri":
+		u, err := url.Parse(s)
+		if err == nil && !u.IsAbs() {
+			err = fmt.Errorf("not absolute")
+		}
+		return err
+	}
+	return nil
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// TypeName describes s briefly for documentation, e.g. "Chirp",
+// "array of Chirp" or "string (uuid)".
+func (d *Document) TypeName(s *Schema) string {
+	if s == nil {
+		return ""
+	}
+	if s.Ref != "" {
+		name, _ := refName(s.Ref, "schemas")
+		return name
+	}
+	if len(s.AllOf) == 1 && s.AllOf[0].Ref != "" {
+		name := d.TypeName(s.AllOf[0])
+		if s.Nullable {
+			name += " or null"
+		}
+		return name
+	}
+	var name string
+	switch {
+	case s.Type == "array":
+		name = "array of " + d.TypeName(s.Items)
+	case s.Type == "object" && s.AdditionalProperties != nil && len(s.Properties) == 0:
+		name = "map of " + d.TypeName(s.AdditionalProperties)
+	case s.Format != "":
+		name = s.Type + " (" + s.Format + ")"
+	default:
+		name = s.Type
+	}
+	if len(s.Enum) > 0 {
+		values := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			values[i] = fmt.Sprint(e)
+		}
+		name += ": " + strings.Join(values, ", ")
+	}
+	if s.Nullable {
+		name += " or null"
+	}
+	return name
+}
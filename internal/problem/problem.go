@@ -0,0 +1,89 @@
+// Package problem defines the API's error responses: RFC 7807
+// application/problem+json documents carrying a stable machine-readable
+// code that clients can branch on.
+package problem
+
+// ContentType is the media type of problem documents.
+const ContentType = "application/problem+json"
+
+// Code identifies a kind of error. Codes are part of the API contract:
+// new ones may be added but existing ones never change meaning.
+type Code string
+
+// Error codes.
+const (
+	// CodeInvalidRequest means the request couldn't be understood, e.g.
+	// malformed JSON or a bad path parameter.
+	CodeInvalidRequest Code = "invalid_request"
+	// CodeValidationFailed means the request was well formed but some
+	// fields were invalid. The problem lists them in Errors.
+	CodeValidationFailed Code = "validation_failed"
+	// CodeBodyTooLarge means the request body exceeded the size limit.
+	CodeBodyTooLarge Code = "body_too_large"
+	// CodeUnsupportedMediaType means the request body wasn't JSON.
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+
+	// CodeUnauthorized means no access token was sent.
+	CodeUnauthorized Code = "unauthorized"
+	// CodeInvalidCredentials means an email or password was wrong.
+	CodeInvalidCredentials Code = "invalid_credentials"
+	// CodeInvalidToken means an access, refresh, confirmation or signature
+	// token was invalid or expired. Clients holding a refresh token can
+	// get a new access token and retry.
+	CodeInvalidToken Code = "invalid_token"
+	// CodeForbidden means the caller is authenticated but not allowed.
+	CodeForbidden Code = "forbidden"
+
+	// CodeNotFound means the resource or endpoint doesn't exist.
+	CodeNotFound Code = "not_found"
+	// CodeMethodNotAllowed means the endpoint exists but not for the
+	// request method. The Allow header lists the methods it supports.
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	// CodeConflict means the request conflicts with existing state.
+	CodeConflict Code = "conflict"
+	// CodeEmailInUse means another account already has the email.
+	CodeEmailInUse Code = "email_in_use"
+	// CodeDuplicateChirp means the same chirp body was already posted.
+	CodeDuplicateChirp Code = "duplicate_chirp"
+
+	// CodeRateLimited means the caller must wait before retrying.
+	CodeRateLimited Code = "rate_limited"
+	// CodeUnavailable means the server can't take the request right now,
+	// e.g. because it is shutting down. Retrying may succeed.
+	CodeUnavailable Code = "unavailable"
+	// CodeInternal means the server failed. The detail is generic.
+	CodeInternal Code = "internal_error"
+)
+
+// Field error codes used in FieldError.Code.
+const (
+	FieldRequired = "required"
+	FieldInvalid  = "invalid"
+	FieldTooLong  = "too_long"
+	FieldUnknown  = "unknown"
+)
+
+// FieldError describes one invalid field. Field is a JSON path such as
+// "email" or "events[2]".
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem document with chirpy's extensions.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// TypeURI returns the problem type URI for code.
+func TypeURI(code Code) string {
+	return "urn:chirpy:problem:" + string(code)
+}
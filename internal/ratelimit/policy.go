@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/chirpy/internal/entitlements"
+)
+
+// Policy holds the limits for one route by caller tier.
+type Policy struct {
+	Anonymous Limit
+	Standard  Limit
+	Premium   Limit
+}
+
+// For returns the limit for tier. Unknown tiers get the anonymous limit.
+func (p Policy) For(tier entitlements.RateLimitTier) Limit {
+	switch tier {
+	case entitlements.RateLimitStandard:
+		return p.Standard
+	case entitlements.RateLimitPremium:
+		return p.Premium
+	}
+	return p.Anonymous
+}
+
+// String formats p as accepted by ParsePolicy.
+func (p Policy) String() string {
+	return fmt.Sprintf("anonymous=%s,standard=%s,premium=%s", p.Anonymous, p.Standard, p.Premium)
+}
+
+// ParsePolicy parses "anonymous=10/m,standard=30/m,premium=120/m". Tiers
+// that aren't mentioned keep their limit from base.
+func ParsePolicy(s string, base Policy) (Policy, error) {
+	p := base
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		tier, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Policy{}, fmt.Errorf("invalid policy entry %q: want tier=limit", part)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return Policy{}, err
+		}
+		switch entitlements.RateLimitTier(strings.TrimSpace(tier)) {
+		case entitlements.RateLimitAnonymous:
+			p.Anonymous = limit
+		case entitlements.RateLimitStandard:
+			p.Standard = limit
+		case entitlements.RateLimitPremium:
+			p.Premium = limit
+		default:
+			return Policy{}, fmt.Errorf("invalid policy entry %q: unknown tier %q", part, tier)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"example.com/chirpy/internal/database"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so limits
+// are shared between replicas. Each Take locks the bucket's row for the
+// duration of a short transaction.
+type PostgresStore struct {
+	db *sql.DB
+	q  *database.Queries
+}
+
+// NewPostgresStore -
+func NewPostgresStore(db *sql.DB, q *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db, q: q}
+}
+
+// Take -
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	now = now.UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+	q := s.q.WithTx(tx)
+
+	err = q.EnsureRateLimitBucket(ctx, database.EnsureRateLimitBucketParams{
+		Key:       key,
+		Tokens:    float64(limit.Requests),
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	b, err := q.GetRateLimitBucketForUpdate(ctx, key)
+	if err != nil {
+		return Result{}, err
+	}
+
+	tokens, res := take(b.Tokens, b.UpdatedAt, limit, now)
+	err = q.UpdateRateLimitBucket(ctx, database.UpdateRateLimitBucketParams{
+		Key:       key,
+		Tokens:    tokens,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return res, tx.Commit()
+}
+
+// DeleteIdle removes buckets untouched since before. Buckets that have
+// refilled can be dropped since a new bucket starts full.
+func (s *PostgresStore) DeleteIdle(ctx context.Context, before time.Time) (int64, error) {
+	return s.q.DeleteIdleRateLimitBuckets(ctx, before.UTC())
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store/storetest"
+)
+
+func TestPostgresStore(t *testing.T) {
+	db := storetest.PostgresDB(t)
+	testStore(t, NewPostgresStore(db, database.New(db)))
+}
+
+func TestPostgresStoreDeleteIdle(t *testing.T) {
+	ctx := context.Background()
+	db := storetest.PostgresDB(t)
+	s := NewPostgresStore(db, database.New(db))
+	limit := Limit{Requests: 1, Per: time.Hour}
+
+	takeN(t, s, "old", limit, t0, 1)
+	takeN(t, s, "new", limit, t0.Add(time.Hour), 1)
+
+	n, err := s.DeleteIdle(ctx, t0.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("deleted %d buckets, want 1", n)
+	}
+
+	// The deleted bucket starts full again, the other one is still empty
+	if res := takeN(t, s, "old", limit, t0.Add(time.Hour), 1); !res.Allowed {
+		t.Errorf("old: got %+v, want a new full bucket", res)
+	}
+	if res := takeN(t, s, "new", limit, t0.Add(time.Hour), 1); res.Allowed {
+		t.Errorf("new: got %+v, want the kept empty bucket", res)
+	}
+}
@@ -0,0 +1,126 @@
+// Package ratelimit implements token bucket rate limiting with in-memory
+// and Postgres-backed bucket stores.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Per on average, with bursts of up to Requests.
+// A zero Limit allows nothing; use Unlimited to disable limiting.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Unlimited disables limiting.
+var Unlimited = Limit{Requests: -1}
+
+// IsUnlimited -
+func (l Limit) IsUnlimited() bool {
+	return l.Requests < 0
+}
+
+// rate returns the refill rate in tokens per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// String formats l as accepted by ParseLimit.
+func (l Limit) String() string {
+	if l.IsUnlimited() {
+		return "unlimited"
+	}
+	unit := map[time.Duration]string{time.Second: "s", time.Minute: "m", time.Hour: "h"}[l.Per]
+	if unit == "" {
+		return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, unit)
+}
+
+// ParseLimit parses "N/s", "N/m", "N/h", "N/<duration>" or "unlimited".
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "unlimited" {
+		return Unlimited, nil
+	}
+	n, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q: want N/s, N/m or N/h", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: bad request count", s)
+	}
+	var d time.Duration
+	switch per {
+	case "s":
+		d = time.Second
+	case "m":
+		d = time.Minute
+	case "h":
+		d = time.Hour
+	default:
+		d, err = time.ParseDuration(per)
+		if err != nil || d <= 0 {
+			return Limit{}, fmt.Errorf("invalid limit %q: bad period", s)
+		}
+	}
+	return Limit{Requests: requests, Per: d}, nil
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket capacity.
+	Limit int
+	// Remaining is the number of whole tokens left.
+	Remaining int
+	// RetryAfter is how long until a token is available, if not allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store holds buckets by key.
+type Store interface {
+	// Take removes one token from the bucket for key, creating a full
+	// bucket if there is none.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// take applies the token bucket algorithm to a bucket holding tokens as of
+// updated, returning the new token count and the result.
+func take(tokens float64, updated time.Time, limit Limit, now time.Time) (float64, Result) {
+	capacity := float64(limit.Requests)
+	res := Result{Limit: limit.Requests}
+	if limit.Requests == 0 {
+		res.RetryAfter = limit.Per
+		res.Reset = limit.Per
+		return 0, res
+	}
+
+	rate := limit.rate()
+	if elapsed := now.Sub(updated).Seconds(); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = secondsToDuration((capacity - tokens) / rate)
+	return tokens, res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
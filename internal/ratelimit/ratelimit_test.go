@@ -0,0 +1,188 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestTake(t *testing.T) {
+	tenPerMinute := Limit{Requests: 10, Per: time.Minute}
+	tests := []struct {
+		name    string
+		tokens  float64
+		elapsed time.Duration
+		limit   Limit
+		want    Result
+		left    float64
+	}{
+		{
+			name:   "full bucket",
+			tokens: 10, limit: tenPerMinute,
+			want: Result{Allowed: true, Limit: 10, Remaining: 9, Reset: 6 * time.Second},
+			left: 9,
+		},
+		{
+			name:   "last token",
+			tokens: 1, limit: tenPerMinute,
+			want: Result{Allowed: true, Limit: 10, Remaining: 0, Reset: time.Minute},
+			left: 0,
+		},
+		{
+			name:   "empty bucket",
+			tokens: 0, limit: tenPerMinute,
+			want: Result{Limit: 10, RetryAfter: 6 * time.Second, Reset: time.Minute},
+			left: 0,
+		},
+		{
+			name:   "part of a token",
+			tokens: 0.5, limit: tenPerMinute,
+			want: Result{Limit: 10, RetryAfter: 3 * time.Second, Reset: 57 * time.Second},
+			left: 0.5,
+		},
+		{
+			name:   "refilled one token",
+			tokens: 0, elapsed: 6 * time.Second, limit: tenPerMinute,
+			want: Result{Allowed: true, Limit: 10, Remaining: 0, Reset: time.Minute},
+			left: 0,
+		},
+		{
+			name:   "refilled several tokens",
+			tokens: 2, elapsed: 30 * time.Second, limit: tenPerMinute,
+			want: Result{Allowed: true, Limit: 10, Remaining: 6, Reset: 24 * time.Second},
+			left: 6,
+		},
+		{
+			name:   "refill stops at capacity",
+			tokens: 0, elapsed: time.Hour, limit: tenPerMinute,
+			want: Result{Allowed: true, Limit: 10, Remaining: 9, Reset: 6 * time.Second},
+			left: 9,
+		},
+		{
+			name:   "clock went backwards",
+			tokens: 0, elapsed: -time.Minute, limit: tenPerMinute,
+			want: Result{Limit: 10, RetryAfter: 6 * time.Second, Reset: time.Minute},
+			left: 0,
+		},
+		{
+			name:   "zero limit",
+			tokens: 0, elapsed: time.Hour, limit: Limit{Requests: 0, Per: time.Minute},
+			want: Result{Limit: 0, RetryAfter: time.Minute, Reset: time.Minute},
+			left: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, got := take(tt.tokens, t0, tt.limit, t0.Add(tt.elapsed))
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if left != tt.left {
+				t.Errorf("left %v tokens, want %v", left, tt.left)
+			}
+		})
+	}
+}
+
+// takeN takes n tokens from key at now and returns the last result.
+func takeN(t *testing.T, s Store, key string, limit Limit, now time.Time, n int) Result {
+	t.Helper()
+	var res Result
+	for range n {
+		var err error
+		res, err = s.Take(context.Background(), key, limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res
+}
+
+// testStore checks burst, refill and key separation against s.
+func testStore(t *testing.T, s Store) {
+	limit := Limit{Requests: 3, Per: time.Minute}
+
+	if res := takeN(t, s, "a", limit, t0, 3); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("burst: got %+v, want the third request allowed with none remaining", res)
+	}
+	res := takeN(t, s, "a", limit, t0, 1)
+	if res.Allowed {
+		t.Fatal("request past the burst was allowed")
+	}
+	if res.RetryAfter != 20*time.Second {
+		t.Errorf("RetryAfter = %v, want 20s", res.RetryAfter)
+	}
+
+	if res := takeN(t, s, "b", limit, t0, 1); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("other key: got %+v, want a full bucket", res)
+	}
+
+	if res := takeN(t, s, "a", limit, t0.Add(20*time.Second), 1); !res.Allowed {
+		t.Errorf("after refilling one token: got %+v, want allowed", res)
+	}
+	if res := takeN(t, s, "a", limit, t0.Add(20*time.Second), 1); res.Allowed {
+		t.Errorf("after using the refilled token: got %+v, want denied", res)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStoreReset(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Requests: 1, Per: time.Hour}
+	takeN(t, s, "a", limit, t0, 1)
+
+	s.Reset()
+	if res := takeN(t, s, "a", limit, t0, 1); !res.Allowed {
+		t.Errorf("after Reset: got %+v, want a full bucket", res)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s := NewMemoryStore()
+	takeN(t, s, "a", Limit{Requests: 2, Per: time.Minute}, t0, 1)
+	takeN(t, s, "b", Limit{Requests: 2, Per: time.Hour}, t0, 1)
+
+	// a has refilled by now, b hasn't
+	takeN(t, s, "c", Limit{Requests: 2, Per: time.Minute}, t0.Add(sweepInterval+time.Second), 1)
+	if _, ok := s.buckets["a"]; ok {
+		t.Error("full bucket a was not swept")
+	}
+	if _, ok := s.buckets["b"]; !ok {
+		t.Error("bucket b was swept before it refilled")
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "10/s", want: Limit{Requests: 10, Per: time.Second}},
+		{in: "10/m", want: Limit{Requests: 10, Per: time.Minute}},
+		{in: " 5/h ", want: Limit{Requests: 5, Per: time.Hour}},
+		{in: "3/90s", want: Limit{Requests: 3, Per: 90 * time.Second}},
+		{in: "0/m", want: Limit{Requests: 0, Per: time.Minute}},
+		{in: "unlimited", want: Unlimited},
+		{in: "10", wantErr: true},
+		{in: "-1/m", wantErr: true},
+		{in: "x/m", wantErr: true},
+		{in: "10/fortnight", wantErr: true},
+		{in: "10/-1s", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q): error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
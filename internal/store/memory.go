@@ -0,0 +1,1310 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"example.com/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Memory is an in-memory Store with the same semantics as Postgres:
+// unique keys, foreign keys, and cascading deletes.
+// It is safe for concurrent use; transactions are serialised.
+type Memory struct {
+	mu   sync.Mutex
+	data *memData
+}
+
+// NewMemory -
+func NewMemory() *Memory {
+	return &Memory{data: newMemData()}
+}
+
+type memData struct {
+	seq           int64
+	users         map[uuid.UUID]database.User
+	chirps        map[uuid.UUID]memChirp
+	refreshTokens map[string]database.RefreshToken
+	emailChanges  map[string]database.EmailChangeRequest
+	follows       map[database.FollowUserParams]time.Time
+	reactions     map[database.AddReactionParams]time.Time
+	subscriptions map[uuid.UUID]database.Subscription // by user ID
+	overrides     map[uuid.UUID]database.EntitlementOverride
+	roles         map[database.GrantRoleParams]bool
+	polkaEvents   map[string]bool
+	outbox        []database.OutboxEvent
+	webhooks      map[uuid.UUID]database.WebhookSubscription
+	deliveries    map[uuid.UUID]database.WebhookDelivery
+}
+
+// memChirp remembers insertion order so chirps created within the same
+// clock tick still list in a stable order.
+type memChirp struct {
+	database.Chirp
+	seq int64
+}
+
+func newMemData() *memData {
+	return &memData{
+		users:         map[uuid.UUID]database.User{},
+		chirps:        map[uuid.UUID]memChirp{},
+		refreshTokens: map[string]database.RefreshToken{},
+		emailChanges:  map[string]database.EmailChangeRequest{},
+		follows:       map[database.FollowUserParams]time.Time{},
+		reactions:     map[database.AddReactionParams]time.Time{},
+		subscriptions: map[uuid.UUID]database.Subscription{},
+		overrides:     map[uuid.UUID]database.EntitlementOverride{},
+		roles:         map[database.GrantRoleParams]bool{},
+		polkaEvents:   map[string]bool{},
+		webhooks:      map[uuid.UUID]database.WebhookSubscription{},
+		deliveries:    map[uuid.UUID]database.WebhookDelivery{},
+	}
+}
+
+func (d *memData) clone() *memData {
+	c := newMemData()
+	c.seq = d.seq
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.chirps {
+		c.chirps[k] = v
+	}
+	for k, v := range d.refreshTokens {
+		c.refreshTokens[k] = v
+	}
+	for k, v := range d.emailChanges {
+		c.emailChanges[k] = v
+	}
+	for k, v := range d.follows {
+		c.follows[k] = v
+	}
+	for k, v := range d.reactions {
+		c.reactions[k] = v
+	}
+	for k, v := range d.subscriptions {
+		c.subscriptions[k] = v
+	}
+	for k, v := range d.overrides {
+		c.overrides[k] = v
+	}
+	for k, v := range d.roles {
+		c.roles[k] = v
+	}
+	for k, v := range d.polkaEvents {
+		c.polkaEvents[k] = v
+	}
+	c.outbox = append(c.outbox, d.outbox...)
+	for k, v := range d.webhooks {
+		c.webhooks[k] = v
+	}
+	for k, v := range d.deliveries {
+		c.deliveries[k] = v
+	}
+	return c
+}
+
+// runner executes fn against the store's data with whatever locking the
+// caller needs.
+type runner func(fn func(d *memData) error) error
+
+func (m *Memory) run(fn func(d *memData) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn(m.data)
+}
+
+// Users -
+func (m *Memory) Users() UserRepository { return memUsers{m.run} }
+
+// Chirps -
+func (m *Memory) Chirps() ChirpRepository { return memChirps{m.run} }
+
+// RefreshTokens -
+func (m *Memory) RefreshTokens() RefreshTokenRepository { return memRefreshTokens{m.run} }
+
+// EmailChanges -
+func (m *Memory) EmailChanges() EmailChangeRepository { return memEmailChanges{m.run} }
+
+// Follows -
+func (m *Memory) Follows() FollowRepository { return memFollows{m.run} }
+
+// Reactions -
+func (m *Memory) Reactions() ReactionRepository { return memReactions{m.run} }
+
+// Subscriptions -
+func (m *Memory) Subscriptions() SubscriptionRepository { return memSubscriptions{m.run} }
+
+// Entitlements -
+func (m *Memory) Entitlements() EntitlementRepository { return memEntitlements{m.run} }
+
+// Roles -
+func (m *Memory) Roles() RoleRepository { return memRoles{m.run} }
+
+// PolkaEvents -
+func (m *Memory) PolkaEvents() PolkaEventRepository { return memPolkaEvents{m.run} }
+
+// Outbox -
+func (m *Memory) Outbox() OutboxRepository { return memOutbox{m.run} }
+
+// Webhooks -
+func (m *Memory) Webhooks() WebhookRepository { return memWebhooks{m.run} }
+
+// Reset -
+func (m *Memory) Reset(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data = newMemData()
+	return nil
+}
+
+// OutboxEvents returns a copy of every event recorded so far.
+func (m *Memory) OutboxEvents() []database.OutboxEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]database.OutboxEvent(nil), m.data.outbox...)
+}
+
+// WithTx holds the store lock for the duration of fn and discards fn's
+// writes if it returns an error.
+func (m *Memory) WithTx(ctx context.Context, fn func(Store) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	working := m.data.clone()
+	if err := fn(&memTx{data: working}); err != nil {
+		return err
+	}
+	m.data = working
+	return nil
+}
+
+// memTx is the Store handed to a WithTx callback. The lock is already held.
+type memTx struct {
+	data *memData
+}
+
+func (t *memTx) run(fn func(d *memData) error) error { return fn(t.data) }
+
+func (t *memTx) Users() UserRepository                 { return memUsers{t.run} }
+func (t *memTx) Chirps() ChirpRepository               { return memChirps{t.run} }
+func (t *memTx) RefreshTokens() RefreshTokenRepository { return memRefreshTokens{t.run} }
+func (t *memTx) EmailChanges() EmailChangeRepository   { return memEmailChanges{t.run} }
+func (t *memTx) Follows() FollowRepository             { return memFollows{t.run} }
+func (t *memTx) Reactions() ReactionRepository         { return memReactions{t.run} }
+func (t *memTx) Subscriptions() SubscriptionRepository { return memSubscriptions{t.run} }
+func (t *memTx) Entitlements() EntitlementRepository   { return memEntitlements{t.run} }
+func (t *memTx) Roles() RoleRepository                 { return memRoles{t.run} }
+func (t *memTx) PolkaEvents() PolkaEventRepository     { return memPolkaEvents{t.run} }
+func (t *memTx) Outbox() OutboxRepository              { return memOutbox{t.run} }
+func (t *memTx) Webhooks() WebhookRepository           { return memWebhooks{t.run} }
+
+func (t *memTx) Reset(ctx context.Context) error {
+	*t.data = *newMemData()
+	return nil
+}
+
+func (t *memTx) WithTx(ctx context.Context, fn func(Store) error) error {
+	return fn(t)
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+type memUsers struct{ run runner }
+
+func (r memUsers) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	var u database.User
+	err := r.run(func(d *memData) error {
+		if d.emailTaken(arg.Email, uuid.Nil) {
+			return ErrConflict
+		}
+		t := now()
+		u = database.User{
+			ID:             uuid.New(),
+			CreatedAt:      t,
+			UpdatedAt:      t,
+			Email:          arg.Email,
+			HashedPassword: arg.HashedPassword,
+		}
+		d.users[u.ID] = u
+		return nil
+	})
+	return u, err
+}
+
+func (r memUsers) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	var u database.User
+	err := r.run(func(d *memData) error {
+		var ok bool
+		u, ok = d.users[id]
+		if !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return u, err
+}
+
+func (r memUsers) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	var u database.User
+	err := r.run(func(d *memData) error {
+		for _, candidate := range d.users {
+			if candidate.Email == email {
+				u = candidate
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return u, err
+}
+
+func (r memUsers) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]database.User, error) {
+	var users []database.User
+	err := r.run(func(d *memData) error {
+		seen := map[uuid.UUID]bool{}
+		for _, id := range ids {
+			if u, ok := d.users[id]; ok && !seen[id] {
+				seen[id] = true
+				users = append(users, u)
+			}
+		}
+		return nil
+	})
+	return users, err
+}
+
+func (r memUsers) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	return r.update(arg.ID, func(d *memData, u *database.User) error {
+		if d.emailTaken(arg.Email, arg.ID) {
+			return ErrConflict
+		}
+		u.Email = arg.Email
+		u.HashedPassword = arg.HashedPassword
+		return nil
+	})
+}
+
+func (r memUsers) UpdateUserEmail(ctx context.Context, arg database.UpdateUserEmailParams) (database.User, error) {
+	return r.update(arg.ID, func(d *memData, u *database.User) error {
+		if d.emailTaken(arg.Email, arg.ID) {
+			return ErrConflict
+		}
+		u.Email = arg.Email
+		return nil
+	})
+}
+
+func (r memUsers) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	return r.update(arg.ID, func(d *memData, u *database.User) error {
+		u.HashedPassword = arg.HashedPassword
+		return nil
+	})
+}
+
+func (r memUsers) update(id uuid.UUID, fn func(d *memData, u *database.User) error) (database.User, error) {
+	var u database.User
+	err := r.run(func(d *memData) error {
+		var ok bool
+		u, ok = d.users[id]
+		if !ok {
+			return ErrNotFound
+		}
+		if err := fn(d, &u); err != nil {
+			return err
+		}
+		u.UpdatedAt = now()
+		d.users[id] = u
+		return nil
+	})
+	return u, err
+}
+
+func (r memUsers) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return r.run(func(d *memData) error {
+		if _, ok := d.users[id]; !ok {
+			return ErrNotFound
+		}
+		d.deleteUser(id)
+		return nil
+	})
+}
+
+func (r memUsers) DeleteAllUsers(ctx context.Context) error {
+	return r.run(func(d *memData) error {
+		for id := range d.users {
+			d.deleteUser(id)
+		}
+		return nil
+	})
+}
+
+func (d *memData) emailTaken(email string, except uuid.UUID) bool {
+	for id, u := range d.users {
+		if id != except && u.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteUser removes a user and cascades to everything that refers to
+// them.
+func (d *memData) deleteUser(id uuid.UUID) {
+	delete(d.users, id)
+	for chirpID, c := range d.chirps {
+		if c.UserID == id {
+			d.deleteChirp(chirpID)
+		}
+	}
+	for token, t := range d.refreshTokens {
+		if t.UserID == id {
+			delete(d.refreshTokens, token)
+		}
+	}
+	for hash, c := range d.emailChanges {
+		if c.UserID == id {
+			delete(d.emailChanges, hash)
+		}
+	}
+	for f := range d.follows {
+		if f.FollowerID == id || f.FolloweeID == id {
+			delete(d.follows, f)
+		}
+	}
+	for reaction := range d.reactions {
+		if reaction.UserID == id {
+			delete(d.reactions, reaction)
+		}
+	}
+	delete(d.subscriptions, id)
+	delete(d.overrides, id)
+	for role := range d.roles {
+		if role.UserID == id {
+			delete(d.roles, role)
+		}
+	}
+	for subID, sub := range d.webhooks {
+		if sub.UserID == id {
+			d.deleteWebhook(subID)
+		}
+	}
+}
+
+type memChirps struct{ run runner }
+
+func (r memChirps) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	var c database.Chirp
+	err := r.run(func(d *memData) error {
+		if _, ok := d.users[arg.UserID]; !ok {
+			return ErrInvalidReference
+		}
+		for _, existing := range d.chirps {
+			if existing.Body == arg.Body {
+				return ErrConflict
+			}
+		}
+		t := now()
+		c = database.Chirp{
+			ID:        uuid.New(),
+			CreatedAt: t,
+			UpdatedAt: t,
+			Body:      arg.Body,
+			UserID:    arg.UserID,
+		}
+		d.seq++
+		d.chirps[c.ID] = memChirp{Chirp: c, seq: d.seq}
+		return nil
+	})
+	return c, err
+}
+
+func (r memChirps) GetChirps(ctx context.Context) ([]database.Chirp, error) {
+	return r.list(func(database.Chirp) bool { return true })
+}
+
+func (r memChirps) GetChirpsByAuthorID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return r.list(func(c database.Chirp) bool { return c.UserID == userID })
+}
+
+func (r memChirps) list(keep func(database.Chirp) bool) ([]database.Chirp, error) {
+	var items []memChirp
+	err := r.run(func(d *memData) error {
+		for _, c := range d.chirps {
+			if keep(c.Chirp) {
+				items = append(items, c)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.Before(items[j].CreatedAt)
+		}
+		return items[i].seq < items[j].seq
+	})
+	var chirps []database.Chirp
+	for _, c := range items {
+		chirps = append(chirps, c.Chirp)
+	}
+	return chirps, nil
+}
+
+func (r memChirps) ListChirps(ctx context.Context, arg database.ListChirpsParams) ([]database.Chirp, error) {
+	return r.page(arg.BeforeCreatedAt, arg.BeforeID, arg.Limit, func(d *memData, c database.Chirp) bool {
+		return !arg.UserID.Valid || c.UserID == arg.UserID.UUID
+	})
+}
+
+func (r memChirps) ListChirpsOldestFirst(ctx context.Context, arg database.ListChirpsOldestFirstParams) ([]database.Chirp, error) {
+	var chirps []database.Chirp
+	err := r.run(func(d *memData) error {
+		for _, c := range d.chirps {
+			if arg.UserID.Valid && c.UserID != arg.UserID.UUID {
+				continue
+			}
+			if arg.AfterCreatedAt.Valid && !newerThan(c.CreatedAt, c.ID, arg.AfterCreatedAt.Time, arg.AfterID.UUID) {
+				continue
+			}
+			chirps = append(chirps, c.Chirp)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(chirps, func(i, j int) bool {
+		return newerThan(chirps[j].CreatedAt, chirps[j].ID, chirps[i].CreatedAt, chirps[i].ID)
+	})
+	return truncate(chirps, arg.Limit), nil
+}
+
+func (r memChirps) ListTimelineChirps(ctx context.Context, arg database.ListTimelineChirpsParams) ([]database.Chirp, error) {
+	return r.page(arg.BeforeCreatedAt, arg.BeforeID, arg.Limit, func(d *memData, c database.Chirp) bool {
+		_, followed := d.follows[database.FollowUserParams{FollowerID: arg.UserID, FolloweeID: c.UserID}]
+		return c.UserID == arg.UserID || followed
+	})
+}
+
+// page returns the chirps keep accepts, newest first, after the optional
+// (beforeCreatedAt, beforeID) cursor.
+func (r memChirps) page(beforeCreatedAt sql.NullTime, beforeID uuid.NullUUID, limit int32, keep func(d *memData, c database.Chirp) bool) ([]database.Chirp, error) {
+	var chirps []database.Chirp
+	err := r.run(func(d *memData) error {
+		for _, c := range d.chirps {
+			if !keep(d, c.Chirp) {
+				continue
+			}
+			if beforeCreatedAt.Valid && !newerThan(beforeCreatedAt.Time, beforeID.UUID, c.CreatedAt, c.ID) {
+				continue
+			}
+			chirps = append(chirps, c.Chirp)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(chirps, func(i, j int) bool {
+		return newerThan(chirps[i].CreatedAt, chirps[i].ID, chirps[j].CreatedAt, chirps[j].ID)
+	})
+	return truncate(chirps, limit), nil
+}
+
+// newerThan reports whether (createdAt, id) sorts after (otherCreatedAt,
+// otherID), comparing IDs bytewise as Postgres does.
+func newerThan(createdAt time.Time, id uuid.UUID, otherCreatedAt time.Time, otherID uuid.UUID) bool {
+	if !createdAt.Equal(otherCreatedAt) {
+		return createdAt.After(otherCreatedAt)
+	}
+	return bytes.Compare(id[:], otherID[:]) > 0
+}
+
+// truncate returns the first limit items of s.
+func truncate[T any](s []T, limit int32) []T {
+	if int64(len(s)) > int64(limit) {
+		return s[:max(limit, 0)]
+	}
+	return s
+}
+
+func (r memChirps) GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	var c database.Chirp
+	err := r.run(func(d *memData) error {
+		stored, ok := d.chirps[id]
+		if !ok {
+			return ErrNotFound
+		}
+		c = stored.Chirp
+		return nil
+	})
+	return c, err
+}
+
+func (r memChirps) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return r.run(func(d *memData) error {
+		d.deleteChirp(id)
+		return nil
+	})
+}
+
+// deleteChirp removes a chirp and its reactions.
+func (d *memData) deleteChirp(id uuid.UUID) {
+	delete(d.chirps, id)
+	for reaction := range d.reactions {
+		if reaction.ChirpID == id {
+			delete(d.reactions, reaction)
+		}
+	}
+}
+
+type memRefreshTokens struct{ run runner }
+
+func (r memRefreshTokens) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	return r.run(func(d *memData) error {
+		if _, ok := d.users[arg.UserID]; !ok {
+			return ErrInvalidReference
+		}
+		if _, ok := d.refreshTokens[arg.Token]; ok {
+			return ErrConflict
+		}
+		t := now()
+		d.refreshTokens[arg.Token] = database.RefreshToken{
+			Token:     arg.Token,
+			CreatedAt: t,
+			UpdatedAt: t,
+			UserID:    arg.UserID,
+			ExpiresAt: arg.ExpiresAt,
+			RevokedAt: arg.RevokedAt,
+		}
+		return nil
+	})
+}
+
+func (r memRefreshTokens) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	var t database.RefreshToken
+	err := r.run(func(d *memData) error {
+		var ok bool
+		t, ok = d.refreshTokens[token]
+		if !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return t, err
+}
+
+func (r memRefreshTokens) RevokeRefreshToken(ctx context.Context, token string) error {
+	return r.run(func(d *memData) error {
+		t, ok := d.refreshTokens[token]
+		if !ok || t.RevokedAt.Valid {
+			return nil
+		}
+		ts := now()
+		t.RevokedAt = sql.NullTime{Time: ts, Valid: true}
+		t.UpdatedAt = ts
+		d.refreshTokens[token] = t
+		return nil
+	})
+}
+
+func (r memRefreshTokens) ListRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	var tokens []database.RefreshToken
+	err := r.run(func(d *memData) error {
+		for _, t := range d.refreshTokens {
+			if t.UserID == userID {
+				tokens = append(tokens, t)
+			}
+		}
+		return nil
+	})
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.After(tokens[j].CreatedAt) })
+	return tokens, err
+}
+
+func (r memRefreshTokens) RevokeRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var n int64
+	err := r.run(func(d *memData) error {
+		ts := now()
+		for token, t := range d.refreshTokens {
+			if t.UserID == userID && !t.RevokedAt.Valid {
+				t.RevokedAt = sql.NullTime{Time: ts, Valid: true}
+				t.UpdatedAt = ts
+				d.refreshTokens[token] = t
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (r memRefreshTokens) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.run(func(d *memData) error {
+		ts := now()
+		for token, t := range d.refreshTokens {
+			if !t.ExpiresAt.After(ts) || t.RevokedAt.Valid {
+				delete(d.refreshTokens, token)
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+type memEmailChanges struct{ run runner }
+
+func (r memEmailChanges) CreateEmailChangeRequest(ctx context.Context, arg database.CreateEmailChangeRequestParams) (database.EmailChangeRequest, error) {
+	var c database.EmailChangeRequest
+	err := r.run(func(d *memData) error {
+		if _, ok := d.users[arg.UserID]; !ok {
+			return ErrInvalidReference
+		}
+		if _, ok := d.emailChanges[arg.TokenHash]; ok {
+			return ErrConflict
+		}
+		c = database.EmailChangeRequest{
+			TokenHash: arg.TokenHash,
+			CreatedAt: now(),
+			UserID:    arg.UserID,
+			NewEmail:  arg.NewEmail,
+			ExpiresAt: arg.ExpiresAt,
+		}
+		d.emailChanges[arg.TokenHash] = c
+		return nil
+	})
+	return c, err
+}
+
+func (r memEmailChanges) GetEmailChangeRequestForUpdate(ctx context.Context, tokenHash string) (database.EmailChangeRequest, error) {
+	var c database.EmailChangeRequest
+	err := r.run(func(d *memData) error {
+		var ok bool
+		c, ok = d.emailChanges[tokenHash]
+		if !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return c, err
+}
+
+func (r memEmailChanges) DeleteEmailChangeRequestsForUser(ctx context.Context, userID uuid.UUID) error {
+	return r.run(func(d *memData) error {
+		for hash, c := range d.emailChanges {
+			if c.UserID == userID {
+				delete(d.emailChanges, hash)
+			}
+		}
+		return nil
+	})
+}
+
+type memFollows struct{ run runner }
+
+func (r memFollows) FollowUser(ctx context.Context, arg database.FollowUserParams) (bool, error) {
+	created := false
+	err := r.run(func(d *memData) error {
+		_, okFollower := d.users[arg.FollowerID]
+		_, okFollowee := d.users[arg.FolloweeID]
+		if !okFollower || !okFollowee {
+			return ErrInvalidReference
+		}
+		if _, ok := d.follows[arg]; ok {
+			return nil
+		}
+		d.follows[arg] = now()
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+func (r memFollows) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error {
+	return r.run(func(d *memData) error {
+		delete(d.follows, database.FollowUserParams{FollowerID: arg.FollowerID, FolloweeID: arg.FolloweeID})
+		return nil
+	})
+}
+
+func (r memFollows) ListFolloweeIDs(ctx context.Context, followerID uuid.UUID) ([]uuid.UUID, error) {
+	var follows []database.Follow
+	err := r.run(func(d *memData) error {
+		for f, createdAt := range d.follows {
+			if f.FollowerID == followerID {
+				follows = append(follows, database.Follow{FollowerID: f.FollowerID, FolloweeID: f.FolloweeID, CreatedAt: createdAt})
+			}
+		}
+		return nil
+	})
+	sort.Slice(follows, func(i, j int) bool {
+		return newerThan(follows[j].CreatedAt, follows[j].FolloweeID, follows[i].CreatedAt, follows[i].FolloweeID)
+	})
+	var ids []uuid.UUID
+	for _, f := range follows {
+		ids = append(ids, f.FolloweeID)
+	}
+	return ids, err
+}
+
+func (r memFollows) ListFollowees(ctx context.Context, arg database.ListFolloweesParams) ([]database.ListFolloweesRow, error) {
+	var rows []database.ListFolloweesRow
+	err := r.run(func(d *memData) error {
+		for f, createdAt := range d.follows {
+			if f.FollowerID != arg.UserID {
+				continue
+			}
+			if arg.BeforeCreatedAt.Valid && !newerThan(arg.BeforeCreatedAt.Time, arg.BeforeID.UUID, createdAt, f.FolloweeID) {
+				continue
+			}
+			rows = append(rows, database.ListFolloweesRow{FolloweeID: f.FolloweeID, CreatedAt: createdAt})
+		}
+		return nil
+	})
+	sort.Slice(rows, func(i, j int) bool {
+		return newerThan(rows[i].CreatedAt, rows[i].FolloweeID, rows[j].CreatedAt, rows[j].FolloweeID)
+	})
+	return truncate(rows, arg.Limit), err
+}
+
+func (r memFollows) ListFollowers(ctx context.Context, arg database.ListFollowersParams) ([]database.ListFollowersRow, error) {
+	var rows []database.ListFollowersRow
+	err := r.run(func(d *memData) error {
+		for f, createdAt := range d.follows {
+			if f.FolloweeID != arg.UserID {
+				continue
+			}
+			if arg.BeforeCreatedAt.Valid && !newerThan(arg.BeforeCreatedAt.Time, arg.BeforeID.UUID, createdAt, f.FollowerID) {
+				continue
+			}
+			rows = append(rows, database.ListFollowersRow{FollowerID: f.FollowerID, CreatedAt: createdAt})
+		}
+		return nil
+	})
+	sort.Slice(rows, func(i, j int) bool {
+		return newerThan(rows[i].CreatedAt, rows[i].FollowerID, rows[j].CreatedAt, rows[j].FollowerID)
+	})
+	return truncate(rows, arg.Limit), err
+}
+
+type memSubscriptions struct{ run runner }
+
+func (r memSubscriptions) UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (database.Subscription, error) {
+	var s database.Subscription
+	err := r.run(func(d *memData) error {
+		if _, ok := d.users[arg.UserID]; !ok {
+			return ErrInvalidReference
+		}
+		t := now()
+		var ok bool
+		s, ok = d.subscriptions[arg.UserID]
+		if !ok {
+			s = database.Subscription{ID: uuid.New(), CreatedAt: t, UserID: arg.UserID}
+		}
+		s.UpdatedAt = t
+		s.Status = arg.Status
+		s.CurrentPeriodStart = arg.CurrentPeriodStart
+		s.CurrentPeriodEnd = arg.CurrentPeriodEnd
+		d.subscriptions[arg.UserID] = s
+		return nil
+	})
+	return s, err
+}
+
+func (r memSubscriptions) GetSubscriptionByUserID(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	return r.update(userID, func(s *database.Subscription) bool { return false })
+}
+
+func (r memSubscriptions) SetSubscriptionStatus(ctx context.Context, arg database.SetSubscriptionStatusParams) (database.Subscription, error) {
+	return r.update(arg.UserID, func(s *database.Subscription) bool {
+		s.Status = arg.Status
+		return true
+	})
+}
+
+func (r memSubscriptions) EndSubscription(ctx context.Context, arg database.EndSubscriptionParams) (database.Subscription, error) {
+	return r.update(arg.UserID, func(s *database.Subscription) bool {
+		s.Status = arg.Status
+		if t := now(); s.CurrentPeriodEnd.After(t) {
+			s.CurrentPeriodEnd = t
+		}
+		return true
+	})
+}
+
+// update applies fn to userID's subscription, saving it if fn reports a
+// change.
+func (r memSubscriptions) update(userID uuid.UUID, fn func(s *database.Subscription) bool) (database.Subscription, error) {
+	var s database.Subscription
+	err := r.run(func(d *memData) error {
+		var ok bool
+		s, ok = d.subscriptions[userID]
+		if !ok {
+			return ErrNotFound
+		}
+		if fn(&s) {
+			s.UpdatedAt = now()
+			d.subscriptions[userID] = s
+		}
+		return nil
+	})
+	return s, err
+}
+
+func (r memSubscriptions) ExpireSubscriptions(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.run(func(d *memData) error {
+		t := now()
+		for userID, s := range d.subscriptions {
+			if redStatus(s.Status) && !s.CurrentPeriodEnd.After(t) {
+				s.Status = SubscriptionExpired
+				s.UpdatedAt = t
+				d.subscriptions[userID] = s
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (r memSubscriptions) IsUserChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	red := false
+	err := r.run(func(d *memData) error {
+		s, ok := d.subscriptions[userID]
+		red = ok && redStatus(s.Status) && s.CurrentPeriodEnd.After(now())
+		return nil
+	})
+	return red, err
+}
+
+// redStatus reports whether a subscription with status counts towards
+// Chirpy Red while its period lasts.
+func redStatus(status string) bool {
+	return status == SubscriptionActive || status == SubscriptionPastDue
+}
+
+type memEntitlements struct{ run runner }
+
+func (r memEntitlements) GetEntitlementOverride(ctx context.Context, userID uuid.UUID) (database.EntitlementOverride, error) {
+	var o database.EntitlementOverride
+	err := r.run(func(d *memData) error {
+		var ok bool
+		o, ok = d.overrides[userID]
+		if !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return o, err
+}
+
+func (r memEntitlements) UpsertEntitlementOverride(ctx context.Context, arg database.UpsertEntitlementOverrideParams) (database.EntitlementOverride, error) {
+	var o database.EntitlementOverride
+	err := r.run(func(d *memData) error {
+		if _, ok := d.users[arg.UserID]; !ok {
+			return ErrInvalidReference
+		}
+		t := now()
+		createdAt := t
+		if existing, ok := d.overrides[arg.UserID]; ok {
+			createdAt = existing.CreatedAt
+		}
+		o = database.EntitlementOverride{
+			UserID:            arg.UserID,
+			CreatedAt:         createdAt,
+			UpdatedAt:         t,
+			Tier:              arg.Tier,
+			MaxChirpLength:    arg.MaxChirpLength,
+			CanEditChirps:     arg.CanEditChirps,
+			MaxMediaPerChirp:  arg.MaxMediaPerChirp,
+			CanScheduleChirps: arg.CanScheduleChirps,
+			RateLimitTier:     arg.RateLimitTier,
+		}
+		d.overrides[arg.UserID] = o
+		return nil
+	})
+	return o, err
+}
+
+func (r memEntitlements) DeleteEntitlementOverride(ctx context.Context, userID uuid.UUID) error {
+	return r.run(func(d *memData) error {
+		delete(d.overrides, userID)
+		return nil
+	})
+}
+
+type memRoles struct{ run runner }
+
+func (r memRoles) GrantRole(ctx context.Context, arg database.GrantRoleParams) error {
+	return r.run(func(d *memData) error {
+		if _, ok := d.users[arg.UserID]; !ok {
+			return ErrInvalidReference
+		}
+		d.roles[arg] = true
+		return nil
+	})
+}
+
+func (r memRoles) RevokeRole(ctx context.Context, arg database.RevokeRoleParams) error {
+	return r.run(func(d *memData) error {
+		delete(d.roles, database.GrantRoleParams{UserID: arg.UserID, Role: arg.Role})
+		return nil
+	})
+}
+
+func (r memRoles) UserHasRole(ctx context.Context, arg database.UserHasRoleParams) (bool, error) {
+	has := false
+	err := r.run(func(d *memData) error {
+		has = d.roles[database.GrantRoleParams{UserID: arg.UserID, Role: arg.Role}]
+		return nil
+	})
+	return has, err
+}
+
+type memPolkaEvents struct{ run runner }
+
+func (r memPolkaEvents) RecordPolkaEvent(ctx context.Context, arg database.RecordPolkaEventParams) (bool, error) {
+	created := false
+	err := r.run(func(d *memData) error {
+		created = !d.polkaEvents[arg.ID]
+		d.polkaEvents[arg.ID] = true
+		return nil
+	})
+	return created, err
+}
+
+type memReactions struct{ run runner }
+
+func (r memReactions) AddReaction(ctx context.Context, arg database.AddReactionParams) (bool, error) {
+	created := false
+	err := r.run(func(d *memData) error {
+		_, okChirp := d.chirps[arg.ChirpID]
+		_, okUser := d.users[arg.UserID]
+		if !okChirp || !okUser {
+			return ErrInvalidReference
+		}
+		if _, ok := d.reactions[arg]; ok {
+			return nil
+		}
+		d.reactions[arg] = now()
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+func (r memReactions) RemoveReaction(ctx context.Context, arg database.RemoveReactionParams) error {
+	return r.run(func(d *memData) error {
+		delete(d.reactions, database.AddReactionParams(arg))
+		return nil
+	})
+}
+
+func (r memReactions) CountReactions(ctx context.Context, arg database.CountReactionsParams) ([]database.CountReactionsRow, error) {
+	type key struct {
+		chirpID uuid.UUID
+		kind    string
+	}
+	counts := map[key]*database.CountReactionsRow{}
+	err := r.run(func(d *memData) error {
+		for reaction := range d.reactions {
+			if !slices.Contains(arg.ChirpIds, reaction.ChirpID) {
+				continue
+			}
+			k := key{reaction.ChirpID, reaction.Kind}
+			c, ok := counts[k]
+			if !ok {
+				c = &database.CountReactionsRow{ChirpID: reaction.ChirpID, Kind: reaction.Kind}
+				counts[k] = c
+			}
+			c.Count++
+			if arg.ViewerID.Valid && reaction.UserID == arg.ViewerID.UUID {
+				c.ViewerReacted = true
+			}
+		}
+		return nil
+	})
+	var rows []database.CountReactionsRow
+	for _, c := range counts {
+		rows = append(rows, *c)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if c := bytes.Compare(rows[i].ChirpID[:], rows[j].ChirpID[:]); c != 0 {
+			return c < 0
+		}
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].Kind < rows[j].Kind
+	})
+	return rows, err
+}
+
+type memOutbox struct{ run runner }
+
+func (r memOutbox) InsertOutboxEvent(ctx context.Context, arg database.InsertOutboxEventParams) (database.OutboxEvent, error) {
+	var e database.OutboxEvent
+	err := r.run(func(d *memData) error {
+		e = database.OutboxEvent{
+			ID:        uuid.New(),
+			CreatedAt: now(),
+			EventType: arg.EventType,
+			UserID:    arg.UserID,
+			Payload:   append([]byte(nil), arg.Payload...),
+		}
+		d.outbox = append(d.outbox, e)
+		return nil
+	})
+	return e, err
+}
+
+func (r memOutbox) GetOutboxEvent(ctx context.Context, id uuid.UUID) (database.OutboxEvent, error) {
+	var e database.OutboxEvent
+	err := r.run(func(d *memData) error {
+		for _, event := range d.outbox {
+			if event.ID == id {
+				e = event
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return e, err
+}
+
+func (r memOutbox) ClaimOutboxEvents(ctx context.Context, limit int32) ([]database.OutboxEvent, error) {
+	var events []database.OutboxEvent
+	err := r.run(func(d *memData) error {
+		for _, e := range d.outbox {
+			if !e.ProcessedAt.Valid {
+				events = append(events, e)
+			}
+		}
+		return nil
+	})
+	return truncate(events, limit), err
+}
+
+func (r memOutbox) MarkOutboxEventProcessed(ctx context.Context, id uuid.UUID) error {
+	return r.run(func(d *memData) error {
+		for i := range d.outbox {
+			if d.outbox[i].ID == id {
+				d.outbox[i].ProcessedAt = sql.NullTime{Time: now(), Valid: true}
+			}
+		}
+		return nil
+	})
+}
+
+type memWebhooks struct{ run runner }
+
+func (r memWebhooks) CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	var s database.WebhookSubscription
+	err := r.run(func(d *memData) error {
+		if _, ok := d.users[arg.UserID]; !ok {
+			return ErrInvalidReference
+		}
+		t := now()
+		s = database.WebhookSubscription{
+			ID:        uuid.New(),
+			CreatedAt: t,
+			UpdatedAt: t,
+			UserID:    arg.UserID,
+			Url:       arg.Url,
+			Secret:    arg.Secret,
+			Events:    append([]string(nil), arg.Events...),
+		}
+		d.webhooks[s.ID] = s
+		return nil
+	})
+	return s, err
+}
+
+func (r memWebhooks) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (database.WebhookSubscription, error) {
+	var s database.WebhookSubscription
+	err := r.run(func(d *memData) error {
+		var ok bool
+		s, ok = d.webhooks[id]
+		if !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return s, err
+}
+
+func (r memWebhooks) ListWebhookSubscriptionsForUser(ctx context.Context, userID uuid.UUID) ([]database.WebhookSubscription, error) {
+	return r.list(func(s database.WebhookSubscription) bool { return s.UserID == userID })
+}
+
+func (r memWebhooks) ListWebhookSubscriptionsForEvent(ctx context.Context, arg database.ListWebhookSubscriptionsForEventParams) ([]database.WebhookSubscription, error) {
+	return r.list(func(s database.WebhookSubscription) bool {
+		return s.UserID == arg.UserID && slices.Contains(s.Events, arg.EventType)
+	})
+}
+
+func (r memWebhooks) list(keep func(database.WebhookSubscription) bool) ([]database.WebhookSubscription, error) {
+	var subs []database.WebhookSubscription
+	err := r.run(func(d *memData) error {
+		for _, s := range d.webhooks {
+			if keep(s) {
+				subs = append(subs, s)
+			}
+		}
+		return nil
+	})
+	sort.Slice(subs, func(i, j int) bool {
+		return newerThan(subs[j].CreatedAt, subs[j].ID, subs[i].CreatedAt, subs[i].ID)
+	})
+	return subs, err
+}
+
+func (r memWebhooks) DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) error {
+	return r.run(func(d *memData) error {
+		if s, ok := d.webhooks[arg.ID]; !ok || s.UserID != arg.UserID {
+			return ErrNotFound
+		}
+		d.deleteWebhook(arg.ID)
+		return nil
+	})
+}
+
+// deleteWebhook removes a subscription and cascades to its deliveries.
+func (d *memData) deleteWebhook(id uuid.UUID) {
+	delete(d.webhooks, id)
+	for deliveryID, delivery := range d.deliveries {
+		if delivery.SubscriptionID == id {
+			delete(d.deliveries, deliveryID)
+		}
+	}
+}
+
+func (r memWebhooks) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) error {
+	return r.run(func(d *memData) error {
+		if _, ok := d.webhooks[arg.SubscriptionID]; !ok {
+			return ErrInvalidReference
+		}
+		t := now()
+		id := uuid.New()
+		d.deliveries[id] = database.WebhookDelivery{
+			ID:             id,
+			CreatedAt:      t,
+			UpdatedAt:      t,
+			SubscriptionID: arg.SubscriptionID,
+			EventID:        arg.EventID,
+			Status:         "pending",
+			NextAttemptAt:  t,
+		}
+		return nil
+	})
+}
+
+func (r memWebhooks) ClaimDueWebhookDeliveries(ctx context.Context, arg database.ClaimDueWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	var due []database.WebhookDelivery
+	err := r.run(func(d *memData) error {
+		t := now()
+		for _, delivery := range d.deliveries {
+			if delivery.Status == "pending" && !delivery.NextAttemptAt.After(t) {
+				due = append(due, delivery)
+			}
+		}
+		sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+		due = truncate(due, arg.BatchSize)
+		for i := range due {
+			due[i].NextAttemptAt = arg.LeaseUntil
+			due[i].UpdatedAt = t
+			d.deliveries[due[i].ID] = due[i]
+		}
+		return nil
+	})
+	return due, err
+}
+
+func (r memWebhooks) MarkWebhookDeliverySucceeded(ctx context.Context, arg database.MarkWebhookDeliverySucceededParams) error {
+	return r.updateDelivery(arg.ID, func(delivery *database.WebhookDelivery, t time.Time) {
+		delivery.Status = "succeeded"
+		delivery.LastStatusCode = arg.LastStatusCode
+		delivery.LastError = sql.NullString{}
+		delivery.DeliveredAt = sql.NullTime{Time: t, Valid: true}
+	})
+}
+
+func (r memWebhooks) MarkWebhookDeliveryFailed(ctx context.Context, arg database.MarkWebhookDeliveryFailedParams) error {
+	return r.updateDelivery(arg.ID, func(delivery *database.WebhookDelivery, t time.Time) {
+		delivery.Status = arg.Status
+		delivery.NextAttemptAt = arg.NextAttemptAt
+		delivery.LastStatusCode = arg.LastStatusCode
+		delivery.LastError = arg.LastError
+	})
+}
+
+// updateDelivery records an attempt at delivery id, like the Mark queries.
+func (r memWebhooks) updateDelivery(id uuid.UUID, fn func(delivery *database.WebhookDelivery, t time.Time)) error {
+	return r.run(func(d *memData) error {
+		delivery, ok := d.deliveries[id]
+		if !ok {
+			return nil
+		}
+		t := now()
+		fn(&delivery, t)
+		delivery.Attempts++
+		delivery.UpdatedAt = t
+		d.deliveries[id] = delivery
+		return nil
+	})
+}
+
+func (r memWebhooks) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	var deliveries []database.WebhookDelivery
+	err := r.run(func(d *memData) error {
+		for _, delivery := range d.deliveries {
+			if delivery.SubscriptionID == arg.SubscriptionID {
+				deliveries = append(deliveries, delivery)
+			}
+		}
+		return nil
+	})
+	sort.Slice(deliveries, func(i, j int) bool {
+		return newerThan(deliveries[i].CreatedAt, deliveries[i].ID, deliveries[j].CreatedAt, deliveries[j].ID)
+	})
+	return truncate(deliveries, arg.Limit), err
+}
+
+func (r memWebhooks) ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	var delivery database.WebhookDelivery
+	err := r.run(func(d *memData) error {
+		var ok bool
+		delivery, ok = d.deliveries[id]
+		if !ok {
+			return ErrNotFound
+		}
+		t := now()
+		delivery.Status = "pending"
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = t
+		delivery.LastStatusCode = sql.NullInt32{}
+		delivery.LastError = sql.NullString{}
+		delivery.DeliveredAt = sql.NullTime{}
+		delivery.UpdatedAt = t
+		d.deliveries[id] = delivery
+		return nil
+	})
+	return delivery, err
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"example.com/chirpy/internal/tracing"
+	"github.com/lib/pq"
+)
+
+// Open picks a backend from the DB URL scheme, postgres:// or
+// postgresql:// for Postgres and sqlite:<path> for SQLite, and returns a
+// Store on it along with the traced connection pool, which the caller
+// must close.
+func Open(dbURL string) (Store, *sql.DB, error) {
+	scheme, rest, ok := strings.Cut(dbURL, ":")
+	if !ok {
+		return nil, nil, errors.New("DB_URL must start with postgres:// or sqlite:")
+	}
+
+	switch scheme {
+	case "postgres", "postgresql":
+		connector, err := pq.NewConnector(dbURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		db := sql.OpenDB(tracing.WrapConnector(connector, "postgresql"))
+		return NewPostgres(db), db, nil
+
+	case "sqlite":
+		// sql.Open is only used to look up the registered driver
+		probe, err := sql.Open("sqlite", "")
+		if err != nil {
+			return nil, nil, err
+		}
+		drv := probe.Driver()
+		probe.Close()
+		db := sql.OpenDB(tracing.WrapConnector(tracing.DSNConnector(drv, sqliteDSN(rest)), "sqlite"))
+		return NewSQLite(db), db, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported DB_URL scheme %q", scheme)
+}
+
+// sqliteDSN turns the part of a sqlite: URL after the scheme into a DSN
+// with foreign keys enabled, which SQLite leaves off by default.
+func sqliteDSN(path string) string {
+	path = strings.TrimPrefix(path, "//")
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+}
@@ -0,0 +1,468 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"example.com/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Postgres is a Store backed by the sqlc queries.
+type Postgres struct {
+	db *sql.DB
+	tx *sql.Tx // set instead of db inside WithTx
+	q  *database.Queries
+}
+
+// NewPostgres -
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db, q: database.New(db)}
+}
+
+// Users -
+func (p *Postgres) Users() UserRepository { return pgUsers{p.q} }
+
+// Chirps -
+func (p *Postgres) Chirps() ChirpRepository { return pgChirps{p.q} }
+
+// RefreshTokens -
+func (p *Postgres) RefreshTokens() RefreshTokenRepository { return pgRefreshTokens{p.q} }
+
+// EmailChanges -
+func (p *Postgres) EmailChanges() EmailChangeRepository { return pgEmailChanges{p.q} }
+
+// Follows -
+func (p *Postgres) Follows() FollowRepository { return pgFollows{p.q} }
+
+// Reactions -
+func (p *Postgres) Reactions() ReactionRepository { return pgReactions{p.q} }
+
+// Subscriptions -
+func (p *Postgres) Subscriptions() SubscriptionRepository { return pgSubscriptions{p.q} }
+
+// Entitlements -
+func (p *Postgres) Entitlements() EntitlementRepository { return pgEntitlements{p.q} }
+
+// Roles -
+func (p *Postgres) Roles() RoleRepository { return pgRoles{p.q} }
+
+// PolkaEvents -
+func (p *Postgres) PolkaEvents() PolkaEventRepository { return pgPolkaEvents{p.q} }
+
+// Outbox -
+func (p *Postgres) Outbox() OutboxRepository { return pgOutbox{p.q} }
+
+// Webhooks -
+func (p *Postgres) Webhooks() WebhookRepository { return pgWebhooks{p.q} }
+
+// Reset truncates every table in the current schema except goose's.
+// The tables are read from the catalog so new ones are covered without
+// changes here.
+func (p *Postgres) Reset(ctx context.Context) error {
+	return p.WithTx(ctx, func(s Store) error {
+		tx := s.(*Postgres).tx
+		rows, err := tx.QueryContext(ctx, `SELECT quote_ident(tablename) FROM pg_tables
+WHERE schemaname = current_schema() AND tablename <> 'goose_db_version'
+ORDER BY tablename`)
+		if err != nil {
+			return err
+		}
+		tables, err := scanStrings(rows)
+		if err != nil || len(tables) == 0 {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" RESTART IDENTITY CASCADE")
+		return err
+	})
+}
+
+// WithTx -
+func (p *Postgres) WithTx(ctx context.Context, fn func(Store) error) error {
+	if p.db == nil {
+		// Already inside a transaction; nest by reusing it
+		return fn(p)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&Postgres{tx: tx, q: p.q.WithTx(tx)}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// mapError translates driver errors into the store's sentinel errors.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return ErrConflict
+		case "23503":
+			return ErrInvalidReference
+		}
+	}
+	return err
+}
+
+type pgUsers struct{ q *database.Queries }
+
+func (r pgUsers) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	u, err := r.q.CreateUser(ctx, arg)
+	return u, mapError(err)
+}
+
+func (r pgUsers) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	u, err := r.q.GetUserByID(ctx, id)
+	return u, mapError(err)
+}
+
+func (r pgUsers) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	u, err := r.q.GetUserByEmail(ctx, email)
+	return u, mapError(err)
+}
+
+func (r pgUsers) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]database.User, error) {
+	u, err := r.q.GetUsersByIDs(ctx, ids)
+	return u, mapError(err)
+}
+
+func (r pgUsers) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	u, err := r.q.UpdateUser(ctx, arg)
+	return u, mapError(err)
+}
+
+func (r pgUsers) UpdateUserEmail(ctx context.Context, arg database.UpdateUserEmailParams) (database.User, error) {
+	u, err := r.q.UpdateUserEmail(ctx, arg)
+	return u, mapError(err)
+}
+
+func (r pgUsers) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	u, err := r.q.UpdateUserPassword(ctx, arg)
+	return u, mapError(err)
+}
+
+func (r pgUsers) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	n, err := r.q.DeleteUser(ctx, id)
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r pgUsers) DeleteAllUsers(ctx context.Context) error {
+	return mapError(r.q.DeleteAllUsers(ctx))
+}
+
+type pgChirps struct{ q *database.Queries }
+
+func (r pgChirps) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	c, err := r.q.CreateChirp(ctx, arg)
+	return c, mapError(err)
+}
+
+func (r pgChirps) GetChirps(ctx context.Context) ([]database.Chirp, error) {
+	c, err := r.q.GetChirps(ctx)
+	return c, mapError(err)
+}
+
+func (r pgChirps) GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	c, err := r.q.GetChirpByID(ctx, id)
+	return c, mapError(err)
+}
+
+func (r pgChirps) GetChirpsByAuthorID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	c, err := r.q.GetChirpsByAuthorID(ctx, userID)
+	return c, mapError(err)
+}
+
+func (r pgChirps) ListChirps(ctx context.Context, arg database.ListChirpsParams) ([]database.Chirp, error) {
+	c, err := r.q.ListChirps(ctx, arg)
+	return c, mapError(err)
+}
+
+func (r pgChirps) ListChirpsOldestFirst(ctx context.Context, arg database.ListChirpsOldestFirstParams) ([]database.Chirp, error) {
+	c, err := r.q.ListChirpsOldestFirst(ctx, arg)
+	return c, mapError(err)
+}
+
+func (r pgChirps) ListTimelineChirps(ctx context.Context, arg database.ListTimelineChirpsParams) ([]database.Chirp, error) {
+	c, err := r.q.ListTimelineChirps(ctx, arg)
+	return c, mapError(err)
+}
+
+func (r pgChirps) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return mapError(r.q.DeleteChirp(ctx, id))
+}
+
+type pgRefreshTokens struct{ q *database.Queries }
+
+func (r pgRefreshTokens) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	return mapError(r.q.CreateRefreshToken(ctx, arg))
+}
+
+func (r pgRefreshTokens) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	t, err := r.q.GetRefreshToken(ctx, token)
+	return t, mapError(err)
+}
+
+func (r pgRefreshTokens) RevokeRefreshToken(ctx context.Context, token string) error {
+	return mapError(r.q.RevokeRefreshToken(ctx, token))
+}
+
+func (r pgRefreshTokens) ListRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	t, err := r.q.ListRefreshTokensForUser(ctx, userID)
+	return t, mapError(err)
+}
+
+func (r pgRefreshTokens) RevokeRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	n, err := r.q.RevokeRefreshTokensForUser(ctx, userID)
+	return n, mapError(err)
+}
+
+func (r pgRefreshTokens) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	n, err := r.q.DeleteExpiredRefreshTokens(ctx)
+	return n, mapError(err)
+}
+
+type pgEmailChanges struct{ q *database.Queries }
+
+func (r pgEmailChanges) CreateEmailChangeRequest(ctx context.Context, arg database.CreateEmailChangeRequestParams) (database.EmailChangeRequest, error) {
+	c, err := r.q.CreateEmailChangeRequest(ctx, arg)
+	return c, mapError(err)
+}
+
+func (r pgEmailChanges) GetEmailChangeRequestForUpdate(ctx context.Context, tokenHash string) (database.EmailChangeRequest, error) {
+	c, err := r.q.GetEmailChangeRequestForUpdate(ctx, tokenHash)
+	return c, mapError(err)
+}
+
+func (r pgEmailChanges) DeleteEmailChangeRequestsForUser(ctx context.Context, userID uuid.UUID) error {
+	return mapError(r.q.DeleteEmailChangeRequestsForUser(ctx, userID))
+}
+
+type pgFollows struct{ q *database.Queries }
+
+func (r pgFollows) FollowUser(ctx context.Context, arg database.FollowUserParams) (bool, error) {
+	n, err := r.q.FollowUser(ctx, arg)
+	return n > 0, mapError(err)
+}
+
+func (r pgFollows) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error {
+	_, err := r.q.UnfollowUser(ctx, arg)
+	return mapError(err)
+}
+
+func (r pgFollows) ListFolloweeIDs(ctx context.Context, followerID uuid.UUID) ([]uuid.UUID, error) {
+	ids, err := r.q.ListFolloweeIDs(ctx, followerID)
+	return ids, mapError(err)
+}
+
+func (r pgFollows) ListFollowees(ctx context.Context, arg database.ListFolloweesParams) ([]database.ListFolloweesRow, error) {
+	f, err := r.q.ListFollowees(ctx, arg)
+	return f, mapError(err)
+}
+
+func (r pgFollows) ListFollowers(ctx context.Context, arg database.ListFollowersParams) ([]database.ListFollowersRow, error) {
+	f, err := r.q.ListFollowers(ctx, arg)
+	return f, mapError(err)
+}
+
+type pgReactions struct{ q *database.Queries }
+
+func (r pgReactions) AddReaction(ctx context.Context, arg database.AddReactionParams) (bool, error) {
+	n, err := r.q.AddReaction(ctx, arg)
+	return n > 0, mapError(err)
+}
+
+func (r pgReactions) RemoveReaction(ctx context.Context, arg database.RemoveReactionParams) error {
+	_, err := r.q.RemoveReaction(ctx, arg)
+	return mapError(err)
+}
+
+func (r pgReactions) CountReactions(ctx context.Context, arg database.CountReactionsParams) ([]database.CountReactionsRow, error) {
+	counts, err := r.q.CountReactions(ctx, arg)
+	return counts, mapError(err)
+}
+
+type pgSubscriptions struct{ q *database.Queries }
+
+func (r pgSubscriptions) UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (database.Subscription, error) {
+	s, err := r.q.UpsertSubscription(ctx, arg)
+	return s, mapError(err)
+}
+
+func (r pgSubscriptions) GetSubscriptionByUserID(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	s, err := r.q.GetSubscriptionByUserID(ctx, userID)
+	return s, mapError(err)
+}
+
+func (r pgSubscriptions) SetSubscriptionStatus(ctx context.Context, arg database.SetSubscriptionStatusParams) (database.Subscription, error) {
+	s, err := r.q.SetSubscriptionStatus(ctx, arg)
+	return s, mapError(err)
+}
+
+func (r pgSubscriptions) EndSubscription(ctx context.Context, arg database.EndSubscriptionParams) (database.Subscription, error) {
+	s, err := r.q.EndSubscription(ctx, arg)
+	return s, mapError(err)
+}
+
+func (r pgSubscriptions) ExpireSubscriptions(ctx context.Context) (int64, error) {
+	n, err := r.q.ExpireSubscriptions(ctx)
+	return n, mapError(err)
+}
+
+func (r pgSubscriptions) IsUserChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	red, err := r.q.IsUserChirpyRed(ctx, userID)
+	return red, mapError(err)
+}
+
+type pgEntitlements struct{ q *database.Queries }
+
+func (r pgEntitlements) GetEntitlementOverride(ctx context.Context, userID uuid.UUID) (database.EntitlementOverride, error) {
+	o, err := r.q.GetEntitlementOverride(ctx, userID)
+	return o, mapError(err)
+}
+
+func (r pgEntitlements) UpsertEntitlementOverride(ctx context.Context, arg database.UpsertEntitlementOverrideParams) (database.EntitlementOverride, error) {
+	o, err := r.q.UpsertEntitlementOverride(ctx, arg)
+	return o, mapError(err)
+}
+
+func (r pgEntitlements) DeleteEntitlementOverride(ctx context.Context, userID uuid.UUID) error {
+	return mapError(r.q.DeleteEntitlementOverride(ctx, userID))
+}
+
+type pgRoles struct{ q *database.Queries }
+
+func (r pgRoles) GrantRole(ctx context.Context, arg database.GrantRoleParams) error {
+	return mapError(r.q.GrantRole(ctx, arg))
+}
+
+func (r pgRoles) RevokeRole(ctx context.Context, arg database.RevokeRoleParams) error {
+	return mapError(r.q.RevokeRole(ctx, arg))
+}
+
+func (r pgRoles) UserHasRole(ctx context.Context, arg database.UserHasRoleParams) (bool, error) {
+	ok, err := r.q.UserHasRole(ctx, arg)
+	return ok, mapError(err)
+}
+
+type pgPolkaEvents struct{ q *database.Queries }
+
+func (r pgPolkaEvents) RecordPolkaEvent(ctx context.Context, arg database.RecordPolkaEventParams) (bool, error) {
+	n, err := r.q.RecordPolkaEvent(ctx, arg)
+	return n > 0, mapError(err)
+}
+
+type pgOutbox struct{ q *database.Queries }
+
+func (r pgOutbox) InsertOutboxEvent(ctx context.Context, arg database.InsertOutboxEventParams) (database.OutboxEvent, error) {
+	e, err := r.q.InsertOutboxEvent(ctx, arg)
+	return e, mapError(err)
+}
+
+func (r pgOutbox) GetOutboxEvent(ctx context.Context, id uuid.UUID) (database.OutboxEvent, error) {
+	e, err := r.q.GetOutboxEvent(ctx, id)
+	return e, mapError(err)
+}
+
+func (r pgOutbox) ClaimOutboxEvents(ctx context.Context, limit int32) ([]database.OutboxEvent, error) {
+	e, err := r.q.ClaimOutboxEvents(ctx, limit)
+	return e, mapError(err)
+}
+
+func (r pgOutbox) MarkOutboxEventProcessed(ctx context.Context, id uuid.UUID) error {
+	return mapError(r.q.MarkOutboxEventProcessed(ctx, id))
+}
+
+type pgWebhooks struct{ q *database.Queries }
+
+func (r pgWebhooks) CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	s, err := r.q.CreateWebhookSubscription(ctx, arg)
+	return s, mapError(err)
+}
+
+func (r pgWebhooks) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (database.WebhookSubscription, error) {
+	s, err := r.q.GetWebhookSubscription(ctx, id)
+	return s, mapError(err)
+}
+
+func (r pgWebhooks) ListWebhookSubscriptionsForUser(ctx context.Context, userID uuid.UUID) ([]database.WebhookSubscription, error) {
+	s, err := r.q.ListWebhookSubscriptionsForUser(ctx, userID)
+	return s, mapError(err)
+}
+
+func (r pgWebhooks) ListWebhookSubscriptionsForEvent(ctx context.Context, arg database.ListWebhookSubscriptionsForEventParams) ([]database.WebhookSubscription, error) {
+	s, err := r.q.ListWebhookSubscriptionsForEvent(ctx, arg)
+	return s, mapError(err)
+}
+
+func (r pgWebhooks) DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) error {
+	n, err := r.q.DeleteWebhookSubscription(ctx, arg)
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r pgWebhooks) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) error {
+	return mapError(r.q.CreateWebhookDelivery(ctx, arg))
+}
+
+func (r pgWebhooks) ClaimDueWebhookDeliveries(ctx context.Context, arg database.ClaimDueWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	d, err := r.q.ClaimDueWebhookDeliveries(ctx, arg)
+	return d, mapError(err)
+}
+
+func (r pgWebhooks) MarkWebhookDeliverySucceeded(ctx context.Context, arg database.MarkWebhookDeliverySucceededParams) error {
+	return mapError(r.q.MarkWebhookDeliverySucceeded(ctx, arg))
+}
+
+func (r pgWebhooks) MarkWebhookDeliveryFailed(ctx context.Context, arg database.MarkWebhookDeliveryFailedParams) error {
+	return mapError(r.q.MarkWebhookDeliveryFailed(ctx, arg))
+}
+
+func (r pgWebhooks) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	d, err := r.q.ListWebhookDeliveries(ctx, arg)
+	return d, mapError(err)
+}
+
+func (r pgWebhooks) ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	d, err := r.q.ReplayWebhookDelivery(ctx, id)
+	return d, mapError(err)
+}
+
+// scanStrings reads a single text column from every row and closes rows.
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,824 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/sqlitedb"
+	"github.com/google/uuid"
+)
+
+// SQLite is a Store for single-node deployments. It generates IDs and
+// timestamps in Go, since SQLite has no gen_random_uuid() or now().
+type SQLite struct {
+	db *sql.DB
+	tx *sql.Tx // set instead of db inside WithTx
+	q  *sqlitedb.Queries
+}
+
+// NewSQLite -
+func NewSQLite(db *sql.DB) *SQLite {
+	return &SQLite{db: db, q: sqlitedb.New(db)}
+}
+
+// Users -
+func (s *SQLite) Users() UserRepository { return sqliteUsers{s.q} }
+
+// Chirps -
+func (s *SQLite) Chirps() ChirpRepository { return sqliteChirps{s.q} }
+
+// RefreshTokens -
+func (s *SQLite) RefreshTokens() RefreshTokenRepository { return sqliteRefreshTokens{s.q} }
+
+// EmailChanges -
+func (s *SQLite) EmailChanges() EmailChangeRepository { return sqliteEmailChanges{s.q} }
+
+// Follows -
+func (s *SQLite) Follows() FollowRepository { return sqliteFollows{s.q} }
+
+// Subscriptions -
+func (s *SQLite) Subscriptions() SubscriptionRepository { return sqliteSubscriptions{s.q} }
+
+// Entitlements -
+func (s *SQLite) Entitlements() EntitlementRepository { return sqliteEntitlements{s.q} }
+
+// Roles -
+func (s *SQLite) Roles() RoleRepository { return sqliteRoles{s.q} }
+
+// PolkaEvents -
+func (s *SQLite) PolkaEvents() PolkaEventRepository { return sqlitePolkaEvents{s.q} }
+
+// Reactions -
+func (s *SQLite) Reactions() ReactionRepository { return sqliteReactions{s.q} }
+
+// Outbox -
+func (s *SQLite) Outbox() OutboxRepository { return sqliteOutbox{s.q} }
+
+// Webhooks -
+func (s *SQLite) Webhooks() WebhookRepository { return sqliteWebhooks{s.q} }
+
+// Reset deletes every row from every table except goose's. Foreign keys
+// are checked at commit, by which time every table is empty, so the order
+// of the deletes doesn't matter.
+func (s *SQLite) Reset(ctx context.Context) error {
+	return s.WithTx(ctx, func(st Store) error {
+		tx := st.(*SQLite).tx
+		rows, err := tx.QueryContext(ctx, `SELECT name FROM sqlite_master
+WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\' AND name <> 'goose_db_version'
+ORDER BY name`)
+		if err != nil {
+			return err
+		}
+		tables, err := scanStrings(rows)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+			return err
+		}
+		for _, table := range tables {
+			quoted := `"` + strings.ReplaceAll(table, `"`, `""`) + `"`
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+quoted); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WithTx -
+func (s *SQLite) WithTx(ctx context.Context, fn func(Store) error) error {
+	if s.db == nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQLite{tx: tx, q: s.q.WithTx(tx)}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// mapSQLiteError translates SQLite errors into the store's sentinel errors.
+// It matches on the message so it works with any SQLite driver.
+func mapSQLiteError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "UNIQUE constraint failed"),
+		strings.Contains(msg, "PRIMARY KEY constraint failed"):
+		return ErrConflict
+	case strings.Contains(msg, "FOREIGN KEY constraint failed"):
+		return ErrInvalidReference
+	}
+	return err
+}
+
+func fromSQLiteUser(u sqlitedb.User, err error) (database.User, error) {
+	return database.User{
+		ID:             u.ID,
+		CreatedAt:      u.CreatedAt,
+		UpdatedAt:      u.UpdatedAt,
+		Email:          u.Email,
+		HashedPassword: u.HashedPassword,
+	}, mapSQLiteError(err)
+}
+
+func fromSQLiteChirp(c sqlitedb.Chirp) database.Chirp {
+	return database.Chirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserID:    c.UserID,
+	}
+}
+
+func fromSQLiteChirps(rows []sqlitedb.Chirp, err error) ([]database.Chirp, error) {
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	var chirps []database.Chirp
+	for _, c := range rows {
+		chirps = append(chirps, fromSQLiteChirp(c))
+	}
+	return chirps, nil
+}
+
+type sqliteUsers struct{ q *sqlitedb.Queries }
+
+func (r sqliteUsers) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	t := now()
+	return fromSQLiteUser(r.q.CreateUser(ctx, sqlitedb.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      t,
+		UpdatedAt:      t,
+		HashedPassword: arg.HashedPassword,
+		Email:          arg.Email,
+	}))
+}
+
+func (r sqliteUsers) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return fromSQLiteUser(r.q.GetUserByID(ctx, id))
+}
+
+func (r sqliteUsers) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	return fromSQLiteUser(r.q.GetUserByEmail(ctx, email))
+}
+
+func (r sqliteUsers) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]database.User, error) {
+	rows, err := r.q.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	var users []database.User
+	for _, u := range rows {
+		user, _ := fromSQLiteUser(u, nil)
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (r sqliteUsers) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error) {
+	return fromSQLiteUser(r.q.UpdateUser(ctx, sqlitedb.UpdateUserParams{
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		UpdatedAt:      now(),
+		ID:             arg.ID,
+	}))
+}
+
+func (r sqliteUsers) UpdateUserEmail(ctx context.Context, arg database.UpdateUserEmailParams) (database.User, error) {
+	return fromSQLiteUser(r.q.UpdateUserEmail(ctx, sqlitedb.UpdateUserEmailParams{
+		Email:     arg.Email,
+		UpdatedAt: now(),
+		ID:        arg.ID,
+	}))
+}
+
+func (r sqliteUsers) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	return fromSQLiteUser(r.q.UpdateUserPassword(ctx, sqlitedb.UpdateUserPasswordParams{
+		HashedPassword: arg.HashedPassword,
+		UpdatedAt:      now(),
+		ID:             arg.ID,
+	}))
+}
+
+func (r sqliteUsers) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	n, err := r.q.DeleteUser(ctx, id)
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r sqliteUsers) DeleteAllUsers(ctx context.Context) error {
+	return mapSQLiteError(r.q.DeleteAllUsers(ctx))
+}
+
+type sqliteChirps struct{ q *sqlitedb.Queries }
+
+func (r sqliteChirps) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	t := now()
+	c, err := r.q.CreateChirp(ctx, sqlitedb.CreateChirpParams{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+	})
+	return fromSQLiteChirp(c), mapSQLiteError(err)
+}
+
+func (r sqliteChirps) GetChirps(ctx context.Context) ([]database.Chirp, error) {
+	return fromSQLiteChirps(r.q.GetChirps(ctx))
+}
+
+func (r sqliteChirps) GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	c, err := r.q.GetChirpByID(ctx, id)
+	return fromSQLiteChirp(c), mapSQLiteError(err)
+}
+
+func (r sqliteChirps) GetChirpsByAuthorID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return fromSQLiteChirps(r.q.GetChirpsByAuthorID(ctx, userID))
+}
+
+func (r sqliteChirps) ListChirps(ctx context.Context, arg database.ListChirpsParams) ([]database.Chirp, error) {
+	return fromSQLiteChirps(r.q.ListChirps(ctx, sqlitedb.ListChirpsParams{
+		UserID:          arg.UserID,
+		BeforeCreatedAt: arg.BeforeCreatedAt,
+		BeforeID:        arg.BeforeID,
+		Limit:           int64(arg.Limit),
+	}))
+}
+
+func (r sqliteChirps) ListChirpsOldestFirst(ctx context.Context, arg database.ListChirpsOldestFirstParams) ([]database.Chirp, error) {
+	return fromSQLiteChirps(r.q.ListChirpsOldestFirst(ctx, sqlitedb.ListChirpsOldestFirstParams{
+		UserID:         arg.UserID,
+		AfterCreatedAt: arg.AfterCreatedAt,
+		AfterID:        arg.AfterID,
+		Limit:          int64(arg.Limit),
+	}))
+}
+
+func (r sqliteChirps) ListTimelineChirps(ctx context.Context, arg database.ListTimelineChirpsParams) ([]database.Chirp, error) {
+	return fromSQLiteChirps(r.q.ListTimelineChirps(ctx, sqlitedb.ListTimelineChirpsParams{
+		UserID:          arg.UserID,
+		BeforeCreatedAt: arg.BeforeCreatedAt,
+		BeforeID:        arg.BeforeID,
+		Limit:           int64(arg.Limit),
+	}))
+}
+
+func (r sqliteChirps) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return mapSQLiteError(r.q.DeleteChirp(ctx, id))
+}
+
+type sqliteRefreshTokens struct{ q *sqlitedb.Queries }
+
+func (r sqliteRefreshTokens) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error {
+	t := now()
+	return mapSQLiteError(r.q.CreateRefreshToken(ctx, sqlitedb.CreateRefreshTokenParams{
+		Token:     arg.Token,
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt.UTC(),
+		RevokedAt: arg.RevokedAt,
+	}))
+}
+
+func fromSQLiteRefreshToken(t sqlitedb.RefreshToken) database.RefreshToken {
+	return database.RefreshToken{
+		Token:     t.Token,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		UserID:    t.UserID,
+		ExpiresAt: t.ExpiresAt,
+		RevokedAt: t.RevokedAt,
+	}
+}
+
+func (r sqliteRefreshTokens) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	t, err := r.q.GetRefreshToken(ctx, token)
+	return fromSQLiteRefreshToken(t), mapSQLiteError(err)
+}
+
+func (r sqliteRefreshTokens) RevokeRefreshToken(ctx context.Context, token string) error {
+	return mapSQLiteError(r.q.RevokeRefreshToken(ctx, sqlitedb.RevokeRefreshTokenParams{
+		Now:   sql.NullTime{Time: now(), Valid: true},
+		Token: token,
+	}))
+}
+
+func (r sqliteRefreshTokens) ListRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	rows, err := r.q.ListRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	var tokens []database.RefreshToken
+	for _, t := range rows {
+		tokens = append(tokens, fromSQLiteRefreshToken(t))
+	}
+	return tokens, nil
+}
+
+func (r sqliteRefreshTokens) RevokeRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	n, err := r.q.RevokeRefreshTokensForUser(ctx, sqlitedb.RevokeRefreshTokensForUserParams{
+		Now:    sql.NullTime{Time: now(), Valid: true},
+		UserID: userID,
+	})
+	return n, mapSQLiteError(err)
+}
+
+func (r sqliteRefreshTokens) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	n, err := r.q.DeleteExpiredRefreshTokens(ctx, now())
+	return n, mapSQLiteError(err)
+}
+
+type sqliteEmailChanges struct{ q *sqlitedb.Queries }
+
+func fromSQLiteEmailChange(c sqlitedb.EmailChangeRequest, err error) (database.EmailChangeRequest, error) {
+	return database.EmailChangeRequest{
+		TokenHash: c.TokenHash,
+		CreatedAt: c.CreatedAt,
+		UserID:    c.UserID,
+		NewEmail:  c.NewEmail,
+		ExpiresAt: c.ExpiresAt,
+	}, mapSQLiteError(err)
+}
+
+func (r sqliteEmailChanges) CreateEmailChangeRequest(ctx context.Context, arg database.CreateEmailChangeRequestParams) (database.EmailChangeRequest, error) {
+	return fromSQLiteEmailChange(r.q.CreateEmailChangeRequest(ctx, sqlitedb.CreateEmailChangeRequestParams{
+		TokenHash: arg.TokenHash,
+		CreatedAt: now(),
+		UserID:    arg.UserID,
+		NewEmail:  arg.NewEmail,
+		ExpiresAt: arg.ExpiresAt,
+	}))
+}
+
+// GetEmailChangeRequestForUpdate needs no row lock: SQLite serialises
+// writers for the whole database.
+func (r sqliteEmailChanges) GetEmailChangeRequestForUpdate(ctx context.Context, tokenHash string) (database.EmailChangeRequest, error) {
+	return fromSQLiteEmailChange(r.q.GetEmailChangeRequest(ctx, tokenHash))
+}
+
+func (r sqliteEmailChanges) DeleteEmailChangeRequestsForUser(ctx context.Context, userID uuid.UUID) error {
+	return mapSQLiteError(r.q.DeleteEmailChangeRequestsForUser(ctx, userID))
+}
+
+type sqliteFollows struct{ q *sqlitedb.Queries }
+
+func (r sqliteFollows) FollowUser(ctx context.Context, arg database.FollowUserParams) (bool, error) {
+	n, err := r.q.FollowUser(ctx, sqlitedb.FollowUserParams{
+		FollowerID: arg.FollowerID,
+		FolloweeID: arg.FolloweeID,
+		CreatedAt:  now(),
+	})
+	return n > 0, mapSQLiteError(err)
+}
+
+func (r sqliteFollows) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error {
+	_, err := r.q.UnfollowUser(ctx, sqlitedb.UnfollowUserParams{
+		FollowerID: arg.FollowerID,
+		FolloweeID: arg.FolloweeID,
+	})
+	return mapSQLiteError(err)
+}
+
+func (r sqliteFollows) ListFolloweeIDs(ctx context.Context, followerID uuid.UUID) ([]uuid.UUID, error) {
+	ids, err := r.q.ListFolloweeIDs(ctx, followerID)
+	return ids, mapSQLiteError(err)
+}
+
+func (r sqliteFollows) ListFollowees(ctx context.Context, arg database.ListFolloweesParams) ([]database.ListFolloweesRow, error) {
+	rows, err := r.q.ListFollowees(ctx, sqlitedb.ListFolloweesParams{
+		UserID:          arg.UserID,
+		BeforeCreatedAt: arg.BeforeCreatedAt,
+		BeforeID:        arg.BeforeID,
+		Limit:           int64(arg.Limit),
+	})
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	var followees []database.ListFolloweesRow
+	for _, row := range rows {
+		followees = append(followees, database.ListFolloweesRow(row))
+	}
+	return followees, nil
+}
+
+func (r sqliteFollows) ListFollowers(ctx context.Context, arg database.ListFollowersParams) ([]database.ListFollowersRow, error) {
+	rows, err := r.q.ListFollowers(ctx, sqlitedb.ListFollowersParams{
+		UserID:          arg.UserID,
+		BeforeCreatedAt: arg.BeforeCreatedAt,
+		BeforeID:        arg.BeforeID,
+		Limit:           int64(arg.Limit),
+	})
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	var followers []database.ListFollowersRow
+	for _, row := range rows {
+		followers = append(followers, database.ListFollowersRow(row))
+	}
+	return followers, nil
+}
+
+type sqliteSubscriptions struct{ q *sqlitedb.Queries }
+
+func fromSQLiteSubscription(s sqlitedb.Subscription, err error) (database.Subscription, error) {
+	return database.Subscription(s), mapSQLiteError(err)
+}
+
+func (r sqliteSubscriptions) UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (database.Subscription, error) {
+	t := now()
+	return fromSQLiteSubscription(r.q.UpsertSubscription(ctx, sqlitedb.UpsertSubscriptionParams{
+		ID:                 uuid.New(),
+		CreatedAt:          t,
+		UpdatedAt:          t,
+		UserID:             arg.UserID,
+		Status:             arg.Status,
+		CurrentPeriodStart: arg.CurrentPeriodStart.UTC(),
+		CurrentPeriodEnd:   arg.CurrentPeriodEnd.UTC(),
+	}))
+}
+
+func (r sqliteSubscriptions) GetSubscriptionByUserID(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	return fromSQLiteSubscription(r.q.GetSubscriptionByUserID(ctx, userID))
+}
+
+func (r sqliteSubscriptions) SetSubscriptionStatus(ctx context.Context, arg database.SetSubscriptionStatusParams) (database.Subscription, error) {
+	return fromSQLiteSubscription(r.q.SetSubscriptionStatus(ctx, sqlitedb.SetSubscriptionStatusParams{
+		Status:    arg.Status,
+		UpdatedAt: now(),
+		UserID:    arg.UserID,
+	}))
+}
+
+func (r sqliteSubscriptions) EndSubscription(ctx context.Context, arg database.EndSubscriptionParams) (database.Subscription, error) {
+	return fromSQLiteSubscription(r.q.EndSubscription(ctx, sqlitedb.EndSubscriptionParams{
+		Status: arg.Status,
+		Now:    now(),
+		UserID: arg.UserID,
+	}))
+}
+
+func (r sqliteSubscriptions) ExpireSubscriptions(ctx context.Context) (int64, error) {
+	n, err := r.q.ExpireSubscriptions(ctx, now())
+	return n, mapSQLiteError(err)
+}
+
+func (r sqliteSubscriptions) IsUserChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	red, err := r.q.IsUserChirpyRed(ctx, sqlitedb.IsUserChirpyRedParams{
+		UserID: userID,
+		Now:    now(),
+	})
+	return red, mapSQLiteError(err)
+}
+
+type sqliteEntitlements struct{ q *sqlitedb.Queries }
+
+func fromSQLiteOverride(o sqlitedb.EntitlementOverride, err error) (database.EntitlementOverride, error) {
+	return database.EntitlementOverride{
+		UserID:            o.UserID,
+		CreatedAt:         o.CreatedAt,
+		UpdatedAt:         o.UpdatedAt,
+		Tier:              o.Tier,
+		MaxChirpLength:    nullInt32(o.MaxChirpLength),
+		CanEditChirps:     o.CanEditChirps,
+		MaxMediaPerChirp:  nullInt32(o.MaxMediaPerChirp),
+		CanScheduleChirps: o.CanScheduleChirps,
+		RateLimitTier:     o.RateLimitTier,
+	}, mapSQLiteError(err)
+}
+
+func (r sqliteEntitlements) GetEntitlementOverride(ctx context.Context, userID uuid.UUID) (database.EntitlementOverride, error) {
+	return fromSQLiteOverride(r.q.GetEntitlementOverride(ctx, userID))
+}
+
+func (r sqliteEntitlements) UpsertEntitlementOverride(ctx context.Context, arg database.UpsertEntitlementOverrideParams) (database.EntitlementOverride, error) {
+	t := now()
+	return fromSQLiteOverride(r.q.UpsertEntitlementOverride(ctx, sqlitedb.UpsertEntitlementOverrideParams{
+		UserID:            arg.UserID,
+		CreatedAt:         t,
+		UpdatedAt:         t,
+		Tier:              arg.Tier,
+		MaxChirpLength:    nullInt64(arg.MaxChirpLength),
+		CanEditChirps:     arg.CanEditChirps,
+		MaxMediaPerChirp:  nullInt64(arg.MaxMediaPerChirp),
+		CanScheduleChirps: arg.CanScheduleChirps,
+		RateLimitTier:     arg.RateLimitTier,
+	}))
+}
+
+func (r sqliteEntitlements) DeleteEntitlementOverride(ctx context.Context, userID uuid.UUID) error {
+	return mapSQLiteError(r.q.DeleteEntitlementOverride(ctx, userID))
+}
+
+type sqliteRoles struct{ q *sqlitedb.Queries }
+
+func (r sqliteRoles) GrantRole(ctx context.Context, arg database.GrantRoleParams) error {
+	return mapSQLiteError(r.q.GrantRole(ctx, sqlitedb.GrantRoleParams{
+		UserID:    arg.UserID,
+		Role:      arg.Role,
+		CreatedAt: now(),
+	}))
+}
+
+func (r sqliteRoles) RevokeRole(ctx context.Context, arg database.RevokeRoleParams) error {
+	return mapSQLiteError(r.q.RevokeRole(ctx, sqlitedb.RevokeRoleParams{
+		UserID: arg.UserID,
+		Role:   arg.Role,
+	}))
+}
+
+func (r sqliteRoles) UserHasRole(ctx context.Context, arg database.UserHasRoleParams) (bool, error) {
+	has, err := r.q.UserHasRole(ctx, sqlitedb.UserHasRoleParams{
+		UserID: arg.UserID,
+		Role:   arg.Role,
+	})
+	return has, mapSQLiteError(err)
+}
+
+type sqlitePolkaEvents struct{ q *sqlitedb.Queries }
+
+func (r sqlitePolkaEvents) RecordPolkaEvent(ctx context.Context, arg database.RecordPolkaEventParams) (bool, error) {
+	n, err := r.q.RecordPolkaEvent(ctx, sqlitedb.RecordPolkaEventParams{
+		ID:         arg.ID,
+		Event:      arg.Event,
+		ReceivedAt: now(),
+	})
+	return n > 0, mapSQLiteError(err)
+}
+
+type sqliteReactions struct{ q *sqlitedb.Queries }
+
+func (r sqliteReactions) AddReaction(ctx context.Context, arg database.AddReactionParams) (bool, error) {
+	n, err := r.q.AddReaction(ctx, sqlitedb.AddReactionParams{
+		ChirpID:   arg.ChirpID,
+		UserID:    arg.UserID,
+		Kind:      arg.Kind,
+		CreatedAt: now(),
+	})
+	return n > 0, mapSQLiteError(err)
+}
+
+func (r sqliteReactions) RemoveReaction(ctx context.Context, arg database.RemoveReactionParams) error {
+	_, err := r.q.RemoveReaction(ctx, sqlitedb.RemoveReactionParams(arg))
+	return mapSQLiteError(err)
+}
+
+func (r sqliteReactions) CountReactions(ctx context.Context, arg database.CountReactionsParams) ([]database.CountReactionsRow, error) {
+	rows, err := r.q.CountReactions(ctx, sqlitedb.CountReactionsParams(arg))
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	var counts []database.CountReactionsRow
+	for _, row := range rows {
+		counts = append(counts, database.CountReactionsRow(row))
+	}
+	return counts, nil
+}
+
+type sqliteOutbox struct{ q *sqlitedb.Queries }
+
+func fromSQLiteOutboxEvent(e sqlitedb.OutboxEvent) database.OutboxEvent {
+	return database.OutboxEvent{
+		ID:          e.ID,
+		CreatedAt:   e.CreatedAt,
+		EventType:   e.EventType,
+		UserID:      e.UserID,
+		Payload:     []byte(e.Payload),
+		ProcessedAt: e.ProcessedAt,
+	}
+}
+
+func (r sqliteOutbox) InsertOutboxEvent(ctx context.Context, arg database.InsertOutboxEventParams) (database.OutboxEvent, error) {
+	e, err := r.q.InsertOutboxEvent(ctx, sqlitedb.InsertOutboxEventParams{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		EventType: arg.EventType,
+		UserID:    arg.UserID,
+		Payload:   string(arg.Payload),
+	})
+	return fromSQLiteOutboxEvent(e), mapSQLiteError(err)
+}
+
+func (r sqliteOutbox) GetOutboxEvent(ctx context.Context, id uuid.UUID) (database.OutboxEvent, error) {
+	e, err := r.q.GetOutboxEvent(ctx, id)
+	return fromSQLiteOutboxEvent(e), mapSQLiteError(err)
+}
+
+func (r sqliteOutbox) ClaimOutboxEvents(ctx context.Context, limit int32) ([]database.OutboxEvent, error) {
+	rows, err := r.q.ClaimOutboxEvents(ctx, int64(limit))
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	var events []database.OutboxEvent
+	for _, e := range rows {
+		events = append(events, fromSQLiteOutboxEvent(e))
+	}
+	return events, nil
+}
+
+func (r sqliteOutbox) MarkOutboxEventProcessed(ctx context.Context, id uuid.UUID) error {
+	return mapSQLiteError(r.q.MarkOutboxEventProcessed(ctx, sqlitedb.MarkOutboxEventProcessedParams{
+		ProcessedAt: sql.NullTime{Time: now(), Valid: true},
+		ID:          id,
+	}))
+}
+
+type sqliteWebhooks struct{ q *sqlitedb.Queries }
+
+// fromSQLiteWebhook decodes the events column, which SQLite stores as a
+// JSON array.
+func fromSQLiteWebhook(w sqlitedb.WebhookSubscription) (database.WebhookSubscription, error) {
+	var events []string
+	if err := json.Unmarshal([]byte(w.Events), &events); err != nil {
+		return database.WebhookSubscription{}, fmt.Errorf("decoding webhook events: %w", err)
+	}
+	return database.WebhookSubscription{
+		ID:        w.ID,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+		UserID:    w.UserID,
+		Url:       w.Url,
+		Secret:    w.Secret,
+		Events:    events,
+	}, nil
+}
+
+func fromSQLiteWebhooks(rows []sqlitedb.WebhookSubscription, err error) ([]database.WebhookSubscription, error) {
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	var subs []database.WebhookSubscription
+	for _, row := range rows {
+		sub, err := fromSQLiteWebhook(row)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func fromSQLiteDelivery(d sqlitedb.WebhookDelivery) database.WebhookDelivery {
+	return database.WebhookDelivery{
+		ID:             d.ID,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+		SubscriptionID: d.SubscriptionID,
+		EventID:        d.EventID,
+		Status:         d.Status,
+		Attempts:       int32(d.Attempts),
+		NextAttemptAt:  d.NextAttemptAt,
+		LastStatusCode: nullInt32(d.LastStatusCode),
+		LastError:      d.LastError,
+		DeliveredAt:    d.DeliveredAt,
+	}
+}
+
+func fromSQLiteDeliveries(rows []sqlitedb.WebhookDelivery, err error) ([]database.WebhookDelivery, error) {
+	if err != nil {
+		return nil, mapSQLiteError(err)
+	}
+	var deliveries []database.WebhookDelivery
+	for _, d := range rows {
+		deliveries = append(deliveries, fromSQLiteDelivery(d))
+	}
+	return deliveries, nil
+}
+
+func (r sqliteWebhooks) CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error) {
+	events, err := json.Marshal(arg.Events)
+	if err != nil {
+		return database.WebhookSubscription{}, err
+	}
+	t := now()
+	w, err := r.q.CreateWebhookSubscription(ctx, sqlitedb.CreateWebhookSubscriptionParams{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    arg.UserID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+		Events:    string(events),
+	})
+	if err != nil {
+		return database.WebhookSubscription{}, mapSQLiteError(err)
+	}
+	return fromSQLiteWebhook(w)
+}
+
+func (r sqliteWebhooks) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (database.WebhookSubscription, error) {
+	w, err := r.q.GetWebhookSubscription(ctx, id)
+	if err != nil {
+		return database.WebhookSubscription{}, mapSQLiteError(err)
+	}
+	return fromSQLiteWebhook(w)
+}
+
+func (r sqliteWebhooks) ListWebhookSubscriptionsForUser(ctx context.Context, userID uuid.UUID) ([]database.WebhookSubscription, error) {
+	return fromSQLiteWebhooks(r.q.ListWebhookSubscriptionsForUser(ctx, userID))
+}
+
+func (r sqliteWebhooks) ListWebhookSubscriptionsForEvent(ctx context.Context, arg database.ListWebhookSubscriptionsForEventParams) ([]database.WebhookSubscription, error) {
+	return fromSQLiteWebhooks(r.q.ListWebhookSubscriptionsForEvent(ctx, sqlitedb.ListWebhookSubscriptionsForEventParams{
+		UserID:    arg.UserID,
+		EventType: arg.EventType,
+	}))
+}
+
+func (r sqliteWebhooks) DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) error {
+	n, err := r.q.DeleteWebhookSubscription(ctx, sqlitedb.DeleteWebhookSubscriptionParams{
+		ID:     arg.ID,
+		UserID: arg.UserID,
+	})
+	if err != nil {
+		return mapSQLiteError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r sqliteWebhooks) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) error {
+	return mapSQLiteError(r.q.CreateWebhookDelivery(ctx, sqlitedb.CreateWebhookDeliveryParams{
+		ID:             uuid.New(),
+		Now:            now(),
+		SubscriptionID: arg.SubscriptionID,
+		EventID:        arg.EventID,
+	}))
+}
+
+func (r sqliteWebhooks) ClaimDueWebhookDeliveries(ctx context.Context, arg database.ClaimDueWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	return fromSQLiteDeliveries(r.q.ClaimDueWebhookDeliveries(ctx, sqlitedb.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: arg.LeaseUntil.UTC(),
+		Now:        now(),
+		BatchSize:  int64(arg.BatchSize),
+	}))
+}
+
+func (r sqliteWebhooks) MarkWebhookDeliverySucceeded(ctx context.Context, arg database.MarkWebhookDeliverySucceededParams) error {
+	return mapSQLiteError(r.q.MarkWebhookDeliverySucceeded(ctx, sqlitedb.MarkWebhookDeliverySucceededParams{
+		LastStatusCode: nullInt64(arg.LastStatusCode),
+		Now:            now(),
+		ID:             arg.ID,
+	}))
+}
+
+func (r sqliteWebhooks) MarkWebhookDeliveryFailed(ctx context.Context, arg database.MarkWebhookDeliveryFailedParams) error {
+	return mapSQLiteError(r.q.MarkWebhookDeliveryFailed(ctx, sqlitedb.MarkWebhookDeliveryFailedParams{
+		Status:         arg.Status,
+		NextAttemptAt:  arg.NextAttemptAt.UTC(),
+		LastStatusCode: nullInt64(arg.LastStatusCode),
+		LastError:      arg.LastError,
+		Now:            now(),
+		ID:             arg.ID,
+	}))
+}
+
+func (r sqliteWebhooks) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	return fromSQLiteDeliveries(r.q.ListWebhookDeliveries(ctx, sqlitedb.ListWebhookDeliveriesParams{
+		SubscriptionID: arg.SubscriptionID,
+		Limit:          int64(arg.Limit),
+	}))
+}
+
+func (r sqliteWebhooks) ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	d, err := r.q.ReplayWebhookDelivery(ctx, sqlitedb.ReplayWebhookDeliveryParams{
+		Now: now(),
+		ID:  id,
+	})
+	return fromSQLiteDelivery(d), mapSQLiteError(err)
+}
+
+func nullInt32(n sql.NullInt64) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(n.Int64), Valid: n.Valid}
+}
+
+func nullInt64(n sql.NullInt32) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n.Int32), Valid: n.Valid}
+}
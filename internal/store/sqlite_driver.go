@@ -0,0 +1,5 @@
+package store
+
+// The SQLite driver is pure Go, so it is linked into every build and
+// sqlite: URLs work without cgo or build tags.
+import _ "modernc.org/sqlite"
@@ -0,0 +1,224 @@
+// Package store defines the repositories handlers use to persist their
+// data, so that handlers run unchanged against Postgres, SQLite and an
+// in-memory store in tests.
+package store
+
+import (
+	"context"
+	"errors"
+
+	"example.com/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned when a lookup matches no rows.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write violates a uniqueness constraint.
+	ErrConflict = errors.New("conflict")
+	// ErrInvalidReference is returned when a write refers to a row that
+	// doesn't exist, such as a chirp for an unknown user.
+	ErrInvalidReference = errors.New("invalid reference")
+)
+
+// UserRepository stores users. Emails are unique, and deleting a user
+// deletes their chirps and refresh tokens.
+type UserRepository interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error)
+	GetUserByEmail(ctx context.Context, email string) (database.User, error)
+	// GetUsersByIDs returns the users that exist among ids, in no
+	// particular order.
+	GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]database.User, error)
+	UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.User, error)
+	UpdateUserEmail(ctx context.Context, arg database.UpdateUserEmailParams) (database.User, error)
+	UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	DeleteAllUsers(ctx context.Context) error
+}
+
+// ChirpRepository stores chirps. Chirp bodies are unique and every chirp
+// must belong to an existing user. Lists are ordered by creation time.
+type ChirpRepository interface {
+	CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+	GetChirps(ctx context.Context) ([]database.Chirp, error)
+	GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	GetChirpsByAuthorID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error)
+	// ListChirps pages through chirps newest first, ordered by creation
+	// time and then ID, starting after the optional (BeforeCreatedAt,
+	// BeforeID) cursor.
+	ListChirps(ctx context.Context, arg database.ListChirpsParams) ([]database.Chirp, error)
+	// ListChirpsOldestFirst pages through chirps in the opposite order,
+	// starting after the optional (AfterCreatedAt, AfterID) cursor.
+	ListChirpsOldestFirst(ctx context.Context, arg database.ListChirpsOldestFirstParams) ([]database.Chirp, error)
+	// ListTimelineChirps pages through the chirps of UserID and the users
+	// they follow the same way.
+	ListTimelineChirps(ctx context.Context, arg database.ListTimelineChirpsParams) ([]database.Chirp, error)
+	DeleteChirp(ctx context.Context, id uuid.UUID) error
+}
+
+// RefreshTokenRepository stores refresh tokens, which must belong to an
+// existing user.
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) error
+	GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
+	// ListRefreshTokensForUser lists a user's tokens newest first,
+	// including revoked and expired ones.
+	ListRefreshTokensForUser(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error)
+	// RevokeRefreshTokensForUser revokes a user's unrevoked tokens and
+	// returns how many it changed.
+	RevokeRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	// DeleteExpiredRefreshTokens deletes expired and revoked tokens and
+	// returns how many it deleted.
+	DeleteExpiredRefreshTokens(ctx context.Context) (int64, error)
+}
+
+// EmailChangeRepository stores pending email changes, keyed by the hash
+// of their confirmation token.
+type EmailChangeRepository interface {
+	CreateEmailChangeRequest(ctx context.Context, arg database.CreateEmailChangeRequestParams) (database.EmailChangeRequest, error)
+	// GetEmailChangeRequestForUpdate also locks the request until the
+	// transaction ends, on backends with row locks.
+	GetEmailChangeRequestForUpdate(ctx context.Context, tokenHash string) (database.EmailChangeRequest, error)
+	DeleteEmailChangeRequestsForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// FollowRepository stores who follows whom. Both users must exist, and
+// following someone twice or unfollowing someone who isn't followed is
+// not an error. Lists are ordered by when the follow was created.
+type FollowRepository interface {
+	// FollowUser reports whether the follow is new.
+	FollowUser(ctx context.Context, arg database.FollowUserParams) (bool, error)
+	UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error
+	ListFolloweeIDs(ctx context.Context, followerID uuid.UUID) ([]uuid.UUID, error)
+	// ListFollowees and ListFollowers page through follows newest first,
+	// ordered by creation time and then the other user's ID.
+	ListFollowees(ctx context.Context, arg database.ListFolloweesParams) ([]database.ListFolloweesRow, error)
+	ListFollowers(ctx context.Context, arg database.ListFollowersParams) ([]database.ListFollowersRow, error)
+}
+
+// Subscription statuses. The queries check for active, past_due and
+// expired by value, so these must not change.
+const (
+	SubscriptionActive   = "active"
+	SubscriptionPastDue  = "past_due"
+	SubscriptionCanceled = "canceled"
+	SubscriptionRefunded = "refunded"
+	SubscriptionExpired  = "expired"
+)
+
+// SubscriptionRepository stores Chirpy Red subscriptions, at most one per
+// user. A user is Chirpy Red while their subscription is active or
+// past_due and the current period hasn't ended.
+type SubscriptionRepository interface {
+	// UpsertSubscription creates the user's subscription or replaces its
+	// status and period.
+	UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (database.Subscription, error)
+	GetSubscriptionByUserID(ctx context.Context, userID uuid.UUID) (database.Subscription, error)
+	SetSubscriptionStatus(ctx context.Context, arg database.SetSubscriptionStatusParams) (database.Subscription, error)
+	// EndSubscription sets the status and ends the current period now,
+	// unless it has already ended.
+	EndSubscription(ctx context.Context, arg database.EndSubscriptionParams) (database.Subscription, error)
+	// ExpireSubscriptions marks lapsed active and past_due subscriptions
+	// expired and returns how many it changed.
+	ExpireSubscriptions(ctx context.Context) (int64, error)
+	IsUserChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+// EntitlementRepository stores the admin overrides of users' entitlements.
+type EntitlementRepository interface {
+	GetEntitlementOverride(ctx context.Context, userID uuid.UUID) (database.EntitlementOverride, error)
+	UpsertEntitlementOverride(ctx context.Context, arg database.UpsertEntitlementOverrideParams) (database.EntitlementOverride, error)
+	DeleteEntitlementOverride(ctx context.Context, userID uuid.UUID) error
+}
+
+// RoleRepository stores the roles granted to users, such as admin.
+// Granting a role twice or revoking one the user doesn't have is not an
+// error.
+type RoleRepository interface {
+	GrantRole(ctx context.Context, arg database.GrantRoleParams) error
+	RevokeRole(ctx context.Context, arg database.RevokeRoleParams) error
+	UserHasRole(ctx context.Context, arg database.UserHasRoleParams) (bool, error)
+}
+
+// PolkaEventRepository records the Polka webhook events already applied,
+// so that retried deliveries are ignored.
+type PolkaEventRepository interface {
+	// RecordPolkaEvent reports whether the event is new.
+	RecordPolkaEvent(ctx context.Context, arg database.RecordPolkaEventParams) (bool, error)
+}
+
+// ReactionRepository stores reactions to chirps. A user reacts to a chirp
+// at most once with each kind, and both must exist. Reacting twice or
+// removing a reaction that isn't there is not an error.
+type ReactionRepository interface {
+	// AddReaction reports whether the reaction is new.
+	AddReaction(ctx context.Context, arg database.AddReactionParams) (bool, error)
+	RemoveReaction(ctx context.Context, arg database.RemoveReactionParams) error
+	// CountReactions counts the reactions to each of ChirpIds by kind,
+	// ordered by chirp and then most used kind first, and reports whether
+	// ViewerID is among them. Chirps without reactions have no rows.
+	CountReactions(ctx context.Context, arg database.CountReactionsParams) ([]database.CountReactionsRow, error)
+}
+
+// OutboxRepository records events for asynchronous delivery.
+type OutboxRepository interface {
+	InsertOutboxEvent(ctx context.Context, arg database.InsertOutboxEventParams) (database.OutboxEvent, error)
+	GetOutboxEvent(ctx context.Context, id uuid.UUID) (database.OutboxEvent, error)
+	// ClaimOutboxEvents returns up to limit unprocessed events, oldest
+	// first. On Postgres they stay locked until the transaction ends, so
+	// several relays can run at once.
+	ClaimOutboxEvents(ctx context.Context, limit int32) ([]database.OutboxEvent, error)
+	MarkOutboxEventProcessed(ctx context.Context, id uuid.UUID) error
+}
+
+// WebhookRepository stores outgoing webhook subscriptions, which must
+// belong to an existing user, and their deliveries.
+type WebhookRepository interface {
+	CreateWebhookSubscription(ctx context.Context, arg database.CreateWebhookSubscriptionParams) (database.WebhookSubscription, error)
+	GetWebhookSubscription(ctx context.Context, id uuid.UUID) (database.WebhookSubscription, error)
+	ListWebhookSubscriptionsForUser(ctx context.Context, userID uuid.UUID) ([]database.WebhookSubscription, error)
+	ListWebhookSubscriptionsForEvent(ctx context.Context, arg database.ListWebhookSubscriptionsForEventParams) ([]database.WebhookSubscription, error)
+	// DeleteWebhookSubscription returns ErrNotFound unless the user has a
+	// subscription with the ID.
+	DeleteWebhookSubscription(ctx context.Context, arg database.DeleteWebhookSubscriptionParams) error
+
+	// CreateWebhookDelivery queues a delivery that is due immediately.
+	CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) error
+	// ClaimDueWebhookDeliveries returns up to BatchSize pending deliveries
+	// that are due, oldest first, and pushes their next attempt to
+	// LeaseUntil so that no other worker claims them meanwhile.
+	ClaimDueWebhookDeliveries(ctx context.Context, arg database.ClaimDueWebhookDeliveriesParams) ([]database.WebhookDelivery, error)
+	MarkWebhookDeliverySucceeded(ctx context.Context, arg database.MarkWebhookDeliverySucceededParams) error
+	MarkWebhookDeliveryFailed(ctx context.Context, arg database.MarkWebhookDeliveryFailedParams) error
+	// ListWebhookDeliveries lists a subscription's deliveries newest first.
+	ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error)
+	// ReplayWebhookDelivery resets a delivery to pending with no attempts,
+	// due immediately.
+	ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error)
+}
+
+// Store groups the repositories.
+type Store interface {
+	Users() UserRepository
+	Chirps() ChirpRepository
+	RefreshTokens() RefreshTokenRepository
+	EmailChanges() EmailChangeRepository
+	Follows() FollowRepository
+	Reactions() ReactionRepository
+	Subscriptions() SubscriptionRepository
+	Entitlements() EntitlementRepository
+	Roles() RoleRepository
+	PolkaEvents() PolkaEventRepository
+	Outbox() OutboxRepository
+	Webhooks() WebhookRepository
+
+	// Reset deletes every row from every table except the migration
+	// history, in one transaction. It is meant for development and tests.
+	Reset(ctx context.Context) error
+
+	// WithTx runs fn with a Store whose writes are committed only if fn
+	// returns nil.
+	WithTx(ctx context.Context, fn func(Store) error) error
+}
@@ -0,0 +1,26 @@
+package store_test
+
+import (
+	"testing"
+
+	"example.com/chirpy/internal/store"
+	"example.com/chirpy/internal/store/storetest"
+)
+
+func TestMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewMemory()
+	})
+}
+
+func TestSQLite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewSQLite(storetest.SQLiteDB(t))
+	})
+}
+
+func TestPostgres(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewPostgres(storetest.PostgresDB(t))
+	})
+}
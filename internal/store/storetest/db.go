@@ -0,0 +1,66 @@
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"example.com/chirpy/internal/migrate"
+	"example.com/chirpy/internal/store"
+	_ "github.com/lib/pq"
+
+	sqlfs "example.com/chirpy/sql"
+)
+
+// PostgresURLEnv names the environment variable holding the Postgres
+// database the tests may use. Tests that need Postgres are skipped when
+// it is unset. Every table in the database is emptied between tests.
+const PostgresURLEnv = "TEST_DATABASE_URL"
+
+// SQLiteDB returns a new, migrated SQLite database in a temporary
+// directory.
+func SQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chirpy.db")
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrateUp(t, db, migrate.SQLite{}, sqlfs.SQLite, "sqlite/schema")
+	return db
+}
+
+// PostgresDB returns the migrated database named by PostgresURLEnv with
+// every table emptied, or skips the test if the variable is unset.
+func PostgresDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv(PostgresURLEnv)
+	if url == "" {
+		t.Skip(PostgresURLEnv + " is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrateUp(t, db, migrate.Postgres{}, sqlfs.Postgres, "schema")
+	if err := store.NewPostgres(db).Reset(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func migrateUp(t *testing.T, db *sql.DB, dialect migrate.Dialect, fsys fs.FS, dir string) {
+	t.Helper()
+	m, err := migrate.New(db, dialect, fsys, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
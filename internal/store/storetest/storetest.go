@@ -0,0 +1,1182 @@
+// Package storetest is a conformance suite that every store.Store
+// implementation must pass. Backends call Run from their own tests with a
+// constructor that returns an empty store.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// Run executes the conformance suite. newStore must return an empty store
+// each time it is called.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"UserEmailIsUnique", testUserEmailIsUnique},
+		{"UserLookups", testUserLookups},
+		{"UserUpdates", testUserUpdates},
+		{"ChirpRequiresUser", testChirpRequiresUser},
+		{"ChirpBodyIsUnique", testChirpBodyIsUnique},
+		{"ChirpListing", testChirpListing},
+		{"ChirpPaging", testChirpPaging},
+		{"UsersByIDs", testUsersByIDs},
+		{"RefreshTokens", testRefreshTokens},
+		{"UserRefreshTokens", testUserRefreshTokens},
+		{"DeleteUserCascades", testDeleteUserCascades},
+		{"DeleteAllUsersCascades", testDeleteAllUsersCascades},
+		{"Reset", testReset},
+		{"TxRollback", testTxRollback},
+		{"EmailChanges", testEmailChanges},
+		{"Follows", testFollows},
+		{"FollowPaging", testFollowPaging},
+		{"Timeline", testTimeline},
+		{"Reactions", testReactions},
+		{"Subscriptions", testSubscriptions},
+		{"ExpireSubscriptions", testExpireSubscriptions},
+		{"EntitlementOverrides", testEntitlementOverrides},
+		{"Roles", testRoles},
+		{"PolkaEvents", testPolkaEvents},
+		{"Outbox", testOutbox},
+		{"WebhookSubscriptions", testWebhookSubscriptions},
+		{"WebhookDeliveries", testWebhookDeliveries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, s store.Store, email string) database.User {
+	t.Helper()
+	u, err := s.Users().CreateUser(context.Background(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "hash",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%q): %v", email, err)
+	}
+	return u
+}
+
+func createChirp(t *testing.T, s store.Store, userID uuid.UUID, body string) database.Chirp {
+	t.Helper()
+	c, err := s.Chirps().CreateChirp(context.Background(), database.CreateChirpParams{
+		UserID: userID,
+		Body:   body,
+	})
+	if err != nil {
+		t.Fatalf("CreateChirp(%q): %v", body, err)
+	}
+	return c
+}
+
+func wantErr(t *testing.T, got, want error) {
+	t.Helper()
+	if !errors.Is(got, want) {
+		t.Fatalf("got error %v, want %v", got, want)
+	}
+}
+
+func testUserEmailIsUnique(t *testing.T, s store.Store) {
+	createUser(t, s, "a@example.com")
+	_, err := s.Users().CreateUser(context.Background(), database.CreateUserParams{
+		Email:          "a@example.com",
+		HashedPassword: "hash",
+	})
+	wantErr(t, err, store.ErrConflict)
+}
+
+func testUserLookups(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "a@example.com")
+	if u.ID == uuid.Nil || u.CreatedAt.IsZero() || u.UpdatedAt.IsZero() {
+		t.Fatalf("CreateUser returned incomplete user: %+v", u)
+	}
+
+	byID, err := s.Users().GetUserByID(ctx, u.ID)
+	if err != nil || byID.Email != u.Email {
+		t.Fatalf("GetUserByID = %+v, %v", byID, err)
+	}
+	byEmail, err := s.Users().GetUserByEmail(ctx, u.Email)
+	if err != nil || byEmail.ID != u.ID {
+		t.Fatalf("GetUserByEmail = %+v, %v", byEmail, err)
+	}
+
+	_, err = s.Users().GetUserByID(ctx, uuid.New())
+	wantErr(t, err, store.ErrNotFound)
+	_, err = s.Users().GetUserByEmail(ctx, "missing@example.com")
+	wantErr(t, err, store.ErrNotFound)
+}
+
+func testUserUpdates(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	b := createUser(t, s, "b@example.com")
+
+	updated, err := s.Users().UpdateUser(ctx, database.UpdateUserParams{
+		Email:          "a2@example.com",
+		HashedPassword: "hash2",
+		ID:             a.ID,
+	})
+	if err != nil || updated.Email != "a2@example.com" || updated.HashedPassword != "hash2" {
+		t.Fatalf("UpdateUser = %+v, %v", updated, err)
+	}
+
+	updated, err = s.Users().UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		HashedPassword: "hash3",
+		ID:             a.ID,
+	})
+	if err != nil || updated.HashedPassword != "hash3" || updated.Email != "a2@example.com" {
+		t.Fatalf("UpdateUserPassword = %+v, %v", updated, err)
+	}
+
+	_, err = s.Users().UpdateUserEmail(ctx, database.UpdateUserEmailParams{
+		Email: b.Email,
+		ID:    a.ID,
+	})
+	wantErr(t, err, store.ErrConflict)
+
+	_, err = s.Users().UpdateUserEmail(ctx, database.UpdateUserEmailParams{
+		Email: "c@example.com",
+		ID:    uuid.New(),
+	})
+	wantErr(t, err, store.ErrNotFound)
+}
+
+func testChirpRequiresUser(t *testing.T, s store.Store) {
+	_, err := s.Chirps().CreateChirp(context.Background(), database.CreateChirpParams{
+		UserID: uuid.New(),
+		Body:   "orphan",
+	})
+	wantErr(t, err, store.ErrInvalidReference)
+}
+
+func testChirpBodyIsUnique(t *testing.T, s store.Store) {
+	u := createUser(t, s, "a@example.com")
+	createChirp(t, s, u.ID, "hello")
+	_, err := s.Chirps().CreateChirp(context.Background(), database.CreateChirpParams{
+		UserID: u.ID,
+		Body:   "hello",
+	})
+	wantErr(t, err, store.ErrConflict)
+}
+
+func testChirpListing(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	b := createUser(t, s, "b@example.com")
+	first := createChirp(t, s, a.ID, "first")
+	second := createChirp(t, s, b.ID, "second")
+	third := createChirp(t, s, a.ID, "third")
+
+	all, err := s.Chirps().GetChirps(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIDs(t, all, first.ID, second.ID, third.ID)
+
+	byA, err := s.Chirps().GetChirpsByAuthorID(ctx, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIDs(t, byA, first.ID, third.ID)
+
+	got, err := s.Chirps().GetChirpByID(ctx, second.ID)
+	if err != nil || got.Body != "second" || got.UserID != b.ID {
+		t.Fatalf("GetChirpByID = %+v, %v", got, err)
+	}
+
+	if err := s.Chirps().DeleteChirp(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Chirps().GetChirpByID(ctx, second.ID)
+	wantErr(t, err, store.ErrNotFound)
+
+	// Deleting a missing chirp is not an error
+	if err := s.Chirps().DeleteChirp(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testChirpPaging(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	b := createUser(t, s, "b@example.com")
+	for i := range 5 {
+		author := a
+		if i%2 == 1 {
+			author = b
+		}
+		createChirp(t, s, author.ID, fmt.Sprintf("chirp %d", i))
+	}
+
+	all, err := s.Chirps().ListChirps(ctx, database.ListChirpsParams{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("got %d chirps, want 5", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		prev, c := all[i-1], all[i]
+		if c.CreatedAt.After(prev.CreatedAt) ||
+			c.CreatedAt.Equal(prev.CreatedAt) && bytes.Compare(c.ID[:], prev.ID[:]) >= 0 {
+			t.Fatalf("chirp %d is not older than chirp %d", i, i-1)
+		}
+	}
+
+	// Paging with the last chirp of each page as the cursor visits every
+	// chirp once, in the same order
+	var paged []database.Chirp
+	arg := database.ListChirpsParams{Limit: 2}
+	for {
+		page, err := s.Chirps().ListChirps(ctx, arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		paged = append(paged, page...)
+		last := page[len(page)-1]
+		arg.BeforeCreatedAt = sql.NullTime{Time: last.CreatedAt, Valid: true}
+		arg.BeforeID = uuid.NullUUID{UUID: last.ID, Valid: true}
+	}
+	var ids, idsByB []uuid.UUID
+	for _, c := range all {
+		ids = append(ids, c.ID)
+		if c.UserID == b.ID {
+			idsByB = append(idsByB, c.ID)
+		}
+	}
+	wantIDs(t, paged, ids...)
+
+	// Oldest first visits them in reverse
+	paged = nil
+	oldest := database.ListChirpsOldestFirstParams{Limit: 2}
+	for {
+		page, err := s.Chirps().ListChirpsOldestFirst(ctx, oldest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		paged = append(paged, page...)
+		last := page[len(page)-1]
+		oldest.AfterCreatedAt = sql.NullTime{Time: last.CreatedAt, Valid: true}
+		oldest.AfterID = uuid.NullUUID{UUID: last.ID, Valid: true}
+	}
+	wantIDs(t, paged, reversed(ids)...)
+
+	byB, err := s.Chirps().ListChirps(ctx, database.ListChirpsParams{
+		UserID: uuid.NullUUID{UUID: b.ID, Valid: true},
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIDs(t, byB, idsByB...)
+	byB, err = s.Chirps().ListChirpsOldestFirst(ctx, database.ListChirpsOldestFirstParams{
+		UserID: uuid.NullUUID{UUID: b.ID, Valid: true},
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIDs(t, byB, reversed(idsByB)...)
+}
+
+func reversed(ids []uuid.UUID) []uuid.UUID {
+	out := slices.Clone(ids)
+	slices.Reverse(out)
+	return out
+}
+
+func testUsersByIDs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	b := createUser(t, s, "b@example.com")
+	createUser(t, s, "c@example.com")
+
+	users, err := s.Users().GetUsersByIDs(ctx, []uuid.UUID{b.ID, uuid.New(), a.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[uuid.UUID]string{}
+	for _, u := range users {
+		got[u.ID] = u.Email
+	}
+	if len(users) != 2 || got[a.ID] != a.Email || got[b.ID] != b.Email {
+		t.Fatalf("GetUsersByIDs = %+v", users)
+	}
+
+	users, err = s.Users().GetUsersByIDs(ctx, nil)
+	if err != nil || len(users) != 0 {
+		t.Fatalf("GetUsersByIDs(nil) = %+v, %v", users, err)
+	}
+}
+
+func wantIDs(t *testing.T, chirps []database.Chirp, ids ...uuid.UUID) {
+	t.Helper()
+	if len(chirps) != len(ids) {
+		t.Fatalf("got %d chirps, want %d", len(chirps), len(ids))
+	}
+	for i, id := range ids {
+		if chirps[i].ID != id {
+			t.Fatalf("chirp %d = %s, want %s", i, chirps[i].ID, id)
+		}
+	}
+}
+
+func testRefreshTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "a@example.com")
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	err := s.RefreshTokens().CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     "tok",
+		UserID:    u.ID,
+		ExpiresAt: expires,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.RefreshTokens().CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:  "orphan",
+		UserID: uuid.New(),
+	})
+	wantErr(t, err, store.ErrInvalidReference)
+
+	got, err := s.RefreshTokens().GetRefreshToken(ctx, "tok")
+	if err != nil || got.UserID != u.ID || got.RevokedAt.Valid || !got.ExpiresAt.Equal(expires) {
+		t.Fatalf("GetRefreshToken = %+v, %v", got, err)
+	}
+
+	if err := s.RefreshTokens().RevokeRefreshToken(ctx, "tok"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.RefreshTokens().GetRefreshToken(ctx, "tok")
+	if err != nil || !got.RevokedAt.Valid {
+		t.Fatalf("token not revoked: %+v, %v", got, err)
+	}
+	revokedAt := got.RevokedAt
+
+	// Revoking again keeps the original timestamp
+	if err := s.RefreshTokens().RevokeRefreshToken(ctx, "tok"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = s.RefreshTokens().GetRefreshToken(ctx, "tok")
+	if got.RevokedAt != (sql.NullTime{Time: revokedAt.Time, Valid: true}) {
+		t.Fatalf("second revoke changed revoked_at from %v to %v", revokedAt, got.RevokedAt)
+	}
+
+	_, err = s.RefreshTokens().GetRefreshToken(ctx, "missing")
+	wantErr(t, err, store.ErrNotFound)
+}
+
+func testUserRefreshTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	b := createUser(t, s, "b@example.com")
+	createToken := func(token string, userID uuid.UUID, expiresIn time.Duration) {
+		t.Helper()
+		err := s.RefreshTokens().CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+			Token:     token,
+			UserID:    userID,
+			ExpiresAt: time.Now().Add(expiresIn),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	createToken("a-live", a.ID, time.Hour)
+	createToken("a-expired", a.ID, -time.Hour)
+	createToken("b-live", b.ID, time.Hour)
+	createToken("b-revoked", b.ID, time.Hour)
+	if err := s.RefreshTokens().RevokeRefreshToken(ctx, "b-revoked"); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := s.RefreshTokens().ListRefreshTokensForUser(ctx, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tok := range tokens {
+		names = append(names, tok.Token)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"a-expired", "a-live"}) {
+		t.Fatalf("ListRefreshTokensForUser = %v", names)
+	}
+
+	// Only the user's unrevoked tokens are revoked, and only once
+	for _, want := range []int64{2, 0} {
+		n, err := s.RefreshTokens().RevokeRefreshTokensForUser(ctx, a.ID)
+		if err != nil || n != want {
+			t.Fatalf("RevokeRefreshTokensForUser = %d, %v; want %d", n, err, want)
+		}
+	}
+	if got, _ := s.RefreshTokens().GetRefreshToken(ctx, "b-live"); got.RevokedAt.Valid {
+		t.Fatal("revoked another user's token")
+	}
+
+	n, err := s.RefreshTokens().DeleteExpiredRefreshTokens(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("DeleteExpiredRefreshTokens = %d, %v; want 3", n, err)
+	}
+	if _, err := s.RefreshTokens().GetRefreshToken(ctx, "b-live"); err != nil {
+		t.Fatalf("deleted a live token: %v", err)
+	}
+	_, err = s.RefreshTokens().GetRefreshToken(ctx, "a-expired")
+	wantErr(t, err, store.ErrNotFound)
+}
+
+func testDeleteUserCascades(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	b := createUser(t, s, "b@example.com")
+	chirpA := createChirp(t, s, a.ID, "from a")
+	chirpB := createChirp(t, s, b.ID, "from b")
+	err := s.RefreshTokens().CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     "tok-a",
+		UserID:    a.ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Users().DeleteUser(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	wantErr(t, s.Users().DeleteUser(ctx, a.ID), store.ErrNotFound)
+
+	_, err = s.Chirps().GetChirpByID(ctx, chirpA.ID)
+	wantErr(t, err, store.ErrNotFound)
+	_, err = s.RefreshTokens().GetRefreshToken(ctx, "tok-a")
+	wantErr(t, err, store.ErrNotFound)
+	if _, err := s.Chirps().GetChirpByID(ctx, chirpB.ID); err != nil {
+		t.Fatalf("other user's chirp was deleted: %v", err)
+	}
+}
+
+func testDeleteAllUsersCascades(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	createChirp(t, s, a.ID, "hello")
+
+	if err := s.Users().DeleteAllUsers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	chirps, err := s.Chirps().GetChirps(ctx)
+	if err != nil || len(chirps) != 0 {
+		t.Fatalf("GetChirps after DeleteAllUsers = %v, %v", chirps, err)
+	}
+	_, err = s.Users().GetUserByID(ctx, a.ID)
+	wantErr(t, err, store.ErrNotFound)
+}
+
+func testReset(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	createChirp(t, s, a.ID, "hello")
+	err := s.RefreshTokens().CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     "tok-a",
+		UserID:    a.ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Users().GetUserByID(ctx, a.ID)
+	wantErr(t, err, store.ErrNotFound)
+	chirps, err := s.Chirps().GetChirps(ctx)
+	if err != nil || len(chirps) != 0 {
+		t.Fatalf("GetChirps after Reset = %v, %v", chirps, err)
+	}
+	_, err = s.RefreshTokens().GetRefreshToken(ctx, "tok-a")
+	wantErr(t, err, store.ErrNotFound)
+
+	// The same data can be created again
+	a = createUser(t, s, "a@example.com")
+	createChirp(t, s, a.ID, "hello")
+}
+
+func testTxRollback(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	err := s.WithTx(ctx, func(tx store.Store) error {
+		createUser(t, tx, "rolled-back@example.com")
+		return errAbort
+	})
+	wantErr(t, err, errAbort)
+	_, err = s.Users().GetUserByEmail(ctx, "rolled-back@example.com")
+	wantErr(t, err, store.ErrNotFound)
+
+	err = s.WithTx(ctx, func(tx store.Store) error {
+		createUser(t, tx, "committed@example.com")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Users().GetUserByEmail(ctx, "committed@example.com"); err != nil {
+		t.Fatalf("committed user missing: %v", err)
+	}
+}
+
+func testEmailChanges(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+
+	_, err := s.EmailChanges().CreateEmailChangeRequest(ctx, database.CreateEmailChangeRequestParams{
+		TokenHash: "hash-1",
+		UserID:    a.ID,
+		NewEmail:  "new@example.com",
+		ExpiresAt: expires,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.EmailChanges().CreateEmailChangeRequest(ctx, database.CreateEmailChangeRequestParams{
+		TokenHash: "hash-2",
+		UserID:    uuid.New(),
+		NewEmail:  "x@example.com",
+		ExpiresAt: expires,
+	})
+	wantErr(t, err, store.ErrInvalidReference)
+
+	got, err := s.EmailChanges().GetEmailChangeRequestForUpdate(ctx, "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != a.ID || got.NewEmail != "new@example.com" || !got.ExpiresAt.Equal(expires) {
+		t.Fatalf("GetEmailChangeRequestForUpdate = %+v", got)
+	}
+
+	if err := s.EmailChanges().DeleteEmailChangeRequestsForUser(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.EmailChanges().GetEmailChangeRequestForUpdate(ctx, "hash-1")
+	wantErr(t, err, store.ErrNotFound)
+}
+
+func follow(t *testing.T, s store.Store, follower, followee uuid.UUID) bool {
+	t.Helper()
+	created, err := s.Follows().FollowUser(context.Background(), database.FollowUserParams{
+		FollowerID: follower,
+		FolloweeID: followee,
+	})
+	if err != nil {
+		t.Fatalf("FollowUser: %v", err)
+	}
+	return created
+}
+
+func testFollows(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	b := createUser(t, s, "b@example.com")
+	c := createUser(t, s, "c@example.com")
+
+	if !follow(t, s, a.ID, b.ID) {
+		t.Fatal("first follow reported as existing")
+	}
+	if follow(t, s, a.ID, b.ID) {
+		t.Fatal("repeated follow reported as new")
+	}
+	follow(t, s, a.ID, c.ID)
+	follow(t, s, c.ID, b.ID)
+	_, err := s.Follows().FollowUser(ctx, database.FollowUserParams{FollowerID: a.ID, FolloweeID: uuid.New()})
+	wantErr(t, err, store.ErrInvalidReference)
+
+	ids, err := s.Follows().ListFolloweeIDs(ctx, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != fmt.Sprint([]uuid.UUID{b.ID, c.ID}) {
+		t.Fatalf("ListFolloweeIDs = %v, want [%s %s]", ids, b.ID, c.ID)
+	}
+
+	followers, err := s.Follows().ListFollowers(ctx, database.ListFollowersParams{UserID: b.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != 2 || followers[0].FollowerID != c.ID || followers[1].FollowerID != a.ID {
+		t.Fatalf("ListFollowers = %+v, want c then a", followers)
+	}
+
+	err = s.Follows().UnfollowUser(ctx, database.UnfollowUserParams{FollowerID: a.ID, FolloweeID: b.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unfollowing someone who isn't followed is not an error
+	err = s.Follows().UnfollowUser(ctx, database.UnfollowUserParams{FollowerID: a.ID, FolloweeID: b.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Users().DeleteUser(ctx, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	ids, err = s.Follows().ListFolloweeIDs(ctx, a.ID)
+	if err != nil || len(ids) != 0 {
+		t.Fatalf("ListFolloweeIDs after unfollow and delete = %v, %v", ids, err)
+	}
+}
+
+func testFollowPaging(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	var want []uuid.UUID
+	for i := range 5 {
+		u := createUser(t, s, fmt.Sprintf("u%d@example.com", i))
+		follow(t, s, a.ID, u.ID)
+		want = append([]uuid.UUID{u.ID}, want...)
+	}
+
+	var got []uuid.UUID
+	arg := database.ListFolloweesParams{UserID: a.ID, Limit: 2}
+	for {
+		page, err := s.Follows().ListFollowees(ctx, arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, row := range page {
+			got = append(got, row.FolloweeID)
+		}
+		last := page[len(page)-1]
+		arg.BeforeCreatedAt = sql.NullTime{Time: last.CreatedAt, Valid: true}
+		arg.BeforeID = uuid.NullUUID{UUID: last.FolloweeID, Valid: true}
+	}
+
+	// Follows made within one clock tick are ordered by ID instead
+	if len(got) != len(want) {
+		t.Fatalf("paged %d followees, want %d", len(got), len(want))
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range got {
+		if seen[id] {
+			t.Fatalf("followee %s listed twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func testTimeline(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	b := createUser(t, s, "b@example.com")
+	c := createUser(t, s, "c@example.com")
+	follow(t, s, a.ID, b.ID)
+	fromA := createChirp(t, s, a.ID, "from a")
+	fromB := createChirp(t, s, b.ID, "from b")
+	createChirp(t, s, c.ID, "from c")
+
+	chirps, err := s.Chirps().ListTimelineChirps(ctx, database.ListTimelineChirpsParams{UserID: a.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[uuid.UUID]bool{}
+	for _, chirp := range chirps {
+		got[chirp.ID] = true
+	}
+	if len(chirps) != 2 || !got[fromA.ID] || !got[fromB.ID] {
+		t.Fatalf("timeline has %d chirps, want a's and b's", len(chirps))
+	}
+}
+
+func testReactions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	b := createUser(t, s, "b@example.com")
+	first := createChirp(t, s, a.ID, "first")
+	second := createChirp(t, s, b.ID, "second")
+	quiet := createChirp(t, s, b.ID, "quiet")
+
+	react := func(chirpID, userID uuid.UUID, kind string) bool {
+		t.Helper()
+		added, err := s.Reactions().AddReaction(ctx, database.AddReactionParams{ChirpID: chirpID, UserID: userID, Kind: kind})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return added
+	}
+	if !react(first.ID, a.ID, "like") {
+		t.Fatal("first reaction reported as existing")
+	}
+	if react(first.ID, a.ID, "like") {
+		t.Fatal("repeated reaction reported as new")
+	}
+	react(first.ID, b.ID, "like")
+	react(first.ID, b.ID, "laugh")
+	react(second.ID, a.ID, "love")
+	_, err := s.Reactions().AddReaction(ctx, database.AddReactionParams{ChirpID: uuid.New(), UserID: a.ID, Kind: "like"})
+	wantErr(t, err, store.ErrInvalidReference)
+	_, err = s.Reactions().AddReaction(ctx, database.AddReactionParams{ChirpID: first.ID, UserID: uuid.New(), Kind: "like"})
+	wantErr(t, err, store.ErrInvalidReference)
+
+	count := func(viewer uuid.NullUUID, chirpIDs ...uuid.UUID) map[uuid.UUID]string {
+		t.Helper()
+		rows, err := s.Reactions().CountReactions(ctx, database.CountReactionsParams{ViewerID: viewer, ChirpIds: chirpIDs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[uuid.UUID]string{}
+		for _, row := range rows {
+			got[row.ChirpID] += fmt.Sprintf("%s=%d/%v ", row.Kind, row.Count, row.ViewerReacted)
+		}
+		return got
+	}
+	got := count(uuid.NullUUID{UUID: a.ID, Valid: true}, first.ID, second.ID, quiet.ID)
+	// Most used first, and only the viewer's own reactions are marked
+	want := map[uuid.UUID]string{
+		first.ID:  "like=2/true laugh=1/false ",
+		second.ID: "love=1/true ",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("CountReactions = %v, want %v", got, want)
+	}
+	if got := count(uuid.NullUUID{}, first.ID); got[first.ID] != "like=2/false laugh=1/false " {
+		t.Fatalf("CountReactions without a viewer = %v", got)
+	}
+	if got := count(uuid.NullUUID{}); len(got) != 0 {
+		t.Fatalf("CountReactions of no chirps = %v", got)
+	}
+
+	err = s.Reactions().RemoveReaction(ctx, database.RemoveReactionParams{ChirpID: first.ID, UserID: a.ID, Kind: "like"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Removing a reaction that isn't there is not an error
+	err = s.Reactions().RemoveReaction(ctx, database.RemoveReactionParams{ChirpID: first.ID, UserID: a.ID, Kind: "like"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := count(uuid.NullUUID{UUID: a.ID, Valid: true}, first.ID); got[first.ID] != "laugh=1/false like=1/false " {
+		t.Fatalf("CountReactions after removing one = %v", got)
+	}
+
+	// Reactions go with their chirp and their user
+	if err := s.Chirps().DeleteChirp(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Users().DeleteUser(ctx, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(uuid.NullUUID{}, first.ID, second.ID); len(got) != 0 {
+		t.Fatalf("CountReactions after deleting = %v", got)
+	}
+}
+
+func testSubscriptions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	start := time.Now().Add(-time.Hour).UTC().Truncate(time.Millisecond)
+	end := start.Add(30 * 24 * time.Hour)
+
+	_, err := s.Subscriptions().GetSubscriptionByUserID(ctx, a.ID)
+	wantErr(t, err, store.ErrNotFound)
+	red, err := s.Subscriptions().IsUserChirpyRed(ctx, a.ID)
+	if err != nil || red {
+		t.Fatalf("IsUserChirpyRed without a subscription = %v, %v", red, err)
+	}
+
+	sub, err := s.Subscriptions().UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:             a.ID,
+		Status:             store.SubscriptionActive,
+		CurrentPeriodStart: start,
+		CurrentPeriodEnd:   end,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status != store.SubscriptionActive || !sub.CurrentPeriodEnd.Equal(end) {
+		t.Fatalf("UpsertSubscription = %+v", sub)
+	}
+	again, err := s.Subscriptions().UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:             a.ID,
+		Status:             store.SubscriptionActive,
+		CurrentPeriodStart: end,
+		CurrentPeriodEnd:   end.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != sub.ID || !again.CurrentPeriodStart.Equal(end) {
+		t.Fatalf("second upsert = %+v, want the same row moved on", again)
+	}
+
+	red, err = s.Subscriptions().IsUserChirpyRed(ctx, a.ID)
+	if err != nil || !red {
+		t.Fatalf("IsUserChirpyRed while active = %v, %v", red, err)
+	}
+
+	pastDue, err := s.Subscriptions().SetSubscriptionStatus(ctx, database.SetSubscriptionStatusParams{UserID: a.ID, Status: store.SubscriptionPastDue})
+	if err != nil || pastDue.Status != store.SubscriptionPastDue {
+		t.Fatalf("SetSubscriptionStatus = %+v, %v", pastDue, err)
+	}
+	red, err = s.Subscriptions().IsUserChirpyRed(ctx, a.ID)
+	if err != nil || !red {
+		t.Fatalf("IsUserChirpyRed while past_due = %v, %v", red, err)
+	}
+
+	ended, err := s.Subscriptions().EndSubscription(ctx, database.EndSubscriptionParams{UserID: a.ID, Status: store.SubscriptionCanceled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ended.Status != store.SubscriptionCanceled || ended.CurrentPeriodEnd.After(time.Now()) {
+		t.Fatalf("EndSubscription = %+v, want canceled and ended", ended)
+	}
+	red, err = s.Subscriptions().IsUserChirpyRed(ctx, a.ID)
+	if err != nil || red {
+		t.Fatalf("IsUserChirpyRed after ending = %v, %v", red, err)
+	}
+
+	_, err = s.Subscriptions().SetSubscriptionStatus(ctx, database.SetSubscriptionStatusParams{UserID: uuid.New(), Status: store.SubscriptionPastDue})
+	wantErr(t, err, store.ErrNotFound)
+	_, err = s.Subscriptions().UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:             uuid.New(),
+		Status:             store.SubscriptionActive,
+		CurrentPeriodStart: start,
+		CurrentPeriodEnd:   end,
+	})
+	wantErr(t, err, store.ErrInvalidReference)
+}
+
+func testExpireSubscriptions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i, period := range []struct {
+		status string
+		end    time.Time
+	}{
+		{store.SubscriptionActive, now.Add(-time.Minute)},
+		{store.SubscriptionPastDue, now.Add(-time.Minute)},
+		{store.SubscriptionActive, now.Add(time.Hour)},
+		{store.SubscriptionCanceled, now.Add(-time.Minute)},
+	} {
+		u := createUser(t, s, fmt.Sprintf("u%d@example.com", i))
+		_, err := s.Subscriptions().UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:             u.ID,
+			Status:             period.status,
+			CurrentPeriodStart: period.end.Add(-time.Hour),
+			CurrentPeriodEnd:   period.end,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := s.Subscriptions().ExpireSubscriptions(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("ExpireSubscriptions = %d, %v, want 2", n, err)
+	}
+	n, err = s.Subscriptions().ExpireSubscriptions(ctx)
+	if err != nil || n != 0 {
+		t.Fatalf("second ExpireSubscriptions = %d, %v, want 0", n, err)
+	}
+}
+
+func testEntitlementOverrides(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+
+	_, err := s.Entitlements().GetEntitlementOverride(ctx, a.ID)
+	wantErr(t, err, store.ErrNotFound)
+
+	o, err := s.Entitlements().UpsertEntitlementOverride(ctx, database.UpsertEntitlementOverrideParams{
+		UserID:         a.ID,
+		Tier:           sql.NullString{String: "chirpy_red", Valid: true},
+		MaxChirpLength: sql.NullInt32{Int32: 500, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Tier.String != "chirpy_red" || o.MaxChirpLength.Int32 != 500 || o.CanEditChirps.Valid {
+		t.Fatalf("UpsertEntitlementOverride = %+v", o)
+	}
+
+	// An upsert replaces every field, clearing the ones left out
+	_, err = s.Entitlements().UpsertEntitlementOverride(ctx, database.UpsertEntitlementOverrideParams{
+		UserID:        a.ID,
+		CanEditChirps: sql.NullBool{Bool: true, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Entitlements().GetEntitlementOverride(ctx, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Tier.Valid || got.MaxChirpLength.Valid || !got.CanEditChirps.Bool {
+		t.Fatalf("GetEntitlementOverride after second upsert = %+v", got)
+	}
+
+	if err := s.Entitlements().DeleteEntitlementOverride(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Entitlements().GetEntitlementOverride(ctx, a.ID)
+	wantErr(t, err, store.ErrNotFound)
+}
+
+func testRoles(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	hasRole := func(role string) bool {
+		t.Helper()
+		has, err := s.Roles().UserHasRole(ctx, database.UserHasRoleParams{UserID: a.ID, Role: role})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return has
+	}
+
+	if hasRole("admin") {
+		t.Fatal("new user is an admin")
+	}
+	for range 2 {
+		if err := s.Roles().GrantRole(ctx, database.GrantRoleParams{UserID: a.ID, Role: "admin"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !hasRole("admin") || hasRole("moderator") {
+		t.Fatal("GrantRole didn't grant exactly the admin role")
+	}
+	err := s.Roles().GrantRole(ctx, database.GrantRoleParams{UserID: uuid.New(), Role: "admin"})
+	wantErr(t, err, store.ErrInvalidReference)
+
+	for range 2 {
+		if err := s.Roles().RevokeRole(ctx, database.RevokeRoleParams{UserID: a.ID, Role: "admin"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hasRole("admin") {
+		t.Fatal("RevokeRole didn't revoke the admin role")
+	}
+}
+
+func testPolkaEvents(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	for i, want := range []bool{true, false} {
+		created, err := s.PolkaEvents().RecordPolkaEvent(ctx, database.RecordPolkaEventParams{
+			ID:    "evt-1",
+			Event: "user.upgraded",
+		})
+		if err != nil || created != want {
+			t.Fatalf("RecordPolkaEvent #%d = %v, %v, want %v", i+1, created, err, want)
+		}
+	}
+}
+
+func enqueue(t *testing.T, s store.Store, userID uuid.UUID, eventType string) database.OutboxEvent {
+	t.Helper()
+	e, err := s.Outbox().InsertOutboxEvent(context.Background(), database.InsertOutboxEventParams{
+		EventType: eventType,
+		UserID:    userID,
+		Payload:   []byte(`{"n":1}`),
+	})
+	if err != nil {
+		t.Fatalf("InsertOutboxEvent: %v", err)
+	}
+	return e
+}
+
+func testOutbox(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID := uuid.New()
+	first := enqueue(t, s, userID, "chirp.created")
+	second := enqueue(t, s, userID, "chirp.deleted")
+
+	got, err := s.Outbox().GetOutboxEvent(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.EventType != "chirp.created" || string(got.Payload) != `{"n":1}` || got.ProcessedAt.Valid {
+		t.Fatalf("GetOutboxEvent = %+v", got)
+	}
+	_, err = s.Outbox().GetOutboxEvent(ctx, uuid.New())
+	wantErr(t, err, store.ErrNotFound)
+
+	claimed, err := s.Outbox().ClaimOutboxEvents(ctx, 1)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimOutboxEvents(1) = %+v, %v", claimed, err)
+	}
+	if err := s.Outbox().MarkOutboxEventProcessed(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err = s.Outbox().ClaimOutboxEvents(ctx, 10)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != second.ID {
+		t.Fatalf("ClaimOutboxEvents after processing = %+v, %v", claimed, err)
+	}
+}
+
+func createWebhook(t *testing.T, s store.Store, userID uuid.UUID, url string, events ...string) database.WebhookSubscription {
+	t.Helper()
+	sub, err := s.Webhooks().CreateWebhookSubscription(context.Background(), database.CreateWebhookSubscriptionParams{
+		UserID: userID,
+		Url:    url,
+		Secret: "secret",
+		Events: events,
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhookSubscription: %v", err)
+	}
+	return sub
+}
+
+func testWebhookSubscriptions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	b := createUser(t, s, "b@example.com")
+	created := createWebhook(t, s, a.ID, "https://a.example.com/1", "chirp.created", "mention")
+	deleted := createWebhook(t, s, a.ID, "https://a.example.com/2", "chirp.deleted")
+	createWebhook(t, s, b.ID, "https://b.example.com/", "chirp.created")
+
+	got, err := s.Webhooks().GetWebhookSubscription(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Url != created.Url || got.Secret != "secret" || fmt.Sprint(got.Events) != "[chirp.created mention]" {
+		t.Fatalf("GetWebhookSubscription = %+v", got)
+	}
+
+	subs, err := s.Webhooks().ListWebhookSubscriptionsForUser(ctx, a.ID)
+	if err != nil || len(subs) != 2 || subs[0].ID != created.ID || subs[1].ID != deleted.ID {
+		t.Fatalf("ListWebhookSubscriptionsForUser = %+v, %v", subs, err)
+	}
+	subs, err = s.Webhooks().ListWebhookSubscriptionsForEvent(ctx, database.ListWebhookSubscriptionsForEventParams{
+		UserID:    a.ID,
+		EventType: "mention",
+	})
+	if err != nil || len(subs) != 1 || subs[0].ID != created.ID {
+		t.Fatalf("ListWebhookSubscriptionsForEvent = %+v, %v", subs, err)
+	}
+
+	// Only the owner can delete a subscription
+	err = s.Webhooks().DeleteWebhookSubscription(ctx, database.DeleteWebhookSubscriptionParams{ID: created.ID, UserID: b.ID})
+	wantErr(t, err, store.ErrNotFound)
+	err = s.Webhooks().DeleteWebhookSubscription(ctx, database.DeleteWebhookSubscriptionParams{ID: created.ID, UserID: a.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Webhooks().GetWebhookSubscription(ctx, created.ID)
+	wantErr(t, err, store.ErrNotFound)
+}
+
+func testWebhookDeliveries(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := createUser(t, s, "a@example.com")
+	sub := createWebhook(t, s, a.ID, "https://a.example.com/", "chirp.created")
+	event := enqueue(t, s, a.ID, "chirp.created")
+
+	err := s.Webhooks().CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{SubscriptionID: sub.ID, EventID: event.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Webhooks().CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{SubscriptionID: uuid.New(), EventID: event.ID})
+	wantErr(t, err, store.ErrInvalidReference)
+
+	lease := time.Now().Add(time.Minute).UTC()
+	claim := func() []database.WebhookDelivery {
+		t.Helper()
+		due, err := s.Webhooks().ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+			LeaseUntil: lease,
+			BatchSize:  10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return due
+	}
+	due := claim()
+	if len(due) != 1 || due[0].EventID != event.ID || due[0].Status != "pending" {
+		t.Fatalf("ClaimDueWebhookDeliveries = %+v", due)
+	}
+	// The lease hides a claimed delivery from other workers
+	if again := claim(); len(again) != 0 {
+		t.Fatalf("claimed a leased delivery again: %+v", again)
+	}
+
+	err = s.Webhooks().MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		ID:             due[0].ID,
+		Status:         "pending",
+		NextAttemptAt:  time.Now().Add(-time.Second),
+		LastStatusCode: sql.NullInt32{Int32: 500, Valid: true},
+		LastError:      sql.NullString{String: "boom", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	due = claim()
+	if len(due) != 1 || due[0].Attempts != 1 || due[0].LastStatusCode.Int32 != 500 || due[0].LastError.String != "boom" {
+		t.Fatalf("retried delivery = %+v", due)
+	}
+
+	err = s.Webhooks().MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+		ID:             due[0].ID,
+		LastStatusCode: sql.NullInt32{Int32: 204, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again := claim(); len(again) != 0 {
+		t.Fatalf("claimed a delivered delivery: %+v", again)
+	}
+
+	log, err := s.Webhooks().ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{SubscriptionID: sub.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 || log[0].Status != "succeeded" || log[0].Attempts != 2 || !log[0].DeliveredAt.Valid || log[0].LastError.Valid {
+		t.Fatalf("ListWebhookDeliveries = %+v", log)
+	}
+
+	replayed, err := s.Webhooks().ReplayWebhookDelivery(ctx, log[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Status != "pending" || replayed.Attempts != 0 || replayed.DeliveredAt.Valid || replayed.LastStatusCode.Valid {
+		t.Fatalf("ReplayWebhookDelivery = %+v", replayed)
+	}
+	if due := claim(); len(due) != 1 || due[0].ID != replayed.ID {
+		t.Fatalf("replayed delivery isn't due: %+v", due)
+	}
+	_, err = s.Webhooks().ReplayWebhookDelivery(ctx, uuid.New())
+	wantErr(t, err, store.ErrNotFound)
+
+	// Deleting the subscription deletes its deliveries
+	err = s.Webhooks().DeleteWebhookSubscription(ctx, database.DeleteWebhookSubscriptionParams{ID: sub.ID, UserID: a.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err = s.Webhooks().ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{SubscriptionID: sub.ID, Limit: 10})
+	if err != nil || len(log) != 0 {
+		t.Fatalf("ListWebhookDeliveries after delete = %+v, %v", log, err)
+	}
+}
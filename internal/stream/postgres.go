@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"example.com/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"log/slog"
+	"time"
+)
+
+// Channel is the Postgres notification channel events are announced on.
+// Notifications carry only the outbox event ID, which keeps them well
+// under NOTIFY's payload limit.
+const Channel = "chirpy_events"
+
+// Notifier publishes events to every replica's hub with NOTIFY. The
+// replica that committed the event hears its own notification too.
+type Notifier struct {
+	db *sql.DB
+}
+
+// NewNotifier -
+func NewNotifier(db *sql.DB) *Notifier {
+	return &Notifier{db: db}
+}
+
+// Publish announces the streamed events among events.
+func (n *Notifier) Publish(ctx context.Context, events ...database.OutboxEvent) error {
+	var errs []error
+	for _, e := range events {
+		if !Streamed(e.EventType) {
+			continue
+		}
+		if _, err := n.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", Channel, e.ID.String()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Listen feeds hub with the events announced on Channel until ctx is
+// cancelled, calling beat after each health check. After the connection
+// drops and comes back, events created since the newest one in the hub
+// are read from the outbox, since notifications sent in between are lost.
+func Listen(ctx context.Context, dbURL string, q *database.Queries, hub *Hub, beat func(error)) {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.WarnContext(ctx, "event listener connection", "event", ev, "error", err)
+			beat(err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(Channel); err != nil {
+		// pq keeps retrying in the background and listens once connected
+		slog.WarnContext(ctx, "listening for events", "error", err)
+		beat(err)
+	}
+
+	ping := time.NewTicker(30 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ping.C:
+			beat(listener.Ping())
+		case n := <-listener.Notify:
+			if n == nil {
+				catchUp(ctx, q, hub)
+				continue
+			}
+			id, err := uuid.Parse(n.Extra)
+			if err != nil {
+				slog.WarnContext(ctx, "ignoring malformed event notification", "payload", n.Extra)
+				continue
+			}
+			event, err := q.GetOutboxEvent(ctx, id)
+			if err == nil {
+				err = hub.Publish(ctx, event)
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "relaying event to streams", "event_id", id, "error", err)
+			}
+		}
+	}
+}
+
+// catchUp publishes the outbox events created since the newest event in
+// the hub. The hub ignores the ones it already has.
+func catchUp(ctx context.Context, q *database.Queries, hub *Hub) {
+	since := hub.Latest()
+	if since.IsZero() {
+		since = time.Now().Add(-time.Minute)
+	}
+	events, err := q.ListOutboxEventsSince(ctx, database.ListOutboxEventsSinceParams{
+		CreatedAt: since,
+		Limit:     int32(hub.size),
+	})
+	if err == nil {
+		err = hub.Publish(ctx, events...)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "catching up on events after reconnecting", "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "event listener reconnected", "checked", len(events))
+}
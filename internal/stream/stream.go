@@ -0,0 +1,312 @@
+// Package stream fans chirp events out to the clients of the server-sent
+// events endpoint. A Hub keeps the most recent events so a client that
+// reconnects with Last-Event-ID gets what it missed.
+//
+// Events are outbox events: handlers publish the ones they committed, and
+// on Postgres a Notifier announces them to every replica with NOTIFY while
+// Listen feeds each replica's hub from LISTEN. Because every hub then
+// receives events in the same order, a client can resume on any replica.
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ErrClosed is returned by Subscribe once the hub has been closed.
+var ErrClosed = errors.New("stream: hub closed")
+
+// Event is a chirp event as sent to stream clients.
+type Event struct {
+	ID        uuid.UUID
+	Type      string
+	CreatedAt time.Time
+	// Data is the chirp as the API returns it, on one line.
+	Data []byte
+
+	author   uuid.UUID
+	hashtags []string
+}
+
+// Streamed reports whether events of type t are sent to stream clients.
+func Streamed(t string) bool {
+	return t == webhooks.EventChirpCreated || t == webhooks.EventChirpDeleted
+}
+
+// FromOutbox turns an outbox event into a stream event. ok is false for
+// event types that aren't streamed.
+func FromOutbox(e database.OutboxEvent) (ev Event, ok bool, err error) {
+	if !Streamed(e.EventType) {
+		return Event{}, false, nil
+	}
+	var chirp struct {
+		UserID uuid.UUID `json:"user_id"`
+		Body   string    `json:"body"`
+	}
+	if err := json.Unmarshal(e.Payload, &chirp); err != nil {
+		return Event{}, false, err
+	}
+	var data bytes.Buffer
+	if err := json.Compact(&data, e.Payload); err != nil {
+		return Event{}, false, err
+	}
+	return Event{
+		ID:        e.ID,
+		Type:      e.EventType,
+		CreatedAt: e.CreatedAt,
+		Data:      data.Bytes(),
+		author:    chirp.UserID,
+		hashtags:  Hashtags(chirp.Body),
+	}, true, nil
+}
+
+// Hashtags returns the lower-cased tags written as "#tag" in body. A tag
+// is letters, digits and underscores following a # at the start of a word.
+func Hashtags(body string) []string {
+	var tags []string
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '#' || (i > 0 && isTagRune(runes[i-1])) {
+			continue
+		}
+		j := i + 1
+		for j < len(runes) && isTagRune(runes[j]) {
+			j++
+		}
+		if j > i+1 {
+			tags = append(tags, strings.ToLower(string(runes[i+1:j])))
+		}
+		i = j - 1
+	}
+	return tags
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Filter selects the events a client receives. The zero Filter matches
+// every event.
+type Filter struct {
+	// Author, if set, matches only chirps by that user.
+	Author uuid.UUID
+	// Authors, if non-nil, matches only chirps by those users, e.g. the
+	// ones the client follows.
+	Authors map[uuid.UUID]bool
+	// Hashtag, if set, matches only chirps tagged with it. It is compared
+	// case-insensitively and without the #.
+	Hashtag string
+}
+
+// Match reports whether e passes the filter.
+func (f Filter) Match(e Event) bool {
+	if f.Author != uuid.Nil && e.author != f.Author {
+		return false
+	}
+	if f.Authors != nil && !f.Authors[e.author] {
+		return false
+	}
+	if f.Hashtag != "" {
+		for _, tag := range e.hashtags {
+			if strings.EqualFold(tag, f.Hashtag) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Publisher announces committed outbox events to stream clients.
+type Publisher interface {
+	Publish(ctx context.Context, events ...database.OutboxEvent) error
+}
+
+// Hub delivers events to subscribers and keeps the last few for replay.
+// It is safe for concurrent use.
+type Hub struct {
+	mu     sync.Mutex
+	size   int
+	recent []Event
+	seen   map[uuid.UUID]bool
+	subs   map[*Subscription]bool
+	closed bool
+	done   chan struct{}
+}
+
+// NewHub returns a hub that keeps the last size events for replay.
+func NewHub(size int) *Hub {
+	return &Hub{
+		size: max(size, 1),
+		seen: map[uuid.UUID]bool{},
+		subs: map[*Subscription]bool{},
+		done: make(chan struct{}),
+	}
+}
+
+// Publish delivers the streamed events among events to matching
+// subscribers. Events the hub has already seen are skipped, so an event
+// may safely arrive both locally and by NOTIFY.
+func (h *Hub) Publish(ctx context.Context, events ...database.OutboxEvent) error {
+	var errs []error
+	for _, e := range events {
+		ev, ok, err := FromOutbox(e)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			h.add(ev)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *Hub) add(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed || h.seen[e.ID] {
+		return
+	}
+
+	if len(h.recent) == h.size {
+		delete(h.seen, h.recent[0].ID)
+		copy(h.recent, h.recent[1:])
+		h.recent = h.recent[:len(h.recent)-1]
+	}
+	h.recent = append(h.recent, e)
+	h.seen[e.ID] = true
+
+	for sub := range h.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			// A client that can't keep up is dropped rather than allowed
+			// to hold events in memory; it resumes with Last-Event-ID
+			sub.lagged = true
+			h.remove(sub)
+		}
+	}
+}
+
+// Latest returns the creation time of the newest event in the replay
+// buffer, or the zero time if it is empty.
+func (h *Hub) Latest() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.recent) == 0 {
+		return time.Time{}
+	}
+	return h.recent[len(h.recent)-1].CreatedAt
+}
+
+// Subscribe registers a subscriber for events matching f. If lastEventID
+// is set, the buffered events after it that match f are returned for the
+// caller to send first; resumed is false if the event is no longer
+// buffered, in which case the client may have missed events.
+func (h *Hub) Subscribe(f Filter, lastEventID uuid.UUID, queue int) (sub *Subscription, replay []Event, resumed bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, nil, false, ErrClosed
+	}
+
+	resumed = lastEventID == uuid.Nil
+	if !resumed && h.seen[lastEventID] {
+		resumed = true
+		i := len(h.recent) - 1
+		for h.recent[i].ID != lastEventID {
+			i--
+		}
+		for _, e := range h.recent[i+1:] {
+			if f.Match(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	sub = &Subscription{hub: h, filter: f, c: make(chan Event, max(queue, 1))}
+	h.subs[sub] = true
+	return sub, replay, resumed, nil
+}
+
+// remove unregisters sub and closes its channel. h.mu must be held.
+func (h *Hub) remove(sub *Subscription) {
+	if h.subs[sub] {
+		delete(h.subs, sub)
+		close(sub.c)
+	}
+}
+
+// Reset empties the replay buffer, so clients resuming from an event
+// before the reset are told they may have missed events rather than
+// replayed ones that no longer exist. Subscriptions stay open.
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recent = nil
+	h.seen = map[uuid.UUID]bool{}
+}
+
+// Close ends every subscription and refuses new ones, so streaming
+// requests finish during shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	close(h.done)
+	for sub := range h.subs {
+		h.remove(sub)
+	}
+}
+
+// Done returns a channel that is closed when the hub is closed, for
+// connections that should end then even without a subscription.
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+// Subscription is one client's view of the hub.
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	c      chan Event
+	lagged bool
+}
+
+// Events returns the channel events are delivered on. It is closed when
+// the subscription ends: by Close, because the hub closed, or because the
+// client fell too far behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.c
+}
+
+// Lagged reports whether the subscription was ended because its queue
+// filled up. Only meaningful after Events is closed.
+func (s *Subscription) Lagged() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.lagged
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
@@ -0,0 +1,322 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	walt   = uuid.MustParse("3311741c-680c-4546-99f3-fc9efac2036c")
+	jesse  = uuid.MustParse("0c2ab0b6-3b43-4a6a-8d8a-6d1c2a5ab9f1")
+	skyler = uuid.MustParse("5b3f9d27-6c2e-4f0e-9a4b-1f6f2d9c8e70")
+)
+
+// chirpEvent returns a chirp.created outbox event for a chirp by author.
+func chirpEvent(t *testing.T, author uuid.UUID, body string) database.OutboxEvent {
+	t.Helper()
+	payload, err := json.Marshal(map[string]any{"id": uuid.New(), "user_id": author, "body": body})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database.OutboxEvent{ID: uuid.New(), EventType: webhooks.EventChirpCreated, Payload: payload, CreatedAt: time.Now()}
+}
+
+// publish publishes events to h, failing the test on error.
+func publish(t *testing.T, h *Hub, events ...database.OutboxEvent) {
+	t.Helper()
+	if err := h.Publish(context.Background(), events...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ids returns the IDs of events, in order.
+func ids(events []Event) []uuid.UUID {
+	var out []uuid.UUID
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+// drain returns the events already queued on sub without waiting for more.
+func drain(sub *Subscription) []Event {
+	var out []Event
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return out
+			}
+			out = append(out, e)
+		default:
+			return out
+		}
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "no tags here"},
+		{body: "#Chemistry is #fun", want: []string{"chemistry", "fun"}},
+		{body: "tags_with_digits #abq505 and #snake_case", want: []string{"abq505", "snake_case"}},
+		{body: "not a tag: walt#white or #", want: nil},
+		{body: "(#inside) #café!", want: []string{"inside", "café"}},
+		{body: "##double", want: []string{"double"}},
+	}
+	for _, tt := range tests {
+		if got := Hashtags(tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Hashtags(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestFromOutbox(t *testing.T) {
+	ev, ok, err := FromOutbox(database.OutboxEvent{
+		ID:        uuid.New(),
+		EventType: webhooks.EventChirpCreated,
+		Payload:   []byte("{\n  \"user_id\": \"" + walt.String() + "\",\n  \"body\": \"#Blue\"\n}"),
+	})
+	if err != nil || !ok {
+		t.Fatalf("got ok %v, error %v", ok, err)
+	}
+	if want := `{"user_id":"` + walt.String() + `","body":"#Blue"}`; string(ev.Data) != want {
+		t.Errorf("data = %s, want %s", ev.Data, want)
+	}
+	if ev.author != walt || !reflect.DeepEqual(ev.hashtags, []string{"blue"}) {
+		t.Errorf("got author %s, hashtags %q", ev.author, ev.hashtags)
+	}
+
+	if _, ok, err := FromOutbox(database.OutboxEvent{EventType: webhooks.EventUserFollowed, Payload: []byte("{}")}); ok || err != nil {
+		t.Errorf("user.followed: got ok %v, error %v, want it skipped", ok, err)
+	}
+	if _, _, err := FromOutbox(database.OutboxEvent{EventType: webhooks.EventChirpDeleted, Payload: []byte("{")}); err == nil {
+		t.Error("malformed payload: got no error")
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	ev := func(author uuid.UUID, tags ...string) Event { return Event{author: author, hashtags: tags} }
+	tests := []struct {
+		name   string
+		filter Filter
+		event  Event
+		want   bool
+	}{
+		{name: "zero filter", event: ev(walt), want: true},
+		{name: "author", filter: Filter{Author: walt}, event: ev(walt), want: true},
+		{name: "other author", filter: Filter{Author: walt}, event: ev(jesse), want: false},
+		{name: "followed", filter: Filter{Authors: map[uuid.UUID]bool{walt: true, jesse: true}}, event: ev(jesse), want: true},
+		{name: "not followed", filter: Filter{Authors: map[uuid.UUID]bool{walt: true}}, event: ev(skyler), want: false},
+		{name: "following nobody", filter: Filter{Authors: map[uuid.UUID]bool{}}, event: ev(walt), want: false},
+		{name: "hashtag", filter: Filter{Hashtag: "blue"}, event: ev(walt, "crystal", "blue"), want: true},
+		{name: "hashtag ignores case", filter: Filter{Hashtag: "Blue"}, event: ev(walt, "blue"), want: true},
+		{name: "other hashtag", filter: Filter{Hashtag: "blue"}, event: ev(walt, "green"), want: false},
+		{name: "no hashtags", filter: Filter{Hashtag: "blue"}, event: ev(walt), want: false},
+		{name: "author and hashtag", filter: Filter{Author: walt, Hashtag: "blue"}, event: ev(walt, "blue"), want: true},
+		{name: "hashtag from another author", filter: Filter{Author: walt, Hashtag: "blue"}, event: ev(jesse, "blue"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.event); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHubDeliversMatchingEvents(t *testing.T) {
+	h := NewHub(10)
+	all, _, _, _ := h.Subscribe(Filter{}, uuid.Nil, 10)
+	byWalt, _, _, _ := h.Subscribe(Filter{Author: walt}, uuid.Nil, 10)
+	followed, _, _, _ := h.Subscribe(Filter{Authors: map[uuid.UUID]bool{jesse: true, skyler: true}}, uuid.Nil, 10)
+	blue, _, _, _ := h.Subscribe(Filter{Hashtag: "blue"}, uuid.Nil, 10)
+
+	e1 := chirpEvent(t, walt, "Say my name #blue")
+	e2 := chirpEvent(t, jesse, "Yeah science! #Blue")
+	e3 := chirpEvent(t, skyler, "I'm the one who counts")
+	publish(t, h, e1, e2, e3, e2)
+
+	tests := []struct {
+		name string
+		sub  *Subscription
+		want []uuid.UUID
+	}{
+		{name: "all", sub: all, want: []uuid.UUID{e1.ID, e2.ID, e3.ID}},
+		{name: "author", sub: byWalt, want: []uuid.UUID{e1.ID}},
+		{name: "followed", sub: followed, want: []uuid.UUID{e2.ID, e3.ID}},
+		{name: "hashtag", sub: blue, want: []uuid.UUID{e1.ID, e2.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(drain(tt.sub)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHubReplay(t *testing.T) {
+	h := NewHub(3)
+	var events []database.OutboxEvent
+	for _, author := range []uuid.UUID{walt, jesse, walt, jesse, walt} {
+		events = append(events, chirpEvent(t, author, "#cook"))
+	}
+	publish(t, h, events...)
+
+	tests := []struct {
+		name        string
+		filter      Filter
+		lastEventID uuid.UUID
+		want        []uuid.UUID
+		resumed     bool
+	}{
+		{name: "new client", resumed: true},
+		{name: "inside the buffer", lastEventID: events[2].ID, want: []uuid.UUID{events[3].ID, events[4].ID}, resumed: true},
+		{name: "inside the buffer, filtered", lastEventID: events[2].ID, filter: Filter{Author: walt}, want: []uuid.UUID{events[4].ID}, resumed: true},
+		{name: "newest", lastEventID: events[4].ID, resumed: true},
+		{name: "evicted", lastEventID: events[1].ID, resumed: false},
+		{name: "unknown", lastEventID: uuid.New(), resumed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, resumed, err := h.Subscribe(tt.filter, tt.lastEventID, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sub.Close()
+			if resumed != tt.resumed {
+				t.Errorf("resumed = %v, want %v", resumed, tt.resumed)
+			}
+			if got := ids(replay); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replayed %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := h.Latest(); !got.Equal(events[4].CreatedAt) {
+		t.Errorf("Latest = %v, want %v", got, events[4].CreatedAt)
+	}
+}
+
+func TestHubReset(t *testing.T) {
+	h := NewHub(10)
+	e := chirpEvent(t, walt, "before")
+	publish(t, h, e)
+	open, _, _, _ := h.Subscribe(Filter{}, uuid.Nil, 10)
+	drain(open)
+
+	h.Reset()
+
+	if !h.Latest().IsZero() {
+		t.Errorf("Latest = %v after reset, want zero", h.Latest())
+	}
+	sub, replay, resumed, err := h.Subscribe(Filter{}, e.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Close()
+	if resumed || len(replay) != 0 {
+		t.Errorf("got resumed %v with %d events, want a reset", resumed, len(replay))
+	}
+
+	// Open subscriptions carry on, and an event from before the reset is
+	// new again
+	publish(t, h, e)
+	if got := ids(drain(open)); !reflect.DeepEqual(got, []uuid.UUID{e.ID}) {
+		t.Errorf("after reset got %v, want %v", got, []uuid.UUID{e.ID})
+	}
+}
+
+func TestHubDropsLaggingSubscriber(t *testing.T) {
+	h := NewHub(10)
+	slow, _, _, _ := h.Subscribe(Filter{}, uuid.Nil, 2)
+	fast, _, _, _ := h.Subscribe(Filter{}, uuid.Nil, 10)
+	filtered, _, _, _ := h.Subscribe(Filter{Author: skyler}, uuid.Nil, 1)
+
+	events := []database.OutboxEvent{chirpEvent(t, walt, "1"), chirpEvent(t, walt, "2"), chirpEvent(t, walt, "3"), chirpEvent(t, walt, "4")}
+	publish(t, h, events...)
+
+	// The slow subscriber keeps what was queued before it fell behind,
+	// then its channel is closed
+	got := drain(slow)
+	if want := []uuid.UUID{events[0].ID, events[1].ID}; !reflect.DeepEqual(ids(got), want) {
+		t.Errorf("slow got %v, want %v", ids(got), want)
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Error("slow subscriber's channel is still open")
+	}
+	if !slow.Lagged() {
+		t.Error("slow subscriber not marked lagged")
+	}
+	slow.Close() // closing a dropped subscription is a no-op
+
+	if got := drain(fast); len(got) != len(events) {
+		t.Errorf("fast got %d events, want %d", len(got), len(events))
+	}
+	if fast.Lagged() {
+		t.Error("fast subscriber marked lagged")
+	}
+
+	// Events a subscriber doesn't match don't fill its queue
+	publish(t, h, chirpEvent(t, skyler, "mine"))
+	if got := drain(filtered); len(got) != 1 || filtered.Lagged() {
+		t.Errorf("filtered got %d events, lagged %v", len(got), filtered.Lagged())
+	}
+}
+
+func TestHubCloseWakesSubscribers(t *testing.T) {
+	h := NewHub(10)
+	var subs []*Subscription
+	for range 3 {
+		sub, _, _, err := h.Subscribe(Filter{}, uuid.Nil, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	finished := make(chan bool)
+	for _, sub := range subs {
+		go func() {
+			for range sub.Events() {
+			}
+			finished <- sub.Lagged()
+		}()
+	}
+
+	h.Close()
+	h.Close()
+	for range subs {
+		select {
+		case lagged := <-finished:
+			if lagged {
+				t.Error("subscriber ended by Close reported as lagged")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("a subscriber was not woken by Close")
+		}
+	}
+
+	select {
+	case <-h.Done():
+	default:
+		t.Error("Done not closed")
+	}
+	if _, _, _, err := h.Subscribe(Filter{}, uuid.Nil, 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe after Close: got %v, want ErrClosed", err)
+	}
+	publish(t, h, chirpEvent(t, walt, "after close"))
+	if !h.Latest().IsZero() {
+		t.Error("event published after Close was buffered")
+	}
+}
@@ -0,0 +1,207 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// finishedSpan returns an ended span with fixed IDs and times.
+func finishedSpan() *Span {
+	s := &Span{
+		tracer: NewTracer(nil),
+		Name:   "GET /api/chirps",
+		Kind:   KindServer,
+		Start:  time.Unix(1700000000, 5),
+		attributes: []Attribute{
+			Attr("http.route", "GET /api/chirps"),
+			Attr("http.response.status_code", 500),
+			Attr("db.rows", int64(3)),
+			Attr("cache.hit", false),
+			Attr("ratio", 0.5),
+			Attr("other", time.Second),
+		},
+	}
+	s.Context, _ = ParseTraceparent("00-" + validTraceID + "-" + validSpanID + "-01")
+	s.Context.TraceState = "rojo=1"
+	s.ParentID = SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+	s.RecordError(errors.New("boom"))
+	s.end = time.Unix(1700000001, 0)
+	s.ended = true
+	return s
+}
+
+func TestToOTLP(t *testing.T) {
+	dat, err := json.Marshal(toOTLP(finishedSpan()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b7",` +
+		`"traceState":"rojo=1","parentSpanId":"0102030405060708","name":"GET /api/chirps","kind":2,` +
+		`"startTimeUnixNano":"1700000000000000005","endTimeUnixNano":"1700000001000000000",` +
+		`"attributes":[` +
+		`{"key":"http.route","value":{"stringValue":"GET /api/chirps"}},` +
+		`{"key":"http.response.status_code","value":{"intValue":"500"}},` +
+		`{"key":"db.rows","value":{"intValue":"3"}},` +
+		`{"key":"cache.hit","value":{"boolValue":false}},` +
+		`{"key":"ratio","value":{"doubleValue":0.5}},` +
+		`{"key":"other","value":{"stringValue":"1s"}}],` +
+		`"status":{"code":2,"message":"boom"}}`
+	if string(dat) != want {
+		t.Errorf("got  %s\nwant %s", dat, want)
+	}
+}
+
+func TestToOTLPRootSpan(t *testing.T) {
+	s := finishedSpan()
+	s.ParentID = SpanID{}
+	s.Context.TraceState = ""
+	s.status, s.statusMessage = StatusUnset, ""
+	dat, err := json.Marshal(toOTLP(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	json.Unmarshal(dat, &got)
+	for _, key := range []string{"parentSpanId", "traceState"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("%s present on a root span: %s", key, dat)
+		}
+	}
+	if status := got["status"].(map[string]any); len(status) != 0 {
+		t.Errorf("unset status encoded as %v", status)
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(NewStdoutExporter(&buf))
+	ctx, parent := tracer.Start(context.Background(), "parent", KindServer)
+	_, child := tracer.Start(ctx, "child", KindClient)
+	child.End()
+	parent.End()
+	parent.End()
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	var first otlpSpan
+	if err := json.Unmarshal(lines[0], &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Name != "child" || first.ParentSpanID != parent.Context.SpanID.String() {
+		t.Errorf("first line = %+v", first)
+	}
+}
+
+func TestUnsampledSpansAreNotExported(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(NewStdoutExporter(&buf))
+	remote, _ := ParseTraceparent("00-" + validTraceID + "-" + validSpanID + "-00")
+	_, span := tracer.Start(ContextWithRemote(context.Background(), remote), "server", KindServer)
+	span.End()
+	if buf.Len() != 0 {
+		t.Errorf("unsampled span exported: %s", buf.String())
+	}
+}
+
+// collector records the OTLP requests it receives.
+type collector struct {
+	mu       sync.Mutex
+	payloads []map[string]any
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.payloads = append(c.payloads, payload)
+	c.mu.Unlock()
+}
+
+func TestOTLPExporterPayload(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	e := NewOTLPExporter(srv.URL+"/v1/traces", "chirpy-test")
+	tracer := NewTracer(e)
+	for i := 0; i < 3; i++ {
+		_, span := tracer.Start(context.Background(), "span "+strconv.Itoa(i), KindInternal)
+		span.End()
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.payloads) != 1 {
+		t.Fatalf("got %d requests, want one batch", len(c.payloads))
+	}
+	// Re-encode the payload to walk it with typed structs
+	dat, _ := json.Marshal(c.payloads[0])
+	var payload struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []otlpAttribute `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(dat, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.ResourceSpans) != 1 || len(payload.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload shape: %s", dat)
+	}
+	rs := payload.ResourceSpans[0]
+	attrs := rs.Resource.Attributes
+	if len(attrs) != 1 || attrs[0].Key != "service.name" || attrs[0].Value["stringValue"] != "chirpy-test" {
+		t.Errorf("resource attributes = %+v", attrs)
+	}
+	if rs.ScopeSpans[0].Scope.Name != "example.com/chirpy/internal/tracing" {
+		t.Errorf("scope = %q", rs.ScopeSpans[0].Scope.Name)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	for i, s := range spans {
+		if s.Name != "span "+strconv.Itoa(i) || len(s.TraceID) != 32 || len(s.SpanID) != 16 {
+			t.Errorf("span %d = %+v", i, s)
+		}
+	}
+}
+
+func TestOTLPExporterCollectorError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	e := &OTLPExporter{endpoint: srv.URL, serviceName: "chirpy", client: srv.Client()}
+	if err := e.send([]*Span{finishedSpan()}); err == nil {
+		t.Fatal("send succeeded on a 503")
+	}
+}
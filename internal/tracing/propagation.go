@@ -0,0 +1,169 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The W3C trace context headers.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// Extract parses the traceparent and tracestate headers of h. An invalid
+// tracestate is dropped without affecting the traceparent.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, ok := ParseTraceparent(h.Get(TraceparentHeader))
+	if !ok {
+		return SpanContext{}, false
+	}
+	// The header may be split over several lines
+	if state, ok := ParseTracestate(strings.Join(h.Values(TracestateHeader), ",")); ok {
+		sc.TraceState = state
+	}
+	return sc, true
+}
+
+// ParseTraceparent parses a version 00 traceparent value:
+// 00-<32 hex trace ID>-<16 hex parent ID>-<2 hex flags>.
+func ParseTraceparent(v string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	// Future versions may append fields, but version 00 has exactly four
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	if len(parts[1]) != 32 || !decodeLower(sc.TraceID[:], parts[1]) {
+		return SpanContext{}, false
+	}
+	if len(parts[2]) != 16 || !decodeLower(sc.SpanID[:], parts[2]) {
+		return SpanContext{}, false
+	}
+	var flags [1]byte
+	if len(parts[3]) != 2 || !decodeLower(flags[:], parts[3]) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// decodeLower decodes s into dst, rejecting upper-case hex as the spec
+// requires.
+func decodeLower(dst []byte, s string) bool {
+	if strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Traceparent formats sc as a traceparent value.
+func Traceparent(sc SpanContext) string {
+	flags := 0
+	if sc.Sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, flags)
+}
+
+// Inject sets the traceparent and tracestate headers of h from the span
+// in ctx.
+func Inject(ctx context.Context, h http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	h.Set(TraceparentHeader, Traceparent(span.Context))
+	if span.Context.TraceState != "" {
+		h.Set(TracestateHeader, span.Context.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+const maxTracestateMembers = 32
+
+// ParseTracestate validates a tracestate value and returns it normalised:
+// empty members and the whitespace around members are removed. It fails if
+// any member is malformed, a key repeats, or there are more than 32
+// members, in which case the spec says to discard the whole list.
+func ParseTracestate(v string) (string, bool) {
+	var members []string
+	seen := map[string]bool{}
+	for _, m := range strings.Split(v, ",") {
+		m = strings.Trim(m, " \t")
+		if m == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(m, "=")
+		if !ok || !validTracestateKey(key) || !validTracestateValue(value) || seen[key] {
+			return "", false
+		}
+		seen[key] = true
+		members = append(members, m)
+	}
+	if len(members) == 0 || len(members) > maxTracestateMembers {
+		return "", false
+	}
+	return strings.Join(members, ","), true
+}
+
+// validTracestateKey accepts a simple key, or a multi-tenant key of the
+// form tenant@system.
+func validTracestateKey(key string) bool {
+	tenant, system, multi := strings.Cut(key, "@")
+	if !multi {
+		return len(key) <= 256 && isKeyPart(key, true)
+	}
+	return len(tenant) <= 241 && isKeyPart(tenant, false) &&
+		len(system) <= 14 && isKeyPart(system, true)
+}
+
+// isKeyPart checks the lcalpha / lcalpha-or-digit first character and the
+// allowed characters of a tracestate key.
+func isKeyPart(s string, mustStartWithLetter bool) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9':
+			if i == 0 && mustStartWithLetter {
+				return false
+			}
+		case i > 0 && (c == '_' || c == '-' || c == '*' || c == '/'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validTracestateValue accepts up to 256 printable ASCII characters other
+// than ',' and '=', not ending in a space.
+func validTracestateValue(v string) bool {
+	if v == "" || len(v) > 256 || v[len(v)-1] == ' ' {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	validTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	validSpanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		ok      bool
+		sampled bool
+	}{
+		{"sampled", "00-" + validTraceID + "-" + validSpanID + "-01", true, true},
+		{"not sampled", "00-" + validTraceID + "-" + validSpanID + "-00", true, false},
+		{"other flags", "00-" + validTraceID + "-" + validSpanID + "-03", true, true},
+		{"surrounding whitespace", " 00-" + validTraceID + "-" + validSpanID + "-01 ", true, true},
+		{"future version with extra field", "01-" + validTraceID + "-" + validSpanID + "-01-extra", true, true},
+		{"version 00 with extra field", "00-" + validTraceID + "-" + validSpanID + "-01-extra", false, false},
+		{"version ff", "ff-" + validTraceID + "-" + validSpanID + "-01", false, false},
+		{"upper-case hex", "00-" + strings.ToUpper(validTraceID) + "-" + validSpanID + "-01", false, false},
+		{"zero trace ID", "00-00000000000000000000000000000000-" + validSpanID + "-01", false, false},
+		{"zero span ID", "00-" + validTraceID + "-0000000000000000-01", false, false},
+		{"short trace ID", "00-" + validTraceID[:30] + "-" + validSpanID + "-01", false, false},
+		{"non-hex", "00-" + validTraceID + "-" + "zzf067aa0ba902b7" + "-01", false, false},
+		{"missing flags", "00-" + validTraceID + "-" + validSpanID, false, false},
+		{"empty", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.in)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if sc.TraceID.String() != validTraceID || sc.SpanID.String() != validSpanID {
+				t.Errorf("got IDs %s/%s", sc.TraceID, sc.SpanID)
+			}
+			if sc.Sampled != tt.sampled {
+				t.Errorf("Sampled = %v, want %v", sc.Sampled, tt.sampled)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, in := range []string{
+		"00-" + validTraceID + "-" + validSpanID + "-01",
+		"00-" + validTraceID + "-" + validSpanID + "-00",
+	} {
+		sc, ok := ParseTraceparent(in)
+		if !ok {
+			t.Fatalf("ParseTraceparent(%q) failed", in)
+		}
+		if got := Traceparent(sc); got != in {
+			t.Errorf("Traceparent() = %q, want %q", got, in)
+		}
+	}
+}
+
+func TestParseTracestate(t *testing.T) {
+	many := make([]string, 33)
+	for i := range many {
+		many[i] = "k" + strings.Repeat("a", i) + "=v"
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+		ok   bool
+	}{
+		{"single", "congo=t61rcWkgMzE", "congo=t61rcWkgMzE", true},
+		{"several", "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", true},
+		{"whitespace and empty members", " rojo=1 ,\t, congo=2", "rojo=1,congo=2", true},
+		{"multi-tenant key", "fw529a3039@dt=abc", "fw529a3039@dt=abc", true},
+		{"key characters", "a-b_c*d/e=1", "a-b_c*d/e=1", true},
+		{"value with spaces inside", "k=a b", "k=a b", true},
+		{"32 members", strings.Join(many[:32], ","), strings.Join(many[:32], ","), true},
+		{"33 members", strings.Join(many, ","), "", false},
+		{"empty", "", "", false},
+		{"upper-case key", "Rojo=1", "", false},
+		{"key starting with a digit", "1rojo=1", "", false},
+		{"tenant starting with a digit", "1rojo@sys=1", "1rojo@sys=1", true},
+		{"system starting with a digit", "rojo@1sys=1", "", false},
+		{"missing value", "rojo=", "", false},
+		{"missing equals", "rojo", "", false},
+		{"equals in value", "rojo=a=b", "", false},
+		{"non-ASCII value", "rojo=é", "", false},
+		{"duplicate key", "rojo=1,rojo=2", "", false},
+		{"long value", "k=" + strings.Repeat("v", 257), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseTracestate(tt.in)
+			if ok != tt.ok {
+				t.Fatalf("ParseTracestate(%q) ok = %v, want %v", tt.in, ok, tt.ok)
+			}
+			if ok && got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	h := http.Header{}
+	h.Set(TraceparentHeader, "00-"+validTraceID+"-"+validSpanID+"-01")
+	h.Add(TracestateHeader, "rojo=1")
+	h.Add(TracestateHeader, "congo=2")
+
+	sc, ok := Extract(h)
+	if !ok {
+		t.Fatal("Extract failed")
+	}
+	if sc.TraceState != "rojo=1,congo=2" {
+		t.Errorf("TraceState = %q, want the header lines joined", sc.TraceState)
+	}
+
+	// An invalid tracestate is dropped, but the trace is still continued
+	h.Set(TracestateHeader, "Bad Key=1")
+	sc, ok = Extract(h)
+	if !ok || sc.TraceState != "" {
+		t.Errorf("with invalid tracestate: got %+v, %v", sc, ok)
+	}
+
+	// tracestate means nothing without a traceparent
+	h.Del(TraceparentHeader)
+	h.Set(TracestateHeader, "rojo=1")
+	if _, ok := Extract(h); ok {
+		t.Error("Extract succeeded without a traceparent")
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	in := http.Header{}
+	in.Set(TraceparentHeader, "00-"+validTraceID+"-"+validSpanID+"-01")
+	in.Set(TracestateHeader, "rojo=1")
+	remote, ok := Extract(in)
+	if !ok {
+		t.Fatal("Extract failed")
+	}
+
+	tracer := NewTracer(nil)
+	ctx, server := tracer.Start(ContextWithRemote(context.Background(), remote), "server", KindServer)
+	if server.ParentID.String() != validSpanID || server.Context.TraceID.String() != validTraceID {
+		t.Fatalf("server span doesn't continue the remote trace: %+v", server.Context)
+	}
+	if !server.Context.Sampled {
+		t.Error("server span lost the sampled flag")
+	}
+	ctx, client := tracer.Start(ctx, "client", KindClient)
+	if client.ParentID != server.Context.SpanID || client.Context.TraceState != "rojo=1" {
+		t.Fatalf("child span: %+v parent %s", client.Context, client.ParentID)
+	}
+
+	out := http.Header{}
+	out.Set(TracestateHeader, "stale=1")
+	Inject(ctx, out)
+	want := "00-" + validTraceID + "-" + client.Context.SpanID.String() + "-01"
+	if got := out.Get(TraceparentHeader); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+	if got := out.Get(TracestateHeader); got != "rojo=1" {
+		t.Errorf("tracestate = %q, want rojo=1", got)
+	}
+
+	// A new trace has no tracestate, and Inject clears a stale one
+	ctx, _ = tracer.Start(context.Background(), "root", KindInternal)
+	Inject(ctx, out)
+	if out.Get(TracestateHeader) != "" {
+		t.Errorf("tracestate = %q, want none", out.Get(TracestateHeader))
+	}
+}
@@ -0,0 +1,63 @@
+// Package webhooks delivers chirpy events to user-registered HTTP endpoints.
+//
+// Handlers never call out to subscribers directly. Instead they write an
+// event to the outbox_events table inside the same transaction as the change
+// that caused it, and a Worker fans the events out to matching subscriptions
+// and delivers them with retries. An event is therefore never lost if the
+// process dies after the handler commits.
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+
+	"example.com/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Event types that can be subscribed to.
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserFollowed = "user.followed"
+	EventMention      = "mention"
+)
+
+// EventTypes lists every event type a subscription may ask for.
+var EventTypes = []string{
+	EventChirpCreated,
+	EventChirpDeleted,
+	EventUserFollowed,
+	EventMention,
+}
+
+// IsEventType reports whether t is a known event type.
+func IsEventType(t string) bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// OutboxWriter inserts outbox events. Both *database.Queries and
+// store.OutboxRepository implement it.
+type OutboxWriter interface {
+	InsertOutboxEvent(ctx context.Context, arg database.InsertOutboxEventParams) (database.OutboxEvent, error)
+}
+
+// Enqueue records an event for userID's subscriptions in the outbox and
+// returns it. q should be bound to the transaction that made the change
+// being announced.
+func Enqueue(ctx context.Context, q OutboxWriter, eventType string, userID uuid.UUID, data any) (database.OutboxEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return database.OutboxEvent{}, err
+	}
+	return q.InsertOutboxEvent(ctx, database.InsertOutboxEventParams{
+		EventType: eventType,
+		UserID:    userID,
+		Payload:   payload,
+	})
+}
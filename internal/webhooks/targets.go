@@ -0,0 +1,119 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrInsecureURL is returned for webhook URLs that don't use https.
+	ErrInsecureURL = errors.New("webhook url must use https")
+	// ErrDisallowedAddress is returned for webhook hosts that resolve to
+	// a loopback, private, link-local or otherwise non-public address.
+	ErrDisallowedAddress = errors.New("webhook url must resolve to a public address")
+)
+
+// nonPublic lists the special-purpose ranges that netip.Addr's methods
+// don't already cover.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// IsPublicAddr reports whether ip is a globally routable unicast address,
+// and so safe for the server to send requests to.
+func IsPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublic {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL reports whether rawURL may be registered as a webhook target:
+// it must use https, or http when allowHTTP is set, and its host must only
+// resolve to public addresses. The worker checks the address again when it
+// connects, since DNS can change after registration.
+func CheckURL(ctx context.Context, rawURL string, allowHTTP bool) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if target.Scheme != "https" && !(allowHTTP && target.Scheme == "http") {
+		return ErrInsecureURL
+	}
+
+	host := target.Hostname()
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !IsPublicAddr(ip) {
+			return ErrDisallowedAddress
+		}
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if !IsPublicAddr(ip) {
+			return ErrDisallowedAddress
+		}
+	}
+	return nil
+}
+
+// refuseNonPublic is a net.Dialer Control function that stops connections
+// to non-public addresses. It runs after name resolution, so a host can't
+// pass registration and later resolve somewhere internal.
+func refuseNonPublic(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrDisallowedAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// newClient returns the HTTP client deliveries are sent with. It only
+// connects to public addresses, ignores proxy settings, which would
+// bypass that check, and doesn't follow redirects.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: refuseNonPublic,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,114 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"example.com/chirpy/internal/database"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublicAddr(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("IsPublicAddr(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url       string
+		allowHTTP bool
+		want      error
+	}{
+		{"https://93.184.216.34/hook", false, nil},
+		{"http://93.184.216.34/hook", false, ErrInsecureURL},
+		{"http://93.184.216.34/hook", true, nil},
+		{"ftp://93.184.216.34/hook", true, ErrInsecureURL},
+		{"https://127.0.0.1/hook", false, ErrDisallowedAddress},
+		{"https://[::1]:8443/hook", false, ErrDisallowedAddress},
+		{"https://169.254.169.254/latest/meta-data", false, ErrDisallowedAddress},
+		{"http://10.0.0.1/hook", true, ErrDisallowedAddress},
+		{"https://localhost/hook", false, ErrDisallowedAddress},
+	}
+	for _, tt := range tests {
+		err := CheckURL(context.Background(), tt.url, tt.allowHTTP)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("CheckURL(%q, %v) = %v, want %v", tt.url, tt.allowHTTP, err, tt.want)
+		}
+	}
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	resp, err := newClient(0).Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrDisallowedAddress) {
+		t.Fatalf("got %v, want ErrDisallowedAddress", err)
+	}
+	if called {
+		t.Error("request reached the loopback server")
+	}
+}
+
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	// Use the redirect policy with a dialer that allows the test server
+	client := newClient(0)
+	client.Transport = http.DefaultTransport
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("got status %d, want the redirect itself", resp.StatusCode)
+	}
+}
+
+func TestSendRequiresHTTPS(t *testing.T) {
+	w := NewWorker(nil, 0)
+	sub := database.WebhookSubscription{Url: "http://93.184.216.34/hook", Secret: "secret"}
+	_, err := w.send(context.Background(), sub, database.WebhookDelivery{}, database.OutboxEvent{Payload: []byte("{}")})
+	if !errors.Is(err, ErrInsecureURL) {
+		t.Fatalf("got %v, want ErrInsecureURL", err)
+	}
+}
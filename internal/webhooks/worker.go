@@ -0,0 +1,260 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent     = "X-Chirpy-Event"
+	HeaderDelivery  = "X-Chirpy-Delivery"
+	HeaderTimestamp = "X-Chirpy-Timestamp"
+	HeaderSignature = "X-Chirpy-Signature"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is
+	// moved to the dead-letter state.
+	MaxAttempts = 8
+
+	baseBackoff  = 30 * time.Second
+	maxBackoff   = 6 * time.Hour
+	batchSize    = 50
+	leaseTimeout = 2 * time.Minute
+)
+
+// Payload is the JSON body POSTed to subscribers.
+type Payload struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Worker relays outbox events into deliveries and sends them.
+type Worker struct {
+	store    store.Store
+	client   *http.Client
+	interval time.Duration
+
+	// AllowHTTP permits deliveries to plain http URLs. Only development
+	// servers set it.
+	AllowHTTP bool
+
+	// OnDelivery, if set, is called after each delivery attempt with
+	// OutcomeSucceeded, OutcomeFailed or OutcomeDead.
+	OnDelivery func(outcome string)
+
+	// OnTick, if set, is called after each polling pass with the pass's
+	// errors joined, or nil.
+	OnTick func(err error)
+}
+
+// Delivery attempt outcomes reported to Worker.OnDelivery.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeDead      = "dead"
+)
+
+// NewWorker -
+func NewWorker(s store.Store, interval time.Duration) *Worker {
+	return &Worker{
+		store:    s,
+		client:   newClient(10 * time.Second),
+		interval: interval,
+	}
+}
+
+// Run polls the outbox and due deliveries every interval until ctx is
+// cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			relayErr := w.relay(ctx)
+			if relayErr != nil {
+				slog.ErrorContext(ctx, "relaying outbox events", "error", relayErr)
+			}
+			deliverErr := w.deliverDue(ctx)
+			if deliverErr != nil {
+				slog.ErrorContext(ctx, "delivering webhooks", "error", deliverErr)
+			}
+			if w.OnTick != nil && ctx.Err() == nil {
+				w.OnTick(errors.Join(relayErr, deliverErr))
+			}
+		}
+	}
+}
+
+// relay turns unprocessed outbox events into one pending delivery per
+// matching subscription. On Postgres events are locked with SKIP LOCKED so
+// several replicas can relay concurrently.
+func (w *Worker) relay(ctx context.Context) error {
+	return w.store.WithTx(ctx, func(tx store.Store) error {
+		events, err := tx.Outbox().ClaimOutboxEvents(ctx, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			subs, err := tx.Webhooks().ListWebhookSubscriptionsForEvent(ctx, database.ListWebhookSubscriptionsForEventParams{
+				UserID:    event.UserID,
+				EventType: event.EventType,
+			})
+			if err != nil {
+				return err
+			}
+			for _, sub := range subs {
+				err := tx.Webhooks().CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+					SubscriptionID: sub.ID,
+					EventID:        event.ID,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if err := tx.Outbox().MarkOutboxEventProcessed(ctx, event.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deliverDue sends every delivery whose next attempt is due. Claiming pushes
+// next_attempt_at forward by a lease so a crashed worker's deliveries are
+// picked up again later.
+func (w *Worker) deliverDue(ctx context.Context) error {
+	deliveries, err := w.store.Webhooks().ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: time.Now().UTC().Add(leaseTimeout),
+		BatchSize:  batchSize,
+	})
+	if err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		if err := w.deliver(ctx, d); err != nil {
+			slog.ErrorContext(ctx, "recording webhook delivery", "delivery_id", d.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (w *Worker) deliver(ctx context.Context, d database.WebhookDelivery) error {
+	sub, err := w.store.Webhooks().GetWebhookSubscription(ctx, d.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	event, err := w.store.Outbox().GetOutboxEvent(ctx, d.EventID)
+	if err != nil {
+		return err
+	}
+
+	statusCode, sendErr := w.send(ctx, sub, d, event)
+	code := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+	if sendErr == nil {
+		w.report(OutcomeSucceeded)
+		return w.store.Webhooks().MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+			ID:             d.ID,
+			LastStatusCode: code,
+		})
+	}
+
+	status, outcome := StatusPending, OutcomeFailed
+	if int(d.Attempts)+1 >= MaxAttempts {
+		status, outcome = StatusDead, OutcomeDead
+	}
+	w.report(outcome)
+	return w.store.Webhooks().MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		ID:             d.ID,
+		Status:         status,
+		NextAttemptAt:  time.Now().UTC().Add(Backoff(int(d.Attempts) + 1)),
+		LastStatusCode: code,
+		LastError:      sql.NullString{String: sendErr.Error(), Valid: true},
+	})
+}
+
+func (w *Worker) report(outcome string) {
+	if w.OnDelivery != nil {
+		w.OnDelivery(outcome)
+	}
+}
+
+func (w *Worker) send(ctx context.Context, sub database.WebhookSubscription, d database.WebhookDelivery, event database.OutboxEvent) (int, error) {
+	body, err := json.Marshal(Payload{
+		ID:        event.ID,
+		Type:      event.EventType,
+		CreatedAt: event.CreatedAt,
+		Data:      event.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if req.URL.Scheme != "https" && !w.AllowHTTP {
+		return 0, ErrInsecureURL
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event.EventType)
+	req.Header.Set(HeaderDelivery, d.ID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, "v1="+auth.SignWebhook(sub.Secret, ts, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the delay before the given attempt: exponential from
+// baseBackoff, capped at maxBackoff, with up to 20% jitter.
+func Backoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d + time.Duration(rand.Int64N(int64(d)/5+1))
+}
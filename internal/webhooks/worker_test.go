@@ -0,0 +1,334 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// received is a request a test target was sent.
+type received struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// target is an httptest subscriber endpoint that answers with status and
+// keeps what it receives.
+type target struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []received
+}
+
+func newTarget(t *testing.T, status int) *target {
+	t.Helper()
+	tg := &target{status: status}
+	tg.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		tg.mu.Lock()
+		tg.requests = append(tg.requests, received{path: r.URL.Path, header: r.Header.Clone(), body: body})
+		status := tg.status
+		tg.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(tg.Close)
+	return tg
+}
+
+func (tg *target) received() []received {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	return append([]received(nil), tg.requests...)
+}
+
+// newTestWorker returns a worker over s that may deliver to the loopback
+// test targets, and the outcomes it reports.
+func newTestWorker(s store.Store, tg *target) (*Worker, *[]string) {
+	w := NewWorker(s, time.Hour)
+	w.client = tg.Client()
+	w.AllowHTTP = true
+	var outcomes []string
+	w.OnDelivery = func(outcome string) { outcomes = append(outcomes, outcome) }
+	return w, &outcomes
+}
+
+func createUser(t *testing.T, s store.Store, email string) uuid.UUID {
+	t.Helper()
+	u, err := s.Users().CreateUser(context.Background(), database.CreateUserParams{Email: email, HashedPassword: "unused"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.ID
+}
+
+func subscribe(t *testing.T, s store.Store, userID uuid.UUID, url, secret string, events ...string) database.WebhookSubscription {
+	t.Helper()
+	sub, err := s.Webhooks().CreateWebhookSubscription(context.Background(), database.CreateWebhookSubscriptionParams{
+		UserID: userID,
+		Url:    url,
+		Secret: secret,
+		Events: events,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func insertEvent(t *testing.T, s store.Store, userID uuid.UUID, eventType string) database.OutboxEvent {
+	t.Helper()
+	e, err := s.Outbox().InsertOutboxEvent(context.Background(), database.InsertOutboxEventParams{
+		EventType: eventType,
+		UserID:    userID,
+		Payload:   json.RawMessage(`{"body":"Say my name"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func deliveries(t *testing.T, s store.Store, subID uuid.UUID) []database.WebhookDelivery {
+	t.Helper()
+	ds, err := s.Webhooks().ListWebhookDeliveries(context.Background(), database.ListWebhookDeliveriesParams{SubscriptionID: subID, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func TestWorkerRelaysAndDelivers(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	tg := newTarget(t, http.StatusNoContent)
+	w, outcomes := newTestWorker(s, tg)
+
+	walt := createUser(t, s, "walt@example.com")
+	jesse := createUser(t, s, "jesse@example.com")
+	created := subscribe(t, s, walt, tg.URL+"/created", "secret-1", EventChirpCreated)
+	both := subscribe(t, s, walt, tg.URL+"/both", "secret-2", EventChirpCreated, EventChirpDeleted)
+	followed := subscribe(t, s, walt, tg.URL+"/followed", "secret-3", EventUserFollowed)
+	other := subscribe(t, s, jesse, tg.URL+"/other", "secret-4", EventChirpCreated)
+
+	event := insertEvent(t, s, walt, EventChirpCreated)
+	if err := w.relay(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// One pending delivery per matching subscription of the event's user
+	for _, tt := range []struct {
+		sub  database.WebhookSubscription
+		want int
+	}{{created, 1}, {both, 1}, {followed, 0}, {other, 0}} {
+		if got := deliveries(t, s, tt.sub.ID); len(got) != tt.want {
+			t.Errorf("%s: got %d deliveries, want %d", tt.sub.Url, len(got), tt.want)
+		}
+	}
+	if claimed, err := s.Outbox().ClaimOutboxEvents(ctx, 10); err != nil || len(claimed) != 0 {
+		t.Errorf("outbox after relay: got %d unprocessed events, error %v", len(claimed), err)
+	}
+
+	// Relaying again doesn't create more deliveries
+	if err := w.relay(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := deliveries(t, s, created.ID); len(got) != 1 {
+		t.Errorf("after a second relay got %d deliveries, want 1", len(got))
+	}
+
+	if err := w.deliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	reqs := tg.received()
+	if len(reqs) != 2 {
+		t.Fatalf("target got %d requests, want 2", len(reqs))
+	}
+	secrets := map[string]string{"/created": "secret-1", "/both": "secret-2"}
+	var deliveryIDs []string
+	for _, req := range reqs {
+		var p Payload
+		if err := json.Unmarshal(req.body, &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.ID != event.ID || p.Type != EventChirpCreated || string(p.Data) != `{"body":"Say my name"}` {
+			t.Errorf("got payload %+v", p)
+		}
+		if req.header.Get(HeaderEvent) != EventChirpCreated {
+			t.Errorf("%s = %q", HeaderEvent, req.header.Get(HeaderEvent))
+		}
+		deliveryIDs = append(deliveryIDs, req.header.Get(HeaderDelivery))
+
+		// Each request is signed with its own subscription's secret
+		err := auth.VerifyWebhook(req.body, req.header.Get(HeaderTimestamp), req.header.Get(HeaderSignature), []string{secrets[req.path]}, time.Minute, time.Now())
+		if err != nil {
+			t.Errorf("%s: %v", req.path, err)
+		}
+	}
+	want := []string{deliveries(t, s, created.ID)[0].ID.String(), deliveries(t, s, both.ID)[0].ID.String()}
+	sort.Strings(want)
+	sort.Strings(deliveryIDs)
+	if deliveryIDs[0] != want[0] || deliveryIDs[1] != want[1] {
+		t.Errorf("got delivery IDs %v, want %v", deliveryIDs, want)
+	}
+
+	for _, sub := range []database.WebhookSubscription{created, both} {
+		d := deliveries(t, s, sub.ID)[0]
+		if d.Status != StatusSucceeded || d.Attempts != 1 || !d.DeliveredAt.Valid || d.LastStatusCode.Int32 != http.StatusNoContent {
+			t.Errorf("%s: got %+v, want a succeeded delivery", sub.Url, d)
+		}
+	}
+	if len(*outcomes) != 2 || (*outcomes)[0] != OutcomeSucceeded || (*outcomes)[1] != OutcomeSucceeded {
+		t.Errorf("outcomes = %v", *outcomes)
+	}
+
+	// Delivered events aren't sent again
+	if err := w.deliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(tg.received()); got != 2 {
+		t.Errorf("target got %d requests after a second pass, want 2", got)
+	}
+}
+
+func TestWorkerRefusesInsecureURL(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	tg := newTarget(t, http.StatusOK)
+	w, outcomes := newTestWorker(s, tg)
+	w.AllowHTTP = false
+
+	walt := createUser(t, s, "walt@example.com")
+	sub := subscribe(t, s, walt, tg.URL, "secret", EventChirpCreated)
+	insertEvent(t, s, walt, EventChirpCreated)
+	if err := w.relay(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.deliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(tg.received()); got != 0 {
+		t.Errorf("target got %d requests over http", got)
+	}
+	d := deliveries(t, s, sub.ID)[0]
+	if d.Status != StatusPending || d.LastError.String != ErrInsecureURL.Error() || d.LastStatusCode.Valid {
+		t.Errorf("got %+v, want a failed attempt", d)
+	}
+	if len(*outcomes) != 1 || (*outcomes)[0] != OutcomeFailed {
+		t.Errorf("outcomes = %v", *outcomes)
+	}
+}
+
+func TestWorkerDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	tg := newTarget(t, http.StatusInternalServerError)
+	w, outcomes := newTestWorker(s, tg)
+
+	walt := createUser(t, s, "walt@example.com")
+	sub := subscribe(t, s, walt, tg.URL, "secret", EventChirpCreated)
+	insertEvent(t, s, walt, EventChirpCreated)
+	if err := w.relay(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first attempt is claimed as due; later ones are scheduled in the
+	// future, so each is sent directly rather than waiting out the backoff
+	if err := w.deliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for attempt := 1; ; attempt++ {
+		d := deliveries(t, s, sub.ID)[0]
+		if d.Attempts != int32(attempt) {
+			t.Fatalf("after attempt %d: attempts = %d", attempt, d.Attempts)
+		}
+		if d.LastStatusCode.Int32 != http.StatusInternalServerError || d.LastError.String != "subscriber responded with 500" {
+			t.Errorf("attempt %d: got status %v, error %q", attempt, d.LastStatusCode, d.LastError.String)
+		}
+		if attempt == MaxAttempts {
+			if d.Status != StatusDead {
+				t.Errorf("after %d attempts status = %s, want %s", attempt, d.Status, StatusDead)
+			}
+			break
+		}
+		if d.Status != StatusPending {
+			t.Fatalf("attempt %d: status = %s, want %s", attempt, d.Status, StatusPending)
+		}
+		backoff := baseBackoff << (attempt - 1)
+		if wait := time.Until(d.NextAttemptAt); wait < backoff-time.Second || wait > backoff*6/5 {
+			t.Errorf("attempt %d: next attempt in %v, want about %v", attempt, wait, backoff)
+		}
+		if err := w.deliver(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := len(tg.received()); got != MaxAttempts {
+		t.Errorf("target got %d requests, want %d", got, MaxAttempts)
+	}
+	for i, outcome := range *outcomes {
+		want := OutcomeFailed
+		if i == MaxAttempts-1 {
+			want = OutcomeDead
+		}
+		if outcome != want {
+			t.Errorf("outcome %d = %s, want %s", i+1, outcome, want)
+		}
+	}
+
+	// A dead delivery is never claimed again
+	due, err := s.Webhooks().ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(time.Minute),
+		BatchSize:  10,
+	})
+	if err != nil || len(due) != 0 {
+		t.Errorf("claimed %d dead deliveries, error %v", len(due), err)
+	}
+}
+
+func TestWorkerMissingSubscription(t *testing.T) {
+	s := store.NewMemory()
+	w, _ := newTestWorker(s, newTarget(t, http.StatusOK))
+	err := w.deliver(context.Background(), database.WebhookDelivery{ID: uuid.New(), SubscriptionID: uuid.New()})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		min     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{9, 128 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, maxBackoff},
+		{100, maxBackoff},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			got := Backoff(tt.attempt)
+			if got < tt.min || got > tt.min*6/5 {
+				t.Fatalf("Backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.min, tt.min*6/5)
+			}
+		}
+	}
+}
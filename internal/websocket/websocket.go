@@ -0,0 +1,413 @@
+// Package websocket implements the server side of the WebSocket protocol
+// (RFC 6455): the opening handshake, framing with fragmentation, control
+// frames and the closing handshake. Extensions and subprotocols aren't
+// supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+const (
+	opContinuation = 0
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+// Close codes from RFC 6455. Applications may use 4000-4999.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	// CloseNoStatus is reported when a close frame has no code. It is
+	// never sent.
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// acceptGUID is appended to the client's key to prove the server speaks
+// WebSocket.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxControlPayload is the largest payload a control frame may carry.
+const maxControlPayload = 125
+
+// MaxReadLimit caps the messages ReadMessage accepts whatever ReadLimit
+// says, since a frame's header alone decides how much is allocated for
+// it.
+const MaxReadLimit = 16 << 20
+
+// closeTimeout bounds sending the close frame.
+const closeTimeout = time.Second
+
+// ErrBadHandshake is returned by Upgrade for requests that aren't valid
+// WebSocket opening handshakes.
+var ErrBadHandshake = errors.New("websocket: bad handshake")
+
+// CloseError is returned by ReadMessage once the connection is closed with
+// a close frame, whether the peer sent it or the connection closed itself
+// because the peer broke the protocol.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: closed with code %d: %s", e.Code, e.Reason)
+}
+
+// IsUpgrade reports whether r asks to switch to WebSocket, so callers can
+// tell plain HTTP requests apart from broken handshakes.
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") && headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade completes the opening handshake and takes over the connection.
+// The headers already set on w are sent with the 101 response. If r isn't
+// a valid handshake nothing is written and the error wraps
+// ErrBadHandshake, so the caller can respond as it likes. Other errors
+// may come after the connection was taken over.
+//
+// Browsers send cookies with cross-origin handshakes, so servers that
+// authenticate with cookies must check Origin. This package doesn't.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, fmt.Errorf("%w: method must be GET", ErrBadHandshake)
+	}
+	if !IsUpgrade(r) {
+		return nil, fmt.Errorf("%w: not an upgrade to websocket", ErrBadHandshake)
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrBadHandshake, r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, fmt.Errorf("%w: invalid Sec-WebSocket-Key", ErrBadHandshake)
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: taking over the connection: %w", err)
+	}
+	// The server's read and write timeouts were set for the HTTP request
+	if err := netConn.SetDeadline(time.Time{}); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if brw.Reader.Buffered() > 0 {
+		// The client can't send frames before it has seen the response
+		netConn.Close()
+		return nil, errors.New("websocket: client sent data before the handshake finished")
+	}
+
+	c := &Conn{conn: netConn, br: brw.Reader, bw: brw.Writer}
+	header := w.Header().Clone()
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", acceptKey(key))
+	c.bw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(c.bw)
+	c.bw.WriteString("\r\n")
+	if err := c.bw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerHasToken reports whether a comma-separated header contains token,
+// ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Conn is a WebSocket connection. One goroutine may call ReadMessage
+// while others write; writes are serialized.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	// ReadLimit, if positive, is the largest message ReadMessage accepts,
+	// up to MaxReadLimit, which applies otherwise. Larger ones close the
+	// connection with CloseMessageTooBig.
+	ReadLimit int64
+	// IdleTimeout, if positive, is how long ReadMessage waits for the next
+	// frame, including pongs, before failing.
+	IdleTimeout time.Duration
+	// WriteTimeout, if positive, bounds each write.
+	WriteTimeout time.Duration
+
+	mu      sync.Mutex
+	bw      *bufio.Writer
+	closed  bool
+	closing atomic.Bool
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and pongs skipped along the way. Once the connection has been closed by
+// either side it returns a *CloseError or the network error.
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	var msg []byte
+	for {
+		if c.IdleTimeout > 0 {
+			if err := c.conn.SetReadDeadline(time.Now().Add(c.IdleTimeout)); err != nil {
+				return 0, nil, err
+			}
+		}
+		f, err := c.readFrame(int64(len(msg)))
+		if err != nil {
+			var ce *CloseError
+			if errors.As(err, &ce) {
+				c.Close(ce.Code, ce.Reason)
+			}
+			return 0, nil, err
+		}
+
+		switch f.op {
+		case opPing:
+			if err := c.write(opPong, f.payload); err != nil {
+				return 0, nil, err
+			}
+		case opPong:
+		case opClose:
+			ce := &CloseError{Code: CloseNoStatus}
+			switch {
+			case len(f.payload) == 1:
+				ce = &CloseError{Code: CloseProtocolError, Reason: "invalid close frame"}
+			case len(f.payload) >= 2:
+				ce.Code = int(binary.BigEndian.Uint16(f.payload))
+				ce.Reason = string(f.payload[2:])
+				if !validCloseCode(ce.Code) || !utf8.ValidString(ce.Reason) {
+					ce = &CloseError{Code: CloseProtocolError, Reason: "invalid close frame"}
+				}
+			}
+			c.Close(ce.Code, "")
+			return 0, nil, ce
+		case opContinuation:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "continuation without a message")
+			}
+			msg = append(msg, f.payload...)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "new message before the last one finished")
+			}
+			messageType = f.op
+			msg = f.payload
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if messageType != 0 && f.fin && !f.control() {
+			if messageType == TextMessage && !utf8.Valid(msg) {
+				return 0, nil, c.fail(CloseInvalidPayload, "text message is not UTF-8")
+			}
+			return messageType, msg, nil
+		}
+	}
+}
+
+type frame struct {
+	fin     bool
+	op      int
+	payload []byte
+}
+
+func (f frame) control() bool {
+	return f.op >= opClose
+}
+
+// readFrame reads and unmasks one frame. buffered is the size of the
+// message read so far, for the read limit.
+func (c *Conn) readFrame(buffered int64) (frame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{fin: head[0]&0x80 != 0, op: int(head[0] & 0x0f)}
+	if head[0]&0x70 != 0 {
+		return frame{}, &CloseError{Code: CloseProtocolError, Reason: "reserved bits set"}
+	}
+	if head[1]&0x80 == 0 {
+		return frame{}, &CloseError{Code: CloseProtocolError, Reason: "client frames must be masked"}
+	}
+
+	n := int64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return frame{}, err
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return frame{}, err
+		}
+		if ext[0]&0x80 != 0 {
+			return frame{}, &CloseError{Code: CloseProtocolError, Reason: "invalid frame length"}
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if f.control() {
+		if !f.fin || n > maxControlPayload {
+			return frame{}, &CloseError{Code: CloseProtocolError, Reason: "invalid control frame"}
+		}
+	} else if limit := c.readLimit(); n > limit-buffered {
+		return frame{}, &CloseError{Code: CloseMessageTooBig, Reason: fmt.Sprintf("messages are limited to %d bytes", limit)}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return frame{}, err
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return frame{}, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+func (c *Conn) readLimit() int64 {
+	if c.ReadLimit <= 0 || c.ReadLimit > MaxReadLimit {
+		return MaxReadLimit
+	}
+	return c.ReadLimit
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code < 1000 || code > 1014:
+		return false
+	}
+	return code != 1004 && code != CloseNoStatus && code != 1006
+}
+
+// fail closes the connection because the peer broke the protocol.
+func (c *Conn) fail(code int, reason string) error {
+	c.Close(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// WriteMessage sends a text or binary message in a single frame.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return c.write(messageType, data)
+}
+
+// Ping sends a ping. The peer answers with a pong, which keeps
+// ReadMessage's IdleTimeout from expiring.
+func (c *Conn) Ping() error {
+	return c.write(opPing, nil)
+}
+
+func (c *Conn) write(op int, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.closing.Load() {
+		return net.ErrClosed
+	}
+	return c.writeLocked(op, payload, c.WriteTimeout)
+}
+
+func (c *Conn) writeLocked(op int, payload []byte, timeout time.Duration) error {
+	if timeout > 0 {
+		if err := c.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+	}
+	c.bw.WriteByte(0x80 | byte(op))
+	switch n := len(payload); {
+	case n <= 125:
+		c.bw.WriteByte(byte(n))
+	case n <= 0xffff:
+		c.bw.WriteByte(126)
+		c.bw.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		c.bw.WriteByte(127)
+		c.bw.Write(binary.BigEndian.AppendUint64(nil, uint64(n)))
+	}
+	c.bw.Write(payload)
+	return c.bw.Flush()
+}
+
+// Close sends a close frame with code and reason, if one hasn't been sent
+// yet, and closes the connection. A reader blocked in ReadMessage returns.
+// It is safe to call more than once and from any goroutine.
+func (c *Conn) Close(code int, reason string) error {
+	// A write blocked on a peer that stopped reading holds the lock; cut
+	// it short rather than wait for WriteTimeout
+	if !c.closing.Swap(true) {
+		c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	var payload []byte
+	if code != CloseNoStatus {
+		// Reasons are cut to fit in a control frame
+		if len(reason) > maxControlPayload-2 {
+			reason = strings.ToValidUTF8(reason[:maxControlPayload-2], "")
+		}
+		payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+	}
+	// The connection may already be gone, in which case there is no one
+	// to tell
+	c.writeLocked(opClose, payload, closeTimeout)
+	return c.conn.Close()
+}
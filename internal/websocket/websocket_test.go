@@ -0,0 +1,482 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestConn returns a Conn for the server end of a TCP connection and
+// the client end, whose frames the tests write by hand.
+func newTestConn(t *testing.T) (*Conn, net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	// No test should wait anywhere near this long
+	client.SetDeadline(time.Now().Add(10 * time.Second))
+	return &Conn{conn: server, br: bufio.NewReader(server), bw: bufio.NewWriter(server)}, client
+}
+
+var testMask = [4]byte{0x37, 0xfa, 0x21, 0x3d}
+
+// writeFrame writes a masked client frame.
+func writeFrame(t *testing.T, w io.Writer, fin bool, op int, payload []byte) {
+	t.Helper()
+	if _, err := w.Write(appendFrame(nil, fin, op, payload, true)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendFrame(b []byte, fin bool, op int, payload []byte, masked bool) []byte {
+	first := byte(op)
+	if fin {
+		first |= 0x80
+	}
+	b = append(b, first)
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		b = append(b, maskBit|byte(n))
+	case n <= 0xffff:
+		b = append(b, maskBit|126)
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, maskBit|127)
+		b = binary.BigEndian.AppendUint64(b, uint64(n))
+	}
+	if !masked {
+		return append(b, payload...)
+	}
+	b = append(b, testMask[:]...)
+	for i, c := range payload {
+		b = append(b, c^testMask[i%4])
+	}
+	return b
+}
+
+// readServerFrame reads a frame the server sent, which must be unmasked,
+// and returns its opcode and payload.
+func readServerFrame(t *testing.T, r io.Reader) (int, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		t.Fatalf("reading frame: %v", err)
+	}
+	if head[0]&0x80 == 0 {
+		t.Fatal("server sent a fragment")
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatal("server frame is masked")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(r, ext[:])
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatalf("reading payload: %v", err)
+	}
+	return int(head[0] & 0x0f), payload
+}
+
+// wantClose checks that the server sent a close frame with code and then
+// closed the connection.
+func wantClose(t *testing.T, client net.Conn, code int) {
+	t.Helper()
+	op, payload := readServerFrame(t, client)
+	if op != opClose || len(payload) < 2 {
+		t.Fatalf("got opcode %d with %q, want a close frame", op, payload)
+	}
+	if got := int(binary.BigEndian.Uint16(payload)); got != code {
+		t.Fatalf("close code = %d (%q), want %d", got, payload[2:], code)
+	}
+	if n, err := client.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Fatalf("after the close frame: read %d, %v, want EOF", n, err)
+	}
+}
+
+func wantCloseError(t *testing.T, err error, code int) {
+	t.Helper()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != code {
+		t.Fatalf("got %v, want a CloseError with code %d", err, code)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc")
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close(CloseNormal, "")
+		// The client checks the echo
+		if typ, msg, err := conn.ReadMessage(); err == nil {
+			conn.WriteMessage(typ, msg)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// The key and accept value are the example in RFC 6455
+	io.WriteString(client, "GET /socket HTTP/1.1\r\nHost: example.com\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Upgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	br := bufio.NewReader(client)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", res.StatusCode)
+	}
+	if got := res.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	if res.Header.Get("X-Request-Id") != "abc" {
+		t.Fatal("headers set before Upgrade weren't sent")
+	}
+
+	writeFrame(t, client, true, TextMessage, []byte("hello"))
+	if op, payload := readServerFrame(t, br); op != TextMessage || string(payload) != "hello" {
+		t.Fatalf("echo = %d %q", op, payload)
+	}
+}
+
+func TestUpgradeBadHandshake(t *testing.T) {
+	valid := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/socket", nil)
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		return r
+	}
+	tests := []struct {
+		name   string
+		modify func(r *http.Request)
+	}{
+		{"POST", func(r *http.Request) { r.Method = http.MethodPost }},
+		{"no upgrade", func(r *http.Request) { r.Header.Del("Upgrade") }},
+		{"old version", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Version", "8") }},
+		{"short key", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Key", "c2hvcnQ=") }},
+	}
+	for _, tt := range tests {
+		r := valid()
+		tt.modify(r)
+		w := httptest.NewRecorder()
+		if _, err := Upgrade(w, r); !errors.Is(err, ErrBadHandshake) {
+			t.Errorf("%s: got %v, want ErrBadHandshake", tt.name, err)
+		}
+		if w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("%s: Upgrade wrote a response", tt.name)
+		}
+	}
+}
+
+func TestFrameLengths(t *testing.T) {
+	// Each of the three ways a length is encoded, at its edges
+	for _, n := range []int{0, 1, 125, 126, 0xffff, 0x10000} {
+		conn, client := newTestConn(t)
+		msg := bytes.Repeat([]byte{'x'}, n)
+
+		go client.Write(appendFrame(nil, true, BinaryMessage, msg, true))
+		typ, got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if typ != BinaryMessage || !bytes.Equal(got, msg) {
+			t.Fatalf("%d bytes: read %d bytes of type %d", n, len(got), typ)
+		}
+
+		go conn.WriteMessage(BinaryMessage, msg)
+		op, payload := readServerFrame(t, client)
+		if op != BinaryMessage || !bytes.Equal(payload, msg) {
+			t.Fatalf("%d bytes: client read %d bytes of type %d", n, len(payload), op)
+		}
+	}
+}
+
+func TestUnmasking(t *testing.T) {
+	conn, client := newTestConn(t)
+	// Masked with a key that changes every byte
+	writeFrame(t, client, true, TextMessage, []byte("Hello, World"))
+	if _, got, err := conn.ReadMessage(); err != nil || string(got) != "Hello, World" {
+		t.Fatalf("ReadMessage = %q, %v", got, err)
+	}
+}
+
+func TestUnmaskedFrame(t *testing.T) {
+	conn, client := newTestConn(t)
+	client.Write(appendFrame(nil, true, TextMessage, []byte("hi"), false))
+	_, _, err := conn.ReadMessage()
+	wantCloseError(t, err, CloseProtocolError)
+	wantClose(t, client, CloseProtocolError)
+}
+
+func TestInvalidFrames(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		code int
+	}{
+		{"reserved bits", []byte{0xc1, 0x80, 0, 0, 0, 0}, CloseProtocolError},
+		{"unknown opcode", appendFrame(nil, true, 3, nil, true), CloseProtocolError},
+		{"fragmented control frame", appendFrame(nil, false, opPing, nil, true), CloseProtocolError},
+		{"long control frame", appendFrame(nil, true, opPing, make([]byte, 126), true), CloseProtocolError},
+		{"negative length", []byte{0x82, 0xff, 0x80, 0, 0, 0, 0, 0, 0, 0}, CloseProtocolError},
+		{"continuation first", appendFrame(nil, true, opContinuation, []byte("x"), true), CloseProtocolError},
+		{"invalid UTF-8", appendFrame(nil, true, TextMessage, []byte{0xff, 0xfe}, true), CloseInvalidPayload},
+		{"one byte close", appendFrame(nil, true, opClose, []byte{3}, true), CloseProtocolError},
+		{"reserved close code", appendFrame(nil, true, opClose, []byte{0x03, 0xed}, true), CloseProtocolError},
+		{
+			"new message mid-message",
+			appendFrame(appendFrame(nil, false, TextMessage, []byte("a"), true), true, TextMessage, []byte("b"), true),
+			CloseProtocolError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := newTestConn(t)
+			client.Write(tt.raw)
+			_, _, err := conn.ReadMessage()
+			wantCloseError(t, err, tt.code)
+			wantClose(t, client, tt.code)
+		})
+	}
+}
+
+func TestFragmentation(t *testing.T) {
+	conn, client := newTestConn(t)
+	// "é" is split across fragments, which is fine once they're joined
+	var raw []byte
+	raw = appendFrame(raw, false, TextMessage, []byte("caf\xc3"), true)
+	raw = appendFrame(raw, true, opPing, []byte("are you there"), true)
+	raw = appendFrame(raw, false, opContinuation, []byte("\xa9 "), true)
+	raw = appendFrame(raw, true, opPong, nil, true)
+	raw = appendFrame(raw, true, opContinuation, []byte("au lait"), true)
+	client.Write(raw)
+
+	typ, got, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != TextMessage || string(got) != "café au lait" {
+		t.Fatalf("ReadMessage = %d %q", typ, got)
+	}
+	// The ping in the middle of the message was answered
+	if op, payload := readServerFrame(t, client); op != opPong || string(payload) != "are you there" {
+		t.Fatalf("got opcode %d with %q, want a pong", op, payload)
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	huge := func(fin bool, op int, n uint64) []byte {
+		first := byte(op)
+		if fin {
+			first |= 0x80
+		}
+		return binary.BigEndian.AppendUint64([]byte{first, 0xff}, n)
+	}
+	tests := []struct {
+		name  string
+		limit int64
+		raw   []byte
+	}{
+		{"one frame", 10, appendFrame(nil, true, BinaryMessage, make([]byte, 11), true)},
+		{
+			"across fragments", 10,
+			appendFrame(appendFrame(nil, false, BinaryMessage, make([]byte, 6), true), true, opContinuation, make([]byte, 6), true),
+		},
+		// The header alone must be refused, before anything is allocated
+		{"no limit set", 0, huge(true, BinaryMessage, 1<<40)},
+		{"limit above the maximum", 1 << 62, huge(true, BinaryMessage, 1<<62)},
+		{
+			"length that overflows", 10,
+			append(appendFrame(nil, false, BinaryMessage, make([]byte, 5), true), huge(true, opContinuation, 1<<63-1)...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := newTestConn(t)
+			conn.ReadLimit = tt.limit
+			client.Write(tt.raw)
+			_, _, err := conn.ReadMessage()
+			wantCloseError(t, err, CloseMessageTooBig)
+			wantClose(t, client, CloseMessageTooBig)
+		})
+	}
+
+	conn, client := newTestConn(t)
+	conn.ReadLimit = 10
+	writeFrame(t, client, true, BinaryMessage, make([]byte, 10))
+	if _, got, err := conn.ReadMessage(); err != nil || len(got) != 10 {
+		t.Fatalf("message at the limit: %d bytes, %v", len(got), err)
+	}
+}
+
+func TestCloseFromClient(t *testing.T) {
+	conn, client := newTestConn(t)
+	writeFrame(t, client, true, opClose, append([]byte{0x03, 0xe8}, "bye"...))
+	_, _, err := conn.ReadMessage()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != CloseNormal || ce.Reason != "bye" {
+		t.Fatalf("got %v, want close 1000 bye", err)
+	}
+	// The server echoes the code and closes the connection
+	wantClose(t, client, CloseNormal)
+	if err := conn.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("write after close: got %v, want net.ErrClosed", err)
+	}
+}
+
+func TestCloseWithoutCode(t *testing.T) {
+	conn, client := newTestConn(t)
+	writeFrame(t, client, true, opClose, nil)
+	_, _, err := conn.ReadMessage()
+	wantCloseError(t, err, CloseNoStatus)
+	// CloseNoStatus is never sent, so the reply is empty too
+	op, payload := readServerFrame(t, client)
+	if op != opClose || len(payload) != 0 {
+		t.Fatalf("got opcode %d with %q, want an empty close frame", op, payload)
+	}
+}
+
+func TestCloseFromServer(t *testing.T) {
+	conn, client := newTestConn(t)
+	reason := strings.Repeat("é", 100)
+	if err := conn.Close(CloseGoingAway, reason); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Close(CloseNormal, ""); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	op, payload := readServerFrame(t, client)
+	if op != opClose || binary.BigEndian.Uint16(payload) != CloseGoingAway {
+		t.Fatalf("got opcode %d with %q, want close 1001", op, payload)
+	}
+	// The reason was cut to fit without splitting a character
+	if got := string(payload[2:]); len(got) != 122 || !strings.HasPrefix(reason, got) {
+		t.Fatalf("reason = %d bytes %q", len(got), got)
+	}
+	if _, err := client.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("after the close frame: %v, want EOF", err)
+	}
+}
+
+func TestCloseUnblocksReader(t *testing.T) {
+	conn, _ := newTestConn(t)
+	done := make(chan error)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		done <- err
+	}()
+	conn.Close(CloseGoingAway, "")
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("ReadMessage returned a message")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ReadMessage still blocked after Close")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	conn, _ := newTestConn(t)
+	conn.IdleTimeout = 50 * time.Millisecond
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("got %v, want a deadline error", err)
+	}
+}
+
+// slowClient returns a Conn whose peer never reads: net.Pipe has no
+// buffer, so every write blocks.
+func slowClient(t *testing.T) *Conn {
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return &Conn{conn: server, br: bufio.NewReader(server), bw: bufio.NewWriter(server)}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	conn := slowClient(t)
+	conn.WriteTimeout = 50 * time.Millisecond
+	if err := conn.WriteMessage(TextMessage, []byte("hello")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("got %v, want a deadline error", err)
+	}
+}
+
+func TestCloseInterruptsBlockedWrite(t *testing.T) {
+	conn := slowClient(t)
+	written := make(chan error)
+	go func() {
+		written <- conn.WriteMessage(TextMessage, []byte("hello"))
+	}()
+	// Let the write block on the peer
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	closed := make(chan error)
+	go func() { closed <- conn.Close(ClosePolicyViolation, "client too slow") }()
+	for _, ch := range []chan error{written, closed} {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("still blocked on a client that doesn't read")
+		}
+	}
+	// Without the cut-off the write would wait forever, as WriteTimeout
+	// isn't set
+	if d := time.Since(start); d > 3*closeTimeout {
+		t.Fatalf("Close took %v", d)
+	}
+	if err := conn.WriteMessage(TextMessage, nil); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("write after close: got %v, want net.ErrClosed", err)
+	}
+}
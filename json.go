@@ -2,30 +2,60 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"example.com/chirpy/internal/problem"
+	"log/slog"
 	"net/http"
 )
 
-func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if err != nil {
-		log.Println(err)
+// respondWithError writes err as a problem+json document. Errors that
+// aren't an *apiError become a generic 500. The cause is attached to the
+// request's log line rather than sent to the client.
+func respondWithError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := recordError(w, r, err)
+	respondWithProblem(w, apiErr.problem(r.URL.Path, w.Header().Get(requestIDHeader)))
+}
+
+// recordError returns err as an *apiError and attaches its cause to the
+// request's log line, for handlers that report errors other than as a
+// problem document.
+func recordError(w http.ResponseWriter, r *http.Request, err error) *apiError {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		apiErr = internalError("Internal server error", err)
 	}
-	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+
+	cause := apiErr.err
+	if cause == nil && apiErr.status > 499 {
+		cause = errors.New(apiErr.detail)
 	}
-	type errorResponse struct {
-		Error string `json:"error"`
+	if cause != nil {
+		if rec, ok := w.(*statusRecorder); ok {
+			rec.err = cause
+		} else {
+			slog.ErrorContext(r.Context(), "request failed", "status", apiErr.status, "error", cause)
+		}
 	}
-	respondWithJSON(w, code, errorResponse{
-		Error: msg,
-	})
+	return apiErr
+}
+
+func respondWithProblem(w http.ResponseWriter, p problem.Problem) {
+	w.Header().Set("Content-Type", problem.ContentType)
+	dat, err := json.Marshal(p)
+	if err != nil {
+		slog.Error("marshalling problem", "error", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(p.Status)
+	w.Write(dat)
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		slog.Error("marshalling JSON", "error", err)
 		w.WriteHeader(500)
 		return
 	}
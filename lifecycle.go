@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// workerGroup runs background workers, each with its own context, so they
+// can be stopped one at a time during shutdown. It also tracks each
+// worker's health for the readiness check.
+type workerGroup struct {
+	mu      sync.Mutex
+	workers []*runningWorker
+}
+
+type runningWorker struct {
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+	// stale is how long the worker may go without a heartbeat before it is
+	// reported unhealthy.
+	stale time.Duration
+
+	mu       sync.Mutex
+	started  time.Time
+	lastBeat time.Time
+	lastErr  error
+	exited   bool
+	stopping bool
+}
+
+// WorkerHealth is a worker's entry in the readiness report.
+type WorkerHealth struct {
+	Status    string     `json:"status"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Go starts run in a goroutine. run must return once its context is
+// cancelled, and should call beat after each unit of work with its error,
+// at least once every stale.
+func (g *workerGroup) Go(name string, stale time.Duration, run func(ctx context.Context, beat func(error))) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &runningWorker{
+		name:    name,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		stale:   stale,
+		started: time.Now(),
+	}
+	g.mu.Lock()
+	g.workers = append(g.workers, w)
+	g.mu.Unlock()
+
+	go func() {
+		defer close(w.done)
+		defer func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.exited = true
+			if p := recover(); p != nil {
+				w.lastErr = fmt.Errorf("panic: %v", p)
+				slog.Error("worker panicked", "worker", name, "panic", p)
+			}
+		}()
+		run(ctx, w.beat)
+	}()
+}
+
+func (w *runningWorker) beat(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastBeat = time.Now()
+	w.lastErr = err
+}
+
+func (w *runningWorker) health(now time.Time) WorkerHealth {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	h := WorkerHealth{Status: "ok"}
+	if !w.lastBeat.IsZero() {
+		lastRun := w.lastBeat
+		h.LastRun = &lastRun
+	}
+	if w.lastErr != nil {
+		h.LastError = w.lastErr.Error()
+	}
+
+	since := w.lastBeat
+	if since.IsZero() {
+		since = w.started
+	}
+	switch {
+	case w.exited && !w.stopping:
+		h.Status = "exited"
+	case w.stopping:
+		h.Status = "stopping"
+	case w.lastErr != nil:
+		h.Status = "failing"
+	case now.Sub(since) > w.stale:
+		h.Status = "stale"
+	}
+	return h
+}
+
+// Health reports every worker by name. healthy is false if any worker
+// has exited unexpectedly, is failing or has missed its heartbeat.
+func (g *workerGroup) Health() (report map[string]WorkerHealth, healthy bool) {
+	g.mu.Lock()
+	workers := append([]*runningWorker(nil), g.workers...)
+	g.mu.Unlock()
+
+	now := time.Now()
+	report = make(map[string]WorkerHealth, len(workers))
+	healthy = true
+	for _, w := range workers {
+		h := w.health(now)
+		report[w.name] = h
+		if h.Status != "ok" {
+			healthy = false
+		}
+	}
+	return report, healthy
+}
+
+// Stop stops the workers in the order they were started, waiting for each
+// to return before moving on. It gives up when ctx is done.
+func (g *workerGroup) Stop(ctx context.Context) error {
+	g.mu.Lock()
+	workers := append([]*runningWorker(nil), g.workers...)
+	g.mu.Unlock()
+
+	for _, w := range workers {
+		w.mu.Lock()
+		w.stopping = true
+		w.mu.Unlock()
+
+		w.cancel()
+		select {
+		case <-w.done:
+			slog.Info("stopped worker", "worker", w.name)
+		case <-ctx.Done():
+			return fmt.Errorf("stopping %s: %w", w.name, ctx.Err())
+		}
+	}
+	return nil
+}
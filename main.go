@@ -1,79 +1,300 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"example.com/chirpy/internal/database"
+	"errors"
+	"example.com/chirpy/internal/config"
+	"example.com/chirpy/internal/entitlements"
+	"example.com/chirpy/internal/fixtures"
+	"example.com/chirpy/internal/graphql"
+	"example.com/chirpy/internal/logging"
+	"example.com/chirpy/internal/mailer"
+	"example.com/chirpy/internal/migrate"
+	"example.com/chirpy/internal/openapi"
+	"example.com/chirpy/internal/ratelimit"
+	"example.com/chirpy/internal/store"
+	"example.com/chirpy/internal/stream"
+	"example.com/chirpy/internal/tracing"
+	"example.com/chirpy/internal/webhooks"
+	"flag"
+	"fmt"
 	"github.com/joho/godotenv"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"sync/atomic"
+	"syscall"
+	"time"
 )
 
-import _ "github.com/lib/pq"
-
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	platform       string
-	jwtSecret      string
+	metrics         *appMetrics
+	store           store.Store
+	sqlDB           *sql.DB
+	platform        string
+	fixtures        fs.FS
+	jwtSecret       string
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	baseURL         string
+	mailer          mailer.Mailer
+	polkaKeys       []string
+	entitlements    *entitlements.Service
+	migrator        *migrate.Migrator
+	workers         *workerGroup
+	// contract is the OpenAPI document responses are checked against, or
+	// nil when response validation is off.
+	contract     *openapi.Document
+	shuttingDown atomic.Bool
+	// stream delivers chirp events to /api/stream clients; handlers
+	// publish committed events through events, which on Postgres reaches
+	// every replica's stream.
+	stream *stream.Hub
+	events stream.Publisher
+	// graphql runs /api/graphql requests.
+	graphql *graphql.Executor
+
+	rateLimiter       ratelimit.Store
+	rateLimitPolicies map[string]ratelimit.Policy
+	trustForwardedFor bool
 }
 
 func main() {
-	const filepathRoot = "."
-	const port = "8080"
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	err := godotenv.Load()
-	if err != nil {
+	if err := run(ctx, os.Args[1:]); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	dbURL := os.Getenv("DB_URL")
+// run starts the server and blocks until ctx is cancelled. It then stops
+// accepting connections, waits for in-flight requests, stops the
+// background workers and closes the database.
+func run(ctx context.Context, args []string) error {
+	// A .env file is a development convenience; deployments set the
+	// environment directly
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
 
-	platform := os.Getenv("PLATFORM")
+	cfg, args, err := config.Load(args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stderr)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
 
-	db, err := sql.Open("postgres", dbURL)
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	slog.SetDefault(logging.New(os.Stderr, level))
 
-	dbQueries := database.New(db)
+	tracer := newTracer(cfg)
+	tracing.SetDefault(tracer)
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET is not set in environment variables")
+	backend, err := openBackend(cfg)
+	if err != nil {
+		return err
 	}
+	defer backend.db.Close()
 
-	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       platform,
-		jwtSecret:      jwtSecret,
+	if len(args) > 0 && args[0] == "migrate" {
+		return runMigrate(ctx, backend, args[1:])
 	}
 
-	mux := http.NewServeMux()
-	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
-	mux.Handle("/app/", fsHandler)
-
-	mux.HandleFunc("GET /api/healthz", handlerReadiness)
-	mux.HandleFunc("POST /api/validate_chirp", handlerChirpsValidate)
-	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
-	mux.HandleFunc("POST /api/login", apiCfg.handlerLoginUser)
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
-	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerChirpsCreate)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirpByID)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirpByID)
+	// The schema is applied at startup unless auto-migrate is off, in which
+	// case "chirpy migrate up" must be run before the server will start
+	migrator, err := migrateOnStart(ctx, backend, cfg.AutoMigrate)
+	if err != nil {
+		return err
+	}
 
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerWebhooks)
+	apiCfg, handler, err := newAPIConfig(cfg, backend, migrator)
+	if err != nil {
+		return err
+	}
 
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
+	// The workers are stopped in this order on shutdown
+	workers := apiCfg.workers
+	if limiter, ok := apiCfg.rateLimiter.(*ratelimit.PostgresStore); ok {
+		workers.Go("rate limit cleanup", time.Hour, func(ctx context.Context, beat func(error)) {
+			runRateLimitCleanup(ctx, limiter, 10*time.Minute, beat)
+		})
+	}
+	workers.Go("webhook worker", time.Minute, func(ctx context.Context, beat func(error)) {
+		worker := webhooks.NewWorker(backend.store, 5*time.Second)
+		worker.AllowHTTP = cfg.Platform == "dev"
+		worker.OnDelivery = func(outcome string) {
+			apiCfg.metrics.webhookDeliveries.With(outcome).Inc()
+		}
+		worker.OnTick = beat
+		worker.Run(ctx)
+	})
+	workers.Go("subscription expiry", 5*time.Minute, func(ctx context.Context, beat func(error)) {
+		apiCfg.runSubscriptionExpiry(ctx, time.Minute, beat)
+	})
+	if backend.queries != nil {
+		// Events reach the hub through LISTEN, even on the replica that
+		// committed them, so every replica streams them in the same order.
+		// A SQLite server is a single process and publishes to its hub
+		// directly.
+		apiCfg.events = stream.NewNotifier(backend.db)
+		workers.Go("event listener", time.Minute, func(ctx context.Context, beat func(error)) {
+			stream.Listen(ctx, cfg.DBURL, backend.queries, apiCfg.stream, beat)
+		})
+	}
 
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("serving", "port", cfg.Port, "filepath_root", cfg.FilepathRoot, "driver", backend.driver)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		// The server failed before a shutdown was requested
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		return errors.Join(err, workers.Stop(shutdownCtx))
+	case <-ctx.Done():
+	}
+
+	// Fail readiness first and give load balancers time to notice before
+	// the listener closes
+	slog.Info("shutting down")
+	apiCfg.shuttingDown.Store(true)
+	time.Sleep(cfg.ShutdownDelay)
+
+	// Streams would otherwise hold Shutdown open until the timeout
+	apiCfg.stream.Close()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("draining requests: %w", err))
+	}
+	if err := workers.Stop(shutdownCtx); err != nil {
+		errs = append(errs, err)
 	}
+	if err := tracer.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("flushing traces: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// newAPIConfig builds the API's shared state and handler on an opened,
+// migrated backend. No background workers are started.
+func newAPIConfig(cfg config.Config, backend *backend, migrator *migrate.Migrator) (*apiConfig, http.Handler, error) {
+	apiCfg := &apiConfig{
+		metrics:         newAppMetrics(backend.db),
+		store:           backend.store,
+		sqlDB:           backend.db,
+		platform:        cfg.Platform,
+		fixtures:        fixtures.Builtin(),
+		jwtSecret:       cfg.JWTSecret,
+		accessTokenTTL:  cfg.AccessTokenTTL,
+		refreshTokenTTL: cfg.RefreshTokenTTL,
+		baseURL:         cfg.BaseURL,
+		mailer:          newMailer(cfg),
+		polkaKeys:       cfg.PolkaKeys,
+		entitlements:    entitlements.New(entitlements.NewRepositoryStore(backend.store), tiers(cfg)),
+		migrator:        migrator,
+		workers:         &workerGroup{},
+		rateLimitPolicies: map[string]ratelimit.Policy{
+			rateLimitSignup:       cfg.RateLimitSignup,
+			rateLimitLogin:        cfg.RateLimitLogin,
+			rateLimitChirpsCreate: cfg.RateLimitChirpsCreate,
+		},
+		trustForwardedFor: cfg.TrustForwardedFor,
+		stream:            stream.NewHub(cfg.StreamReplayEvents),
+	}
+	apiCfg.events = apiCfg.stream
+	if cfg.RateLimitStore == "postgres" {
+		apiCfg.rateLimiter = ratelimit.NewPostgresStore(backend.db, backend.queries)
+	} else {
+		apiCfg.rateLimiter = ratelimit.NewMemoryStore()
+	}
+
+	var err error
+	apiCfg.graphql, err = apiCfg.newGraphQLExecutor(cfg.GraphQLMaxDepth, cfg.GraphQLMaxComplexity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Every route must be in the OpenAPI document, so the published
+	// contract can't silently fall behind the server
+	doc, err := openapi.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.ValidateResponses {
+		apiCfg.contract = doc
+	}
+	if cfg.FixturesDir != "" {
+		apiCfg.fixtures = os.DirFS(cfg.FixturesDir)
+	}
+	handler, patterns := apiCfg.routes(cfg.FilepathRoot)
+	if err := doc.CheckRoutes(patterns); err != nil {
+		return nil, nil, fmt.Errorf("routes missing from the OpenAPI document:\n%w", err)
+	}
+	return apiCfg, handler, nil
+}
+
+func newMailer(cfg config.Config) mailer.Mailer {
+	switch cfg.MailTransport {
+	case "smtp":
+		return mailer.SMTPMailer{
+			Addr:     cfg.SMTPAddr,
+			From:     cfg.MailFrom,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+		}
+	case "http":
+		return mailer.HTTPMailer{URL: cfg.MailHTTPURL, Token: cfg.MailHTTPToken, From: cfg.MailFrom}
+	}
+	return mailer.LogMailer{}
+}
+
+func newTracer(cfg config.Config) *tracing.Tracer {
+	switch cfg.TraceExporter {
+	case "stdout":
+		return tracing.NewTracer(tracing.NewStdoutExporter(os.Stdout))
+	case "otlp":
+		return tracing.NewTracer(tracing.NewOTLPExporter(cfg.OTLPEndpoint, "chirpy"))
+	}
+	return tracing.NewTracer(nil)
+}
+
+// tiers applies the configured chirp limits to the default tiers.
+func tiers(cfg config.Config) map[entitlements.Tier]entitlements.Capabilities {
+	t := make(map[entitlements.Tier]entitlements.Capabilities, len(entitlements.DefaultTiers))
+	for tier, caps := range entitlements.DefaultTiers {
+		t[tier] = caps
+	}
+
+	free := t[entitlements.TierFree]
+	free.MaxChirpLength = cfg.FreeMaxChirpLength
+	t[entitlements.TierFree] = free
+
+	red := t[entitlements.TierChirpyRed]
+	red.MaxChirpLength = cfg.RedMaxChirpLength
+	t[entitlements.TierChirpyRed] = red
 
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(srv.ListenAndServe())
+	return t
 }
@@ -1,10 +1,104 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/chirpy/internal/metrics"
+)
+
+// appMetrics holds the server's metrics. Everything is registered in one
+// registry, which backs both /metrics and the admin page.
+type appMetrics struct {
+	registry *metrics.Registry
+
+	fileserverHits    *metrics.Counter
+	requests          *metrics.CounterVec
+	requestDuration   *metrics.HistogramVec
+	chirpsCreated     *metrics.Counter
+	logins            *metrics.CounterVec
+	polkaWebhooks     *metrics.CounterVec
+	webhookDeliveries *metrics.CounterVec
+	rateLimited       *metrics.CounterVec
+	// contractViolations only moves when response validation is enabled
+	contractViolations *metrics.CounterVec
+}
+
+// Label values for the outcome metrics.
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+
+	polkaProcessed        = "processed"
+	polkaDuplicate        = "duplicate"
+	polkaIgnored          = "ignored"
+	polkaOrphaned         = "orphaned"
+	polkaInvalidSignature = "invalid_signature"
+	polkaInvalid          = "invalid"
+	polkaError            = "error"
 )
 
+func newAppMetrics(db *sql.DB) *appMetrics {
+	r := metrics.NewRegistry()
+	m := &appMetrics{
+		registry: r,
+		fileserverHits: r.NewCounter("chirpy_fileserver_hits_total",
+			"Requests served from /app/."),
+		requests: r.NewCounterVec("chirpy_http_requests_total",
+			"HTTP requests by route pattern and status code.", "route", "status"),
+		requestDuration: r.NewHistogramVec("chirpy_http_request_duration_seconds",
+			"HTTP request latency by route pattern and status code.", metrics.DefaultBuckets, "route", "status"),
+		chirpsCreated: r.NewCounter("chirpy_chirps_created_total",
+			"Chirps created."),
+		logins: r.NewCounterVec("chirpy_logins_total",
+			"Login attempts by outcome.", "outcome"),
+		polkaWebhooks: r.NewCounterVec("chirpy_polka_webhooks_total",
+			"Incoming Polka webhooks by outcome.", "outcome"),
+		webhookDeliveries: r.NewCounterVec("chirpy_webhook_deliveries_total",
+			"Outgoing webhook delivery attempts by outcome.", "outcome"),
+		rateLimited: r.NewCounterVec("chirpy_rate_limited_total",
+			"Requests rejected by rate limiting by policy and tier.", "policy", "tier"),
+		contractViolations: r.NewCounterVec("chirpy_openapi_violations_total",
+			"Responses that didn't match the OpenAPI document by route pattern.", "route"),
+	}
+
+	if db != nil {
+		r.NewGaugeFunc("chirpy_db_max_open_connections", "Maximum open database connections.",
+			func() float64 { return float64(db.Stats().MaxOpenConnections) })
+		r.NewGaugeFunc("chirpy_db_open_connections", "Open database connections.",
+			func() float64 { return float64(db.Stats().OpenConnections) })
+		r.NewGaugeFunc("chirpy_db_in_use_connections", "Database connections in use.",
+			func() float64 { return float64(db.Stats().InUse) })
+		r.NewGaugeFunc("chirpy_db_idle_connections", "Idle database connections.",
+			func() float64 { return float64(db.Stats().Idle) })
+		r.NewCounterFunc("chirpy_db_wait_count_total", "Connections waited for.",
+			func() float64 { return float64(db.Stats().WaitCount) })
+		r.NewCounterFunc("chirpy_db_wait_duration_seconds_total", "Time spent waiting for connections.",
+			func() float64 { return db.Stats().WaitDuration.Seconds() })
+		r.NewCounterFunc("chirpy_db_max_idle_closed_total", "Connections closed due to the idle limit.",
+			func() float64 { return float64(db.Stats().MaxIdleClosed) })
+		r.NewCounterFunc("chirpy_db_max_lifetime_closed_total", "Connections closed due to the lifetime limit.",
+			func() float64 { return float64(db.Stats().MaxLifetimeClosed) })
+	}
+
+	return m
+}
+
+// observeRequest records a served request. Requests that matched no route
+// share one label value so unknown paths can't grow the series.
+func (m *appMetrics) observeRequest(route string, status int, d time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	code := strconv.Itoa(status)
+	m.requests.With(route, code).Inc()
+	m.requestDuration.With(route, code).Observe(d.Seconds())
+}
+
 func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
@@ -13,19 +107,20 @@ func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
 
 <body>
 	<h1>Welcome, Chirpy Admin</h1>
-	<p>Chirpy has been visited %dtimes!</p>
+	<p>Chirpy has been visited %d times!</p>
+	<p>%d chirps created, %d requests served.</p>
 </body>
 
 </html>
-	`, cfg.fileserverHits.Load())))
+	`, int64(cfg.metrics.fileserverHits.Value()), int64(cfg.metrics.chirpsCreated.Value()), int64(cfg.metrics.requests.Total()))))
 	if err != nil {
-		fmt.Println(err)
+		slog.ErrorContext(r.Context(), "writing metrics page", "error", err)
 	}
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
+		cfg.metrics.fileserverHits.Inc()
 		next.ServeHTTP(w, r)
 	})
 }
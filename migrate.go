@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"example.com/chirpy/internal/migrate"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// runMigrate implements the "migrate up|down|status" subcommands.
+func runMigrate(ctx context.Context, b *backend, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: chirpy migrate up|down|status")
+	}
+
+	m, err := b.migrator()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied %s\n", mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return nil
+
+	case "down":
+		mig, ok, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("no migrations to roll back")
+			return nil
+		}
+		fmt.Printf("rolled back %s\n", mig.Name)
+		return nil
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown migrate command %q", args[0])
+}
+
+// migrateOnStart applies pending migrations unless auto is false and then
+// refuses to continue if the schema is still behind. The migrator is
+// returned for the readiness check.
+func migrateOnStart(ctx context.Context, b *backend, auto bool) (*migrate.Migrator, error) {
+	m, err := b.migrator()
+	if err != nil {
+		return nil, err
+	}
+	if auto {
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			slog.InfoContext(ctx, "applied migration", "migration", mig.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := m.Check(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
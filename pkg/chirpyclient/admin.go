@@ -0,0 +1,32 @@
+package chirpyclient
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// The calls in this file need a user with the admin role.
+
+// UserEntitlements returns a user's override and effective capabilities.
+func (c *Client) UserEntitlements(ctx context.Context, userID uuid.UUID) (Entitlements, error) {
+	var e Entitlements
+	err := c.do(ctx, request{method: http.MethodGet, path: entitlementsPath(userID), auth: authAccess}, &e)
+	return e, err
+}
+
+// SetUserEntitlements replaces a user's override.
+func (c *Client) SetUserEntitlements(ctx context.Context, userID uuid.UUID, override EntitlementOverride) (Entitlements, error) {
+	var e Entitlements
+	err := c.do(ctx, request{method: http.MethodPut, path: entitlementsPath(userID), body: override, auth: authAccess}, &e)
+	return e, err
+}
+
+// DeleteUserEntitlements removes a user's override.
+func (c *Client) DeleteUserEntitlements(ctx context.Context, userID uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: entitlementsPath(userID), auth: authAccess}, nil)
+}
+
+func entitlementsPath(userID uuid.UUID) string {
+	return "/admin/users/" + userID.String() + "/entitlements"
+}
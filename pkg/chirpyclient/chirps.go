@@ -0,0 +1,159 @@
+package chirpyclient
+
+import (
+	"cmp"
+	"context"
+	"github.com/google/uuid"
+	"iter"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CreateChirp posts a chirp as the logged-in user.
+func (c *Client) CreateChirp(ctx context.Context, body string) (Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/chirps",
+		body:   map[string]string{"body": body},
+		auth:   authAccess,
+	}, &chirp)
+	return chirp, err
+}
+
+// ValidateChirp returns body as it would be stored, or an error if it
+// would be rejected.
+func (c *Client) ValidateChirp(ctx context.Context, body string) (string, error) {
+	var res struct {
+		CleanedBody string `json:"cleaned_body"`
+	}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/validate_chirp",
+		body:   map[string]string{"body": body},
+	}, &res)
+	return res.CleanedBody, err
+}
+
+// GetChirp returns one chirp.
+func (c *Client) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/chirps/" + id.String()}, &chirp)
+	return chirp, err
+}
+
+// DeleteChirp deletes one of the logged-in user's chirps.
+func (c *Client) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/chirps/" + id.String(), auth: authAccess}, nil)
+}
+
+// ListChirpsOptions filter and order ListChirps.
+type ListChirpsOptions struct {
+	// AuthorID, if set, limits the list to one user's chirps.
+	AuthorID uuid.UUID
+	// Newest lists the newest chirps first instead of the oldest.
+	Newest bool
+	// PageSize is how many chirps Chirps fetches per request. Zero means
+	// DefaultPageSize.
+	PageSize int
+}
+
+// DefaultPageSize is the page size Chirps uses unless told otherwise. It
+// is the most the server returns in one page.
+const DefaultPageSize = 100
+
+func (o ListChirpsOptions) query() url.Values {
+	q := url.Values{}
+	if o.AuthorID != uuid.Nil {
+		q.Set("author_id", o.AuthorID.String())
+	}
+	if o.Newest {
+		q.Set("sort", "desc")
+	}
+	return q
+}
+
+// ListChirps returns every chirp matching opts in one response. Use Chirps
+// for large lists.
+func (c *Client) ListChirps(ctx context.Context, opts ListChirpsOptions) ([]Chirp, error) {
+	var chirps []Chirp
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/chirps", query: opts.query()}, &chirps)
+	return chirps, err
+}
+
+// Chirps iterates over the chirps matching opts, stopping after the first
+// error. It fetches a page at a time, following the server's next links,
+// and only asks for the next page once the caller has ranged over the
+// last one.
+func (c *Client) Chirps(ctx context.Context, opts ListChirpsOptions) iter.Seq2[Chirp, error] {
+	q := opts.query()
+	q.Set("limit", strconv.Itoa(cmp.Or(opts.PageSize, DefaultPageSize)))
+	return c.pages(ctx, request{method: http.MethodGet, path: "/api/chirps", query: q})
+}
+
+// Timeline iterates over the chirps of the logged-in user and the users
+// they follow, newest first, like Chirps. pageSize is how many chirps are
+// fetched per request; zero means DefaultPageSize.
+func (c *Client) Timeline(ctx context.Context, pageSize int) iter.Seq2[Chirp, error] {
+	q := url.Values{"limit": {strconv.Itoa(cmp.Or(pageSize, DefaultPageSize))}}
+	return c.pages(ctx, request{method: http.MethodGet, path: "/api/users/me/timeline", query: q, auth: authAccess})
+}
+
+// pages iterates over the chirps of req and the pages after it.
+func (c *Client) pages(ctx context.Context, req request) iter.Seq2[Chirp, error] {
+	return func(yield func(Chirp, error) bool) {
+		for {
+			var (
+				page   []Chirp
+				header http.Header
+			)
+			req.header = &header
+			if err := c.do(ctx, req, &page); err != nil {
+				yield(Chirp{}, err)
+				return
+			}
+			for _, chirp := range page {
+				if !yield(chirp, nil) {
+					return
+				}
+			}
+
+			next, ok := nextLink(header)
+			if !ok {
+				return
+			}
+			req.path, req.query = next.Path, next.Query()
+		}
+	}
+}
+
+// nextLink returns the target of the rel="next" Link header, if any.
+func nextLink(h http.Header) (*url.URL, bool) {
+	for _, v := range h.Values("Link") {
+		for _, link := range strings.Split(v, ",") {
+			target, params, _ := strings.Cut(link, ";")
+			if !slices.Contains(strings.Fields(strings.ReplaceAll(params, ";", " ")), `rel="next"`) {
+				continue
+			}
+			u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+			if err != nil {
+				return nil, false
+			}
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// Follow makes the logged-in user follow userID.
+func (c *Client) Follow(ctx context.Context, userID uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/users/" + userID.String() + "/follow", auth: authAccess}, nil)
+}
+
+// Unfollow stops the logged-in user following userID.
+func (c *Client) Unfollow(ctx context.Context, userID uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/users/" + userID.String() + "/follow", auth: authAccess}, nil)
+}
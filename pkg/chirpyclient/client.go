@@ -0,0 +1,330 @@
+// Package chirpyclient is a Go client for the Chirpy API.
+//
+// A Client logs in once and then sends the access token with every call
+// that needs one. When the server rejects the access token as invalid or
+// expired, the client trades its refresh token for a new one through
+// /api/refresh and repeats the call. Failed calls return an *Error carrying the API's
+// problem code, and transient failures are retried with backoff.
+//
+//	c := chirpyclient.New("https://chirpy.example.com")
+//	if _, err := c.Login(ctx, email, password); err != nil {
+//		return err
+//	}
+//	chirp, err := c.CreateChirp(ctx, "hello")
+//	if errors.Is(err, chirpyclient.ErrDuplicateChirp) {
+//		...
+//	}
+package chirpyclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tokens are the credentials returned by Login.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RetryPolicy controls how failed requests are retried. Requests are
+// retried after network errors and 502, 503 and 504 responses when the
+// method is idempotent, and after 429 responses for every method, since a
+// rate-limited request was never processed. Retry-After is honoured up to
+// MaxDelay.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries; 1 disables retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used by clients from New.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// Client calls the Chirpy API. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+
+	// HTTPClient sends the requests. It defaults to a client with a 30
+	// second timeout.
+	HTTPClient *http.Client
+
+	// Retry is the retry policy.
+	Retry RetryPolicy
+
+	// UserAgent, if set, is sent with every request.
+	UserAgent string
+
+	// OnTokens, if set, is called whenever the client's tokens change:
+	// after Login, after an automatic refresh, and with empty tokens after
+	// Logout. Use it to persist the session.
+	OnTokens func(Tokens)
+
+	mu         sync.Mutex
+	tokens     Tokens
+	refreshing chan struct{}
+	refreshErr error
+}
+
+// New returns a client for the API at baseURL, e.g. http://localhost:8080.
+func New(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Retry:      DefaultRetryPolicy,
+	}
+}
+
+// Tokens returns the client's current tokens.
+func (c *Client) Tokens() Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+// SetTokens replaces the client's tokens, e.g. with a saved session.
+// OnTokens is not called.
+func (c *Client) SetTokens(t Tokens) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens = t
+}
+
+func (c *Client) updateTokens(t Tokens) {
+	c.mu.Lock()
+	c.tokens = t
+	onTokens := c.OnTokens
+	c.mu.Unlock()
+	if onTokens != nil {
+		onTokens(t)
+	}
+}
+
+// auth selects the credential sent with a request.
+type auth int
+
+const (
+	authNone auth = iota
+	authAccess
+	authRefresh
+)
+
+// request describes one API call.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	auth   auth
+
+	// header, if set, receives the headers of a successful response.
+	header *http.Header
+}
+
+// do sends req and decodes a successful JSON response into out, which may
+// be nil. An access token rejected as invalid or expired is refreshed once;
+// other 401s, such as a missing token, are returned as they are.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	access := c.Tokens().AccessToken
+	err := c.send(ctx, req, access, out)
+
+	if req.auth != authAccess || !errors.Is(err, ErrInvalidToken) {
+		return err
+	}
+	if c.Tokens().RefreshToken == "" {
+		return err
+	}
+	if refreshErr := c.refreshAfter(ctx, access); refreshErr != nil {
+		return errors.Join(err, fmt.Errorf("refreshing access token: %w", refreshErr))
+	}
+	return c.send(ctx, req, c.Tokens().AccessToken, out)
+}
+
+// refreshAfter gets a new access token unless another goroutine already
+// replaced stale. Concurrent callers share one /api/refresh call.
+func (c *Client) refreshAfter(ctx context.Context, stale string) error {
+	c.mu.Lock()
+	if c.tokens.AccessToken != stale {
+		c.mu.Unlock()
+		return nil
+	}
+	if wait := c.refreshing; wait != nil {
+		c.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.refreshErr
+	}
+	done := make(chan struct{})
+	c.refreshing = done
+	c.mu.Unlock()
+
+	err := c.Refresh(ctx)
+
+	c.mu.Lock()
+	c.refreshing = nil
+	c.refreshErr = err
+	c.mu.Unlock()
+	close(done)
+	return err
+}
+
+// send makes the HTTP request, retrying as the policy allows.
+func (c *Client) send(ctx context.Context, req request, access string, out any) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		body, err = json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+	}
+
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+
+	attempts := max(c.Retry.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, u, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		if body != nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+		httpReq.Header.Set("Accept", "application/json, application/problem+json")
+		if c.UserAgent != "" {
+			httpReq.Header.Set("User-Agent", c.UserAgent)
+		}
+		switch req.auth {
+		case authAccess:
+			httpReq.Header.Set("Authorization", "Bearer "+access)
+		case authRefresh:
+			httpReq.Header.Set("Authorization", "Bearer "+c.Tokens().RefreshToken)
+		}
+
+		res, err := c.HTTPClient.Do(httpReq)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= attempts || !idempotent(req.method) {
+				return err
+			}
+			if err := sleep(ctx, c.backoff(attempt, 0)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if res.StatusCode < 300 {
+			defer res.Body.Close()
+			if req.header != nil {
+				*req.header = res.Header
+			}
+			if out == nil || res.StatusCode == http.StatusNoContent {
+				_, err := io.Copy(io.Discard, res.Body)
+				return err
+			}
+			if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+				return fmt.Errorf("decoding %s %s response: %w", req.method, req.path, err)
+			}
+			return nil
+		}
+
+		apiErr := readError(res)
+		res.Body.Close()
+		if attempt >= attempts || !retryable(req.method, res.StatusCode) {
+			return apiErr
+		}
+		if err := sleep(ctx, c.backoff(attempt, apiErr.RetryAfter)); err != nil {
+			return err
+		}
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent(method)
+	}
+	return false
+}
+
+// backoff returns the delay before the next attempt: the server's
+// Retry-After if it sent one, otherwise exponential backoff with full
+// jitter. Either is capped at MaxDelay.
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if c.Retry.MaxDelay > 0 && retryAfter > c.Retry.MaxDelay {
+			return c.Retry.MaxDelay
+		}
+		return retryAfter
+	}
+	ceiling := c.Retry.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || (c.Retry.MaxDelay > 0 && ceiling > c.Retry.MaxDelay) {
+		ceiling = c.Retry.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readError turns a failed response into an *Error. Responses that aren't
+// problem documents still produce an *Error with the status.
+func readError(res *http.Response) *Error {
+	e := &Error{StatusCode: res.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err := json.Unmarshal(data, e); err != nil || e.Title == "" {
+		e.Title = http.StatusText(res.StatusCode)
+		e.Detail = strings.TrimSpace(string(data))
+	}
+	e.StatusCode = res.StatusCode
+	if s := res.Header.Get("Retry-After"); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil {
+			e.RetryAfter = time.Duration(secs) * time.Second
+		}
+	}
+	return e
+}
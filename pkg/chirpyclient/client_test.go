@@ -0,0 +1,133 @@
+package chirpyclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoffCapsRetryAfter(t *testing.T) {
+	c := New("http://example.com")
+	c.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+	if got := c.backoff(1, time.Hour); got != time.Second {
+		t.Errorf("Retry-After of an hour: got %v, want MaxDelay", got)
+	}
+	if got := c.backoff(1, 500*time.Millisecond); got != 500*time.Millisecond {
+		t.Errorf("Retry-After under MaxDelay: got %v, want it honoured", got)
+	}
+	for attempt := 1; attempt < 40; attempt++ {
+		if got := c.backoff(attempt, 0); got < 0 || got > time.Second {
+			t.Fatalf("attempt %d: got %v, want at most MaxDelay", attempt, got)
+		}
+	}
+}
+
+// problemServer answers protected calls with a 401 problem carrying code
+// and counts calls to /api/refresh.
+func problemServer(t *testing.T, code Code) (*Client, *atomic.Int32) {
+	t.Helper()
+	var refreshes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/refresh" {
+			refreshes.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token":"fresh"}`))
+			return
+		}
+		if r.Header.Get("Authorization") == "Bearer fresh" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"tier":"free"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"status":401,"title":"Unauthorized","code":"` + string(code) + `"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New(srv.URL)
+	c.SetTokens(Tokens{AccessToken: "stale", RefreshToken: "refresh"})
+	return c, &refreshes
+}
+
+func TestRefreshOnlyOnInvalidToken(t *testing.T) {
+	c, refreshes := problemServer(t, CodeInvalidToken)
+	if _, err := c.MyEntitlements(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if refreshes.Load() != 1 || c.Tokens().AccessToken != "fresh" {
+		t.Fatalf("refreshes = %d, tokens = %+v", refreshes.Load(), c.Tokens())
+	}
+
+	c, refreshes = problemServer(t, CodeUnauthorized)
+	if _, err := c.MyEntitlements(context.Background()); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("got %v, want ErrUnauthorized", err)
+	}
+	if refreshes.Load() != 0 {
+		t.Fatalf("refreshed after a 401 that wasn't invalid_token")
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		header []string
+		want   string
+	}{
+		{nil, ""},
+		{[]string{`</api/chirps?after=abc&limit=2>; rel="next"`}, "/api/chirps?after=abc&limit=2"},
+		{[]string{`</a>; rel="prev", </b>; rel="next"`}, "/b"},
+		{[]string{`</a>; rel="prev"`, `</b>; title="x"; rel="next"`}, "/b"},
+		{[]string{`</a>; rel="nextish"`}, ""},
+	}
+	for _, tt := range tests {
+		u, ok := nextLink(http.Header{"Link": tt.header})
+		got := ""
+		if ok {
+			got = u.String()
+		}
+		if got != tt.want {
+			t.Errorf("nextLink(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestStreamResumes(t *testing.T) {
+	var lastIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		if len(lastIDs) == 1 {
+			// Multi-line data and comments, then the connection drops
+			w.Write([]byte("retry: 1\n\n: keepalive\n\nid: 1\nevent: chirp.created\ndata: {\"body\":\ndata: \"first\"}\n\n"))
+			return
+		}
+		w.Write([]byte("id: 2\nevent: chirp.deleted\ndata: {\"body\":\"second\"}\n\n"))
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []StreamEvent
+	for event, err := range New(srv.URL).Stream(ctx, StreamOptions{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, event)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 ||
+		got[0].ID != "1" || got[0].Type != StreamChirpCreated || got[0].Chirp.Body != "first" ||
+		got[1].ID != "2" || got[1].Type != StreamChirpDeleted || got[1].Chirp.Body != "second" {
+		t.Fatalf("events = %+v", got)
+	}
+	if len(lastIDs) != 2 || lastIDs[0] != "" || lastIDs[1] != "1" {
+		t.Fatalf("Last-Event-ID per connection = %q", lastIDs)
+	}
+}
@@ -0,0 +1,92 @@
+package chirpyclient
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Code is a machine-readable error code from the API. Codes never change
+// meaning, but new ones may be added.
+type Code string
+
+// Error codes returned by the API.
+const (
+	CodeInvalidRequest       Code = "invalid_request"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeBodyTooLarge         Code = "body_too_large"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeInvalidCredentials   Code = "invalid_credentials"
+	CodeInvalidToken         Code = "invalid_token"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeConflict             Code = "conflict"
+	CodeEmailInUse           Code = "email_in_use"
+	CodeDuplicateChirp       Code = "duplicate_chirp"
+	CodeRateLimited          Code = "rate_limited"
+	CodeUnavailable          Code = "unavailable"
+	CodeInternal             Code = "internal_error"
+)
+
+// Targets for errors.Is. An *Error matches a target with the same code.
+var (
+	ErrValidationFailed   = &Error{Code: CodeValidationFailed}
+	ErrUnauthorized       = &Error{Code: CodeUnauthorized}
+	ErrInvalidCredentials = &Error{Code: CodeInvalidCredentials}
+	ErrInvalidToken       = &Error{Code: CodeInvalidToken}
+	ErrForbidden          = &Error{Code: CodeForbidden}
+	ErrNotFound           = &Error{Code: CodeNotFound}
+	ErrConflict           = &Error{Code: CodeConflict}
+	ErrEmailInUse         = &Error{Code: CodeEmailInUse}
+	ErrDuplicateChirp     = &Error{Code: CodeDuplicateChirp}
+	ErrRateLimited        = &Error{Code: CodeRateLimited}
+)
+
+// FieldError describes one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is a failed API call, decoded from the server's problem document.
+type Error struct {
+	StatusCode int          `json:"status"`
+	Code       Code         `json:"code"`
+	Title      string       `json:"title"`
+	Detail     string       `json:"detail"`
+	RequestID  string       `json:"request_id"`
+	Fields     []FieldError `json:"errors"`
+
+	// RetryAfter is how long the server asked the client to wait, if it
+	// said.
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "chirpy: %d", e.StatusCode)
+	if e.Code != "" {
+		fmt.Fprintf(&b, " %s", e.Code)
+	}
+	if e.Detail != "" {
+		fmt.Fprintf(&b, ": %s", e.Detail)
+	} else if e.Title != "" {
+		fmt.Fprintf(&b, ": %s", e.Title)
+	}
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "; %s: %s", f.Field, f.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+// Is reports whether target is an *Error with the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == e.Code
+}
@@ -0,0 +1,55 @@
+package chirpyclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Readiness is the server's readiness report.
+type Readiness struct {
+	Status       string `json:"status"`
+	ShuttingDown bool   `json:"shutting_down"`
+	Database     struct {
+		Status    string  `json:"status"`
+		LatencyMS float64 `json:"latency_ms"`
+		Error     string  `json:"error,omitempty"`
+	} `json:"database"`
+	Migrations struct {
+		Status   string `json:"status"`
+		Version  int64  `json:"version"`
+		Expected int64  `json:"expected"`
+		Error    string `json:"error,omitempty"`
+	} `json:"migrations"`
+	Workers map[string]struct {
+		Status    string     `json:"status"`
+		LastRun   *time.Time `json:"last_run,omitempty"`
+		LastError string     `json:"last_error,omitempty"`
+	} `json:"workers"`
+}
+
+// Ready fetches the server's readiness report. A server that isn't ready
+// answers 503 with Status "unavailable"; that isn't an error. Ready
+// doesn't retry, so it can be used as a probe.
+func (c *Client) Ready(ctx context.Context) (Readiness, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/readyz", nil)
+	if err != nil {
+		return Readiness{}, err
+	}
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return Readiness{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusServiceUnavailable {
+		return Readiness{}, readError(res)
+	}
+	var r Readiness
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return Readiness{}, fmt.Errorf("decoding readiness report: %w", err)
+	}
+	return r, nil
+}
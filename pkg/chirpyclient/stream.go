@@ -0,0 +1,195 @@
+package chirpyclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stream event types. StreamReset means the client was disconnected for
+// too long to be sent the events it missed; reload anything that depends
+// on them.
+const (
+	StreamChirpCreated = EventChirpCreated
+	StreamChirpDeleted = EventChirpDeleted
+	StreamReset        = "stream.reset"
+)
+
+// StreamOptions filter Stream.
+type StreamOptions struct {
+	// AuthorID, if set, limits the stream to one user's chirps.
+	AuthorID uuid.UUID
+	// Hashtag, if set, limits the stream to chirps tagged with it.
+	Hashtag string
+	// Followed limits the stream to users the logged-in user follows.
+	Followed bool
+}
+
+func (o StreamOptions) query() url.Values {
+	q := url.Values{}
+	if o.AuthorID != uuid.Nil {
+		q.Set("author_id", o.AuthorID.String())
+	}
+	if o.Hashtag != "" {
+		q.Set("hashtag", o.Hashtag)
+	}
+	if o.Followed {
+		q.Set("followed", "true")
+	}
+	return q
+}
+
+// StreamEvent is one event from Stream. Chirp is set for chirp.created
+// and chirp.deleted.
+type StreamEvent struct {
+	ID    string
+	Type  string
+	Chirp Chirp
+}
+
+// defaultStreamRetry is the reconnection delay until the server suggests
+// one.
+const defaultStreamRetry = 2 * time.Second
+
+// Stream follows chirp events as they happen, from /api/stream. When the
+// connection drops it reconnects after the delay the server suggested and
+// resumes after the last event received. It stops when the caller stops
+// ranging or ctx is done, or after an error reconnecting can't fix, which
+// it yields.
+func (c *Client) Stream(ctx context.Context, opts StreamOptions) iter.Seq2[StreamEvent, error] {
+	return func(yield func(StreamEvent, error) bool) {
+		s := &eventStream{client: c, query: opts.query(), auth: opts.Followed, retry: defaultStreamRetry}
+		for attempt := 1; ; attempt++ {
+			received, err := s.connect(ctx, yield, false)
+			if ctx.Err() != nil || errors.Is(err, errStopped) {
+				return
+			}
+			if received {
+				attempt = 1
+			}
+			var apiErr *Error
+			if errors.As(err, &apiErr) && !retryable(http.MethodGet, apiErr.StatusCode) {
+				yield(StreamEvent{}, err)
+				return
+			}
+			delay := s.retry
+			if err != nil {
+				delay = max(delay, c.backoff(attempt, 0))
+			}
+			if err := sleep(ctx, delay); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// errStopped means the caller stopped ranging over the stream.
+var errStopped = errors.New("stream stopped")
+
+// eventStream is the state Stream keeps across connections.
+type eventStream struct {
+	client      *Client
+	query       url.Values
+	auth        bool
+	lastEventID string
+	retry       time.Duration
+}
+
+// connect opens one connection and yields its events until it ends. It
+// reports whether any event was received. An access token rejected as
+// invalid or expired is refreshed once, unless refreshed is already set.
+func (s *eventStream) connect(ctx context.Context, yield func(StreamEvent, error) bool, refreshed bool) (bool, error) {
+	c := s.client
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/stream?"+s.query.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if s.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", s.lastEventID)
+	}
+	access := c.Tokens().AccessToken
+	if s.auth {
+		req.Header.Set("Authorization", "Bearer "+access)
+	}
+
+	// The client's timeout would cut the stream off
+	hc := *c.HTTPClient
+	hc.Timeout = 0
+	res, err := hc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		apiErr := readError(res)
+		if s.auth && !refreshed && errors.Is(apiErr, ErrInvalidToken) && c.Tokens().RefreshToken != "" {
+			if err := c.refreshAfter(ctx, access); err != nil {
+				return false, errors.Join(apiErr, fmt.Errorf("refreshing access token: %w", err))
+			}
+			res.Body.Close()
+			return s.connect(ctx, yield, true)
+		}
+		return false, apiErr
+	}
+
+	received := false
+	var event StreamEvent
+	var data strings.Builder
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if event.Type == "" && data.Len() == 0 {
+				continue
+			}
+			if event.Type == "" {
+				event.Type = "message"
+			}
+			if event.Type == StreamChirpCreated || event.Type == StreamChirpDeleted {
+				if err := json.Unmarshal([]byte(data.String()), &event.Chirp); err != nil {
+					return received, fmt.Errorf("decoding %s event: %w", event.Type, err)
+				}
+			}
+			received = true
+			if !yield(event, nil) {
+				return received, errStopped
+			}
+			event, data = StreamEvent{}, strings.Builder{}
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			event.ID = value
+			s.lastEventID = value
+		case "event":
+			event.Type = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return received, scanner.Err()
+}
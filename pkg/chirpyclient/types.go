@@ -0,0 +1,94 @@
+package chirpyclient
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// User is an account. Token and RefreshToken are only set by Login.
+type User struct {
+	ID           uuid.UUID `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Email        string    `json:"email"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	// PendingEmail is an email change waiting for confirmation.
+	PendingEmail string `json:"pending_email,omitempty"`
+	IsChirpyRed  bool   `json:"is_chirpy_red"`
+}
+
+// Chirp is a post.
+type Chirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+// Account tiers.
+const (
+	TierFree      = "free"
+	TierChirpyRed = "chirpy_red"
+)
+
+// Capabilities are what an account may do.
+type Capabilities struct {
+	Tier              string `json:"tier"`
+	MaxChirpLength    int    `json:"max_chirp_length"`
+	CanEditChirps     bool   `json:"can_edit_chirps"`
+	MaxMediaPerChirp  int    `json:"max_media_per_chirp"`
+	CanScheduleChirps bool   `json:"can_schedule_chirps"`
+	RateLimitTier     string `json:"rate_limit_tier"`
+}
+
+// EntitlementOverride replaces parts of a user's capabilities. Nil fields
+// fall back to the user's tier.
+type EntitlementOverride struct {
+	Tier              *string `json:"tier,omitempty"`
+	MaxChirpLength    *int    `json:"max_chirp_length,omitempty"`
+	CanEditChirps     *bool   `json:"can_edit_chirps,omitempty"`
+	MaxMediaPerChirp  *int    `json:"max_media_per_chirp,omitempty"`
+	CanScheduleChirps *bool   `json:"can_schedule_chirps,omitempty"`
+	RateLimitTier     *string `json:"rate_limit_tier,omitempty"`
+}
+
+// Entitlements is a user's override and the capabilities it results in.
+type Entitlements struct {
+	UserID       uuid.UUID            `json:"user_id"`
+	Override     *EntitlementOverride `json:"override"`
+	Capabilities Capabilities         `json:"capabilities"`
+}
+
+// Webhook event types.
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserFollowed = "user.followed"
+	EventMention      = "mention"
+)
+
+// WebhookSubscription is an outgoing webhook. Secret is only set when the
+// subscription is created.
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+// WebhookDelivery is one event sent, or being sent, to a subscription.
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EventID        uuid.UUID  `json:"event_id"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int       `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,117 @@
+package chirpyclient
+
+import (
+	"context"
+	"net/http"
+)
+
+// CreateUser signs up a new account. It doesn't log in.
+func (c *Client) CreateUser(ctx context.Context, email, password string) (User, error) {
+	var u User
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/users",
+		body:   map[string]string{"email": email, "password": password},
+	}, &u)
+	return u, err
+}
+
+// Login authenticates and keeps the returned tokens for later calls.
+func (c *Client) Login(ctx context.Context, email, password string) (User, error) {
+	var u User
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/login",
+		body:   map[string]string{"email": email, "password": password},
+	}, &u)
+	if err != nil {
+		return User{}, err
+	}
+	c.updateTokens(Tokens{AccessToken: u.Token, RefreshToken: u.RefreshToken})
+	return u, nil
+}
+
+// Refresh trades the refresh token for a new access token. Calls whose
+// access token is rejected as invalid or expired do this automatically.
+func (c *Client) Refresh(ctx context.Context) error {
+	var res struct {
+		Token string `json:"token"`
+	}
+	err := c.send(ctx, request{method: http.MethodPost, path: "/api/refresh", auth: authRefresh}, "", &res)
+	if err != nil {
+		return err
+	}
+	t := c.Tokens()
+	t.AccessToken = res.Token
+	c.updateTokens(t)
+	return nil
+}
+
+// Logout revokes the refresh token and forgets both tokens.
+func (c *Client) Logout(ctx context.Context) error {
+	if c.Tokens().RefreshToken != "" {
+		err := c.send(ctx, request{method: http.MethodPost, path: "/api/revoke", auth: authRefresh}, "", nil)
+		if err != nil {
+			return err
+		}
+	}
+	c.updateTokens(Tokens{})
+	return nil
+}
+
+// ReplaceUser sets the caller's email and password, both at once.
+// currentPassword is checked unless it is empty.
+//
+// Deprecated: PUT /api/users is kept for existing clients. Use UpdateUser,
+// which needs the current password and confirms email changes.
+func (c *Client) ReplaceUser(ctx context.Context, email, password, currentPassword string) (User, error) {
+	var u User
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   "/api/users",
+		body:   replaceUserRequest{Email: email, Password: password, CurrentPassword: currentPassword},
+		auth:   authAccess,
+	}, &u)
+	return u, err
+}
+
+type replaceUserRequest struct {
+	Email           string `json:"email"`
+	Password        string `json:"password"`
+	CurrentPassword string `json:"current_password,omitempty"`
+}
+
+// UpdateUserParams are the fields UpdateUser changes. Nil fields are left
+// alone. Changing the password needs CurrentPassword.
+type UpdateUserParams struct {
+	Email           *string `json:"email,omitempty"`
+	Password        *string `json:"password,omitempty"`
+	CurrentPassword string  `json:"current_password,omitempty"`
+}
+
+// UpdateUser changes the caller's email or password. A new email only
+// takes effect once confirmed and is reported as PendingEmail until then.
+func (c *Client) UpdateUser(ctx context.Context, params UpdateUserParams) (User, error) {
+	var u User
+	err := c.do(ctx, request{method: http.MethodPatch, path: "/api/users/me", body: params, auth: authAccess}, &u)
+	return u, err
+}
+
+// ConfirmEmailChange applies an email change using the token from the
+// confirmation link.
+func (c *Client) ConfirmEmailChange(ctx context.Context, token string) (User, error) {
+	var u User
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/users/email/confirm",
+		body:   map[string]string{"token": token},
+	}, &u)
+	return u, err
+}
+
+// MyEntitlements returns the caller's capabilities.
+func (c *Client) MyEntitlements(ctx context.Context) (Capabilities, error) {
+	var caps Capabilities
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/users/me/entitlements", auth: authAccess}, &caps)
+	return caps, err
+}
@@ -0,0 +1,69 @@
+package chirpyclient
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// CreateWebhook subscribes url to events. The returned subscription's
+// Secret signs deliveries and isn't shown again.
+func (c *Client) CreateWebhook(ctx context.Context, url string, events ...string) (WebhookSubscription, error) {
+	var sub WebhookSubscription
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/webhooks",
+		body:   map[string]any{"url": url, "events": events},
+		auth:   authAccess,
+	}, &sub)
+	return sub, err
+}
+
+// ListWebhooks returns the logged-in user's subscriptions.
+func (c *Client) ListWebhooks(ctx context.Context) ([]WebhookSubscription, error) {
+	var subs []WebhookSubscription
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/webhooks", auth: authAccess}, &subs)
+	return subs, err
+}
+
+// DeleteWebhook removes a subscription.
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/webhooks/" + id.String(), auth: authAccess}, nil)
+}
+
+// ListWebhookDeliveries returns up to limit of a subscription's most
+// recent deliveries, newest first. A limit of 0 uses the server default.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, id uuid.UUID, limit int) ([]WebhookDelivery, error) {
+	q := url.Values{}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var deliveries []WebhookDelivery
+	err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/webhooks/" + id.String() + "/deliveries",
+		query:  q,
+		auth:   authAccess,
+	}, &deliveries)
+	return deliveries, err
+}
+
+// WebhookDeliveries iterates over a subscription's recent deliveries,
+// newest first, stopping after the first error.
+func (c *Client) WebhookDeliveries(ctx context.Context, id uuid.UUID) iter.Seq2[WebhookDelivery, error] {
+	return func(yield func(WebhookDelivery, error) bool) {
+		deliveries, err := c.ListWebhookDeliveries(ctx, id, 0)
+		if err != nil {
+			yield(WebhookDelivery{}, err)
+			return
+		}
+		for _, d := range deliveries {
+			if !yield(d, nil) {
+				return
+			}
+		}
+	}
+}
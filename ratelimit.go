@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/entitlements"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/ratelimit"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rate limit policy names, used in bucket keys and metrics.
+const (
+	rateLimitSignup       = "signup"
+	rateLimitLogin        = "login"
+	rateLimitChirpsCreate = "chirps_create"
+)
+
+// rateLimited applies the named policy to next. Signed-in callers are
+// limited per user at their entitlement's rate limit tier, everyone else
+// per client IP. If the limiter's store fails the request is let through.
+func (cfg *apiConfig) rateLimited(name string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, tier := cfg.rateLimitSubject(r)
+		res, limit, ok := cfg.takeRateLimit(r.Context(), name, key, tier)
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Per.Seconds())))
+		if !res.Allowed {
+			h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+			respondWithError(w, r, rateLimitExceeded(res))
+			return
+		}
+		next(w, r)
+	})
+}
+
+// takeRateLimit takes a token from key's bucket under the named policy.
+// ok is false if the policy doesn't limit the tier or the limiter's store
+// failed, in which case the caller should go ahead.
+func (cfg *apiConfig) takeRateLimit(ctx context.Context, name, key string, tier entitlements.RateLimitTier) (res ratelimit.Result, limit ratelimit.Limit, ok bool) {
+	policy, ok := cfg.rateLimitPolicies[name]
+	if !ok || cfg.rateLimiter == nil {
+		return res, limit, false
+	}
+	limit = policy.For(tier)
+	if limit.IsUnlimited() {
+		return res, limit, false
+	}
+
+	res, err := cfg.rateLimiter.Take(ctx, name+":"+key, limit, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "rate limiter unavailable", "policy", name, "error", err)
+		return res, limit, false
+	}
+	if !res.Allowed {
+		cfg.metrics.rateLimited.With(name, string(tier)).Inc()
+	}
+	return res, limit, true
+}
+
+func rateLimitExceeded(res ratelimit.Result) *apiError {
+	return newAPIError(http.StatusTooManyRequests, problem.CodeRateLimited, "Too many requests, retry after "+ceilSeconds(res.RetryAfter)+" seconds", nil)
+}
+
+// rateLimitSubject returns the bucket key and tier for the caller. A
+// valid access token is enough to be limited as a user; handlers still do
+// their own authentication.
+func (cfg *apiConfig) rateLimitSubject(r *http.Request) (string, entitlements.RateLimitTier) {
+	if token, err := auth.GetBearerToken(r.Header); err == nil {
+		if userID, err := auth.ValidateJWT(token, cfg.jwtSecret); err == nil {
+			return "user:" + userID.String(), cfg.rateLimitTier(r.Context(), userID)
+		}
+	}
+	return "ip:" + cfg.clientIP(r), entitlements.RateLimitAnonymous
+}
+
+func (cfg *apiConfig) rateLimitTier(ctx context.Context, userID uuid.UUID) entitlements.RateLimitTier {
+	caps, err := cfg.entitlements.For(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "looking up rate limit tier", "error", err)
+		return entitlements.RateLimitStandard
+	}
+	return caps.RateLimitTier
+}
+
+// clientIP returns the caller's address, taken from the last
+// X-Forwarded-For entry when the server is configured to trust it. That
+// entry is the one our proxy appended; anything before it came from the
+// client and could be made up to dodge the limits.
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+	if cfg.trustForwardedFor {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			last := fwd[len(fwd)-1]
+			if i := strings.LastIndexByte(last, ','); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := net.ParseIP(strings.TrimSpace(last)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// runRateLimitCleanup deletes Postgres buckets that have been idle for a
+// day, long enough for any configured bucket to have refilled.
+func runRateLimitCleanup(ctx context.Context, s *ratelimit.PostgresStore, interval time.Duration, beat func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.DeleteIdle(ctx, time.Now().Add(-24*time.Hour))
+			beat(err)
+			if err != nil {
+				slog.ErrorContext(ctx, "deleting idle rate limit buckets", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "deleted idle rate limit buckets", "count", n)
+			}
+		}
+	}
+}
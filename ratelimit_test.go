@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"example.com/chirpy/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// failingLimiter is a rate limit store that is always down.
+type failingLimiter struct{}
+
+func (failingLimiter) Take(context.Context, string, ratelimit.Limit, time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func newRateLimitTestConfig(limiter ratelimit.Store, limit ratelimit.Limit) *apiConfig {
+	return &apiConfig{
+		metrics:     newAppMetrics(nil),
+		jwtSecret:   "test-secret",
+		rateLimiter: limiter,
+		rateLimitPolicies: map[string]ratelimit.Policy{
+			rateLimitLogin: {Anonymous: limit, Standard: limit, Premium: limit},
+		},
+	}
+}
+
+// rateLimitTestHandler counts the requests that get through.
+func rateLimitTestHandler(cfg *apiConfig, served *int) http.Handler {
+	return cfg.rateLimited(rateLimitLogin, func(w http.ResponseWriter, r *http.Request) {
+		*served++
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func TestRateLimitedHeaders(t *testing.T) {
+	cfg := newRateLimitTestConfig(ratelimit.NewMemoryStore(), ratelimit.Limit{Requests: 2, Per: time.Minute})
+	var served int
+	h := rateLimitTestHandler(cfg, &served)
+
+	var w *httptest.ResponseRecorder
+	for i := range 2 {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/login", nil))
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("request %d: got status %d, want 204", i+1, w.Code)
+		}
+		if got, want := w.Header().Get("RateLimit-Remaining"), strconv.Itoa(1-i); got != want {
+			t.Errorf("request %d: RateLimit-Remaining = %q, want %q", i+1, got, want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/login", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, want 429", w.Code)
+	}
+	if served != 2 {
+		t.Errorf("handler served %d requests, want 2", served)
+	}
+	want := map[string]string{
+		"Retry-After":         "30",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+		"RateLimit-Policy":    "2;w=60",
+		"Content-Type":        "application/problem+json",
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if got := cfg.metrics.rateLimited.With(rateLimitLogin, "anonymous").Value(); got != 1 {
+		t.Errorf("rate limited count = %v, want 1", got)
+	}
+}
+
+func TestRateLimitedFailsOpen(t *testing.T) {
+	cfg := newRateLimitTestConfig(failingLimiter{}, ratelimit.Limit{Requests: 0, Per: time.Minute})
+	var served int
+	h := rateLimitTestHandler(cfg, &served)
+
+	for range 3 {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/login", nil))
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("got status %d, want 204", w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != "" {
+			t.Errorf("RateLimit-Limit = %q, want no header", got)
+		}
+	}
+	if served != 3 {
+		t.Errorf("handler served %d requests, want 3", served)
+	}
+}
+
+func TestRateLimitedUnlimited(t *testing.T) {
+	cfg := newRateLimitTestConfig(failingLimiter{}, ratelimit.Unlimited)
+	var served int
+	w := httptest.NewRecorder()
+	rateLimitTestHandler(cfg, &served).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/login", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("got status %d and RateLimit-Limit %q, want 204 without limit headers", w.Code, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trust      bool
+		forwarded  []string
+		remoteAddr string
+		want       string
+	}{
+		{name: "remote address", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "untrusted header", forwarded: []string{"198.51.100.7"}, remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "single entry", trust: true, forwarded: []string{"198.51.100.7"}, remoteAddr: "192.0.2.1:1234", want: "198.51.100.7"},
+		{name: "client supplied entries", trust: true, forwarded: []string{"203.0.113.9, 10.0.0.1, 198.51.100.7"}, remoteAddr: "192.0.2.1:1234", want: "198.51.100.7"},
+		{name: "repeated header", trust: true, forwarded: []string{"203.0.113.9", "198.51.100.7"}, remoteAddr: "192.0.2.1:1234", want: "198.51.100.7"},
+		{name: "ipv6", trust: true, forwarded: []string{"2001:db8::1"}, remoteAddr: "192.0.2.1:1234", want: "2001:db8::1"},
+		{name: "garbage", trust: true, forwarded: []string{"198.51.100.7, unknown"}, remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "no port", remoteAddr: "192.0.2.1", want: "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &apiConfig{trustForwardedFor: tt.trust}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := cfg.clientIP(r); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/store"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+)
+
+// reactionKinds are the reactions a chirp can get.
+var reactionKinds = []string{"like", "love", "laugh", "surprised", "sad", "angry"}
+
+// parseReactionKind checks a kind argument against reactionKinds.
+func parseReactionKind(kind string) (string, error) {
+	if !slices.Contains(reactionKinds, kind) {
+		return "", validationFailed(fieldError("kind", problem.FieldInvalid, "kind must be one of "+strings.Join(reactionKinds, ", ")))
+	}
+	return kind, nil
+}
+
+// addReaction makes userID react to chirpID with kind. Reacting twice is
+// not an error.
+func (cfg *apiConfig) addReaction(ctx context.Context, userID, chirpID uuid.UUID, kind string) error {
+	_, err := cfg.store.Reactions().AddReaction(ctx, database.AddReactionParams{
+		ChirpID: chirpID,
+		UserID:  userID,
+		Kind:    kind,
+	})
+	if errors.Is(err, store.ErrInvalidReference) {
+		return notFound("Chirp not found", err)
+	}
+	if err != nil {
+		return internalError("Couldn't add reaction", err)
+	}
+	return nil
+}
+
+// removeReaction takes back userID's reaction to chirpID. Removing a
+// reaction that isn't there is not an error, but the chirp must exist.
+func (cfg *apiConfig) removeReaction(ctx context.Context, userID, chirpID uuid.UUID, kind string) error {
+	if _, err := cfg.store.Chirps().GetChirpByID(ctx, chirpID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return notFound("Chirp not found", err)
+		}
+		return internalError("Failed to retrieve chirp", err)
+	}
+	err := cfg.store.Reactions().RemoveReaction(ctx, database.RemoveReactionParams{
+		ChirpID: chirpID,
+		UserID:  userID,
+		Kind:    kind,
+	})
+	if err != nil {
+		return internalError("Couldn't remove reaction", err)
+	}
+	return nil
+}
+
+// loadReactions fetches reaction counts for the request's reaction loader,
+// so that a page of chirps costs one query for all their reactions.
+// viewerID marks the signed-in user's own reactions; uuid.Nil marks none.
+func (cfg *apiConfig) loadReactions(viewerID uuid.UUID) func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]database.CountReactionsRow, error) {
+	return func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]database.CountReactionsRow, error) {
+		rows, err := cfg.store.Reactions().CountReactions(ctx, database.CountReactionsParams{
+			ViewerID: uuid.NullUUID{UUID: viewerID, Valid: viewerID != uuid.Nil},
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return nil, internalError("Couldn't count reactions", err)
+		}
+		// Chirps without reactions have an empty list rather than null
+		byChirp := make(map[uuid.UUID][]database.CountReactionsRow, len(ids))
+		for _, id := range ids {
+			byChirp[id] = []database.CountReactionsRow{}
+		}
+		for _, row := range rows {
+			byChirp[row.ChirpID] = append(byChirp[row.ChirpID], row)
+		}
+		return byChirp, nil
+	}
+}
@@ -1,15 +1,99 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
-func handlerReadiness(w http.ResponseWriter, r *http.Request) {
+const readinessDBTimeout = 2 * time.Second
+
+// handlerLiveness reports that the process is up and serving. It checks
+// no dependencies, so a database outage doesn't get the server restarted.
+func handlerLiveness(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	_, err := w.Write([]byte(http.StatusText(http.StatusOK)))
 	if err != nil {
-		fmt.Println(err)
+		slog.ErrorContext(r.Context(), "writing liveness response", "error", err)
+	}
+}
+
+// ReadinessResponse is the body of /api/readyz.
+type ReadinessResponse struct {
+	Status       string                  `json:"status"`
+	ShuttingDown bool                    `json:"shutting_down"`
+	Database     CheckResult             `json:"database"`
+	Migrations   MigrationCheckResult    `json:"migrations"`
+	Workers      map[string]WorkerHealth `json:"workers"`
+}
+
+// CheckResult is the outcome of one dependency probe.
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// MigrationCheckResult compares the applied and expected schema versions.
+type MigrationCheckResult struct {
+	Status   string `json:"status"`
+	Version  int64  `json:"version"`
+	Expected int64  `json:"expected"`
+	Error    string `json:"error,omitempty"`
+}
+
+// handlerReadiness reports whether the server should receive traffic: the
+// database answers, the schema is current and the server isn't shutting
+// down. It responds 503 otherwise. Worker health is reported, and an
+// unhealthy worker marks the server "degraded", but it doesn't fail the
+// check: taking every replica out of rotation wouldn't fix a stuck worker
+// and would turn it into an outage.
+func (cfg *apiConfig) handlerReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessDBTimeout)
+	defer cancel()
+
+	res := ReadinessResponse{
+		Status:       "ok",
+		ShuttingDown: cfg.shuttingDown.Load(),
+		Database:     CheckResult{Status: "ok"},
+		Migrations:   MigrationCheckResult{Status: "ok", Expected: cfg.migrator.Latest()},
+	}
+	ready := !res.ShuttingDown
+
+	start := time.Now()
+	err := cfg.sqlDB.PingContext(ctx)
+	res.Database.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		res.Database.Status = "unavailable"
+		res.Database.Error = err.Error()
+		ready = false
+	}
+
+	version, err := cfg.migrator.Version(ctx)
+	res.Migrations.Version = version
+	switch {
+	case err != nil:
+		res.Migrations.Status = "unavailable"
+		res.Migrations.Error = err.Error()
+		ready = false
+	case version < res.Migrations.Expected:
+		res.Migrations.Status = "behind"
+		ready = false
+	}
+
+	workers, healthy := cfg.workers.Health()
+	res.Workers = workers
+
+	code := http.StatusOK
+	switch {
+	case !ready:
+		res.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+	case !healthy:
+		res.Status = "degraded"
 	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, res)
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readiness(t *testing.T, h http.Handler) (int, ReadinessResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/readyz", nil))
+	var res ReadinessResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body.String(), err)
+	}
+	return rec.Code, res
+}
+
+func TestReadiness(t *testing.T) {
+	apiCfg, h := newTestAPI(t, "sqlite:"+filepath.Join(t.TempDir(), "chirpy.db"))
+
+	code, res := readiness(t, h)
+	if code != http.StatusOK || res.Status != "ok" || res.Database.Status != "ok" || res.Migrations.Status != "ok" {
+		t.Fatalf("got %d %+v", code, res)
+	}
+	if res.Migrations.Version != apiCfg.migrator.Latest() {
+		t.Errorf("version = %d, want %d", res.Migrations.Version, apiCfg.migrator.Latest())
+	}
+
+	// A failing worker is reported but doesn't take the server out of
+	// rotation
+	beaten := make(chan struct{})
+	apiCfg.workers.Go("broken", time.Minute, func(ctx context.Context, beat func(error)) {
+		beat(errors.New("smtp relay unreachable"))
+		close(beaten)
+		<-ctx.Done()
+	})
+	<-beaten
+	code, res = readiness(t, h)
+	if code != http.StatusOK || res.Status != "degraded" {
+		t.Fatalf("with a failing worker: got %d %q, want 200 degraded", code, res.Status)
+	}
+	if w := res.Workers["broken"]; w.Status != "failing" || w.LastError != "smtp relay unreachable" {
+		t.Errorf("worker health = %+v", w)
+	}
+
+	apiCfg.shuttingDown.Store(true)
+	code, res = readiness(t, h)
+	if code != http.StatusServiceUnavailable || res.Status != "unavailable" || !res.ShuttingDown {
+		t.Fatalf("shutting down: got %d %+v", code, res)
+	}
+}
+
+func TestReadinessDatabaseDown(t *testing.T) {
+	apiCfg, h := newTestAPI(t, "sqlite:"+filepath.Join(t.TempDir(), "chirpy.db"))
+	apiCfg.sqlDB.Close()
+
+	code, res := readiness(t, h)
+	if code != http.StatusServiceUnavailable || res.Database.Status != "unavailable" || res.Database.Error == "" {
+		t.Fatalf("got %d %+v", code, res)
+	}
+}
@@ -1,21 +1,125 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"example.com/chirpy/internal/auth"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/fixtures"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/ratelimit"
+	"example.com/chirpy/internal/store"
+	"fmt"
+	"github.com/google/uuid"
 	"net/http"
 )
 
+// ResetRequest is the body of POST /admin/reset.
+type ResetRequest struct {
+	// Confirm must be true; it guards against a stray request wiping the
+	// database.
+	Confirm bool `json:"confirm"`
+	// Fixtures names a fixture set to load after the reset.
+	Fixtures string `json:"fixtures"`
+}
+
+func (req ResetRequest) Validate() []problem.FieldError {
+	if !req.Confirm {
+		return []problem.FieldError{fieldError("confirm", problem.FieldInvalid, "confirm must be true")}
+	}
+	return nil
+}
+
+// ResetResponse reports what the reset loaded.
+type ResetResponse struct {
+	Message  string               `json:"message"`
+	Fixtures string               `json:"fixtures,omitempty"`
+	Users    map[string]uuid.UUID `json:"users"`
+	Chirps   int                  `json:"chirps"`
+	Follows  int                  `json:"follows"`
+}
+
+// handlerReset empties every table in one transaction, loads the requested
+// fixture set in the same transaction, then zeroes the metrics, refills the
+// rate limit buckets and empties the stream's replay buffer, so each local
+// run or integration test starts from the same state.
 func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	if cfg.platform != "dev" {
-		respondWithError(w, http.StatusForbidden, "This endpoint is only available in development", nil)
+		respondWithError(w, r, forbidden("This endpoint is only available in development", nil))
+		return
+	}
+
+	var req ResetRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithError(w, r, err)
 		return
 	}
 
-	// Reset the users in the database
-	err := cfg.db.DeleteAllUsers(r.Context())
+	var set *fixtures.Set
+	if req.Fixtures != "" {
+		var err error
+		set, err = fixtures.Load(cfg.fixtures, req.Fixtures)
+		if errors.Is(err, fixtures.ErrNotFound) {
+			respondWithError(w, r, validationFailed(fieldError("fixtures", problem.FieldInvalid, fmt.Sprintf("there is no fixture set %q", req.Fixtures))))
+			return
+		}
+		if err != nil {
+			respondWithError(w, r, internalError("Couldn't load the fixture set", err))
+			return
+		}
+	}
+
+	res := ResetResponse{Message: "Database reset", Fixtures: req.Fixtures, Users: map[string]uuid.UUID{}}
+	err := cfg.store.WithTx(r.Context(), func(s store.Store) error {
+		if err := s.Reset(r.Context()); err != nil {
+			return err
+		}
+		if set == nil {
+			return nil
+		}
+		return loadFixtures(r.Context(), s, set, &res)
+	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not reset users", nil)
+		respondWithError(w, r, internalError("Couldn't reset the database", err))
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{"message": "All users have been deleted"})
+	cfg.metrics.registry.Reset()
+	// Postgres buckets live in a table and went with the rest
+	if limiter, ok := cfg.rateLimiter.(*ratelimit.MemoryStore); ok {
+		limiter.Reset()
+	}
+	cfg.stream.Reset()
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// loadFixtures creates the set's users, chirps and follows through s and
+// records them in res.
+func loadFixtures(ctx context.Context, s store.Store, set *fixtures.Set, res *ResetResponse) error {
+	for _, u := range set.Users {
+		hash, err := auth.HashPassword(u.Password)
+		if err != nil {
+			return err
+		}
+		user, err := s.Users().CreateUser(ctx, database.CreateUserParams{Email: u.Email, HashedPassword: hash})
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", u.Email, err)
+		}
+		res.Users[user.Email] = user.ID
+	}
+	for _, c := range set.Chirps {
+		_, err := s.Chirps().CreateChirp(ctx, database.CreateChirpParams{UserID: res.Users[c.Author], Body: c.Body})
+		if err != nil {
+			return fmt.Errorf("creating chirp by %s: %w", c.Author, err)
+		}
+		res.Chirps++
+	}
+	for _, f := range set.Follows {
+		_, err := s.Follows().FollowUser(ctx, database.FollowUserParams{FollowerID: res.Users[f.Follower], FolloweeID: res.Users[f.Followee]})
+		if err != nil {
+			return fmt.Errorf("%s following %s: %w", f.Follower, f.Followee, err)
+		}
+		res.Follows++
+	}
+	return nil
 }
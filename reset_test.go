@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"example.com/chirpy/internal/database"
+	"example.com/chirpy/internal/fixtures"
+	"example.com/chirpy/internal/problem"
+	"example.com/chirpy/internal/ratelimit"
+	"example.com/chirpy/internal/store"
+	"example.com/chirpy/internal/stream"
+	"example.com/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newResetTestConfig(t *testing.T, platform string) (*apiConfig, database.User) {
+	t.Helper()
+	s := store.NewMemory()
+	user, err := s.Users().CreateUser(context.Background(), database.CreateUserParams{
+		Email:          "walt@example.com",
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &apiConfig{
+		store:       s,
+		platform:    platform,
+		fixtures:    fixtures.Builtin(),
+		metrics:     newAppMetrics(nil),
+		stream:      stream.NewHub(10),
+		rateLimiter: ratelimit.NewMemoryStore(),
+	}, user
+}
+
+// sendReset posts body to the reset handler and decodes the response into
+// out.
+func sendReset(t *testing.T, cfg *apiConfig, body any, out any) int {
+	t.Helper()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/admin/reset", bytes.NewReader(b))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	cfg.handlerReset(w, r)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+	}
+	return w.Code
+}
+
+// assertUserKept fails the test if a refused reset deleted user.
+func assertUserKept(t *testing.T, cfg *apiConfig, user database.User) {
+	t.Helper()
+	if _, err := cfg.store.Users().GetUserByEmail(context.Background(), user.Email); err != nil {
+		t.Errorf("user is gone after a refused reset: %v", err)
+	}
+}
+
+func TestResetOnlyInDev(t *testing.T) {
+	for _, platform := range []string{"", "prod"} {
+		t.Run(platform, func(t *testing.T) {
+			cfg, user := newResetTestConfig(t, platform)
+			var p problem.Problem
+			if got := sendReset(t, cfg, ResetRequest{Confirm: true}, &p); got != http.StatusForbidden {
+				t.Fatalf("got status %d, want 403", got)
+			}
+			if p.Code != problem.CodeForbidden {
+				t.Errorf("got code %q, want %q", p.Code, problem.CodeForbidden)
+			}
+			assertUserKept(t, cfg, user)
+		})
+	}
+}
+
+func TestResetRejectsBadRequests(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  any
+		field string
+	}{
+		{name: "no confirm", body: map[string]any{}, field: "confirm"},
+		{name: "confirm false", body: ResetRequest{Fixtures: "basic"}, field: "confirm"},
+		{name: "unknown fixture set", body: ResetRequest{Confirm: true, Fixtures: "nope"}, field: "fixtures"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, user := newResetTestConfig(t, "dev")
+			var p problem.Problem
+			if got := sendReset(t, cfg, tt.body, &p); got != http.StatusBadRequest {
+				t.Fatalf("got status %d, want 400", got)
+			}
+			if p.Code != problem.CodeValidationFailed || len(p.Errors) != 1 || p.Errors[0].Field != tt.field {
+				t.Errorf("got %+v, want a validation error for %s", p, tt.field)
+			}
+			assertUserKept(t, cfg, user)
+		})
+	}
+}
+
+func TestResetClearsState(t *testing.T) {
+	ctx := context.Background()
+	cfg, user := newResetTestConfig(t, "dev")
+
+	// Fill the replay buffer, empty a rate limit bucket and count a metric
+	eventID := uuid.New()
+	payload, _ := json.Marshal(map[string]any{"user_id": user.ID, "body": "gone soon"})
+	err := cfg.stream.Publish(ctx, database.OutboxEvent{ID: eventID, EventType: webhooks.EventChirpCreated, Payload: payload, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := ratelimit.Limit{Requests: 1, Per: time.Hour}
+	if res, _ := cfg.rateLimiter.Take(ctx, "signup:ip:192.0.2.1", limit, time.Now()); !res.Allowed {
+		t.Fatal("first request was limited")
+	}
+	cfg.metrics.rateLimited.With(rateLimitSignup, "anonymous").Inc()
+
+	var res ResetResponse
+	if got := sendReset(t, cfg, ResetRequest{Confirm: true, Fixtures: "basic"}, &res); got != http.StatusOK {
+		t.Fatalf("got status %d, want 200", got)
+	}
+	if len(res.Users) != 2 || res.Chirps != 2 || res.Fixtures != "basic" {
+		t.Errorf("got %+v, want the basic fixture set", res)
+	}
+
+	if _, err := cfg.store.Users().GetUserByEmail(ctx, user.Email); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("user lookup after reset: got %v, want ErrNotFound", err)
+	}
+	if _, err := cfg.store.Users().GetUserByEmail(ctx, "alice@example.com"); err != nil {
+		t.Errorf("fixture user missing: %v", err)
+	}
+
+	sub, replay, resumed, err := cfg.stream.Subscribe(stream.Filter{}, eventID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Close()
+	if resumed || len(replay) != 0 {
+		t.Errorf("resuming from a deleted event: got resumed %v with %d events, want a reset", resumed, len(replay))
+	}
+
+	if res, _ := cfg.rateLimiter.Take(ctx, "signup:ip:192.0.2.1", limit, time.Now()); !res.Allowed {
+		t.Error("rate limit bucket was not refilled")
+	}
+	if got := cfg.metrics.rateLimited.With(rateLimitSignup, "anonymous").Value(); got != 0 {
+		t.Errorf("rate limited count = %v, want 0", got)
+	}
+}
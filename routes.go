@@ -0,0 +1,114 @@
+package main
+
+import (
+	"example.com/chirpy/internal/problem"
+	"net/http"
+	"strings"
+)
+
+// routes builds the HTTP handler for the API, wrapped in tracing and
+// request logging, and returns the registered patterns so they can be
+// checked against the OpenAPI document.
+func (cfg *apiConfig) routes(filepathRoot string) (http.Handler, []string) {
+	mux := &routeMux{ServeMux: http.NewServeMux()}
+	fsHandler := cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
+	mux.Handle("/app/", fsHandler)
+
+	mux.Handle("GET /metrics", cfg.metrics.registry.Handler())
+
+	mux.HandleFunc("GET /api/healthz", handlerLiveness)
+	mux.HandleFunc("GET /api/livez", handlerLiveness)
+	mux.HandleFunc("GET /api/readyz", cfg.handlerReadiness)
+	mux.HandleFunc("GET /api/openapi.json", handlerOpenAPI)
+	mux.HandleFunc("GET /api/docs", handlerDocs)
+	mux.HandleFunc("POST /api/validate_chirp", cfg.handlerChirpsValidate)
+	mux.Handle("POST /api/users", cfg.rateLimited(rateLimitSignup, cfg.handlerCreateUser))
+	mux.Handle("POST /api/login", cfg.rateLimited(rateLimitLogin, cfg.handlerLoginUser))
+	mux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
+	mux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
+	mux.HandleFunc("PUT /api/users", cfg.handlerUpdateUser)
+	mux.Handle("POST /api/chirps", cfg.rateLimited(rateLimitChirpsCreate, cfg.handlerChirpsCreate))
+	mux.HandleFunc("GET /api/chirps", cfg.handlerGetChirps)
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.handlerGetChirpByID)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.handlerDeleteChirpByID)
+	mux.HandleFunc("GET /api/stream", cfg.handlerStream)
+	mux.HandleFunc("GET /api/ws", cfg.handlerSocket)
+	mux.HandleFunc("GET /api/graphql", cfg.handlerGraphQL)
+	mux.HandleFunc("POST /api/graphql", cfg.handlerGraphQL)
+	mux.HandleFunc("GET /api/graphql/schema", cfg.handlerGraphQLSchema)
+	mux.HandleFunc("GET /api/users/me/entitlements", cfg.handlerGetMyEntitlements)
+	mux.HandleFunc("GET /api/users/me/timeline", cfg.handlerGetTimeline)
+
+	mux.HandleFunc("POST /admin/reset", cfg.handlerReset)
+	mux.HandleFunc("GET /admin/metrics", cfg.handlerMetrics)
+
+	mux.HandleFunc("PATCH /api/users/me", cfg.handlerPatchUser)
+	mux.HandleFunc("GET /api/users/email/confirm", cfg.handlerConfirmEmailChangePage)
+	mux.HandleFunc("POST /api/users/email/confirm", cfg.handlerConfirmEmailChange)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.handlerUnfollowUser)
+	mux.HandleFunc("POST /api/webhooks", cfg.handlerCreateWebhookSubscription)
+	mux.HandleFunc("GET /api/webhooks", cfg.handlerListWebhookSubscriptions)
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", cfg.handlerDeleteWebhookSubscription)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", cfg.handlerListWebhookDeliveries)
+
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerWebhooks)
+
+	mux.HandleFunc("GET /admin/users/{userID}/entitlements", cfg.handlerAdminGetEntitlements)
+	mux.HandleFunc("PUT /admin/users/{userID}/entitlements", cfg.handlerAdminSetEntitlements)
+	mux.HandleFunc("DELETE /admin/users/{userID}/entitlements", cfg.handlerAdminDeleteEntitlements)
+
+	return cfg.middleware(mux.ServeMux), mux.patterns
+}
+
+// middleware wraps mux in the middleware every request passes through.
+func (cfg *apiConfig) middleware(mux *http.ServeMux) http.Handler {
+	var h http.Handler = unmatchedAsProblems(mux)
+	if cfg.contract != nil {
+		h = cfg.middlewareContract(mux, h)
+	}
+	return cfg.middlewareTracing(mux, cfg.middlewareLogging(mux, h))
+}
+
+// routeMux is a ServeMux that remembers the patterns registered on it.
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// unmatchedAsProblems answers requests that match no route with a problem
+// document instead of the mux's plain-text 404 and 405 responses.
+func unmatchedAsProblems(mux *http.ServeMux) http.Handler {
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		var allowed []string
+		for _, method := range methods {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			if _, pattern := mux.Handler(probe); pattern != "" {
+				allowed = append(allowed, method)
+			}
+		}
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			respondWithError(w, r, newAPIError(http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method not allowed", nil))
+			return
+		}
+		respondWithError(w, r, notFound("No such endpoint", nil))
+	})
+}
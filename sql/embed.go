@@ -0,0 +1,15 @@
+// Package sqlfs embeds the goose migrations so the server can apply them
+// itself at startup.
+package sqlfs
+
+import "embed"
+
+// Postgres holds the Postgres migrations under schema/.
+//
+//go:embed schema/*.sql
+var Postgres embed.FS
+
+// SQLite holds the SQLite migrations under sqlite/schema/.
+//
+//go:embed sqlite/schema/*.sql
+var SQLite embed.FS
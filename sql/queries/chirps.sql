@@ -23,4 +23,32 @@ WHERE id = $1;
 SELECT *
 FROM chirps
 WHERE user_id = $1
-ORDER BY created_at;
\ No newline at end of file
+ORDER BY created_at;
+
+-- name: ListChirps :many
+SELECT id, created_at, updated_at, body, user_id
+FROM chirps
+WHERE (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(before_created_at)::timestamp IS NULL
+       OR (created_at, id) < (sqlc.narg(before_created_at), sqlc.narg(before_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListChirpsOldestFirst :many
+SELECT id, created_at, updated_at, body, user_id
+FROM chirps
+WHERE (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(after_created_at)::timestamp IS NULL
+       OR (created_at, id) > (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: ListTimelineChirps :many
+SELECT id, created_at, updated_at, body, user_id
+FROM chirps
+WHERE (user_id = sqlc.arg(user_id)
+       OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg(user_id)))
+  AND (sqlc.narg(before_created_at)::timestamp IS NULL
+       OR (created_at, id) < (sqlc.narg(before_created_at), sqlc.narg(before_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
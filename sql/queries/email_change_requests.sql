@@ -0,0 +1,14 @@
+-- name: CreateEmailChangeRequest :one
+INSERT INTO email_change_requests (token_hash, created_at, user_id, new_email, expires_at)
+VALUES ($1, now(), $2, $3, $4)
+RETURNING *;
+
+-- name: GetEmailChangeRequestForUpdate :one
+SELECT *
+FROM email_change_requests
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: DeleteEmailChangeRequestsForUser :exec
+DELETE FROM email_change_requests
+WHERE user_id = $1;
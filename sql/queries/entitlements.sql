@@ -0,0 +1,21 @@
+-- name: GetEntitlementOverride :one
+SELECT *
+FROM entitlement_overrides
+WHERE user_id = $1;
+
+-- name: UpsertEntitlementOverride :one
+INSERT INTO entitlement_overrides (user_id, created_at, updated_at, tier, max_chirp_length, can_edit_chirps, max_media_per_chirp, can_schedule_chirps, rate_limit_tier)
+VALUES ($1, now(), now(), $2, $3, $4, $5, $6, $7)
+ON CONFLICT (user_id) DO UPDATE
+SET tier = EXCLUDED.tier,
+    max_chirp_length = EXCLUDED.max_chirp_length,
+    can_edit_chirps = EXCLUDED.can_edit_chirps,
+    max_media_per_chirp = EXCLUDED.max_media_per_chirp,
+    can_schedule_chirps = EXCLUDED.can_schedule_chirps,
+    rate_limit_tier = EXCLUDED.rate_limit_tier,
+    updated_at = now()
+RETURNING *;
+
+-- name: DeleteEntitlementOverride :exec
+DELETE FROM entitlement_overrides
+WHERE user_id = $1;
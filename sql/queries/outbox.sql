@@ -0,0 +1,31 @@
+-- name: InsertOutboxEvent :one
+INSERT INTO outbox_events (id, created_at, event_type, user_id, payload)
+VALUES (
+           gen_random_uuid (), now(), $1, $2, $3
+       )
+RETURNING *;
+
+-- name: ClaimOutboxEvents :many
+SELECT *
+FROM outbox_events
+WHERE processed_at IS NULL
+ORDER BY created_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxEventProcessed :exec
+UPDATE outbox_events
+SET processed_at = now()
+WHERE id = $1;
+
+-- name: GetOutboxEvent :one
+SELECT *
+FROM outbox_events
+WHERE id = $1;
+
+-- name: ListOutboxEventsSince :many
+SELECT *
+FROM outbox_events
+WHERE created_at >= $1
+ORDER BY created_at
+LIMIT $2;
@@ -0,0 +1,4 @@
+-- name: RecordPolkaEvent :execrows
+INSERT INTO polka_events (id, event, received_at)
+VALUES ($1, $2, now())
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,16 @@
+-- name: AddReaction :execrows
+INSERT INTO reactions (chirp_id, user_id, kind, created_at)
+VALUES ($1, $2, $3, now())
+ON CONFLICT (chirp_id, user_id, kind) DO NOTHING;
+
+-- name: RemoveReaction :execrows
+DELETE FROM reactions
+WHERE chirp_id = $1 AND user_id = $2 AND kind = $3;
+
+-- name: CountReactions :many
+SELECT chirp_id, kind, COUNT(*) AS count,
+       COALESCE(bool_or(user_id = sqlc.narg(viewer_id)::uuid), false)::boolean AS viewer_reacted
+FROM reactions
+WHERE chirp_id = ANY (sqlc.arg(chirp_ids)::uuid[])
+GROUP BY chirp_id, kind
+ORDER BY chirp_id, count DESC, kind;
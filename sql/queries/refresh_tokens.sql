@@ -11,4 +11,20 @@ VALUES ($1, now(), now(), $2, $3, $4);
 UPDATE refresh_tokens
 SET revoked_at = NOW(),
     updated_at = NOW()
-WHERE token = $1 AND revoked_at IS NULL;
\ No newline at end of file
+WHERE token = $1 AND revoked_at IS NULL;
+
+-- name: ListRefreshTokensForUser :many
+SELECT *
+FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: RevokeRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at <= now() OR revoked_at IS NOT NULL;
@@ -0,0 +1,42 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, status, current_period_start, current_period_end)
+VALUES (
+           gen_random_uuid (), now(), now(), $1, $2, $3, $4
+       )
+ON CONFLICT (user_id) DO UPDATE
+SET status = EXCLUDED.status,
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    updated_at = now()
+RETURNING *;
+
+-- name: GetSubscriptionByUserID :one
+SELECT *
+FROM subscriptions
+WHERE user_id = $1;
+
+-- name: SetSubscriptionStatus :one
+UPDATE subscriptions
+SET status = $2, updated_at = now()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: EndSubscription :one
+UPDATE subscriptions
+SET status = $2, current_period_end = LEAST(current_period_end, now()), updated_at = now()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: ExpireSubscriptions :execrows
+UPDATE subscriptions
+SET status = 'expired', updated_at = now()
+WHERE status IN ('active', 'past_due') AND current_period_end <= now();
+
+-- name: IsUserChirpyRed :one
+SELECT EXISTS (
+    SELECT 1
+    FROM subscriptions
+    WHERE user_id = $1
+      AND status IN ('active', 'past_due')
+      AND current_period_end > now()
+);
@@ -0,0 +1,21 @@
+-- name: GrantRole :exec
+INSERT INTO user_roles (user_id, role, created_at)
+VALUES ($1, $2, now())
+ON CONFLICT (user_id, role) DO NOTHING;
+
+-- name: RevokeRole :exec
+DELETE FROM user_roles
+WHERE user_id = $1 AND role = $2;
+
+-- name: UserHasRole :one
+SELECT EXISTS (
+    SELECT 1
+    FROM user_roles
+    WHERE user_id = $1 AND role = $2
+);
+
+-- name: ListRolesForUser :many
+SELECT role
+FROM user_roles
+WHERE user_id = $1
+ORDER BY role;
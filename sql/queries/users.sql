@@ -18,12 +18,29 @@ SELECT *
 FROM users
 WHERE id = $1;
 
--- name: UpdateUser :exec
+-- name: GetUsersByIDs :many
+SELECT *
+FROM users
+WHERE id = ANY (sqlc.arg(ids)::uuid[]);
+
+-- name: UpdateUser :one
 UPDATE users
 SET email = $1, hashed_password = $2, updated_at = now()
-WHERE id = $3;
+WHERE id = $3
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $1, updated_at = now()
+WHERE id = $2
+RETURNING *;
 
--- name: UpgradeUserToChirpRed :exec
+-- name: UpdateUserEmail :one
 UPDATE users
-SET is_chirpy_red = TRUE, updated_at = now()
-WHERE id = $1;
\ No newline at end of file
+SET email = $1, updated_at = now()
+WHERE id = $2
+RETURNING *;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
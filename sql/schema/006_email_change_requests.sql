@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE email_change_requests (
+                                       token_hash TEXT PRIMARY KEY,
+                                       created_at TIMESTAMP NOT NULL,
+                                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                       new_email TEXT NOT NULL,
+                                       expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE email_change_requests;
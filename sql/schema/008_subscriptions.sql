@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+                               id UUID PRIMARY KEY,
+                               created_at TIMESTAMP NOT NULL,
+                               updated_at TIMESTAMP NOT NULL,
+                               user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+                               status TEXT NOT NULL,
+                               current_period_start TIMESTAMP NOT NULL,
+                               current_period_end TIMESTAMP NOT NULL
+);
+
+-- Existing Chirpy Red users had no end date; give them one billing period.
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, status, current_period_start, current_period_end)
+SELECT gen_random_uuid(), now(), now(), id, 'active', now(), now() + INTERVAL '30 days'
+FROM users
+WHERE is_chirpy_red;
+
+ALTER TABLE users DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+    ADD COLUMN is_chirpy_red
+        BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE users
+SET is_chirpy_red = TRUE
+WHERE id IN (
+    SELECT user_id
+    FROM subscriptions
+    WHERE status IN ('active', 'past_due') AND current_period_end > now()
+);
+
+DROP TABLE subscriptions;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE user_roles (
+                            user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                            role TEXT NOT NULL,
+                            created_at TIMESTAMP NOT NULL,
+                            PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE entitlement_overrides (
+                                       user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+                                       created_at TIMESTAMP NOT NULL,
+                                       updated_at TIMESTAMP NOT NULL,
+                                       tier TEXT,
+                                       max_chirp_length INTEGER,
+                                       can_edit_chirps BOOLEAN,
+                                       max_media_per_chirp INTEGER,
+                                       can_schedule_chirps BOOLEAN,
+                                       rate_limit_tier TEXT
+);
+
+-- +goose Down
+DROP TABLE entitlement_overrides;
+DROP TABLE user_roles;
@@ -0,0 +1,42 @@
+-- +goose Up
+CREATE TABLE outbox_events (
+                               id UUID PRIMARY KEY,
+                               created_at TIMESTAMP NOT NULL,
+                               event_type TEXT NOT NULL,
+                               user_id UUID NOT NULL,
+                               payload JSONB NOT NULL,
+                               processed_at TIMESTAMP
+);
+
+CREATE INDEX outbox_events_unprocessed_idx ON outbox_events (created_at) WHERE processed_at IS NULL;
+
+CREATE TABLE webhook_subscriptions (
+                                       id UUID PRIMARY KEY,
+                                       created_at TIMESTAMP NOT NULL,
+                                       updated_at TIMESTAMP NOT NULL,
+                                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                       url TEXT NOT NULL,
+                                       secret TEXT NOT NULL,
+                                       events TEXT[] NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+                                    id UUID PRIMARY KEY,
+                                    created_at TIMESTAMP NOT NULL,
+                                    updated_at TIMESTAMP NOT NULL,
+                                    subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+                                    event_id UUID NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+                                    status TEXT NOT NULL,
+                                    attempts INTEGER NOT NULL,
+                                    next_attempt_at TIMESTAMP NOT NULL,
+                                    last_status_code INTEGER,
+                                    last_error TEXT,
+                                    delivered_at TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
+DROP TABLE outbox_events;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE rate_limit_buckets (
+                                    key TEXT PRIMARY KEY,
+                                    tokens DOUBLE PRECISION NOT NULL,
+                                    updated_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE rate_limit_buckets;
@@ -0,0 +1,52 @@
+-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetChirps :many
+SELECT id, created_at, updated_at, body, user_id
+FROM chirps
+ORDER BY created_at, rowid;
+
+-- name: GetChirpByID :one
+SELECT id, created_at, updated_at, body, user_id
+FROM chirps
+WHERE id = ?;
+
+-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id = ?;
+
+-- name: GetChirpsByAuthorID :many
+SELECT *
+FROM chirps
+WHERE user_id = ?
+ORDER BY created_at, rowid;
+
+-- name: ListChirps :many
+SELECT id, created_at, updated_at, body, user_id
+FROM chirps
+WHERE (sqlc.narg(user_id) IS NULL OR user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(before_created_at) IS NULL
+       OR (created_at, id) < (sqlc.narg(before_created_at), sqlc.narg(before_id)))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListChirpsOldestFirst :many
+SELECT id, created_at, updated_at, body, user_id
+FROM chirps
+WHERE (sqlc.narg(user_id) IS NULL OR user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(after_created_at) IS NULL
+       OR (created_at, id) > (sqlc.narg(after_created_at), sqlc.narg(after_id)))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: ListTimelineChirps :many
+SELECT id, created_at, updated_at, body, user_id
+FROM chirps
+WHERE (user_id = sqlc.arg(user_id)
+       OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg(user_id)))
+  AND (sqlc.narg(before_created_at) IS NULL
+       OR (created_at, id) < (sqlc.narg(before_created_at), sqlc.narg(before_id)))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,13 @@
+-- name: CreateEmailChangeRequest :one
+INSERT INTO email_change_requests (token_hash, created_at, user_id, new_email, expires_at)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetEmailChangeRequest :one
+SELECT *
+FROM email_change_requests
+WHERE token_hash = ?;
+
+-- name: DeleteEmailChangeRequestsForUser :exec
+DELETE FROM email_change_requests
+WHERE user_id = ?;
@@ -0,0 +1,30 @@
+-- name: IsUserChirpyRed :one
+SELECT CAST(EXISTS (
+    SELECT 1
+    FROM subscriptions
+    WHERE user_id = sqlc.arg(user_id)
+      AND status IN ('active', 'past_due')
+      AND current_period_end > sqlc.arg(now)
+) AS BOOLEAN) AS is_chirpy_red;
+
+-- name: GetEntitlementOverride :one
+SELECT *
+FROM entitlement_overrides
+WHERE user_id = ?;
+
+-- name: UpsertEntitlementOverride :one
+INSERT INTO entitlement_overrides (user_id, created_at, updated_at, tier, max_chirp_length, can_edit_chirps, max_media_per_chirp, can_schedule_chirps, rate_limit_tier)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (user_id) DO UPDATE
+SET tier = excluded.tier,
+    max_chirp_length = excluded.max_chirp_length,
+    can_edit_chirps = excluded.can_edit_chirps,
+    max_media_per_chirp = excluded.max_media_per_chirp,
+    can_schedule_chirps = excluded.can_schedule_chirps,
+    rate_limit_tier = excluded.rate_limit_tier,
+    updated_at = excluded.updated_at
+RETURNING *;
+
+-- name: DeleteEntitlementOverride :exec
+DELETE FROM entitlement_overrides
+WHERE user_id = ?;
@@ -0,0 +1,32 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = ? AND followee_id = ?;
+
+-- name: ListFolloweeIDs :many
+SELECT followee_id
+FROM follows
+WHERE follower_id = ?
+ORDER BY created_at;
+
+-- name: ListFollowees :many
+SELECT followee_id, created_at
+FROM follows
+WHERE follower_id = sqlc.arg(user_id)
+  AND (sqlc.narg(before_created_at) IS NULL
+       OR (created_at, followee_id) < (sqlc.narg(before_created_at), sqlc.narg(before_id)))
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListFollowers :many
+SELECT follower_id, created_at
+FROM follows
+WHERE followee_id = sqlc.arg(user_id)
+  AND (sqlc.narg(before_created_at) IS NULL
+       OR (created_at, follower_id) < (sqlc.narg(before_created_at), sqlc.narg(before_id)))
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg('limit');
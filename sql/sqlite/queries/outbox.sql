@@ -0,0 +1,21 @@
+-- name: InsertOutboxEvent :one
+INSERT INTO outbox_events (id, created_at, event_type, user_id, payload)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ClaimOutboxEvents :many
+SELECT *
+FROM outbox_events
+WHERE processed_at IS NULL
+ORDER BY created_at
+LIMIT ?;
+
+-- name: MarkOutboxEventProcessed :exec
+UPDATE outbox_events
+SET processed_at = ?
+WHERE id = ?;
+
+-- name: GetOutboxEvent :one
+SELECT *
+FROM outbox_events
+WHERE id = ?;
@@ -0,0 +1,16 @@
+-- name: AddReaction :execrows
+INSERT INTO reactions (chirp_id, user_id, kind, created_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (chirp_id, user_id, kind) DO NOTHING;
+
+-- name: RemoveReaction :execrows
+DELETE FROM reactions
+WHERE chirp_id = ? AND user_id = ? AND kind = ?;
+
+-- name: CountReactions :many
+SELECT chirp_id, kind, COUNT(*) AS count,
+       CAST(COALESCE(MAX(user_id = sqlc.narg(viewer_id)), 0) AS BOOLEAN) AS viewer_reacted
+FROM reactions
+WHERE chirp_id IN (sqlc.slice(chirp_ids))
+GROUP BY chirp_id, kind
+ORDER BY chirp_id, count DESC, kind;
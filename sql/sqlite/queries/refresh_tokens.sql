@@ -0,0 +1,30 @@
+-- name: GetRefreshToken :one
+SELECT *
+FROM refresh_tokens
+WHERE token = ?;
+
+-- name: CreateRefreshToken :exec
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: RevokeRefreshToken :exec
+UPDATE refresh_tokens
+SET revoked_at = sqlc.arg(now),
+    updated_at = sqlc.arg(now)
+WHERE token = sqlc.arg(token) AND revoked_at IS NULL;
+
+-- name: ListRefreshTokensForUser :many
+SELECT *
+FROM refresh_tokens
+WHERE user_id = ?
+ORDER BY created_at DESC;
+
+-- name: RevokeRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = sqlc.arg(now),
+    updated_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id) AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at <= sqlc.arg(now) OR revoked_at IS NOT NULL;
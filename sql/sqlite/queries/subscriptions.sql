@@ -0,0 +1,31 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, status, current_period_start, current_period_end)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (user_id) DO UPDATE
+SET status = excluded.status,
+    current_period_start = excluded.current_period_start,
+    current_period_end = excluded.current_period_end,
+    updated_at = excluded.updated_at
+RETURNING *;
+
+-- name: GetSubscriptionByUserID :one
+SELECT *
+FROM subscriptions
+WHERE user_id = ?;
+
+-- name: SetSubscriptionStatus :one
+UPDATE subscriptions
+SET status = ?, updated_at = ?
+WHERE user_id = ?
+RETURNING *;
+
+-- name: EndSubscription :one
+UPDATE subscriptions
+SET status = sqlc.arg(status), current_period_end = MIN(current_period_end, sqlc.arg(now)), updated_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: ExpireSubscriptions :execrows
+UPDATE subscriptions
+SET status = 'expired', updated_at = sqlc.arg(now)
+WHERE status IN ('active', 'past_due') AND current_period_end <= sqlc.arg(now);
@@ -0,0 +1,15 @@
+-- name: GrantRole :exec
+INSERT INTO user_roles (user_id, role, created_at)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, role) DO NOTHING;
+
+-- name: RevokeRole :exec
+DELETE FROM user_roles
+WHERE user_id = ? AND role = ?;
+
+-- name: UserHasRole :one
+SELECT CAST(EXISTS (
+    SELECT 1
+    FROM user_roles
+    WHERE user_id = ? AND role = ?
+) AS BOOLEAN) AS has_role;
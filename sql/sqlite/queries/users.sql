@@ -0,0 +1,44 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, hashed_password, email)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: DeleteAllUsers :exec
+DELETE FROM users;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = ?;
+
+-- name: GetUserByEmail :one
+SELECT *
+FROM users
+WHERE email = ?;
+
+-- name: GetUserByID :one
+SELECT *
+FROM users
+WHERE id = ?;
+
+-- name: GetUsersByIDs :many
+SELECT *
+FROM users
+WHERE id IN (sqlc.slice(ids));
+
+-- name: UpdateUser :one
+UPDATE users
+SET email = ?, hashed_password = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: UpdateUserEmail :one
+UPDATE users
+SET email = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
@@ -0,0 +1,66 @@
+-- +goose Up
+-- SQLite has no gen_random_uuid() or now(), so IDs and timestamps are
+-- always supplied by the application.
+CREATE TABLE users (
+                       id UUID PRIMARY KEY,
+                       created_at TIMESTAMP NOT NULL,
+                       updated_at TIMESTAMP NOT NULL,
+                       email TEXT NOT NULL UNIQUE,
+                       hashed_password TEXT NOT NULL DEFAULT 'unset'
+);
+
+CREATE TABLE chirps (
+                        id UUID PRIMARY KEY,
+                        created_at TIMESTAMP NOT NULL,
+                        updated_at TIMESTAMP NOT NULL,
+                        body TEXT UNIQUE NOT NULL,
+                        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE refresh_tokens (
+                                token TEXT PRIMARY KEY,
+                                created_at TIMESTAMP NOT NULL,
+                                updated_at TIMESTAMP NOT NULL,
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                expires_at TIMESTAMP NOT NULL,
+                                revoked_at TIMESTAMP
+);
+
+CREATE TABLE subscriptions (
+                               id UUID PRIMARY KEY,
+                               created_at TIMESTAMP NOT NULL,
+                               updated_at TIMESTAMP NOT NULL,
+                               user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+                               status TEXT NOT NULL,
+                               current_period_start TIMESTAMP NOT NULL,
+                               current_period_end TIMESTAMP NOT NULL
+);
+
+CREATE TABLE entitlement_overrides (
+                                       user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+                                       created_at TIMESTAMP NOT NULL,
+                                       updated_at TIMESTAMP NOT NULL,
+                                       tier TEXT,
+                                       max_chirp_length INTEGER,
+                                       can_edit_chirps BOOLEAN,
+                                       max_media_per_chirp INTEGER,
+                                       can_schedule_chirps BOOLEAN,
+                                       rate_limit_tier TEXT
+);
+
+CREATE TABLE outbox_events (
+                               id UUID PRIMARY KEY,
+                               created_at TIMESTAMP NOT NULL,
+                               event_type TEXT NOT NULL,
+                               user_id UUID NOT NULL,
+                               payload TEXT NOT NULL,
+                               processed_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE outbox_events;
+DROP TABLE entitlement_overrides;
+DROP TABLE subscriptions;
+DROP TABLE refresh_tokens;
+DROP TABLE chirps;
+DROP TABLE users;
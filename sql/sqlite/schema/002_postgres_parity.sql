@@ -0,0 +1,65 @@
+-- +goose Up
+-- The tables the Postgres schema gained in 006-011. Arrays are stored as
+-- JSON text.
+CREATE TABLE email_change_requests (
+                                       token_hash TEXT PRIMARY KEY,
+                                       created_at TIMESTAMP NOT NULL,
+                                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                       new_email TEXT NOT NULL,
+                                       expires_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE polka_events (
+                              id TEXT PRIMARY KEY,
+                              event TEXT NOT NULL,
+                              received_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE user_roles (
+                            user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                            role TEXT NOT NULL,
+                            created_at TIMESTAMP NOT NULL,
+                            PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE follows (
+                         follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                         followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                         created_at TIMESTAMP NOT NULL,
+                         PRIMARY KEY (follower_id, followee_id),
+                         CHECK (follower_id <> followee_id)
+);
+
+CREATE TABLE webhook_subscriptions (
+                                       id UUID PRIMARY KEY,
+                                       created_at TIMESTAMP NOT NULL,
+                                       updated_at TIMESTAMP NOT NULL,
+                                       user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                       url TEXT NOT NULL,
+                                       secret TEXT NOT NULL,
+                                       events TEXT NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+                                    id UUID PRIMARY KEY,
+                                    created_at TIMESTAMP NOT NULL,
+                                    updated_at TIMESTAMP NOT NULL,
+                                    subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+                                    event_id UUID NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+                                    status TEXT NOT NULL,
+                                    attempts INTEGER NOT NULL,
+                                    next_attempt_at TIMESTAMP NOT NULL,
+                                    last_status_code INTEGER,
+                                    last_error TEXT,
+                                    delivered_at TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
+DROP TABLE follows;
+DROP TABLE user_roles;
+DROP TABLE polka_events;
+DROP TABLE email_change_requests;
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const subscriptionPeriod = 30 * 24 * time.Hour
+
+// runSubscriptionExpiry marks lapsed subscriptions as expired every interval
+// until ctx is cancelled, calling beat after each pass.
+func (cfg *apiConfig) runSubscriptionExpiry(ctx context.Context, interval time.Duration, beat func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := cfg.store.Subscriptions().ExpireSubscriptions(ctx)
+			beat(err)
+			if err != nil {
+				slog.ErrorContext(ctx, "expiring subscriptions", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "expired subscriptions", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"example.com/chirpy/internal/tracing"
+	"net/http"
+)
+
+// middlewareTracing starts a server span for each request, continuing the
+// caller's trace if it sent traceparent and tracestate headers. Database
+// queries made while handling the request become child spans.
+func (cfg *apiConfig) middlewareTracing(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if remote, ok := tracing.Extract(r.Header); ok {
+			ctx = tracing.ContextWithRemote(ctx, remote)
+		}
+
+		_, route := mux.Handler(r)
+		name := route
+		if name == "" {
+			name = r.Method + " unmatched"
+		}
+		ctx, span := tracing.Start(ctx, name, tracing.KindServer,
+			tracing.Attr("http.request.method", r.Method),
+			tracing.Attr("http.route", route),
+			tracing.Attr("url.path", r.URL.Path),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(tracing.Attr("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(tracing.StatusError, http.StatusText(rec.status))
+		}
+	})
+}